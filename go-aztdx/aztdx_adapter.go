@@ -34,12 +34,38 @@ func NewAzureTdxAdapter(tpmFactory tpm.TpmFactory, userData []byte) (connector.E
 	}, nil
 }
 
+// AzureTdxAdapterOptions for creating an Azure TDX evidence adapter (see
+// NewCompositeEvidenceAdapter).
+type AzureTdxAdapterOptions func(*azureTdxAdapter) error
+
 // NewCompositeEvidenceAdapter returns an evidence adapter that uses Azure's
 // vTPM/paravisor implementation to collect TDX evidence.
-func NewCompositeEvidenceAdapter(tpmFactory tpm.TpmFactory) (connector.CompositeEvidenceAdapter, error) {
-	return &azureTdxAdapter{
+func NewCompositeEvidenceAdapter(tpmFactory tpm.TpmFactory, opts ...AzureTdxAdapterOptions) (connector.CompositeEvidenceAdapter, error) {
+	adapter := &azureTdxAdapter{
 		tpmFactory: tpmFactory,
-	}, nil
+	}
+
+	for _, option := range opts {
+		if err := option(adapter); err != nil {
+			return nil, err
+		}
+	}
+
+	return adapter, nil
+}
+
+// WithReportData specifies the exact 64 bytes of report data that are written to the
+// vTPM (and included in the TD report).  By default, the report data is the SHA-512 hash
+// of the verifier-nonce and user-data.  This option is intended for flows that compute
+// the report data externally (ex. binding the quote to a TLS key).
+func WithReportData(reportData []byte) AzureTdxAdapterOptions {
+	return func(a *azureTdxAdapter) error {
+		if len(reportData) != sha512.Size {
+			return errors.Errorf("The report data must be %d bytes, got %d", sha512.Size, len(reportData))
+		}
+		a.reportData = reportData
+		return nil
+	}
 }
 
 // tdxEvidence contains evidence returned by the Azure TDX adapter.
@@ -54,6 +80,7 @@ type tdxEvidence struct {
 // CollectEvidence and GetEvidence boil down to getAzureTdxEvidence.
 type azureTdxAdapter struct {
 	userData   []byte
+	reportData []byte
 	tpmFactory tpm.TpmFactory
 }
 
@@ -64,7 +91,7 @@ func (a *azureTdxAdapter) CollectEvidence(nonce []byte) (*connector.Evidence, er
 		nonce = []byte{}
 	}
 
-	tdxEvidence, err := getAzureTdxEvidence(a.tpmFactory, nonce, a.userData)
+	tdxEvidence, err := getAzureTdxEvidence(a.tpmFactory, nonce, a.userData, a.reportData)
 	if err != nil {
 		return nil, err
 	}
@@ -90,7 +117,7 @@ func (a *azureTdxAdapter) GetEvidence(verifierNonce *connector.VerifierNonce, us
 		nonce = append(nonce, verifierNonce.Iat...)
 	}
 
-	tdxEvidence, err := getAzureTdxEvidence(a.tpmFactory, nonce, userData)
+	tdxEvidence, err := getAzureTdxEvidence(a.tpmFactory, nonce, userData, a.reportData)
 	if err != nil {
 		return nil, err
 	}
@@ -99,19 +126,25 @@ func (a *azureTdxAdapter) GetEvidence(verifierNonce *connector.VerifierNonce, us
 	return tdxEvidence, nil
 }
 
-func getAzureTdxEvidence(tpmFactory tpm.TpmFactory, nonce []byte, userData []byte) (*tdxEvidence, error) {
-	reportData := [][]byte{}
-	if nonce != nil {
-		reportData = append(reportData, nonce)
-	}
+// getAzureTdxEvidence collects TDX evidence where the report data is 'reportDataOverride'
+// (see WithReportData) or, when nil, the hash of 'nonce' and 'userData'.
+func getAzureTdxEvidence(tpmFactory tpm.TpmFactory, nonce []byte, userData []byte, reportDataOverride []byte) (*tdxEvidence, error) {
+	reportDataHash := reportDataOverride
+	if reportDataHash == nil {
+		reportData := [][]byte{}
+		if nonce != nil {
+			reportData = append(reportData, nonce)
+		}
 
-	if len(userData) != 0 {
-		reportData = append(reportData, userData)
-	}
+		if len(userData) != 0 {
+			reportData = append(reportData, userData)
+		}
 
-	reportDataHash, err := getReportDataHash(reportData)
-	if err != nil {
-		return nil, err
+		var err error
+		reportDataHash, err = getReportDataHash(reportData)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	azRuntimeData, err := getAzRuntimeData(tpmFactory, reportDataHash, azRuntimeReadIdx, azRuntimeWriteIdx)
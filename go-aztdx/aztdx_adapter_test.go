@@ -7,12 +7,14 @@
 package aztdx
 
 import (
+	"crypto/sha512"
 	"encoding/base64"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/intel/trustauthority-client/go-connector"
 	"github.com/intel/trustauthority-client/go-tpm"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/mock"
@@ -219,6 +221,45 @@ func TestCompositeAdapterQuoteBadBase64(t *testing.T) {
 	}
 }
 
+func TestCompositeAdapterWithReportData(t *testing.T) {
+	// the mock runtime data's user-data is the sha512 hash of empty data
+	reportData := sha512.Sum512([]byte{})
+
+	azureRuntimeData, _ := base64.StdEncoding.DecodeString(azureRuntimeDataB64)
+	mockTpm := MockTpm{}
+	mockTpm.On("NVExists", mock.Anything).Return(true)
+	mockTpm.On("NVWrite", mock.Anything, mock.Anything).Return(nil)
+	mockTpm.On("NVRead", mock.Anything).Return(azureRuntimeData, nil)
+	mockTpm.On("Close", mock.Anything).Return()
+	tpmFactory := createHappyTpmFactory(&mockTpm)
+
+	// create a test server that returns a TDX quote
+	defer createTestQuoteServer(nil).Close()
+
+	adapter, err := NewCompositeEvidenceAdapter(tpmFactory, WithReportData(reportData[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = adapter.GetEvidence(&connector.VerifierNonce{
+		Val: []byte("val"),
+		Iat: []byte("iat"),
+	}, []byte("user data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the supplied report data (not the nonce/user-data hash) is written to the vTPM
+	mockTpm.AssertCalled(t, "NVWrite", azRuntimeWriteIdx, reportData[:])
+
+	for _, length := range []int{0, 32, sha512.Size + 1} {
+		_, err = NewCompositeEvidenceAdapter(tpmFactory, WithReportData(make([]byte, length)))
+		if err == nil {
+			t.Errorf("Expected an error for report data of length %d", length)
+		}
+	}
+}
+
 func createTestQuoteServer(f http.HandlerFunc) *httptest.Server {
 	// default succesful response
 	if f == nil {
@@ -43,6 +43,11 @@ func (m *MockTpm) CreateAK(akHandle int, ekHandle int) error {
 	return args.Error(0)
 }
 
+func (m *MockTpm) EvictHandle(handle int) error {
+	args := m.Called(handle)
+	return args.Error(0)
+}
+
 func (m *MockTpm) ActivateCredential(ekHandle int, akHandle int, credentialBlob []byte, secret []byte) ([]byte, error) {
 	args := m.Called(ekHandle, akHandle, credentialBlob, secret)
 	return args.Get(0).([]byte), args.Error(1)
@@ -93,11 +98,21 @@ func (m *MockTpm) GetPcrs(selection ...tpm.PcrSelection) ([]byte, error) {
 	return args.Get(0).([]byte), args.Error(1)
 }
 
+func (m *MockTpm) ReadPcrValues(selection ...tpm.PcrSelection) ([]tpm.PcrValue, error) {
+	args := m.Called(selection)
+	return args.Get(0).([]tpm.PcrValue), args.Error(1)
+}
+
 func (m *MockTpm) HandleExists(handle int) bool {
 	args := m.Called(handle)
 	return args.Get(0).(bool)
 }
 
+func (m *MockTpm) StartEncryptedSession(ekHandle int, ekPublic crypto.PublicKey) error {
+	args := m.Called(ekHandle, ekPublic)
+	return args.Error(0)
+}
+
 func (m *MockTpm) Close() {
 	m.Called()
 }
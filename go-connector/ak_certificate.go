@@ -81,7 +81,7 @@ func (connector *trustAuthorityConnector) GetAKCertificate(ekCert *x509.Certific
 		return nil
 	}
 
-	if err := doRequest(*connector.rclient, connector.cfg.TlsCfg, newRequest, nil, headers, processResponse); err != nil {
+	if err := doRequest(*connector.rclient, nil, newRequest, nil, headers, processResponse); err != nil {
 		return nil, nil, nil, err
 	}
 
@@ -0,0 +1,75 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxApiKeyFileSize limits how much is read from an API key file (API keys are
+// well under 1KB).
+const maxApiKeyFileSize = 8 * 1024
+
+// readApiKeyFile reads and validates the API key in 'path'.  The path must refer to a
+// regular file (path traversal is rejected and symlinks are resolved) and the buffer holding the
+// file's contents is zeroed before returning.
+func readApiKeyFile(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("%w: the file path was not provided", ErrInvalidApiKeyFile)
+	}
+
+	// reject ".." elements that remain after cleaning (ex. "../apikey") rather than
+	// file names that contain dots (ex. "key..txt")
+	for _, element := range strings.Split(filepath.ToSlash(filepath.Clean(path)), "/") {
+		if element == ".." {
+			return "", fmt.Errorf("%w: %q contains path traversal", ErrInvalidApiKeyFile, path)
+		}
+	}
+
+	// secret mounts (ex. Kubernetes) expose keys as symlinks, so the path is resolved
+	// and the target must be a regular file
+	resolvedPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidApiKeyFile, err)
+	}
+
+	info, err := os.Stat(resolvedPath)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidApiKeyFile, err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%w: %q is not a regular file", ErrInvalidApiKeyFile, path)
+	}
+
+	f, err := os.Open(resolvedPath)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidApiKeyFile, err)
+	}
+	defer f.Close()
+
+	buf, err := io.ReadAll(io.LimitReader(f, maxApiKeyFileSize+1))
+	defer clear(buf)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidApiKeyFile, err)
+	}
+
+	if len(buf) > maxApiKeyFileSize {
+		return "", fmt.Errorf("%w: %q exceeds the maximum size of %d bytes", ErrInvalidApiKeyFile, path, maxApiKeyFileSize)
+	}
+
+	apiKey := string(bytes.TrimSpace(buf))
+	if err = ValidateApiKey(apiKey); err != nil {
+		return "", err
+	}
+
+	return apiKey, nil
+}
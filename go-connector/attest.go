@@ -16,7 +16,7 @@ func (connector *trustAuthorityConnector) Attest(args AttestArgs) (AttestRespons
 	nonceResponse, err := connector.GetNonce(GetNonceArgs{args.RequestId})
 	response.Headers = nonceResponse.Headers
 	if err != nil {
-		return response, errors.Errorf("Failed to collect nonce from Trust Authority: %s", err)
+		return response, errors.Wrap(err, "Failed to collect nonce from Trust Authority")
 	}
 
 	evidence, err := args.Adapter.CollectEvidence(append(nonceResponse.Nonce.Val, nonceResponse.Nonce.Iat[:]...))
@@ -32,7 +32,7 @@ func (connector *trustAuthorityConnector) Attest(args AttestArgs) (AttestRespons
 	tokenResponse, err := connector.GetToken(GetTokenArgs{nonceResponse.Nonce, evidence, args.PolicyIds, args.RequestId, apiEndpoint, args.TokenSigningAlg, args.PolicyMustMatch})
 	response.Token, response.Headers = tokenResponse.Token, tokenResponse.Headers
 	if err != nil {
-		return response, errors.Errorf("Failed to collect token from Trust Authority: %s", err)
+		return response, errors.Wrap(err, "Failed to collect token from Trust Authority")
 	}
 
 	return response, nil
@@ -12,20 +12,43 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
 func (ctr *trustAuthorityConnector) AttestEvidence(evidence interface{}, cloudProvider string, requestId string) (AttestResponse, error) {
+	return ctr.attestEvidence(evidence, cloudProvider, requestId, mimeApplicationJson, nil)
+}
+
+func (ctr *trustAuthorityConnector) AttestEvidenceStream(evidence interface{}, cloudProvider string, requestId string, onStatus func(AttestStatus)) (AttestResponse, error) {
+	// prefer a streamed (newline delimited json) response but accept a single json response
+	return ctr.attestEvidence(evidence, cloudProvider, requestId, mimeApplicationNdjson+", "+mimeApplicationJson, onStatus)
+}
+
+// attestEvidence sends 'evidence' to the Trust Authority with the 'accept' header.  When
+// the response is streamed (see AttestEvidenceStream), 'onStatus' is called for each
+// intermediate status.
+func (ctr *trustAuthorityConnector) attestEvidence(evidence interface{}, cloudProvider string, requestId string, accept string, onStatus func(AttestStatus)) (AttestResponse, error) {
 	var response AttestResponse
 
-	requestBody, err := json.Marshal(evidence)
+	requestBody, contentType, err := marshalRequest(ctr.cfg.SerializationFormat, evidence, MarshalEvidence)
 	if err != nil {
 		return response, err
 	}
 
-	logrus.Debugf("REQUEST BODY: %s", string(requestBody))
+	if err = ctr.checkEvidenceSize(requestBody, evidence); err != nil {
+		return response, err
+	}
+
+	requestId = ctr.requestIdFor(requestId, requestBody)
+
+	if contentType == mimeApplicationJson {
+		logrus.Debugf("REQUEST BODY: %s", string(requestBody))
+	} else {
+		logrus.Debugf("REQUEST BODY: %x", requestBody)
+	}
 
 	url, err := url.Parse(ctr.cfg.ApiUrl)
 	if err != nil {
@@ -34,27 +57,63 @@ func (ctr *trustAuthorityConnector) AttestEvidence(evidence interface{}, cloudPr
 	url.Path = path.Join(url.Path, attestEndpoint)
 	url.Path = path.Join(url.Path, cloudProvider)
 
+	if ctr.cfg.RequestAuditSink != nil {
+		ctr.cfg.RequestAuditSink(url.String(), requestBody)
+	}
+
+	// the audit sink receives the uncompressed body
+	requestBody, contentEncoding, err := compressRequest(ctr.cfg.RequestCompression, requestBody)
+	if err != nil {
+		return response, err
+	}
+
 	newRequest := func() (*http.Request, error) {
 		return http.NewRequest(http.MethodPost, url.String(), bytes.NewReader(requestBody))
 	}
 
 	var headers = map[string]string{
 		headerXApiKey:     ctr.cfg.ApiKey,
-		headerAccept:      mimeApplicationJson,
-		headerContentType: mimeApplicationJson,
+		headerAccept:      accept,
+		headerContentType: contentType,
 		HeaderRequestId:   requestId,
 	}
+	if contentEncoding != "" {
+		headers[headerContentEncoding] = contentEncoding
+	}
 
 	processResponse := func(resp *http.Response) error {
 		response.Headers = resp.Header
 
+		if strings.HasPrefix(resp.Header.Get(headerContentType), mimeApplicationNdjson) {
+			var reader io.Reader = resp.Body
+			var streamed bytes.Buffer
+			if ctr.cfg.ResponseAuditSink != nil {
+				// the stream is provided to the sink once it has been read
+				reader = io.TeeReader(resp.Body, &streamed)
+			}
+
+			token, err := readAttestStream(reader, onStatus)
+			if ctr.cfg.ResponseAuditSink != nil {
+				ctr.cfg.ResponseAuditSink(url.String(), streamed.Bytes(), resp.Header)
+			}
+			if err != nil {
+				return errors.Wrapf(err, "Failed to read streamed response from %s", url)
+			}
+			response.Token = token
+			return nil
+		}
+
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return errors.Errorf("Failed to read body from %s: %s", url, err)
 		}
 
+		if ctr.cfg.ResponseAuditSink != nil {
+			ctr.cfg.ResponseAuditSink(url.String(), body, resp.Header)
+		}
+
 		if resp.StatusCode != http.StatusOK {
-			return errors.Errorf("Request returned %d: %q", resp.StatusCode, string(body))
+			return newHttpStatusError(resp.StatusCode, errors.Errorf("Request returned %d: %q", resp.StatusCode, string(body)))
 		}
 
 		dec := json.NewDecoder(bytes.NewReader(body))
@@ -66,9 +125,43 @@ func (ctr *trustAuthorityConnector) AttestEvidence(evidence interface{}, cloudPr
 		return nil
 	}
 
-	if err := doRequest(*ctr.rclient, ctr.cfg.TlsCfg, newRequest, nil, headers, processResponse); err != nil {
+	if err := doRequest(*ctr.rclient, nil, newRequest, nil, headers, processResponse); err != nil {
 		return response, err
 	}
 
 	return response, nil
 }
+
+// readAttestStream reads newline delimited json events from 'reader' until the final
+// token is received.  Each event is either a status (passed to 'onStatus'), an error
+// or the token, for example...
+//
+//	{"status":{"evidence_type":"tdx","status":"verified"}}
+//	{"status":{"evidence_type":"tpm","status":"verified"}}
+//	{"token":"eyJhbGciOiJQUzM4NCIsInR5cCI6IkpXVCJ9..."}
+func readAttestStream(reader io.Reader, onStatus func(AttestStatus)) (string, error) {
+	dec := json.NewDecoder(reader)
+	for {
+		var event struct {
+			Status *AttestStatus `json:"status"`
+			Error  string        `json:"error"`
+			Token  string        `json:"token"`
+		}
+
+		err := dec.Decode(&event)
+		if err == io.EOF {
+			return "", errors.New("The stream ended without a token")
+		} else if err != nil {
+			return "", err
+		}
+
+		switch {
+		case event.Error != "":
+			return "", errors.Errorf("Attestation failed: %s", event.Error)
+		case event.Token != "":
+			return event.Token, nil
+		case event.Status != nil && onStatus != nil:
+			onStatus(*event.Status)
+		}
+	}
+}
@@ -6,7 +6,11 @@
 package connector
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -27,3 +31,290 @@ func TestAttestEvidence(t *testing.T) {
 
 	t.Logf("Response: %v", response)
 }
+
+func TestAttestEvidence_statusErrors(t *testing.T) {
+	tests := []struct {
+		statusCode  int
+		expectedErr error
+	}{
+		{statusCode: http.StatusUnauthorized, expectedErr: ErrUnauthorized},
+		{statusCode: http.StatusForbidden, expectedErr: ErrForbidden},
+		{statusCode: http.StatusBadRequest, expectedErr: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.statusCode), func(t *testing.T) {
+			connector, mux, _, teardown := setup()
+			defer teardown()
+
+			mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(`{"error":"unit test"}`))
+			})
+
+			_, err := connector.AttestEvidence(&struct{}{}, "", "")
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			} else if tt.expectedErr == nil && (errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrForbidden)) {
+				t.Fatalf("expected an untyped error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAttestEvidence_responseAuditSink(t *testing.T) {
+
+	ctr, mux, serverUrl, teardown := setup()
+	defer teardown()
+
+	traceId := "test-trace-id"
+	responseBody := `{"token":"` + token + `"}`
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderTraceId, traceId)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(responseBody))
+	})
+
+	var auditEndpoint string
+	var auditBody []byte
+	var auditHeaders http.Header
+	ctr.(*trustAuthorityConnector).cfg.ResponseAuditSink = func(endpoint string, body []byte, headers http.Header) {
+		auditEndpoint = endpoint
+		auditBody = body
+		auditHeaders = headers
+	}
+
+	response, err := ctr.AttestEvidence(&struct{}{}, "", "")
+	if err != nil {
+		t.Fatalf("AttestEvidence returned unexpected error: %v", err)
+	}
+
+	if response.Token != token {
+		t.Errorf("Expected the token to be processed, got %q", response.Token)
+	}
+
+	if auditEndpoint != serverUrl+attestEndpoint {
+		t.Errorf("Expected audit endpoint %q, got %q", serverUrl+attestEndpoint, auditEndpoint)
+	}
+
+	if string(auditBody) != responseBody {
+		t.Errorf("Expected audit body %q, got %q", responseBody, string(auditBody))
+	}
+
+	if auditHeaders.Get(HeaderTraceId) != traceId {
+		t.Errorf("Expected trace id %q in the audit headers, got %q", traceId, auditHeaders.Get(HeaderTraceId))
+	}
+}
+
+func TestAttestEvidence_tokenAudience(t *testing.T) {
+
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	audience := "https://relying-party.example.com"
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+
+		if body["token_audience"] != audience {
+			t.Errorf("Expected token_audience %q in request body, got %v", audience, body["token_audience"])
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"token":"` + token + `"}`))
+	})
+
+	builder, err := NewEvidenceBuilder(WithEvidenceAdapter(&testCompositeEvidenceAdapter{}), WithTokenAudience(audience))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidence, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = connector.AttestEvidence(evidence, "", "")
+	if err != nil {
+		t.Errorf("AttestEvidence returned unexpected error: %v", err)
+	}
+}
+
+func TestAttestEvidence_evidenceContext(t *testing.T) {
+
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	evidenceContext := "namespace/workload"
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+
+		if body["context"] != evidenceContext {
+			t.Errorf("Expected context %q in request body, got %v", evidenceContext, body["context"])
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"token":"` + token + `"}`))
+	})
+
+	builder, err := NewEvidenceBuilder(WithEvidenceAdapter(&testCompositeEvidenceAdapter{}), WithEvidenceContext(evidenceContext))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidence, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = connector.AttestEvidence(evidence, "", "")
+	if err != nil {
+		t.Errorf("AttestEvidence returned unexpected error: %v", err)
+	}
+}
+
+func TestAttestEvidenceStream(t *testing.T) {
+
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	events := []string{
+		`{"status":{"evidence_type":"tdx","status":"verified"}}`,
+		`{"status":{"evidence_type":"tpm","status":"verified","message":"pcrs matched"}}`,
+		`{"token":"` + token + `"}`,
+	}
+
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get(headerAccept), mimeApplicationNdjson) {
+			t.Errorf("Expected %q in the accept header, got %q", mimeApplicationNdjson, r.Header.Get(headerAccept))
+		}
+
+		w.Header().Set(headerContentType, mimeApplicationNdjson)
+		w.WriteHeader(http.StatusOK)
+		for _, event := range events {
+			w.Write([]byte(event + "\n"))
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	var statuses []AttestStatus
+	response, err := connector.AttestEvidenceStream(&struct{}{}, "", "", func(status AttestStatus) {
+		statuses = append(statuses, status)
+	})
+	if err != nil {
+		t.Fatalf("AttestEvidenceStream returned unexpected error: %v", err)
+	}
+
+	expected := []AttestStatus{
+		{EvidenceType: "tdx", Status: "verified"},
+		{EvidenceType: "tpm", Status: "verified", Message: "pcrs matched"},
+	}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("Expected statuses %v, got %v", expected, statuses)
+	}
+
+	if response.Token != token {
+		t.Errorf("Expected the streamed token, got %q", response.Token)
+	}
+}
+
+func TestAttestEvidenceStream_responseAuditSink(t *testing.T) {
+
+	ctr, mux, _, teardown := setup()
+	defer teardown()
+
+	stream := `{"status":{"evidence_type":"tdx","status":"verified"}}` + "\n" + `{"token":"` + token + `"}` + "\n"
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerContentType, mimeApplicationNdjson)
+		w.Header().Set(HeaderTraceId, "test-trace-id")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(stream))
+	})
+
+	var auditBody []byte
+	var auditHeaders http.Header
+	ctr.(*trustAuthorityConnector).cfg.ResponseAuditSink = func(endpoint string, body []byte, headers http.Header) {
+		auditBody = body
+		auditHeaders = headers
+	}
+
+	var statuses []AttestStatus
+	response, err := ctr.AttestEvidenceStream(&struct{}{}, "", "", func(status AttestStatus) {
+		statuses = append(statuses, status)
+	})
+	if err != nil {
+		t.Fatalf("AttestEvidenceStream returned unexpected error: %v", err)
+	}
+
+	if response.Token != token || len(statuses) != 1 {
+		t.Errorf("Expected the stream to be processed, got token %q and statuses %v", response.Token, statuses)
+	}
+
+	if !strings.HasPrefix(stream, string(auditBody)) || !strings.Contains(string(auditBody), token) {
+		t.Errorf("Expected the streamed events in the audit body, got %q", string(auditBody))
+	}
+
+	if auditHeaders.Get(HeaderTraceId) != "test-trace-id" {
+		t.Errorf("Expected the trace id in the audit headers, got %q", auditHeaders.Get(HeaderTraceId))
+	}
+}
+
+func TestAttestEvidenceStream_singleResponse(t *testing.T) {
+
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"token":"` + token + `"}`))
+	})
+
+	response, err := connector.AttestEvidenceStream(&struct{}{}, "", "", func(status AttestStatus) {
+		t.Errorf("Did not expect a status, got %v", status)
+	})
+	if err != nil {
+		t.Fatalf("AttestEvidenceStream returned unexpected error: %v", err)
+	}
+
+	if response.Token != token {
+		t.Errorf("Expected the token, got %q", response.Token)
+	}
+}
+
+func TestAttestEvidenceStream_errors(t *testing.T) {
+	testData := []struct {
+		name   string
+		events string
+	}{
+		{"Stream error", `{"status":{"evidence_type":"tdx","status":"failed"}}` + "\n" + `{"error":"tdx quote verification failed"}` + "\n"},
+		{"Stream without token", `{"status":{"evidence_type":"tdx","status":"verified"}}` + "\n"},
+		{"Invalid stream", `{"status":`},
+	}
+
+	for _, td := range testData {
+		t.Run(td.name, func(t *testing.T) {
+			connector, mux, _, teardown := setup()
+			defer teardown()
+
+			mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(headerContentType, mimeApplicationNdjson)
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(td.events))
+			})
+
+			_, err := connector.AttestEvidenceStream(&struct{}{}, "", "", nil)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+		})
+	}
+}
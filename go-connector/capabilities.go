@@ -0,0 +1,72 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Capabilities describes the types of evidence the host can provide for attestation.
+type Capabilities struct {
+	Tdx    bool `json:"tdx"`
+	Tpm    bool `json:"tpm"`
+	SevSnp bool `json:"sevsnp"`
+	NvGpu  bool `json:"nvgpu"`
+}
+
+var (
+	// tdxPaths are present in TDX guests (the TDX guest device, the configfs-tsm
+	// report interface used to get quotes on newer kernels or the CCEL ACPI table used
+	// for TDX event logs)
+	tdxPaths = []string{
+		"dev/tdx_guest",
+		"dev/tdx-guest",
+		"sys/kernel/config/tsm/report",
+		"sys/firmware/acpi/tables/CCEL",
+	}
+
+	tpmPaths = []string{
+		"dev/tpmrm0",
+		"dev/tpm0",
+	}
+
+	sevSnpPaths = []string{
+		"dev/sev-guest",
+	}
+
+	nvGpuPattern = "dev/nvidia[0-9]*"
+)
+
+// DetectCapabilities probes the host's devices and firmware tables to determine
+// which types of evidence are available (ex. to select evidence adapters).  The
+// presence of a device does not guarantee that evidence can be collected (ex. the
+// caller may not have permission to access it).
+func DetectCapabilities() Capabilities {
+	return detectCapabilities("/")
+}
+
+// detectCapabilities probes the paths relative to 'root' (allowing tests to fake
+// the host's file system).
+func detectCapabilities(root string) Capabilities {
+	nvGpus, _ := filepath.Glob(filepath.Join(root, nvGpuPattern))
+
+	return Capabilities{
+		Tdx:    anyPathExists(root, tdxPaths),
+		Tpm:    anyPathExists(root, tpmPaths),
+		SevSnp: anyPathExists(root, sevSnpPaths),
+		NvGpu:  len(nvGpus) > 0,
+	}
+}
+
+func anyPathExists(root string, paths []string) bool {
+	for _, path := range paths {
+		if _, err := os.Stat(filepath.Join(root, path)); err == nil {
+			return true
+		}
+	}
+	return false
+}
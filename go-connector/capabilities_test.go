@@ -0,0 +1,82 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetectCapabilities(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		expected Capabilities
+	}{
+		{
+			name:     "No Capabilities",
+			files:    []string{"dev/null", "dev/nvidiactl"},
+			expected: Capabilities{},
+		},
+		{
+			name:     "TDX Guest Device",
+			files:    []string{"dev/tdx_guest"},
+			expected: Capabilities{Tdx: true},
+		},
+		{
+			name:     "TDX configfs-tsm",
+			files:    []string{"sys/kernel/config/tsm/report"},
+			expected: Capabilities{Tdx: true},
+		},
+		{
+			name:     "TDX CCEL",
+			files:    []string{"sys/firmware/acpi/tables/CCEL"},
+			expected: Capabilities{Tdx: true},
+		},
+		{
+			name:     "TPM",
+			files:    []string{"dev/tpmrm0"},
+			expected: Capabilities{Tpm: true},
+		},
+		{
+			name:     "SEV-SNP",
+			files:    []string{"dev/sev-guest"},
+			expected: Capabilities{SevSnp: true},
+		},
+		{
+			name:     "NVIDIA GPU",
+			files:    []string{"dev/nvidia0", "dev/nvidiactl"},
+			expected: Capabilities{NvGpu: true},
+		},
+		{
+			name:     "TDX, TPM and NVIDIA GPU",
+			files:    []string{"dev/tdx_guest", "dev/tpm0", "dev/nvidia1"},
+			expected: Capabilities{Tdx: true, Tpm: true, NvGpu: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, file := range tt.files {
+				path := filepath.Join(root, file)
+				if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			capabilities := detectCapabilities(root)
+			if !reflect.DeepEqual(capabilities, tt.expected) {
+				t.Errorf("Expected capabilities %+v, got %+v", tt.expected, capabilities)
+			}
+		})
+	}
+}
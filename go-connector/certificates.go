@@ -6,16 +6,117 @@
 package connector
 
 import (
+	"bytes"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lestrrat-go/jwx/v2/cert"
+	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
-// GetTokenSigningCertificates is used to get Trust Authority attestation token signing certificates
+// jwksCache holds the most recently downloaded token signing certificates along
+// with the validators (ETag/Last-Modified) that are used to make conditional requests.
+type jwksCache struct {
+	mutex        sync.Mutex
+	jwks         []byte
+	etag         string
+	lastModified string
+}
+
+// issuerJwksCache holds the token signing certificates of each trusted issuer's
+// base URL (see Config.TrustedIssuers).
+type issuerJwksCache struct {
+	mutex  sync.Mutex
+	caches map[string]*jwksCache
+}
+
+// get returns the cache for 'baseUrl', creating it if needed.
+func (c *issuerJwksCache) get(baseUrl string) *jwksCache {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.caches == nil {
+		c.caches = map[string]*jwksCache{}
+	}
+
+	cache, ok := c.caches[baseUrl]
+	if !ok {
+		cache = &jwksCache{}
+		c.caches[baseUrl] = cache
+	}
+
+	return cache
+}
+
+// GetTokenSigningCertificates is used to get Trust Authority attestation token signing certificates.
+// When the Trust Authority reports that the certificates have not changed since the last
+// request (HTTP 304), the previously downloaded certificates are returned.
 func (connector *trustAuthorityConnector) GetTokenSigningCertificates() ([]byte, error) {
-	url := fmt.Sprintf("%s/certs", connector.cfg.BaseUrl)
+	return connector.getTokenSigningCertificates(connector.cfg.BaseUrl, &connector.jwks)
+}
+
+// GetTokenSigningCaBundle returns the CA certificates of the token signing certificates'
+// x5c chains, in the order they are first listed and without duplicates.
+func (connector *trustAuthorityConnector) GetTokenSigningCaBundle() ([]*x509.Certificate, error) {
+	jwks, err := connector.GetTokenSigningCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	jwkSet, err := jwk.Parse(jwks)
+	if err != nil {
+		return nil, errors.Errorf("Unable to unmarshal response into a JWT Key Set: %s", err)
+	}
+
+	var bundle []*x509.Certificate
+	for i := 0; i < jwkSet.Len(); i++ {
+		jwkKey, _ := jwkSet.Key(i)
+		chain := jwkKey.X509CertChain()
+		if chain == nil {
+			continue
+		}
+
+		for j := 0; j < chain.Len(); j++ {
+			der, _ := chain.Get(j)
+			caCert, err := cert.Parse(der)
+			if err != nil {
+				return nil, errors.Errorf("Failed to parse x509 certificate[%d] of key %q: %v", j, jwkKey.KeyID(), err)
+			}
+
+			if !caCert.IsCA || containsCertificate(bundle, caCert) {
+				continue
+			}
+			bundle = append(bundle, caCert)
+		}
+	}
+
+	if len(bundle) == 0 {
+		return nil, errors.New("The token signing certificates do not contain any CA certificates")
+	}
+
+	return bundle, nil
+}
+
+func containsCertificate(certs []*x509.Certificate, c *x509.Certificate) bool {
+	for _, existing := range certs {
+		if bytes.Equal(existing.Raw, c.Raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// getTokenSigningCertificates downloads the token signing certificates from 'baseUrl',
+// using 'cache' to make conditional requests.
+func (connector *trustAuthorityConnector) getTokenSigningCertificates(baseUrl string, cache *jwksCache) ([]byte, error) {
+	url := fmt.Sprintf("%s/certs", baseUrl)
+	logrus.Debugf("Fetching token signing certificates from %s", url)
 
 	newRequest := func() (*http.Request, error) {
 		return http.NewRequest(http.MethodGet, url, nil)
@@ -25,19 +126,60 @@ func (connector *trustAuthorityConnector) GetTokenSigningCertificates() ([]byte,
 		headerAccept: mimeApplicationJson,
 	}
 
+	cache.mutex.Lock()
+	cached := cache.jwks
+	if cached != nil {
+		if cache.etag != "" {
+			headers[headerIfNoneMatch] = cache.etag
+		}
+		if cache.lastModified != "" {
+			headers[headerIfModifiedSince] = cache.lastModified
+		}
+	}
+	cache.mutex.Unlock()
+
 	var jwks []byte
 	processResponse := func(resp *http.Response) error {
+		if resp.StatusCode == http.StatusNotModified {
+			jwks = cached
+			return nil
+		}
+
 		var err error
 		jwks, err = io.ReadAll(resp.Body)
 		if err != nil {
 			return errors.Errorf("Failed to read body from %s: %s", url, err)
 		}
+
+		cache.mutex.Lock()
+		cache.jwks = jwks
+		cache.etag = resp.Header.Get(headerETag)
+		cache.lastModified = resp.Header.Get(headerLastModified)
+		cache.mutex.Unlock()
 		return nil
 	}
 
-	if err := doRequest(*connector.rclient, connector.cfg.TlsCfg, newRequest, nil, headers, processResponse); err != nil {
+	if err := doRequest(*connector.rclient, nil, newRequest, nil, headers, processResponse); err != nil {
 		return nil, err
 	}
 
 	return jwks, nil
 }
+
+// getIssuerSigningCertificates returns the token signing certificates used to verify
+// 'token'.  When Config.TrustedIssuers is provided, the certificates are downloaded from
+// the base URL of the token's issuer ('iss' claim).  Otherwise, the certificates are
+// downloaded from Config.BaseUrl.
+func (connector *trustAuthorityConnector) getIssuerSigningCertificates(token *jwt.Token) ([]byte, error) {
+	if len(connector.cfg.TrustedIssuers) == 0 {
+		return connector.GetTokenSigningCertificates()
+	}
+
+	issuer := tokenIssuer(token)
+	baseUrl, ok := connector.cfg.TrustedIssuers[issuer]
+	if !ok {
+		return nil, errors.Wrapf(ErrUntrustedIssuer, "issuer %q", issuer)
+	}
+
+	return connector.getTokenSigningCertificates(baseUrl, connector.issuerJwks.get(baseUrl))
+}
@@ -6,6 +6,8 @@
 package connector
 
 import (
+	"crypto/x509"
+	"encoding/json"
 	"net/http"
 	"testing"
 )
@@ -26,3 +28,172 @@ func TestGetSigningCertificates(t *testing.T) {
 		t.Errorf("GetTokenSigningCertificates returned unexpected error: %v", err)
 	}
 }
+
+func TestGetSigningCertificates_notModified(t *testing.T) {
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	etag := `"jwks-etag"`
+	requests := 0
+	downloads := 0
+	mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get(headerIfNoneMatch) == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		downloads++
+		w.Header().Set(headerETag, etag)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(jwks))
+	})
+
+	for i := 0; i < 3; i++ {
+		certs, err := connector.GetTokenSigningCertificates()
+		if err != nil {
+			t.Fatalf("GetTokenSigningCertificates returned unexpected error: %v", err)
+		}
+
+		if string(certs) != jwks {
+			t.Fatalf("GetTokenSigningCertificates returned unexpected jwks")
+		}
+	}
+
+	if requests != 3 || downloads != 1 {
+		t.Errorf("expected 3 requests and 1 download, got %d requests and %d downloads", requests, downloads)
+	}
+}
+
+func TestGetSigningCertificates_lastModified(t *testing.T) {
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	lastModified := "Wed, 21 Oct 2015 07:28:00 GMT"
+	downloads := 0
+	mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(headerIfModifiedSince) == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		downloads++
+		w.Header().Set(headerLastModified, lastModified)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(jwks))
+	})
+
+	for i := 0; i < 2; i++ {
+		certs, err := connector.GetTokenSigningCertificates()
+		if err != nil {
+			t.Fatalf("GetTokenSigningCertificates returned unexpected error: %v", err)
+		}
+
+		if string(certs) != jwks {
+			t.Fatalf("GetTokenSigningCertificates returned unexpected jwks")
+		}
+	}
+
+	if downloads != 1 {
+		t.Errorf("expected 1 download, got %d", downloads)
+	}
+}
+
+func TestGetSigningCertificates_unexpectedNotModified(t *testing.T) {
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	// a 304 response without a conditional request should fail
+	mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	})
+
+	_, err := connector.GetTokenSigningCertificates()
+	if err == nil {
+		t.Error("GetTokenSigningCertificates returned nil, expected error")
+	}
+}
+
+func TestGetTokenSigningCaBundle(t *testing.T) {
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(jwks))
+	})
+
+	bundle, err := connector.GetTokenSigningCaBundle()
+	if err != nil {
+		t.Fatalf("GetTokenSigningCaBundle returned unexpected error: %v", err)
+	}
+
+	// the leaf certificate is excluded from the bundle
+	expected := []string{"Development Amber ATS Signing CA", "Development Amber Root CA"}
+	if len(bundle) != len(expected) {
+		t.Fatalf("Expected %d CA certificates, got %d", len(expected), len(bundle))
+	}
+
+	for i, caCert := range bundle {
+		if caCert.Subject.CommonName != expected[i] {
+			t.Errorf("Expected CA certificate %q at index %d, got %q", expected[i], i, caCert.Subject.CommonName)
+		}
+	}
+
+	// the signing CA is issued by the root CA in the bundle
+	roots := x509.NewCertPool()
+	roots.AddCert(bundle[1])
+	if _, err = bundle[0].Verify(x509.VerifyOptions{Roots: roots, CurrentTime: bundle[0].NotBefore}); err != nil {
+		t.Errorf("Failed to verify the signing CA against the root CA: %v", err)
+	}
+}
+
+func TestGetTokenSigningCaBundle_duplicateCAs(t *testing.T) {
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	// two keys signed by the same CAs
+	var jwkSet map[string][]map[string]interface{}
+	if err := json.Unmarshal([]byte(jwks), &jwkSet); err != nil {
+		t.Fatal(err)
+	}
+	second := map[string]interface{}{}
+	for k, v := range jwkSet["keys"][0] {
+		second[k] = v
+	}
+	second["kid"] = "second"
+	jwkSet["keys"] = append(jwkSet["keys"], second)
+
+	body, err := json.Marshal(jwkSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	})
+
+	bundle, err := connector.GetTokenSigningCaBundle()
+	if err != nil {
+		t.Fatalf("GetTokenSigningCaBundle returned unexpected error: %v", err)
+	}
+
+	if len(bundle) != 2 {
+		t.Errorf("Expected 2 CA certificates, got %d", len(bundle))
+	}
+}
+
+func TestGetTokenSigningCaBundle_noCAs(t *testing.T) {
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"keys":[{"kty":"RSA","kid":"no-x5c","e":"AQAB","n":"vKKV7v7czOHapQ22ZnW677i4BkQIuxVTLk933javfZyLzpM7ZP_Mhvu9QqHrr-iKEqCDBuX1slL_hoB0fTCGGnoFTZ1lTqBdmhFysIgg5uzAqMWL2SJdzYX9RJ_ZXMFnvzTznO-b2jJd864pUI6y72mrzfTqQvgw_60fa3tjc9zjJPiqT1yadKar3G5c0fJqg7AUooTuMkIq291tHqoNhfYzzshZCSFV_d5RruheVMjvgMunx1zISiZ5RNRjcy39G7-08UTCIlSKE_GdsLDNViHqACz60BW3p-kSY5YdoslwKvDUOJnkVZMpJNfdYDoBIiIGgKL2j5H8arHmhSw1A1kl66YdDl7H5Pa46qp4B2FrS5Qpt1D9C-SZXkWN3wzDIQLsHKs0e86R5guLMS9_WcfsPCcHCLjqMZe6S-18SdjwzCK4hbn5vLCZYUzIyVEIcYT8f3mS3s3I1UxJRW53WZOEKkyGVKKGTF8uRxaksFVGrIdW0Q41Wo3mB30N2tqL"}]}`))
+	})
+
+	if _, err := connector.GetTokenSigningCaBundle(); err == nil {
+		t.Error("GetTokenSigningCaBundle returned nil, expected error")
+	}
+}
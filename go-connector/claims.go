@@ -0,0 +1,116 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"encoding/json"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	policyIdsMatchedClaim   = "policy_ids_matched"
+	policyIdsUnmatchedClaim = "policy_ids_unmatched"
+)
+
+// PolicyClaim is a policy listed in the "policy_ids_matched" or "policy_ids_unmatched"
+// claims of an attestation token.
+type PolicyClaim struct {
+	Id      uuid.UUID `json:"id"`
+	Version string    `json:"version,omitempty"`
+}
+
+// PolicyClaims holds the policies that the attested evidence matched or did not match
+// (see GetPolicyClaims).
+type PolicyClaims struct {
+	Matched   []PolicyClaim
+	Unmatched []PolicyClaim
+}
+
+// GetPolicyClaims parses the matched and unmatched policies from the claims of 'token'
+// (ex. returned by VerifyToken).  The policy claims of composite tokens, which are nested
+// in the claims of each evidence type (ex. "tdx", "tpm"), are combined.
+func GetPolicyClaims(token *jwt.Token) (*PolicyClaims, error) {
+	if token == nil {
+		return nil, errors.New("The token cannot be nil")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("The token's claims are not a json object")
+	}
+
+	policyClaims := PolicyClaims{}
+	for _, scope := range claimScopes(claims) {
+		matched, err := parsePolicyClaim(scope, policyIdsMatchedClaim)
+		if err != nil {
+			return nil, err
+		}
+		policyClaims.Matched = appendPolicyClaims(policyClaims.Matched, matched...)
+
+		unmatched, err := parsePolicyClaim(scope, policyIdsUnmatchedClaim)
+		if err != nil {
+			return nil, err
+		}
+		policyClaims.Unmatched = appendPolicyClaims(policyClaims.Unmatched, unmatched...)
+	}
+
+	return &policyClaims, nil
+}
+
+// claimScopes returns the top level 'claims' followed by the evidence specific claims
+// that are nested in composite tokens (ex. "tdx", "tpm").
+func claimScopes(claims jwt.MapClaims) []map[string]interface{} {
+	scopes := []map[string]interface{}{claims}
+	for _, identifier := range []string{tdxEvidenceIdentifier, tpmEvidenceIdentifier, nvGpuEvidenceIdentifier, sevSnpEvidenceIdentifier} {
+		if nested, ok := claims[identifier].(map[string]interface{}); ok {
+			scopes = append(scopes, nested)
+		}
+	}
+	return scopes
+}
+
+// parsePolicyClaim returns the policies in the 'name' claim, or nil if the claim is
+// not present.
+func parsePolicyClaim(claims map[string]interface{}, name string) ([]PolicyClaim, error) {
+	value, ok := claims[name]
+	if !ok || value == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to marshal the %q claim", name)
+	}
+
+	var policies []PolicyClaim
+	if err = json.Unmarshal(b, &policies); err != nil {
+		return nil, errors.Wrapf(err, "Invalid %q claim", name)
+	}
+
+	return policies, nil
+}
+
+// appendPolicyClaims appends the 'policies' that are not already in 'claims'.
+func appendPolicyClaims(claims []PolicyClaim, policies ...PolicyClaim) []PolicyClaim {
+	for _, policy := range policies {
+		exists := false
+		for _, c := range claims {
+			if c == policy {
+				exists = true
+				break
+			}
+		}
+
+		if !exists {
+			claims = append(claims, policy)
+		}
+	}
+
+	return claims
+}
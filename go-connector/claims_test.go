@@ -0,0 +1,84 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+func TestGetPolicyClaims(t *testing.T) {
+	tdxPolicy := uuid.New()
+	tpmPolicy := uuid.New()
+	unmatchedPolicy := uuid.New()
+
+	tests := []struct {
+		name        string
+		claims      jwt.MapClaims
+		expected    *PolicyClaims
+		expectError bool
+	}{
+		{
+			name: "Top level claims",
+			claims: jwt.MapClaims{
+				"policy_ids_matched":   []interface{}{map[string]interface{}{"id": tdxPolicy.String(), "version": "v1"}},
+				"policy_ids_unmatched": []interface{}{map[string]interface{}{"id": unmatchedPolicy.String(), "version": "v1"}},
+			},
+			expected: &PolicyClaims{
+				Matched:   []PolicyClaim{{Id: tdxPolicy, Version: "v1"}},
+				Unmatched: []PolicyClaim{{Id: unmatchedPolicy, Version: "v1"}},
+			},
+		},
+		{
+			name: "Composite token claims",
+			claims: jwt.MapClaims{
+				"tdx": map[string]interface{}{
+					"policy_ids_matched": []interface{}{map[string]interface{}{"id": tdxPolicy.String(), "version": "v1"}},
+				},
+				"tpm": map[string]interface{}{
+					"policy_ids_matched": []interface{}{
+						map[string]interface{}{"id": tdxPolicy.String(), "version": "v1"},
+						map[string]interface{}{"id": tpmPolicy.String(), "version": "v2"},
+					},
+				},
+			},
+			expected: &PolicyClaims{
+				Matched: []PolicyClaim{{Id: tdxPolicy, Version: "v1"}, {Id: tpmPolicy, Version: "v2"}},
+			},
+		},
+		{
+			name:     "No policy claims",
+			claims:   jwt.MapClaims{"iss": "Intel Trust Authority"},
+			expected: &PolicyClaims{},
+		},
+		{
+			name:        "Invalid policy claim",
+			claims:      jwt.MapClaims{"policy_ids_matched": "not a list"},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			policyClaims, err := GetPolicyClaims(&jwt.Token{Claims: tc.claims})
+			if tc.expectError {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(policyClaims, tc.expected) {
+				t.Errorf("Expected %+v, got %+v", tc.expected, policyClaims)
+			}
+		})
+	}
+}
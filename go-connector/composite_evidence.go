@@ -0,0 +1,141 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	tdxEvidenceIdentifier    = "tdx"
+	tpmEvidenceIdentifier    = "tpm"
+	nvGpuEvidenceIdentifier  = "nvgpu"
+	sevSnpEvidenceIdentifier = "sevsnp"
+)
+
+// compositeEvidenceFields are the json names of the CompositeEvidence fields.
+var compositeEvidenceFields = []string{
+	tdxEvidenceIdentifier,
+	tpmEvidenceIdentifier,
+	nvGpuEvidenceIdentifier,
+	sevSnpEvidenceIdentifier,
+	"policy_ids",
+	"policy_must_match",
+	"token_signing_alg",
+	"token_audience",
+	"token_type",
+	"context",
+	"claims_request",
+	"platform_info",
+}
+
+// CompositeEvidence is the attestation request payload created by EvidenceBuilder.
+// Evidence from the TDX, TPM, NVIDIA GPU and SEV-SNP adapters is available in the
+// corresponding fields (i.e., the value returned by the adapter's GetEvidence), while
+// evidence from other adapters is in 'Other' keyed by the adapter's evidence
+// identifier.  CompositeEvidence is serialized as a single json object (ex.
+// { "tdx": {...}, "tpm": {...}, "policy_ids": [...] }).
+type CompositeEvidence struct {
+	Tdx    interface{}            `json:"tdx,omitempty"`
+	Tpm    interface{}            `json:"tpm,omitempty"`
+	NvGpu  interface{}            `json:"nvgpu,omitempty"`
+	SevSnp interface{}            `json:"sevsnp,omitempty"`
+	Other  map[string]interface{} `json:"-"`
+
+	PolicyIds       []uuid.UUID       `json:"policy_ids,omitempty"`
+	PolicyMustMatch bool              `json:"policy_must_match,omitempty"`
+	TokenSigningAlg JwtAlg            `json:"token_signing_alg,omitempty"`
+	TokenAudience   string            `json:"token_audience,omitempty"`
+	TokenType       TokenType         `json:"token_type,omitempty"`
+	Context         string            `json:"context,omitempty"`
+	ClaimsRequest   map[string]string `json:"claims_request,omitempty"`
+	PlatformInfo    *PlatformInfo     `json:"platform_info,omitempty"`
+}
+
+// compositeEvidence is used to (un)marshal the fields of CompositeEvidence without
+// recursing into its MarshalJSON/UnmarshalJSON.
+type compositeEvidence CompositeEvidence
+
+// setEvidence stores the adapter evidence 'e' in the field that corresponds to the
+// adapter's 'identifier'.
+func (ce *CompositeEvidence) setEvidence(identifier string, e interface{}) {
+	switch identifier {
+	case tdxEvidenceIdentifier:
+		ce.Tdx = e
+	case tpmEvidenceIdentifier:
+		ce.Tpm = e
+	case nvGpuEvidenceIdentifier:
+		ce.NvGpu = e
+	case sevSnpEvidenceIdentifier:
+		ce.SevSnp = e
+	default:
+		if ce.Other == nil {
+			ce.Other = map[string]interface{}{}
+		}
+		ce.Other[identifier] = e
+	}
+}
+
+func (ce CompositeEvidence) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(compositeEvidence(ce))
+	if err != nil {
+		return nil, err
+	}
+
+	// merge the evidence from 'Other' into the top level object
+	fields := map[string]json.RawMessage{}
+	if err = json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	for identifier, e := range ce.Other {
+		if _, exists := fields[identifier]; exists {
+			return nil, errors.Errorf("Evidence identifier %q conflicts with a composite evidence field", identifier)
+		}
+
+		fields[identifier], err = json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(fields)
+}
+
+func (ce *CompositeEvidence) UnmarshalJSON(data []byte) error {
+	var known compositeEvidence
+	if err := json.Unmarshal(data, &known); err != nil {
+		return err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	// the fields that are not part of CompositeEvidence are from other adapters
+	for _, name := range compositeEvidenceFields {
+		delete(fields, name)
+	}
+
+	for identifier, raw := range fields {
+		var e interface{}
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+
+		if known.Other == nil {
+			known.Other = map[string]interface{}{}
+		}
+		known.Other[identifier] = e
+	}
+
+	*ce = CompositeEvidence(known)
+	return nil
+}
@@ -0,0 +1,154 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// testIdentifiedEvidenceAdapter returns the same evidence as testCompositeEvidenceAdapter
+// using a configurable evidence identifier (ex. "tdx").
+type testIdentifiedEvidenceAdapter struct {
+	testCompositeEvidenceAdapter
+	identifier string
+}
+
+func (m *testIdentifiedEvidenceAdapter) GetEvidenceIdentifier() string {
+	return m.identifier
+}
+
+func TestCompositeEvidenceBuild(t *testing.T) {
+	eb, err := NewEvidenceBuilder(
+		WithEvidenceAdapter(&testIdentifiedEvidenceAdapter{identifier: "tdx"}),
+		WithEvidenceAdapter(&testIdentifiedEvidenceAdapter{identifier: "tpm"}),
+		WithEvidenceAdapter(&testIdentifiedEvidenceAdapter{identifier: "nvgpu"}),
+		WithEvidenceAdapter(&testIdentifiedEvidenceAdapter{identifier: "sevsnp"}),
+		WithEvidenceAdapter(&testCompositeEvidenceAdapter{}),
+		WithUserData(make([]byte, 8)),
+		WithPolicyIds([]uuid.UUID{uuid.Nil}),
+		WithTokenSigningAlgorithm(PS384),
+		WithTokenAudience("relying-party"),
+		WithEvidenceContext("workload"),
+		WithPoliciesMustMatch(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidence, err := eb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, e := range map[string]interface{}{"tdx": evidence.Tdx, "tpm": evidence.Tpm, "nvgpu": evidence.NvGpu, "sevsnp": evidence.SevSnp, "test": evidence.Other["test"]} {
+		if e == nil {
+			t.Errorf("Expected %q evidence to be set", name)
+		}
+	}
+
+	// the json must be the same as the untyped map that was previously built
+	expected := map[string]interface{}{
+		"tdx":               evidence.Tdx,
+		"tpm":               evidence.Tpm,
+		"nvgpu":             evidence.NvGpu,
+		"sevsnp":            evidence.SevSnp,
+		"test":              evidence.Other["test"],
+		"policy_ids":        []uuid.UUID{uuid.Nil},
+		"policy_must_match": true,
+		"token_signing_alg": PS384,
+		"token_audience":    "relying-party",
+		"context":           "workload",
+	}
+
+	expectedJson, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidenceJson, err := json.Marshal(evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(evidenceJson) != string(expectedJson) {
+		t.Errorf("Expected evidence json %s, but got %s", expectedJson, evidenceJson)
+	}
+}
+
+func TestCompositeEvidenceRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		evidenceJson string
+	}{
+		{
+			name:         "All Fields",
+			evidenceJson: `{"context":"workload","nvgpu":{"evidence":"AA=="},"policy_ids":["00000000-0000-0000-0000-000000000000"],"policy_must_match":true,"sevsnp":{"report":"AA=="},"tdx":{"quote":"AA=="},"test":{"quote":"AAAAAAAAAAA="},"token_audience":"relying-party","token_signing_alg":"RS256","tpm":{"quote":"AA=="}}`,
+		},
+		{
+			name:         "Tdx Only",
+			evidenceJson: `{"tdx":{"quote":"AA=="}}`,
+		},
+		{
+			name:         "Other Only",
+			evidenceJson: `{"test":{"quote":"AAAAAAAAAAA="}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var evidence CompositeEvidence
+			if err := json.Unmarshal([]byte(tt.evidenceJson), &evidence); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := json.Marshal(evidence)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != tt.evidenceJson {
+				t.Errorf("Expected evidence json %s, but got %s", tt.evidenceJson, string(b))
+			}
+		})
+	}
+}
+
+func TestCompositeEvidenceUnmarshal(t *testing.T) {
+	var evidence CompositeEvidence
+	err := json.Unmarshal([]byte(`{"tdx":{"quote":"AA=="},"test":{"quote":"AA=="},"policy_must_match":false,"token_signing_alg":"PS384"}`), &evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := CompositeEvidence{
+		Tdx:             map[string]interface{}{"quote": "AA=="},
+		Other:           map[string]interface{}{"test": map[string]interface{}{"quote": "AA=="}},
+		TokenSigningAlg: PS384,
+	}
+
+	if !reflect.DeepEqual(evidence, expected) {
+		t.Errorf("Expected evidence %+v, but got %+v", expected, evidence)
+	}
+}
+
+func TestCompositeEvidenceNegative(t *testing.T) {
+	conflicting := CompositeEvidence{
+		Context: "workload",
+		Other:   map[string]interface{}{"context": "conflict"},
+	}
+	if _, err := json.Marshal(conflicting); err == nil {
+		t.Error("Expected an error when other evidence conflicts with a composite evidence field")
+	}
+
+	var evidence CompositeEvidence
+	if err := json.Unmarshal([]byte(`{"policy_ids":"invalid"}`), &evidence); err == nil {
+		t.Error("Expected an error when unmarshalling invalid evidence")
+	}
+}
@@ -0,0 +1,53 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"bytes"
+	"compress/gzip"
+
+	"github.com/pkg/errors"
+)
+
+// RequestCompression determines how the body of attestation requests (GetToken and
+// AttestEvidence) is compressed (see Config.RequestCompression).
+type RequestCompression string
+
+const (
+	NoCompression RequestCompression = ""
+	Gzip          RequestCompression = "gzip"
+)
+
+// validateRequestCompression returns an error if 'compression' is not supported.
+func validateRequestCompression(compression RequestCompression) error {
+	switch compression {
+	case NoCompression, Gzip:
+		return nil
+	default:
+		return errors.Errorf("Unsupported request compression %q", compression)
+	}
+}
+
+// compressRequest compresses the request 'body' using 'compression' and returns the
+// compressed body and its content encoding (empty when the body is not compressed).
+func compressRequest(compression RequestCompression, body []byte) ([]byte, string, error) {
+	switch compression {
+	case NoCompression:
+		return body, "", nil
+	case Gzip:
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(body); err != nil {
+			return nil, "", errors.Wrap(err, "Failed to gzip the request body")
+		}
+		if err := writer.Close(); err != nil {
+			return nil, "", errors.Wrap(err, "Failed to gzip the request body")
+		}
+		return buf.Bytes(), string(Gzip), nil
+	default:
+		return nil, "", validateRequestCompression(compression)
+	}
+}
@@ -0,0 +1,135 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// readGzipRequest returns the uncompressed body of a gzip encoded request.
+func readGzipRequest(t *testing.T, r *http.Request) []byte {
+	if r.Header.Get(headerContentEncoding) != string(Gzip) {
+		t.Errorf("Expected content encoding %q, got %q", Gzip, r.Header.Get(headerContentEncoding))
+	}
+
+	reader, err := gzip.NewReader(r.Body)
+	if err != nil {
+		t.Fatalf("Expected a gzip encoded request body: %v", err)
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return body
+}
+
+func TestAttestEvidence_gzip(t *testing.T) {
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	var auditedBody []byte
+	cfg := connector.(*trustAuthorityConnector).cfg
+	cfg.RequestCompression = Gzip
+	cfg.RequestAuditSink = func(endpoint string, body []byte) { auditedBody = body }
+
+	var requestBody []byte
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		requestBody = readGzipRequest(t, r)
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"token":"` + token + `"}`))
+	})
+
+	_, err := connector.AttestEvidence(&CompositeEvidence{TokenAudience: "audience"}, "", "")
+	if err != nil {
+		t.Fatalf("AttestEvidence returned unexpected error: %v", err)
+	}
+
+	var evidence CompositeEvidence
+	if err = json.Unmarshal(requestBody, &evidence); err != nil {
+		t.Fatalf("Failed to decode the uncompressed request body: %v", err)
+	} else if evidence.TokenAudience != "audience" {
+		t.Errorf("Expected token_audience %q, got %q", "audience", evidence.TokenAudience)
+	}
+
+	// the audit sink receives the uncompressed body
+	if !bytes.Equal(auditedBody, requestBody) {
+		t.Errorf("Expected the audited body %s to be the uncompressed request %s", auditedBody, requestBody)
+	}
+}
+
+func TestGetToken_gzip(t *testing.T) {
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	connector.(*trustAuthorityConnector).cfg.RequestCompression = Gzip
+
+	var requestBody []byte
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		requestBody = readGzipRequest(t, r)
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"token":"` + token + `"}`))
+	})
+
+	evidence := &Evidence{Evidence: []byte("quote")}
+	_, err := connector.GetToken(GetTokenArgs{&VerifierNonce{}, evidence, nil, "req1", attestEndpoint, string(PS384), false})
+	if err != nil {
+		t.Fatalf("GetToken returned unexpected error: %v", err)
+	}
+
+	var tr tokenRequest
+	if err = json.Unmarshal(requestBody, &tr); err != nil {
+		t.Fatalf("Failed to decode the uncompressed request body: %v", err)
+	} else if string(tr.Quote) != "quote" {
+		t.Errorf("Expected quote %q, got %q", "quote", tr.Quote)
+	}
+}
+
+func TestAttestEvidence_noCompression(t *testing.T) {
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(headerContentEncoding) != "" {
+			t.Errorf("Did not expect content encoding %q", r.Header.Get(headerContentEncoding))
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"token":"` + token + `"}`))
+	})
+
+	_, err := connector.AttestEvidence(&CompositeEvidence{}, "", "")
+	if err != nil {
+		t.Fatalf("AttestEvidence returned unexpected error: %v", err)
+	}
+}
+
+func TestWithRequestCompression(t *testing.T) {
+	cfg := &Config{}
+	if err := WithRequestCompression(Gzip)(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.RequestCompression != Gzip {
+		t.Fatalf("Expected request compression %q, got %q", Gzip, cfg.RequestCompression)
+	}
+
+	if err := WithRequestCompression("br")(cfg); err == nil {
+		t.Fatal("Expected an error for an unsupported request compression")
+	}
+
+	if _, err := New(&Config{ApiUrl: "https://custom-url/api/v1", RequestCompression: "br"}); err == nil {
+		t.Fatal("Expected New to fail with an unsupported request compression")
+	}
+}
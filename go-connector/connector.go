@@ -9,8 +9,13 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,10 +40,36 @@ type Connector interface {
 	// x-request-id header that can be used for troubleshooting.
 	AttestEvidence(evidence interface{}, cloudProvider string, reqId string) (AttestResponse, error)
 
+	// AttestEvidenceStream is similar to AttestEvidence but, when the Trust Authority
+	// streams its response, 'onStatus' is called (in order) with the intermediate status
+	// of the appraisal (ex. per evidence type) before the final token is returned.  When
+	// the response is not streamed, only the final token is returned.
+	AttestEvidenceStream(evidence interface{}, cloudProvider string, reqId string, onStatus func(AttestStatus)) (AttestResponse, error)
+
+	// EvaluateEvidence sends 'evidence' to the Trust Authority as a preview (i.e., the
+	// evidence is appraised without issuing a token) and returns whether it satisfies
+	// each policy.  When 'policyIds' is provided, it replaces the policy ids in the
+	// evidence.  'cloudProvider' and 'reqId' are the same as AttestEvidence.
+	EvaluateEvidence(evidence interface{}, policyIds []uuid.UUID, cloudProvider string, reqId string) (EvaluateResponse, error)
+
 	// GetAkCertificate sends the TPM's EK certificate and the AK's TPMT_PUBLIC structure
 	// to Intel Trust Authority and returns an encrypted AK certificate, a secret, and credential blob
 	// that can be decrypted by the TPM (ActivateCredential command).
 	GetAKCertificate(ekCert *x509.Certificate, akTpmtPublic []byte) ([]byte, []byte, []byte, error)
+
+	// Ping performs a lightweight request to Intel Trust Authority and returns nil if the
+	// service is reachable and (when an API key is configured) the request was authenticated.
+	Ping() error
+
+	// GetTokenSigningCaBundle downloads the token signing certificates and returns the
+	// CA certificates (i.e., the intermediate and root CAs) from their x5c chains.  The
+	// bundle can be persisted to seed the verification of tokens on hosts that cannot
+	// reach the Trust Authority.
+	GetTokenSigningCaBundle() ([]*x509.Certificate, error)
+
+	// GetCRL downloads and parses the (DER encoded) CRL at 'crlUrl' (ex. a CRL distribution
+	// point of the token signing certificates).  The CRL's DER bytes are in its Raw field.
+	GetCRL(crlUrl string) (*x509.RevocationList, error)
 }
 
 // GetNonceArgs holds the request parameters needed for getting nonce from Intel Trust Authority
@@ -84,6 +115,14 @@ type AttestResponse struct {
 	Headers http.Header
 }
 
+// AttestStatus holds an intermediate status streamed by Intel Trust Authority while
+// it appraises evidence (see AttestEvidenceStream)
+type AttestStatus struct {
+	EvidenceType string `json:"evidence_type,omitempty"`
+	Status       string `json:"status"`
+	Message      string `json:"message,omitempty"`
+}
+
 // RetryConfig holds the configuration for automatic retries to tolerate minor outages
 type RetryConfig struct {
 	RetryWaitMin *time.Duration // Minimum time to wait between retries
@@ -97,10 +136,89 @@ type RetryConfig struct {
 // Config holds the Intel Trust Authority configuration for Connector
 type Config struct {
 	BaseUrl string
-	TlsCfg  *tls.Config
+	TlsCfg  *tls.Config // DefaultTlsConfig is used when nil
 	ApiUrl  string
 	ApiKey  string
 	*RetryConfig
+
+	DialTimeout         time.Duration // Maximum time to wait for a connection (defaults to DefaultDialTimeoutSeconds)
+	TLSHandshakeTimeout time.Duration // Maximum time to wait for a TLS handshake (defaults to DefaultTLSHandshakeSeconds)
+
+	// MaxIdleConns, MaxIdleConnsPerHost and IdleConnTimeout configure the pool of keep-alive
+	// connections to Trust Authority (see http.Transport) so that agents attesting frequently
+	// can reuse connections rather than repeat TCP/TLS handshakes.  Zero values use the
+	// http.Transport defaults.  They are ignored when HttpClient is provided.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+
+	// PerAttemptTimeout bounds each attempt of a request (including reading the response)
+	// so that a single stuck attempt does not consume the whole retry budget.  Attempts that
+	// time out are retried (see RetryConfig.RetryMax).  By default, attempts are not bounded.
+	PerAttemptTimeout time.Duration
+
+	// HttpClient is an optional client that can be shared by multiple connectors so that
+	// connections are pooled (see NewSharedClientConnectorFactory).  When provided, its
+	// transport's configuration is used instead of TlsCfg, DialTimeout and TLSHandshakeTimeout.
+	HttpClient *http.Client
+
+	// SerializationFormat determines how the evidence in attestation requests is encoded
+	// (JSON or CBOR).  By default, JSON is used.
+	SerializationFormat SerializationFormat
+
+	// RequestCompression determines how the body of attestation requests is compressed
+	// (ex. Gzip, which also sets the Content-Encoding header).  By default, request bodies
+	// are not compressed.  Compression must be supported by the Trust Authority endpoint.
+	RequestCompression RequestCompression
+
+	// MaxEvidenceSize is the maximum size (in bytes) of serialized evidence.  When
+	// provided, larger attestation requests are not sent and ErrEvidenceTooLarge is
+	// returned instead.
+	MaxEvidenceSize int
+
+	// DeterministicRequestId derives the request id of attestation requests (GetToken,
+	// AttestEvidence) from a hash of the serialized evidence when the caller does not
+	// provide one, so that identical evidence is sent with the same request id.
+	DeterministicRequestId bool
+
+	// TrustedIssuers maps the 'iss' claim of tokens issued by different Trust Authority
+	// regions to the base URL of that region.  When provided, VerifyToken downloads the
+	// token signing certificates from the base URL of the token's issuer and tokens from
+	// other issuers are rejected with ErrUntrustedIssuer.  Otherwise, the certificates are
+	// downloaded from BaseUrl.
+	TrustedIssuers map[string]string
+
+	// ExpectedIssuer pins the 'iss' claim of the tokens verified by VerifyToken (ex. the
+	// issuer of a single Trust Authority region) so that tokens from other issuers (ex. a
+	// spoofed endpoint) are rejected with ErrUnexpectedIssuer.  By default, the issuer is
+	// not checked (unless TrustedIssuers is provided).
+	ExpectedIssuer string
+
+	// RequestAuditSink is an optional callback that receives the URL and serialized body
+	// of attestation requests (GetToken, AttestEvidence) just before they are sent so that
+	// the submitted evidence can be archived.  The API key is a header and is not included
+	// in the body.  The sink must not modify the body.
+	RequestAuditSink func(endpoint string, body []byte)
+
+	// ResponseAuditSink is an optional callback that receives the URL, raw body and headers
+	// (including HeaderTraceId) of successful attestation responses (GetToken, AttestEvidence) so that
+	// client and Trust Authority logs can be correlated.  It is called before the response
+	// is processed and must not modify the body or headers.
+	ResponseAuditSink func(endpoint string, body []byte, headers http.Header)
+
+	// HttpRetryLogger is an optional logger that receives a debug message for each retry
+	// of a request to Trust Authority (the request, the status code or error of the failed
+	// attempt, the attempt number and the wait before the next attempt).
+	HttpRetryLogger HttpRetryLogger
+
+	// FailoverEndpoints are the API URLs of backup Trust Authority regions (see
+	// WithFailoverEndpoints).  When provided, requests to the API are sent to ApiUrl until
+	// FailoverThreshold consecutive requests fail, after which subsequent requests are sent
+	// to the next endpoint.  While failed over, ApiUrl is pinged every
+	// FailoverRecoveryInterval and requests return to it once it is healthy.
+	FailoverEndpoints        []string
+	FailoverThreshold        int           // defaults to DefaultFailoverThreshold
+	FailoverRecoveryInterval time.Duration // defaults to DefaultFailoverRecoverySeconds
 }
 
 // VerifierNonce holds the signed nonce issued from Intel Trust Authority
@@ -113,60 +231,153 @@ type VerifierNonce struct {
 // New returns a new Connector instance
 func New(cfg *Config) (Connector, error) {
 	var err error
+	if err = validateSerializationFormat(cfg.SerializationFormat); err != nil {
+		return nil, err
+	}
+
+	if err = validateRequestCompression(cfg.RequestCompression); err != nil {
+		return nil, err
+	}
+
 	if cfg.BaseUrl != "" {
-		cfg.BaseUrl = strings.TrimSuffix(cfg.BaseUrl, "/")
-		err = validateURLScheme(cfg.BaseUrl)
+		cfg.BaseUrl, err = validateURL(cfg.BaseUrl)
 		if err != nil {
-			return nil, errors.New("Invalid Trust Authority base URL")
+			return nil, fmt.Errorf("%w: %w", ErrInvalidBaseUrl, err)
 		}
 	}
 
 	if cfg.ApiUrl != "" {
-		cfg.ApiUrl = strings.TrimSuffix(cfg.ApiUrl, "/")
-		err = validateURLScheme(cfg.ApiUrl)
+		cfg.ApiUrl, err = validateURL(cfg.ApiUrl)
 		if err != nil {
-			return nil, errors.New("Invalid Trust Authority API URL")
+			return nil, fmt.Errorf("%w: %w", ErrInvalidApiUrl, err)
 		}
 	}
 
+	// the API key is optional when the connector is only used to verify tokens
+	if cfg.ApiKey != "" {
+		if err = ValidateApiKey(cfg.ApiKey); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(cfg.FailoverEndpoints) != 0 {
+		failoverEndpoints := make([]string, len(cfg.FailoverEndpoints))
+		for i, endpoint := range cfg.FailoverEndpoints {
+			failoverEndpoints[i], err = validateURL(endpoint)
+			if err != nil {
+				return nil, fmt.Errorf("%w: failover endpoint %q: %w", ErrInvalidApiUrl, endpoint, err)
+			}
+		}
+		cfg.FailoverEndpoints = failoverEndpoints
+	}
+
+	if len(cfg.TrustedIssuers) != 0 {
+		trustedIssuers := make(map[string]string, len(cfg.TrustedIssuers))
+		for issuer, baseUrl := range cfg.TrustedIssuers {
+			trustedIssuers[issuer], err = validateURL(baseUrl)
+			if err != nil {
+				return nil, fmt.Errorf("%w: issuer %q: %w", ErrInvalidBaseUrl, issuer, err)
+			}
+		}
+		cfg.TrustedIssuers = trustedIssuers
+	}
+
+	dialTimeout := DefaultDialTimeoutSeconds * time.Second
+	if cfg.DialTimeout != 0 {
+		dialTimeout = cfg.DialTimeout
+	}
+
+	tlsHandshakeTimeout := DefaultTLSHandshakeSeconds * time.Second
+	if cfg.TLSHandshakeTimeout != 0 {
+		tlsHandshakeTimeout = cfg.TLSHandshakeTimeout
+	}
+
+	tlsCfg := cfg.TlsCfg
+	if tlsCfg == nil {
+		tlsCfg = DefaultTlsConfig()
+	}
+
 	retryableClient := retryablehttp.NewClient()
+	if cfg.HttpClient != nil {
+		retryableClient.HTTPClient = cfg.HttpClient
+	} else {
+		retryableClient.HTTPClient = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: tlsCfg,
+				Proxy:           http.ProxyFromEnvironment,
+				DialContext: (&net.Dialer{
+					Timeout: dialTimeout,
+				}).DialContext,
+				TLSHandshakeTimeout: tlsHandshakeTimeout,
+				MaxIdleConns:        cfg.MaxIdleConns,
+				MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+				IdleConnTimeout:     cfg.IdleConnTimeout,
+			},
+		}
+	}
 	retryableClient.CheckRetry = defaultRetryPolicy
+	retryableClient.Backoff = RetryAfterBackoff
 	retryableClient.RetryWaitMax = DefaultRetryWaitMaxSeconds * time.Second
 	retryableClient.RetryWaitMin = DefaultRetryWaitMinSeconds * time.Second
 	retryableClient.RetryMax = MaxRetries
-	if cfg.RetryConfig == nil {
-		return &trustAuthorityConnector{
-			cfg:     cfg,
-			rclient: retryableClient,
-		}, nil
+	if cfg.RetryConfig != nil {
+		if cfg.RetryConfig.CheckRetry != nil {
+			retryableClient.CheckRetry = cfg.RetryConfig.CheckRetry
+		}
+		if cfg.RetryConfig.RetryWaitMax != nil {
+			retryableClient.RetryWaitMax = *cfg.RetryConfig.RetryWaitMax
+		}
+		if cfg.RetryConfig.RetryWaitMin != nil {
+			retryableClient.RetryWaitMin = *cfg.RetryConfig.RetryWaitMin
+		}
+		if cfg.RetryConfig.RetryMax != nil {
+			retryableClient.RetryMax = *cfg.RetryConfig.RetryMax
+		}
+		if cfg.RetryConfig.BackOff != nil {
+			retryableClient.Backoff = cfg.RetryConfig.BackOff
+		}
 	}
 
-	if cfg.RetryConfig.CheckRetry != nil {
-		retryableClient.CheckRetry = cfg.RetryConfig.CheckRetry
-	}
-	if cfg.RetryConfig.RetryWaitMax != nil {
-		retryableClient.RetryWaitMax = *cfg.RetryConfig.RetryWaitMax
-	}
-	if cfg.RetryConfig.RetryWaitMin != nil {
-		retryableClient.RetryWaitMin = *cfg.RetryConfig.RetryWaitMin
-	}
-	if cfg.RetryConfig.RetryMax != nil {
-		retryableClient.RetryMax = *cfg.RetryConfig.RetryMax
+	if cfg.HttpRetryLogger != nil {
+		retryLogger := &httpRetryLogger{
+			logger:   cfg.HttpRetryLogger,
+			retryMax: retryableClient.RetryMax,
+		}
+		retryableClient.Backoff = retryLogger.backoff(retryableClient.Backoff)
+		retryableClient.RequestLogHook = retryLogger.requestLogHook
+
+		// the retries are logged by the HttpRetryLogger instead of retryablehttp's
+		// default (stderr) logger
+		retryableClient.Logger = nil
 	}
-	if cfg.RetryConfig.BackOff != nil {
-		retryableClient.Backoff = cfg.RetryConfig.BackOff
+
+	if cfg.PerAttemptTimeout != 0 {
+		// copy the client so that a shared Config.HttpClient is not modified
+		httpClient := *retryableClient.HTTPClient
+		httpClient.Timeout = cfg.PerAttemptTimeout
+		retryableClient.HTTPClient = &httpClient
+		retryableClient.CheckRetry = retryAttemptTimeout(retryableClient.CheckRetry)
 	}
 
-	return &trustAuthorityConnector{
+	ctr := &trustAuthorityConnector{
 		cfg:     cfg,
 		rclient: retryableClient,
-	}, nil
+	}
+
+	if len(cfg.FailoverEndpoints) != 0 {
+		return newFailoverConnector(cfg, ctr)
+	}
+
+	return ctr, nil
 }
 
 // trustAuthorityConnector manages communication with Intel Trust Authority
 type trustAuthorityConnector struct {
 	cfg     *Config
 	rclient *retryablehttp.Client
+	jwks    jwksCache
+
+	issuerJwks issuerJwksCache // the certificates of each trusted issuer (see Config.TrustedIssuers)
 }
 
 var retryableStatusCode = map[int]bool{
@@ -204,23 +415,192 @@ func defaultRetryPolicy(ctx context.Context, resp *http.Response, err error) (bo
 	return false, nil
 }
 
-func validateURLScheme(inputUrl string) error {
-	parsedUrl, err := url.Parse(inputUrl)
+// retryAttemptTimeout wraps 'checkRetry' so that attempts that exceeded
+// Config.PerAttemptTimeout are retried (unless the request's context is done).
+func retryAttemptTimeout(checkRetry retryablehttp.CheckRetry) retryablehttp.CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		var urlErr *url.Error
+		if ctx.Err() == nil && errors.As(err, &urlErr) && urlErr.Timeout() {
+			return true, err
+		}
+		return checkRetry(ctx, resp, err)
+	}
+}
+
+// RetryAfterBackoff is the connector's default retryablehttp.Backoff.  When a 429 or
+// 503 response includes a 'Retry-After' header (in seconds or as an HTTP-date), the
+// server directed wait is used, clamped to 'max'.  Otherwise, the wait is exponential
+// between 'min' and 'max' based on the attempt number.
+func RetryAfterBackoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if wait, ok := parseRetryAfter(resp.Header.Get(headerRetryAfter), time.Now()); ok {
+			if wait > max {
+				wait = max
+			}
+			return wait
+		}
+	}
+
+	return retryablehttp.DefaultBackoff(min, max, attemptNum, nil)
+}
+
+// parseRetryAfter returns the wait specified by a 'Retry-After' header value that is
+// either a number of seconds or an HTTP-date (a date in the past results in no wait).
+// False is returned if the value is empty or invalid.
+func parseRetryAfter(retryAfter string, now time.Time) (time.Duration, bool) {
+	retryAfter = strings.TrimSpace(retryAfter)
+	if retryAfter == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(retryAfter, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		if seconds > int64(math.MaxInt64/time.Second) {
+			return time.Duration(math.MaxInt64), true
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	retryTime, err := http.ParseTime(retryAfter)
+	if err != nil {
+		return 0, false
+	}
+
+	wait := retryTime.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
+
+// validateURL checks that 'inputUrl' is an https url with a host and no query or
+// fragment.  The path may contain a prefix (ex. for a gateway) but must not include
+// the appraisal endpoints that the connector appends to the API URL.  The normalized
+// url (i.e., without surrounding whitespace or trailing slashes) is returned.
+func validateURL(inputUrl string) (string, error) {
+	parsedUrl, err := url.Parse(strings.TrimSpace(inputUrl))
 	if err != nil {
-		return err
+		return "", err
 	}
+
 	if parsedUrl.Scheme != HttpsScheme {
-		return errors.New("url scheme must be https")
+		return "", ErrInvalidUrlScheme
 	}
-	return nil
+
+	if parsedUrl.Hostname() == "" {
+		return "", ErrMissingUrlHost
+	}
+
+	if parsedUrl.RawQuery != "" || parsedUrl.ForceQuery || parsedUrl.Fragment != "" {
+		return "", ErrUrlQueryOrFragment
+	}
+
+	if strings.Contains(parsedUrl.Path+"/", appraisalPathPrefix+"/") {
+		return "", errors.Wrapf(ErrInvalidUrlPath, "%q", parsedUrl.Path)
+	}
+
+	parsedUrl.Path = strings.TrimRight(parsedUrl.Path, "/")
+	parsedUrl.RawPath = ""
+	return parsedUrl.String(), nil
 }
 
+// ValidateTokenSigningAlg returns true when 'input' is one of the supported token
+// signing algorithms (see SupportedTokenSigningAlgs).
 func ValidateTokenSigningAlg(input string) bool {
-	validJwtTokenSignAlgs := []JwtAlg{RS256, PS384}
-	for _, alg := range validJwtTokenSignAlgs {
+	for _, alg := range tokenSigningAlgs {
 		if strings.Compare(input, string(alg)) == 0 {
 			return true
 		}
 	}
 	return false
 }
+
+// ValidateTokenAudience returns true when 'input' can be requested as the token's
+// audience (see WithTokenAudience): 1 to MaxTokenAudienceLength printable ascii
+// characters without whitespace.
+func ValidateTokenAudience(input string) bool {
+	if len(input) == 0 || len(input) > MaxTokenAudienceLength {
+		return false
+	}
+
+	for _, c := range input {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateTokenType returns true when 'input' is one of the token types that can be
+// requested (see WithTokenType).
+func ValidateTokenType(input string) bool {
+	for _, tokenType := range tokenTypes {
+		if input == string(tokenType) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateClaimsRequest checks that the claims-request metadata 'claims' (see
+// WithClaimsRequest) has at most MaxClaimsRequestEntries entries, that each key is
+// 1 to MaxClaimsRequestKeyLength letters, digits, '_', '-' or '.', and that each value
+// is at most MaxClaimsRequestValueLength printable ascii characters.  An error wrapping
+// ErrInvalidClaimsRequest is returned otherwise.
+func ValidateClaimsRequest(claims map[string]string) error {
+	if len(claims) > MaxClaimsRequestEntries {
+		return errors.Wrapf(ErrInvalidClaimsRequest, "at most %d entries are allowed, got %d", MaxClaimsRequestEntries, len(claims))
+	}
+
+	for key, value := range claims {
+		if len(key) == 0 || len(key) > MaxClaimsRequestKeyLength {
+			return errors.Wrapf(ErrInvalidClaimsRequest, "the key %q must be 1 to %d characters", key, MaxClaimsRequestKeyLength)
+		}
+
+		for _, c := range key {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.') {
+				return errors.Wrapf(ErrInvalidClaimsRequest, "the key %q may only contain letters, digits, '_', '-' and '.'", key)
+			}
+		}
+
+		if len(value) > MaxClaimsRequestValueLength {
+			return errors.Wrapf(ErrInvalidClaimsRequest, "the value of %q must be at most %d characters", key, MaxClaimsRequestValueLength)
+		}
+
+		for _, c := range value {
+			if c < ' ' || c > '~' {
+				return errors.Wrapf(ErrInvalidClaimsRequest, "the value of %q may only contain printable ascii characters", key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateApiKey checks that 'apiKey' is either a base64 (url) encoded Trust Authority
+// API key or a JWT (ex. the packaged software use-case).  ErrMissingApiKey is returned
+// when 'apiKey' is empty and ErrInvalidApiKey when it cannot be parsed.
+func ValidateApiKey(apiKey string) error {
+	if apiKey == "" {
+		return ErrMissingApiKey
+	}
+
+	_, err := base64.URLEncoding.DecodeString(apiKey)
+	if err == nil {
+		return nil
+	}
+
+	_, _, err = jwt.NewParser().ParseUnverified(apiKey, jwt.MapClaims{})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidApiKey, err)
+	}
+	return nil
+}
+
+// SupportedTokenSigningAlgs returns the algorithms that can be used to sign
+// attestation tokens.
+func SupportedTokenSigningAlgs() []JwtAlg {
+	return append([]JwtAlg{}, tokenSigningAlgs...)
+}
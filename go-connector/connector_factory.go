@@ -6,6 +6,12 @@
 
 package connector
 
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
 // ConnectorFactory is an interface for instantiating Connector
 // objects.
 type ConnectorFactory interface {
@@ -23,3 +29,26 @@ type connectorFactory struct{}
 func (c *connectorFactory) NewConnector(config *Config) (Connector, error) {
 	return New(config)
 }
+
+// NewSharedClientConnectorFactory returns a ConnectorFactory that creates Connectors
+// using 'httpClient' so that connections are pooled across connectors (ex. a service
+// that creates a connector per tenant).  Each connector's Config (API key, URLs, retry
+// configuration, etc.) remains separate.  The tls configuration and timeouts should
+// be configured on the client's transport.
+func NewSharedClientConnectorFactory(httpClient *http.Client) ConnectorFactory {
+	return &sharedClientConnectorFactory{httpClient: httpClient}
+}
+
+type sharedClientConnectorFactory struct {
+	httpClient *http.Client
+}
+
+func (c *sharedClientConnectorFactory) NewConnector(config *Config) (Connector, error) {
+	if c.httpClient == nil {
+		return nil, errors.New("The shared http client cannot be nil")
+	}
+
+	cfg := *config
+	cfg.HttpClient = c.httpClient
+	return New(&cfg)
+}
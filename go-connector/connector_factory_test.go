@@ -0,0 +1,93 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestSharedClientConnectorFactory(t *testing.T) {
+	var mutex sync.Mutex
+	newConnections := 0
+	apiKeys := []string{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(nonceEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		apiKeys = append(apiKeys, r.Header.Get(headerXApiKey))
+		mutex.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"val":"` + nonceVal + `","iat":"` + nonceIat + `","signature":"` + nonceSig + `"}`))
+	})
+
+	server := httptest.NewUnstartedServer(mux)
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mutex.Lock()
+			newConnections++
+			mutex.Unlock()
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	sharedClient := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+
+	factory := NewSharedClientConnectorFactory(sharedClient)
+
+	tenant1, err := factory.NewConnector(&Config{
+		ApiUrl: server.URL,
+		ApiKey: "dGVuYW50MQ==",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenant2, err := factory.NewConnector(&Config{
+		ApiUrl: server.URL,
+		ApiKey: "dGVuYW50Mg==",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ctr := range []Connector{tenant1, tenant2, tenant1, tenant2} {
+		_, err = ctr.GetNonce(GetNonceArgs{"req1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if newConnections != 1 {
+		t.Errorf("expected the connectors to share one connection, got %d connections", newConnections)
+	}
+
+	expectedKeys := []string{"dGVuYW50MQ==", "dGVuYW50Mg==", "dGVuYW50MQ==", "dGVuYW50Mg=="}
+	for i := range expectedKeys {
+		if apiKeys[i] != expectedKeys[i] {
+			t.Fatalf("expected api keys %v, got %v", expectedKeys, apiKeys)
+		}
+	}
+}
+
+func TestSharedClientConnectorFactory_nilClient(t *testing.T) {
+	_, err := NewSharedClientConnectorFactory(nil).NewConnector(&Config{})
+	if err == nil {
+		t.Error("NewConnector returned nil, expected error")
+	}
+}
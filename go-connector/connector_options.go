@@ -0,0 +1,351 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ConfigOption sets a field of the Config used by NewFromOptions.  Options return
+// typed errors (ex. ErrInvalidApiUrl, ErrMissingApiKey) that can be checked with
+// errors.Is.
+type ConfigOption func(*Config) error
+
+// NewFromOptions creates a new Connector from the specified options.  Unlike New,
+// the API URL and API key are required since they are needed to request nonces and
+// attestation tokens.  The base URL is only needed when verifying tokens.
+func NewFromOptions(opts ...ConfigOption) (Connector, error) {
+	cfg := &Config{}
+
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.ApiUrl == "" {
+		return nil, fmt.Errorf("%w: the API URL was not provided", ErrInvalidApiUrl)
+	}
+
+	if cfg.ApiKey == "" {
+		return nil, ErrMissingApiKey
+	}
+
+	return New(cfg)
+}
+
+// WithBaseUrl sets the Trust Authority base URL (used to download the token
+// signing certificates).
+func WithBaseUrl(baseUrl string) ConfigOption {
+	return func(cfg *Config) error {
+		url, err := validateURL(baseUrl)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidBaseUrl, err)
+		}
+		cfg.BaseUrl = url
+		return nil
+	}
+}
+
+// WithTrustedIssuer trusts tokens whose 'iss' claim is 'issuer' and verifies them using
+// the token signing certificates from the Trust Authority region at 'baseUrl' (see
+// Config.TrustedIssuers).  The option can be provided for each region that issues tokens.
+func WithTrustedIssuer(issuer string, baseUrl string) ConfigOption {
+	return func(cfg *Config) error {
+		if issuer == "" {
+			return errors.New("The trusted issuer cannot be empty")
+		}
+
+		url, err := validateURL(baseUrl)
+		if err != nil {
+			return fmt.Errorf("%w: issuer %q: %w", ErrInvalidBaseUrl, issuer, err)
+		}
+
+		if cfg.TrustedIssuers == nil {
+			cfg.TrustedIssuers = map[string]string{}
+		}
+		cfg.TrustedIssuers[issuer] = url
+		return nil
+	}
+}
+
+// WithExpectedIssuer rejects tokens whose 'iss' claim is not 'issuer' with
+// ErrUnexpectedIssuer when they are verified (see Config.ExpectedIssuer).
+func WithExpectedIssuer(issuer string) ConfigOption {
+	return func(cfg *Config) error {
+		if issuer == "" {
+			return errors.New("The expected issuer cannot be empty")
+		}
+		cfg.ExpectedIssuer = issuer
+		return nil
+	}
+}
+
+// WithApiUrl sets the Trust Authority API URL.
+func WithApiUrl(apiUrl string) ConfigOption {
+	return func(cfg *Config) error {
+		url, err := validateURL(apiUrl)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidApiUrl, err)
+		}
+		cfg.ApiUrl = url
+		return nil
+	}
+}
+
+// WithApiKey sets the API key included in requests to the Trust Authority.  The key is
+// validated with ValidateApiKey.
+func WithApiKey(apiKey string) ConfigOption {
+	return func(cfg *Config) error {
+		if err := ValidateApiKey(apiKey); err != nil {
+			return err
+		}
+		cfg.ApiKey = apiKey
+		return nil
+	}
+}
+
+// WithApiKeyFromFile reads the API key from the file at 'path' (ex. a Kubernetes secret
+// mount) so that the key does not need to be embedded in configuration or command lines.
+// Leading/trailing whitespace is removed and the key is validated with ValidateApiKey.
+func WithApiKeyFromFile(path string) ConfigOption {
+	return func(cfg *Config) error {
+		apiKey, err := readApiKeyFile(path)
+		if err != nil {
+			return err
+		}
+		cfg.ApiKey = apiKey
+		return nil
+	}
+}
+
+// DefaultTlsConfig returns the TLS configuration used to connect to the Trust Authority
+// when one is not provided: TLS 1.2 or later restricted to ECDHE/AES-256-GCM cipher suites
+// (TLS 1.3 cipher suites are not configurable).  Callers can customize the returned config
+// and provide it to WithTlsConfig.
+func DefaultTlsConfig() *tls.Config {
+	return &tls.Config{
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		},
+		InsecureSkipVerify: false,
+		MinVersion:         tls.VersionTLS12,
+	}
+}
+
+// WithTlsConfig sets the TLS configuration used when connecting to the Trust Authority
+// (see DefaultTlsConfig).
+func WithTlsConfig(tlsCfg *tls.Config) ConfigOption {
+	return func(cfg *Config) error {
+		cfg.TlsCfg = tlsCfg
+		return nil
+	}
+}
+
+// WithInsecureTLS disables verification of the Trust Authority's TLS certificate
+// (ex. for local testing against a self-signed instance).  Verification is only
+// disabled when 'ack' is true, acknowledging that the connection is vulnerable to
+// man-in-the-middle attacks; otherwise ErrInsecureTlsNotAcknowledged is returned.
+// The option must follow WithTlsConfig, which replaces the TLS configuration.
+func WithInsecureTLS(ack bool) ConfigOption {
+	return func(cfg *Config) error {
+		if !ack {
+			return ErrInsecureTlsNotAcknowledged
+		}
+
+		logrus.Warn("TLS certificate verification of the Trust Authority is DISABLED, this must not be used in production")
+
+		if cfg.TlsCfg == nil {
+			cfg.TlsCfg = DefaultTlsConfig()
+		} else {
+			cfg.TlsCfg = cfg.TlsCfg.Clone()
+		}
+		cfg.TlsCfg.InsecureSkipVerify = true
+		return nil
+	}
+}
+
+// WithRetryConfig sets the retry configuration used to tolerate minor outages.
+func WithRetryConfig(retryCfg *RetryConfig) ConfigOption {
+	return func(cfg *Config) error {
+		cfg.RetryConfig = retryCfg
+		return nil
+	}
+}
+
+// WithRetryBackoff sets the retryablehttp.Backoff that determines how long to wait
+// between retries (RetryAfterBackoff is used by default).  The RetryConfig provided by
+// WithRetryConfig is copied rather than modified.
+func WithRetryBackoff(backoff retryablehttp.Backoff) ConfigOption {
+	return func(cfg *Config) error {
+		if backoff == nil {
+			return errors.New("A backoff function must be provided")
+		}
+
+		var retryCfg RetryConfig
+		if cfg.RetryConfig != nil {
+			retryCfg = *cfg.RetryConfig
+		}
+		retryCfg.BackOff = backoff
+		cfg.RetryConfig = &retryCfg
+		return nil
+	}
+}
+
+// WithHttpRetryLogger sets the logger that receives a debug message for each retry of a
+// request to Trust Authority (see Config.HttpRetryLogger), ex. logrus.StandardLogger().
+func WithHttpRetryLogger(logger HttpRetryLogger) ConfigOption {
+	return func(cfg *Config) error {
+		if logger == nil {
+			return errors.New("An http retry logger must be provided")
+		}
+		cfg.HttpRetryLogger = logger
+		return nil
+	}
+}
+
+// WithFailoverEndpoints sets the API URLs of backup Trust Authority regions that requests
+// fail over to when the API URL is unavailable (see Config.FailoverEndpoints).
+func WithFailoverEndpoints(endpoints []string) ConfigOption {
+	return func(cfg *Config) error {
+		if len(endpoints) == 0 {
+			return errors.New("At least one failover endpoint must be provided")
+		}
+
+		failoverEndpoints := make([]string, len(endpoints))
+		for i, endpoint := range endpoints {
+			url, err := validateURL(endpoint)
+			if err != nil {
+				return fmt.Errorf("%w: failover endpoint %q: %w", ErrInvalidApiUrl, endpoint, err)
+			}
+			failoverEndpoints[i] = url
+		}
+
+		cfg.FailoverEndpoints = failoverEndpoints
+		return nil
+	}
+}
+
+// WithFailoverPolicy sets the number of consecutive failures after which requests fail
+// over to the next endpoint and the interval at which the API URL is checked for recovery
+// (see Config.FailoverThreshold and Config.FailoverRecoveryInterval).
+func WithFailoverPolicy(threshold int, recoveryInterval time.Duration) ConfigOption {
+	return func(cfg *Config) error {
+		if threshold <= 0 || recoveryInterval <= 0 {
+			return errors.Errorf("The failover threshold and recovery interval must be greater than zero, got %d and %v", threshold, recoveryInterval)
+		}
+		cfg.FailoverThreshold = threshold
+		cfg.FailoverRecoveryInterval = recoveryInterval
+		return nil
+	}
+}
+
+// WithSerializationFormat sets how the evidence in attestation requests is encoded
+// (JSON or CBOR).  By default, JSON is used.  CBOR is more compact but must be supported
+// by the Trust Authority endpoint.
+func WithSerializationFormat(format SerializationFormat) ConfigOption {
+	return func(cfg *Config) error {
+		if err := validateSerializationFormat(format); err != nil {
+			return err
+		}
+		cfg.SerializationFormat = format
+		return nil
+	}
+}
+
+// WithRequestAuditSink sets a callback that receives the URL and serialized body of
+// attestation requests before they are sent (see Config.RequestAuditSink).
+func WithRequestAuditSink(sink func(endpoint string, body []byte)) ConfigOption {
+	return func(cfg *Config) error {
+		if sink == nil {
+			return errors.New("A request audit sink must be provided")
+		}
+		cfg.RequestAuditSink = sink
+		return nil
+	}
+}
+
+// WithResponseAuditSink sets a callback that receives the URL, raw body and headers of
+// successful attestation responses (see Config.ResponseAuditSink).
+func WithResponseAuditSink(sink func(endpoint string, body []byte, headers http.Header)) ConfigOption {
+	return func(cfg *Config) error {
+		if sink == nil {
+			return errors.New("A response audit sink must be provided")
+		}
+		cfg.ResponseAuditSink = sink
+		return nil
+	}
+}
+
+// WithMaxEvidenceSize limits the size of the serialized evidence sent to the Trust Authority
+// (see Config.MaxEvidenceSize).  Exceeding the limit returns ErrEvidenceTooLarge, naming the
+// largest part of the evidence (ex. "tpm.ima_logs"), rather than a rejection by the service.
+func WithMaxEvidenceSize(maxBytes int) ConfigOption {
+	return func(cfg *Config) error {
+		if maxBytes <= 0 {
+			return errors.Errorf("The maximum evidence size must be greater than zero, got %d", maxBytes)
+		}
+		cfg.MaxEvidenceSize = maxBytes
+		return nil
+	}
+}
+
+// WithConnectionPool configures the keep-alive connections that are pooled by the connector
+// (see Config.MaxIdleConns, Config.MaxIdleConnsPerHost and Config.IdleConnTimeout).
+func WithConnectionPool(maxIdleConns int, maxIdleConnsPerHost int, idleConnTimeout time.Duration) ConfigOption {
+	return func(cfg *Config) error {
+		if maxIdleConns < 0 || maxIdleConnsPerHost < 0 || idleConnTimeout < 0 {
+			return errors.New("The connection pool settings cannot be negative")
+		}
+		cfg.MaxIdleConns = maxIdleConns
+		cfg.MaxIdleConnsPerHost = maxIdleConnsPerHost
+		cfg.IdleConnTimeout = idleConnTimeout
+		return nil
+	}
+}
+
+// WithDeterministicRequestId sets Config.DeterministicRequestId so that attestation
+// requests without a request id use one derived from the serialized evidence (useful
+// for idempotency and correlating logs).
+func WithDeterministicRequestId(enabled bool) ConfigOption {
+	return func(cfg *Config) error {
+		cfg.DeterministicRequestId = enabled
+		return nil
+	}
+}
+
+// WithPerAttemptTimeout sets Config.PerAttemptTimeout so that each attempt of a request
+// is bounded by 'timeout' (and retried when it is exceeded).
+func WithPerAttemptTimeout(timeout time.Duration) ConfigOption {
+	return func(cfg *Config) error {
+		if timeout <= 0 {
+			return errors.Errorf("The per-attempt timeout must be greater than zero, got %v", timeout)
+		}
+		cfg.PerAttemptTimeout = timeout
+		return nil
+	}
+}
+
+// WithRequestCompression sets how the body of attestation requests is compressed (see
+// Config.RequestCompression).  Gzip reduces the upload size of large evidence (ex. event
+// logs) but must be supported by the Trust Authority endpoint.
+func WithRequestCompression(compression RequestCompression) ConfigOption {
+	return func(cfg *Config) error {
+		if err := validateRequestCompression(compression); err != nil {
+			return err
+		}
+		cfg.RequestCompression = compression
+		return nil
+	}
+}
@@ -0,0 +1,389 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewFromOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []ConfigOption
+		expectedErr error
+	}{
+		{
+			name: "Valid Options",
+			opts: []ConfigOption{
+				WithBaseUrl("https://portal.trustauthority.intel.com"),
+				WithApiUrl("https://api.trustauthority.intel.com"),
+				WithApiKey("YXBpa2V5"),
+				WithTlsConfig(&tls.Config{}),
+				WithRetryConfig(&RetryConfig{}),
+			},
+		},
+		{
+			name: "Without Base URL",
+			opts: []ConfigOption{
+				WithApiUrl("https://api.trustauthority.intel.com"),
+				WithApiKey("YXBpa2V5"),
+			},
+		},
+		{
+			name: "Invalid Base URL",
+			opts: []ConfigOption{
+				WithBaseUrl("http://portal.trustauthority.intel.com"),
+				WithApiUrl("https://api.trustauthority.intel.com"),
+				WithApiKey("YXBpa2V5"),
+			},
+			expectedErr: ErrInvalidBaseUrl,
+		},
+		{
+			name: "Invalid API URL",
+			opts: []ConfigOption{
+				WithApiUrl("https://api.trustauthority.intel.com?key=value"),
+				WithApiKey("YXBpa2V5"),
+			},
+			expectedErr: ErrInvalidApiUrl,
+		},
+		{
+			name: "Missing API URL",
+			opts: []ConfigOption{
+				WithApiKey("YXBpa2V5"),
+			},
+			expectedErr: ErrInvalidApiUrl,
+		},
+		{
+			name: "Empty API Key",
+			opts: []ConfigOption{
+				WithApiUrl("https://api.trustauthority.intel.com"),
+				WithApiKey(""),
+			},
+			expectedErr: ErrMissingApiKey,
+		},
+		{
+			name: "Invalid API Key",
+			opts: []ConfigOption{
+				WithApiUrl("https://api.trustauthority.intel.com"),
+				WithApiKey("@p!key"),
+			},
+			expectedErr: ErrInvalidApiKey,
+		},
+		{
+			name: "Missing API Key",
+			opts: []ConfigOption{
+				WithApiUrl("https://api.trustauthority.intel.com"),
+			},
+			expectedErr: ErrMissingApiKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFromOptions(tt.opts...)
+			if tt.expectedErr == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestWithApiUrlWrapsUrlError(t *testing.T) {
+	err := WithApiUrl("https://api.trustauthority.intel.com#fragment")(&Config{})
+	if !errors.Is(err, ErrInvalidApiUrl) || !errors.Is(err, ErrUrlQueryOrFragment) {
+		t.Fatalf("expected ErrInvalidApiUrl and ErrUrlQueryOrFragment, got %v", err)
+	}
+}
+
+func TestWithRetryBackoff(t *testing.T) {
+	cfg := Config{}
+	if err := WithRetryBackoff(RetryAfterBackoff)(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RetryConfig == nil || cfg.RetryConfig.BackOff == nil {
+		t.Fatal("expected the backoff to be set in the retry config")
+	}
+
+	if err := WithRetryBackoff(nil)(&Config{}); err == nil {
+		t.Fatal("expected an error for a nil backoff")
+	}
+
+	// the caller's retry config is not modified
+	retryMax := 5
+	retryCfg := &RetryConfig{RetryMax: &retryMax}
+	cfg = Config{}
+	if err := WithRetryConfig(retryCfg)(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := WithRetryBackoff(RetryAfterBackoff)(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if retryCfg.BackOff != nil {
+		t.Fatal("expected the caller's retry config not to be modified")
+	}
+	if cfg.RetryConfig.BackOff == nil || cfg.RetryConfig.RetryMax != &retryMax {
+		t.Fatal("expected the backoff to be set in a copy of the retry config")
+	}
+}
+
+func TestWithRequestAuditSink(t *testing.T) {
+	cfg := Config{}
+	if err := WithRequestAuditSink(func(string, []byte) {})(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RequestAuditSink == nil {
+		t.Fatal("expected the request audit sink to be set")
+	}
+
+	if err := WithRequestAuditSink(nil)(&Config{}); err == nil {
+		t.Fatal("expected an error for a nil request audit sink")
+	}
+}
+
+func TestWithResponseAuditSink(t *testing.T) {
+	cfg := Config{}
+	if err := WithResponseAuditSink(func(string, []byte, http.Header) {})(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ResponseAuditSink == nil {
+		t.Fatal("expected the response audit sink to be set")
+	}
+
+	if err := WithResponseAuditSink(nil)(&Config{}); err == nil {
+		t.Fatal("expected an error for a nil response audit sink")
+	}
+}
+
+func TestWithMaxEvidenceSize(t *testing.T) {
+	cfg := Config{}
+	if err := WithMaxEvidenceSize(1024)(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxEvidenceSize != 1024 {
+		t.Fatalf("expected a maximum evidence size of 1024, got %d", cfg.MaxEvidenceSize)
+	}
+
+	if err := WithMaxEvidenceSize(0)(&Config{}); err == nil {
+		t.Fatal("expected an error for a maximum evidence size of zero")
+	}
+}
+
+func TestWithApiKeyFromFile(t *testing.T) {
+	dir := t.TempDir()
+
+	keyFile := filepath.Join(dir, "apikey")
+	if err := os.WriteFile(keyFile, []byte("YXBpa2V5\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{}
+	if err := WithApiKeyFromFile(keyFile)(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ApiKey != "YXBpa2V5" {
+		t.Fatalf("expected the api key from the file, got %q", cfg.ApiKey)
+	}
+
+	// secret mounts expose keys as symlinks
+	symlink := filepath.Join(dir, "apikey-link")
+	if err := os.Symlink(keyFile, symlink); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = Config{}
+	if err := WithApiKeyFromFile(symlink)(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ApiKey != "YXBpa2V5" {
+		t.Fatalf("expected the api key from the symlinked file, got %q", cfg.ApiKey)
+	}
+
+	// dots in the file name are not path traversal
+	dotsKeyFile := filepath.Join(dir, "key..txt")
+	if err := os.WriteFile(dotsKeyFile, []byte("YXBpa2V5"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = Config{}
+	if err := WithApiKeyFromFile(dotsKeyFile)(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ApiKey != "YXBpa2V5" {
+		t.Fatalf("expected the api key from %q, got %q", dotsKeyFile, cfg.ApiKey)
+	}
+
+	dirSymlink := filepath.Join(dir, "dir-link")
+	if err := os.Symlink(dir, dirSymlink); err != nil {
+		t.Fatal(err)
+	}
+
+	invalidKeyFile := filepath.Join(dir, "invalid-apikey")
+	if err := os.WriteFile(invalidKeyFile, []byte("@p!key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		expectedErr error
+	}{
+		{name: "Empty Path", path: "", expectedErr: ErrInvalidApiKeyFile},
+		{name: "Missing File", path: filepath.Join(dir, "missing"), expectedErr: ErrInvalidApiKeyFile},
+		{name: "Path Traversal", path: "../apikey", expectedErr: ErrInvalidApiKeyFile},
+		{name: "Nested Path Traversal", path: "keys/../../apikey", expectedErr: ErrInvalidApiKeyFile},
+		{name: "Directory", path: dir, expectedErr: ErrInvalidApiKeyFile},
+		{name: "Symlink To Directory", path: dirSymlink, expectedErr: ErrInvalidApiKeyFile},
+		{name: "Invalid Key", path: invalidKeyFile, expectedErr: ErrInvalidApiKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := WithApiKeyFromFile(tt.path)(&Config{})
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+// newSelfSignedServer starts a TLS server with a newly generated, self-signed
+// certificate (i.e., one that is not trusted by the system or test cert pool).
+func newSelfSignedServer(t *testing.T, handler http.Handler) *httptest.Server {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "self-signed"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}
+	server.StartTLS()
+	return server
+}
+
+func TestWithInsecureTLS(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(nonceEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"val":"","iat":"","signature":""}`))
+	})
+
+	server := newSelfSignedServer(t, mux)
+	defer server.Close()
+
+	retryMax := 0
+	tests := []struct {
+		name             string
+		opts             []ConfigOption
+		expectedErr      error
+		nonceErrExpected bool
+	}{
+		{
+			name: "Acknowledged",
+			opts: []ConfigOption{WithInsecureTLS(true)},
+		},
+		{
+			name: "Acknowledged After TLS Config",
+			opts: []ConfigOption{WithTlsConfig(&tls.Config{MinVersion: tls.VersionTLS12}), WithInsecureTLS(true)},
+		},
+		{
+			name:        "Not Acknowledged",
+			opts:        []ConfigOption{WithInsecureTLS(false)},
+			expectedErr: ErrInsecureTlsNotAcknowledged,
+		},
+		{
+			name:             "Not Set",
+			opts:             []ConfigOption{},
+			nonceErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]ConfigOption{
+				WithApiUrl(server.URL),
+				WithApiKey("YXBpa2V5"),
+				WithRetryConfig(&RetryConfig{RetryMax: &retryMax}),
+			}, tt.opts...)
+
+			ctr, err := NewFromOptions(opts...)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = ctr.GetNonce(GetNonceArgs{})
+			if tt.nonceErrExpected && err == nil {
+				t.Fatal("expected the self-signed certificate to be rejected")
+			} else if !tt.nonceErrExpected && err != nil {
+				t.Fatalf("GetNonce returned unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDefaultTlsConfig(t *testing.T) {
+	tlsCfg := DefaultTlsConfig()
+
+	if tlsCfg.MinVersion != tls.VersionTLS12 {
+		t.Errorf("expected minimum version TLS 1.2, got 0x%x", tlsCfg.MinVersion)
+	}
+
+	if tlsCfg.InsecureSkipVerify {
+		t.Error("expected TLS certificate verification to be enabled")
+	}
+
+	expectedSuites := []uint16{
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	}
+	if !reflect.DeepEqual(tlsCfg.CipherSuites, expectedSuites) {
+		t.Errorf("expected cipher suites %v, got %v", expectedSuites, tlsCfg.CipherSuites)
+	}
+
+	// each call returns a new config that can be customized
+	tlsCfg.MinVersion = tls.VersionTLS13
+	if DefaultTlsConfig().MinVersion != tls.VersionTLS12 {
+		t.Error("expected DefaultTlsConfig to return a new config")
+	}
+}
@@ -7,11 +7,15 @@ package connector
 
 import (
 	"crypto/tls"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/golang-jwt/jwt/v4"
 )
 
 // setup sets up a test HTTP server along with a Connector that is
@@ -78,6 +82,17 @@ func TestNewWithRetryConfig(t *testing.T) {
 	}
 }
 
+func TestNew_InvalidApiKey(t *testing.T) {
+	cfg := Config{
+		ApiUrl: "https://custom-url/api/v1",
+		ApiKey: "@p!key",
+	}
+
+	if _, err := New(&cfg); !errors.Is(err, ErrInvalidApiKey) {
+		t.Errorf("Expected ErrInvalidApiKey, got %v", err)
+	}
+}
+
 func TestNew_HttpBaseURL(t *testing.T) {
 	cfg := Config{
 		BaseUrl: "http://custom-base-url/certs",
@@ -98,6 +113,94 @@ func TestNew_HttpApiURL(t *testing.T) {
 	}
 }
 
+func TestNew_urlValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		expectedUrl string
+		expectedErr error
+	}{
+		{
+			name:        "Valid URL",
+			url:         "https://api.trustauthority.intel.com",
+			expectedUrl: "https://api.trustauthority.intel.com",
+		},
+		{
+			name:        "Valid URL With Port",
+			url:         "https://localhost:8443",
+			expectedUrl: "https://localhost:8443",
+		},
+		{
+			name:        "Valid URL With Prefix And Trailing Slashes",
+			url:         " https://custom-url/api/v1// ",
+			expectedUrl: "https://custom-url/api/v1",
+		},
+		{
+			name:        "Missing Host",
+			url:         "https:///api/v1",
+			expectedErr: ErrMissingUrlHost,
+		},
+		{
+			name:        "Missing Host With Port",
+			url:         "https://:8443",
+			expectedErr: ErrMissingUrlHost,
+		},
+		{
+			name:        "Typo In Scheme Separator",
+			url:         "https:/api.trustauthority.intel.com",
+			expectedErr: ErrMissingUrlHost,
+		},
+		{
+			name:        "Embedded Query",
+			url:         "https://api.trustauthority.intel.com?key=value",
+			expectedErr: ErrUrlQueryOrFragment,
+		},
+		{
+			name:        "Empty Query",
+			url:         "https://api.trustauthority.intel.com?",
+			expectedErr: ErrUrlQueryOrFragment,
+		},
+		{
+			name:        "Embedded Fragment",
+			url:         "https://api.trustauthority.intel.com#fragment",
+			expectedErr: ErrUrlQueryOrFragment,
+		},
+		{
+			name:        "Appraisal Endpoint In Path",
+			url:         "https://api.trustauthority.intel.com/appraisal/v2",
+			expectedErr: ErrInvalidUrlPath,
+		},
+		{
+			name:        "Http Scheme",
+			url:         "http://api.trustauthority.intel.com",
+			expectedErr: ErrInvalidUrlScheme,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				BaseUrl: tt.url,
+				ApiUrl:  tt.url,
+			}
+
+			_, err := New(&cfg)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if cfg.BaseUrl != tt.expectedUrl || cfg.ApiUrl != tt.expectedUrl {
+				t.Errorf("expected url %q, got %q and %q", tt.expectedUrl, cfg.BaseUrl, cfg.ApiUrl)
+			}
+		})
+	}
+}
+
 func TestNew_badAPIURL(t *testing.T) {
 	cfg := Config{
 		ApiUrl: "bogus\napi\nURL",
@@ -117,3 +220,222 @@ func TestNew_badBaseURL(t *testing.T) {
 		t.Error("New retruned nil, expected error")
 	}
 }
+
+func TestValidateTokenSigningAlg(t *testing.T) {
+	tests := []struct {
+		alg      string
+		expected bool
+	}{
+		{string(RS256), true},
+		{string(RS384), true},
+		{string(PS256), true},
+		{string(PS384), true},
+		{"RS512", false},
+		{"PS512", false},
+		{"HS256", false},
+		{"none", false},
+		{"rs256", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			if ValidateTokenSigningAlg(tt.alg) != tt.expected {
+				t.Errorf("ValidateTokenSigningAlg(%q) expected %v", tt.alg, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSupportedTokenSigningAlgs(t *testing.T) {
+	algs := SupportedTokenSigningAlgs()
+	for _, alg := range algs {
+		if !ValidateTokenSigningAlg(string(alg)) {
+			t.Errorf("expected %q to be a valid token signing algorithm", alg)
+		}
+	}
+
+	// modifying the results should not change the supported algorithms
+	algs[0] = "none"
+	if ValidateTokenSigningAlg("none") {
+		t.Error("expected the supported algorithms to be immutable")
+	}
+}
+
+func TestValidateTokenAudience(t *testing.T) {
+	tests := []struct {
+		name     string
+		audience string
+		expected bool
+	}{
+		{"Identifier", "relying-party", true},
+		{"Url", "https://relying-party.example.com/api", true},
+		{"Max Length", strings.Repeat("a", MaxTokenAudienceLength), true},
+		{"Empty", "", false},
+		{"Too Long", strings.Repeat("a", MaxTokenAudienceLength+1), false},
+		{"Whitespace", "relying party", false},
+		{"Control Character", "relying-party\n", false},
+		{"Non Ascii", "relying-pärty", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ValidateTokenAudience(tt.audience) != tt.expected {
+				t.Errorf("ValidateTokenAudience(%q) expected %v", tt.audience, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRetryAfterBackoff(t *testing.T) {
+	min := 1 * time.Second
+	max := 30 * time.Second
+
+	newResponse := func(statusCode int, retryAfter string) *http.Response {
+		resp := &http.Response{StatusCode: statusCode, Header: http.Header{}}
+		if retryAfter != "" {
+			resp.Header.Set(headerRetryAfter, retryAfter)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		name        string
+		resp        *http.Response
+		expectedMin time.Duration
+		expectedMax time.Duration
+	}{
+		{
+			name:        "Seconds On 429",
+			resp:        newResponse(http.StatusTooManyRequests, "5"),
+			expectedMin: 5 * time.Second,
+			expectedMax: 5 * time.Second,
+		},
+		{
+			name:        "Seconds On 503",
+			resp:        newResponse(http.StatusServiceUnavailable, "7"),
+			expectedMin: 7 * time.Second,
+			expectedMax: 7 * time.Second,
+		},
+		{
+			name:        "HTTP Date",
+			resp:        newResponse(http.StatusTooManyRequests, time.Now().Add(10*time.Second).UTC().Format(http.TimeFormat)),
+			expectedMin: 8 * time.Second,
+			expectedMax: 10 * time.Second,
+		},
+		{
+			name:        "HTTP Date In The Past",
+			resp:        newResponse(http.StatusServiceUnavailable, time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)),
+			expectedMin: 0,
+			expectedMax: 0,
+		},
+		{
+			name:        "Seconds Clamped To Max",
+			resp:        newResponse(http.StatusTooManyRequests, "3600"),
+			expectedMin: max,
+			expectedMax: max,
+		},
+		{
+			name:        "HTTP Date Clamped To Max",
+			resp:        newResponse(http.StatusTooManyRequests, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)),
+			expectedMin: max,
+			expectedMax: max,
+		},
+		{
+			name:        "Invalid Header Uses Exponential Backoff",
+			resp:        newResponse(http.StatusTooManyRequests, "invalid"),
+			expectedMin: min,
+			expectedMax: min,
+		},
+		{
+			name:        "Negative Seconds Uses Exponential Backoff",
+			resp:        newResponse(http.StatusTooManyRequests, "-1"),
+			expectedMin: min,
+			expectedMax: min,
+		},
+		{
+			name:        "Header Ignored On 500",
+			resp:        newResponse(http.StatusInternalServerError, "5"),
+			expectedMin: min,
+			expectedMax: min,
+		},
+		{
+			name:        "No Response",
+			resp:        nil,
+			expectedMin: min,
+			expectedMax: min,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait := RetryAfterBackoff(min, max, 0, tt.resp)
+			if wait < tt.expectedMin || wait > tt.expectedMax {
+				t.Errorf("expected a wait between %v and %v, got %v", tt.expectedMin, tt.expectedMax, wait)
+			}
+		})
+	}
+}
+
+func TestNew_retryAfter(t *testing.T) {
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	attempts := 0
+	mux.HandleFunc(nonceEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set(headerRetryAfter, "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"val":"","iat":"","signature":""}`))
+	})
+
+	start := time.Now()
+	_, err := connector.GetNonce(GetNonceArgs{})
+	if err != nil {
+		t.Fatalf("GetNonce returned unexpected error: %v", err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+
+	if time.Since(start) < time.Second {
+		t.Errorf("expected the request to be retried after the Retry-After delay")
+	}
+}
+
+func TestValidateApiKey(t *testing.T) {
+	jwtApiKey, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{}).SignedString([]byte("testkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		apiKey      string
+		expectedErr error
+	}{
+		{"Base64 Key", "YXBpa2V5", nil},
+		{"JWT Key", jwtApiKey, nil},
+		{"Empty Key", "", ErrMissingApiKey},
+		{"Malformed Key", "not@n@pikey", ErrInvalidApiKey},
+		{"Malformed JWT Key", "header.payload.signature", ErrInvalidApiKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateApiKey(tt.apiKey)
+			if tt.expectedErr == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
@@ -6,29 +6,90 @@
 package connector
 
 const (
-	headerXApiKey     = "x-api-key"
-	headerAccept      = "Accept"
-	headerContentType = "Content-Type"
-	HeaderRequestId   = "request-id"
-	HeaderTraceId     = "trace-id"
+	headerXApiKey         = "x-api-key"
+	headerAccept          = "Accept"
+	headerContentType     = "Content-Type"
+	headerContentEncoding = "Content-Encoding"
+	HeaderRequestId       = "request-id"
+	HeaderTraceId         = "trace-id"
 
+	headerETag            = "ETag"
+	headerLastModified    = "Last-Modified"
+	headerIfNoneMatch     = "If-None-Match"
+	headerIfModifiedSince = "If-Modified-Since"
+	headerRetryAfter      = "Retry-After"
+
+	appraisalPathPrefix   = "/appraisal"
 	nonceEndpoint         = "/appraisal/v2/nonce"
 	attestEndpoint        = "/appraisal/v2/attest"
 	attestAzureTdEndpoint = "/appraisal/v2/attest/azure"
 
-	mimeApplicationJson        = "application/json"
-	AtsCertChainMaxLen         = 10
-	MaxRetries                 = 2
-	DefaultRetryWaitMinSeconds = 2
-	DefaultRetryWaitMaxSeconds = 10
-	ServiceUnavailableError    = `service unavailable`
+	mimeApplicationJson           = "application/json"
+	mimeApplicationNdjson         = "application/x-ndjson"
+	mimeApplicationCbor           = "application/cbor"
+	AtsCertChainMaxLen            = 10
+	MaxRetries                    = 2
+	DefaultRetryWaitMinSeconds    = 2
+	DefaultRetryWaitMaxSeconds    = 10
+	DefaultDialTimeoutSeconds     = 10
+	DefaultTLSHandshakeSeconds    = 10
+	DefaultRefreshLeadTimeSeconds = 60
+	DefaultRefreshRetrySeconds    = 10
+	MaxRefreshRetrySeconds        = 300
+	MaxTokenAudienceLength        = 256
+	MaxEvidenceContextLength      = 256
+	MaxCrlSize                    = 10 * 1024 * 1024
+	ServiceUnavailableError       = `service unavailable`
 
 	HttpsScheme = "https"
+
+	// the backoff of evidence collection retries (see NewRetryingAdapter)
+	DefaultCollectionRetryWaitMinSeconds = 1
+	DefaultCollectionRetryWaitMaxSeconds = 5
+
+	// the time allowed to query the cloud instance metadata service (see WithPlatformInfo)
+	DefaultInstanceMetadataTimeoutSeconds = 2
+
+	// the consecutive failures before failing over to the next endpoint and the interval
+	// at which the primary endpoint is checked for recovery (see WithFailoverEndpoints)
+	DefaultFailoverThreshold       = 3
+	DefaultFailoverRecoverySeconds = 60
+
+	// nonceIatLayout is the format of the verifier nonce's 'iat' (issued at) time
+	nonceIatLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
 )
 
 type JwtAlg string
 
 const (
 	RS256 JwtAlg = "RS256"
+	RS384 JwtAlg = "RS384"
+	PS256 JwtAlg = "PS256"
 	PS384 JwtAlg = "PS384"
 )
+
+// The limits of the claims-request metadata (see WithClaimsRequest).
+const (
+	MaxClaimsRequestEntries     = 16
+	MaxClaimsRequestKeyLength   = 64
+	MaxClaimsRequestValueLength = 256
+)
+
+// TokenType is the profile of the attestation token requested from the Trust Authority
+// (see WithTokenType).
+type TokenType string
+
+const (
+	// LegacyTokenType is the Trust Authority's current claim set (the default).
+	LegacyTokenType TokenType = "legacy"
+	// EarTokenType is an Entity Attestation Result (EAR) token.
+	EarTokenType TokenType = "ear"
+)
+
+// tokenTypes are the token profiles that can be requested (see ValidateTokenType).
+var tokenTypes = []TokenType{LegacyTokenType, EarTokenType}
+
+// tokenSigningAlgs are the algorithms the Trust Authority can use to sign attestation
+// tokens.  It is used when validating requests (ValidateTokenSigningAlg) and verifying
+// tokens (VerifyToken).
+var tokenSigningAlgs = []JwtAlg{RS256, RS384, PS256, PS384}
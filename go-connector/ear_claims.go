@@ -0,0 +1,171 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"encoding/json"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	// EarProfile is the "eat_profile" claim of Entity Attestation Result (EAR) tokens.
+	EarProfile = "tag:github.com,2023:veraison/ear"
+
+	eatProfileClaim = "eat_profile"
+	submodsClaim    = "submods"
+)
+
+// EarStatus is the trust tier ("ear.status") of an EAR submodule.
+type EarStatus string
+
+const (
+	EarStatusNone            EarStatus = "none"
+	EarStatusAffirming       EarStatus = "affirming"
+	EarStatusWarning         EarStatus = "warning"
+	EarStatusContraindicated EarStatus = "contraindicated"
+)
+
+// earStatusRanks orders the EAR statuses from the most to the least trustworthy
+// (see EarClaims.Status).
+var earStatusRanks = map[EarStatus]int{
+	EarStatusAffirming:       0,
+	EarStatusNone:            1,
+	EarStatusWarning:         2,
+	EarStatusContraindicated: 3,
+}
+
+// EarTrustVector is the "ear.trustworthiness-vector" of an EAR submodule.  Each claim
+// is an AR4SI trustworthiness value (ex. 2 "affirming", 32 "warning", 96
+// "contraindicated") or nil when the verifier made no claim.
+type EarTrustVector struct {
+	InstanceIdentity *int8 `json:"instance-identity,omitempty"`
+	Configuration    *int8 `json:"configuration,omitempty"`
+	Executables      *int8 `json:"executables,omitempty"`
+	FileSystem       *int8 `json:"file-system,omitempty"`
+	Hardware         *int8 `json:"hardware,omitempty"`
+	RuntimeOpaque    *int8 `json:"runtime-opaque,omitempty"`
+	StorageOpaque    *int8 `json:"storage-opaque,omitempty"`
+	SourcedData      *int8 `json:"sourced-data,omitempty"`
+}
+
+// EarSubmodule is the appraisal result of one attester (ex. "tdx", "tpm") listed in the
+// "submods" claim of an EAR token.
+type EarSubmodule struct {
+	Status            EarStatus              `json:"ear.status"`
+	TrustVector       *EarTrustVector        `json:"ear.trustworthiness-vector,omitempty"`
+	AppraisalPolicyId string                 `json:"ear.appraisal-policy-id,omitempty"`
+	Extensions        map[string]interface{} `json:"-"`
+}
+
+// EarClaims holds the results parsed from an EAR token (see GetEarClaims).  Status is
+// the least trustworthy status of the submodules.
+type EarClaims struct {
+	Profile    string
+	Status     EarStatus
+	Submodules map[string]EarSubmodule
+}
+
+// DetectTokenType returns EarTokenType when the "eat_profile" claim of 'token' is
+// EarProfile, otherwise LegacyTokenType.
+func DetectTokenType(token *jwt.Token) TokenType {
+	if token == nil {
+		return LegacyTokenType
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return LegacyTokenType
+	}
+
+	if profile, _ := claims[eatProfileClaim].(string); profile == EarProfile {
+		return EarTokenType
+	}
+
+	return LegacyTokenType
+}
+
+// GetEarClaims parses the per-submodule results from the claims of an EAR 'token' (ex.
+// returned by VerifyToken when WithTokenType("ear") was requested).  An error is
+// returned if the token is not an EAR token (see DetectTokenType).
+func GetEarClaims(token *jwt.Token) (*EarClaims, error) {
+	if token == nil {
+		return nil, errors.New("The token cannot be nil")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("The token's claims are not a json object")
+	}
+
+	if DetectTokenType(token) != EarTokenType {
+		return nil, errors.Errorf("The token's %q claim is not %q", eatProfileClaim, EarProfile)
+	}
+
+	submods, ok := claims[submodsClaim].(map[string]interface{})
+	if !ok || len(submods) == 0 {
+		return nil, errors.Errorf("The EAR token does not contain a %q claim", submodsClaim)
+	}
+
+	earClaims := EarClaims{
+		Profile:    EarProfile,
+		Status:     EarStatusAffirming,
+		Submodules: make(map[string]EarSubmodule, len(submods)),
+	}
+
+	for name, value := range submods {
+		submodule, err := parseEarSubmodule(name, value)
+		if err != nil {
+			return nil, err
+		}
+
+		if earStatusRanks[submodule.Status] > earStatusRanks[earClaims.Status] {
+			earClaims.Status = submodule.Status
+		}
+		earClaims.Submodules[name] = *submodule
+	}
+
+	return &earClaims, nil
+}
+
+// parseEarSubmodule decodes the 'name' submodule of the "submods" claim.  Claims that
+// are not part of the EAR appraisal (ex. Trust Authority specific claims) are kept in
+// the submodule's Extensions.
+func parseEarSubmodule(name string, value interface{}) (*EarSubmodule, error) {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("The EAR submodule %q is not a json object", name)
+	}
+
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to marshal the EAR submodule %q", name)
+	}
+
+	var submodule EarSubmodule
+	if err = json.Unmarshal(b, &submodule); err != nil {
+		return nil, errors.Wrapf(err, "Invalid EAR submodule %q", name)
+	}
+
+	if _, ok := earStatusRanks[submodule.Status]; !ok {
+		return nil, errors.Errorf("Invalid \"ear.status\" %q in EAR submodule %q", submodule.Status, name)
+	}
+
+	for key, v := range fields {
+		switch key {
+		case "ear.status", "ear.trustworthiness-vector", "ear.appraisal-policy-id":
+			continue
+		}
+
+		if submodule.Extensions == nil {
+			submodule.Extensions = map[string]interface{}{}
+		}
+		submodule.Extensions[key] = v
+	}
+
+	return &submodule, nil
+}
@@ -0,0 +1,171 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const sampleEarClaims = `{
+	"eat_profile": "tag:github.com,2023:veraison/ear",
+	"iat": 1700000000,
+	"ear.verifier-id": {"developer": "Intel", "build": "v1.0.0"},
+	"submods": {
+		"tdx": {
+			"ear.status": "affirming",
+			"ear.trustworthiness-vector": {
+				"instance-identity": 2,
+				"configuration": 2,
+				"executables": 2,
+				"hardware": 2
+			},
+			"ear.appraisal-policy-id": "policy:tdx",
+			"tdx_mrtd": "b00f..."
+		},
+		"tpm": {
+			"ear.status": "warning",
+			"ear.trustworthiness-vector": {
+				"instance-identity": 2,
+				"file-system": 32
+			}
+		}
+	}
+}`
+
+// newSampleEarToken signs 'claimsJson' and parses it back, as VerifyToken would return it.
+func newSampleEarToken(t *testing.T, claimsJson string) *jwt.Token {
+	var claims jwt.MapClaims
+	if err := json.Unmarshal([]byte(claimsJson), &claims); err != nil {
+		t.Fatal(err)
+	}
+
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func int8Ptr(v int8) *int8 {
+	return &v
+}
+
+func TestGetEarClaims(t *testing.T) {
+	token := newSampleEarToken(t, sampleEarClaims)
+	if tokenType := DetectTokenType(token); tokenType != EarTokenType {
+		t.Fatalf("Expected token type %q, got %q", EarTokenType, tokenType)
+	}
+
+	earClaims, err := GetEarClaims(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if earClaims.Profile != EarProfile {
+		t.Errorf("Expected profile %q, got %q", EarProfile, earClaims.Profile)
+	}
+
+	if earClaims.Status != EarStatusWarning {
+		t.Errorf("Expected overall status %q, got %q", EarStatusWarning, earClaims.Status)
+	}
+
+	expected := map[string]EarSubmodule{
+		"tdx": {
+			Status: EarStatusAffirming,
+			TrustVector: &EarTrustVector{
+				InstanceIdentity: int8Ptr(2),
+				Configuration:    int8Ptr(2),
+				Executables:      int8Ptr(2),
+				Hardware:         int8Ptr(2),
+			},
+			AppraisalPolicyId: "policy:tdx",
+			Extensions:        map[string]interface{}{"tdx_mrtd": "b00f..."},
+		},
+		"tpm": {
+			Status: EarStatusWarning,
+			TrustVector: &EarTrustVector{
+				InstanceIdentity: int8Ptr(2),
+				FileSystem:       int8Ptr(32),
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(earClaims.Submodules, expected) {
+		t.Errorf("Expected submodules %+v, got %+v", expected, earClaims.Submodules)
+	}
+}
+
+func TestGetEarClaimsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{
+			name:   "Legacy token",
+			claims: jwt.MapClaims{"iss": "Intel Trust Authority"},
+		},
+		{
+			name:   "Missing submods",
+			claims: jwt.MapClaims{"eat_profile": EarProfile},
+		},
+		{
+			name: "Invalid submodule",
+			claims: jwt.MapClaims{
+				"eat_profile": EarProfile,
+				"submods":     map[string]interface{}{"tdx": "not an object"},
+			},
+		},
+		{
+			name: "Invalid status",
+			claims: jwt.MapClaims{
+				"eat_profile": EarProfile,
+				"submods":     map[string]interface{}{"tdx": map[string]interface{}{"ear.status": "trusted"}},
+			},
+		},
+		{
+			name: "Invalid trust vector",
+			claims: jwt.MapClaims{
+				"eat_profile": EarProfile,
+				"submods": map[string]interface{}{"tdx": map[string]interface{}{
+					"ear.status":                 "affirming",
+					"ear.trustworthiness-vector": map[string]interface{}{"hardware": 1000},
+				}},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := GetEarClaims(&jwt.Token{Claims: tc.claims}); err == nil {
+				t.Fatal("Expected an error")
+			}
+		})
+	}
+
+	if _, err := GetEarClaims(nil); err == nil {
+		t.Fatal("Expected an error for a nil token")
+	}
+}
+
+func TestDetectTokenType(t *testing.T) {
+	if tokenType := DetectTokenType(&jwt.Token{Claims: jwt.MapClaims{"iss": "Intel Trust Authority"}}); tokenType != LegacyTokenType {
+		t.Errorf("Expected token type %q, got %q", LegacyTokenType, tokenType)
+	}
+
+	if tokenType := DetectTokenType(nil); tokenType != LegacyTokenType {
+		t.Errorf("Expected token type %q, got %q", LegacyTokenType, tokenType)
+	}
+}
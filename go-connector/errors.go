@@ -0,0 +1,45 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInvalidBaseUrl = errors.New("Invalid Trust Authority base URL")
+	ErrInvalidApiUrl  = errors.New("Invalid Trust Authority API URL")
+	ErrMissingApiKey  = errors.New("Trust Authority API key is required")
+	ErrInvalidApiKey  = errors.New("Invalid Trust Authority API key")
+
+	ErrInvalidApiKeyFile = errors.New("Invalid Trust Authority API key file")
+
+	ErrInsecureTlsNotAcknowledged = errors.New("Disabling TLS certificate verification must be acknowledged")
+
+	ErrInvalidUrlScheme   = errors.New("url scheme must be https")
+	ErrMissingUrlHost     = errors.New("url must contain a host")
+	ErrUrlQueryOrFragment = errors.New("url must not contain a query or fragment")
+	ErrInvalidUrlPath     = errors.New("url path must not include the appraisal endpoints")
+
+	ErrInvalidTokenAudience = errors.New("Invalid token audience")
+	ErrInvalidTokenType     = errors.New("Invalid token type")
+	ErrInvalidClaimsRequest = errors.New("Invalid claims request")
+	ErrUntrustedIssuer      = errors.New("The token was not issued by a trusted issuer")
+	ErrUnexpectedIssuer     = errors.New("The token was not issued by the expected issuer")
+	ErrInvalidTcbStatus     = errors.New("Invalid TCB status")
+	ErrTcbStatusBelowMin    = errors.New("The token's TCB status is below the minimum TCB status")
+
+	ErrInvalidNonceSignature = errors.New("Invalid verifier nonce signature")
+
+	ErrEvidenceTooLarge = errors.New("The serialized evidence is too large")
+	ErrInvalidEvidence  = errors.New("Invalid evidence")
+	ErrAdapterTimeout   = errors.New("Evidence collection timed out")
+
+	ErrEvaluationIssuedToken = errors.New("The Trust Authority issued a token instead of evaluating the evidence")
+
+	ErrUnauthorized = errors.New("Trust Authority rejected the API key (401 Unauthorized)")
+	ErrForbidden    = errors.New("Trust Authority denied access to the requested resource (403 Forbidden)")
+)
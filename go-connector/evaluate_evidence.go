@@ -0,0 +1,180 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// previewQueryParam is added to attestation requests made by EvaluateEvidence so that
+// the Trust Authority appraises the evidence without issuing a token.  A server that
+// does not support it issues a token instead, which EvaluateEvidence reports as
+// ErrEvaluationIssuedToken.
+const previewQueryParam = "preview"
+
+// PolicyResult holds whether evidence satisfied a single policy (see EvaluateEvidence)
+type PolicyResult struct {
+	PolicyId uuid.UUID `json:"policy_id"`
+	Matched  bool      `json:"matched"`
+	Message  string    `json:"message,omitempty"`
+}
+
+// EvaluateResponse holds the per-policy results returned by EvaluateEvidence
+type EvaluateResponse struct {
+	Policies []PolicyResult `json:"policies"`
+	Headers  http.Header    `json:"-"`
+}
+
+func (ctr *trustAuthorityConnector) EvaluateEvidence(evidence interface{}, policyIds []uuid.UUID, cloudProvider string, requestId string) (EvaluateResponse, error) {
+	var response EvaluateResponse
+
+	requestBody, contentType, err := newEvaluateRequest(ctr.cfg.SerializationFormat, evidence, policyIds)
+	if err != nil {
+		return response, err
+	}
+
+	if err = ctr.checkEvidenceSize(requestBody, evidence); err != nil {
+		return response, err
+	}
+
+	url, err := url.Parse(ctr.cfg.ApiUrl)
+	if err != nil {
+		return response, errors.Wrap(err, "Failed to parse API URL")
+	}
+	url.Path = path.Join(url.Path, attestEndpoint)
+	url.Path = path.Join(url.Path, cloudProvider)
+
+	if ctr.cfg.RequestAuditSink != nil {
+		ctr.cfg.RequestAuditSink(url.String(), requestBody)
+	}
+
+	// the audit sink receives the uncompressed body
+	requestBody, contentEncoding, err := compressRequest(ctr.cfg.RequestCompression, requestBody)
+	if err != nil {
+		return response, err
+	}
+
+	newRequest := func() (*http.Request, error) {
+		return http.NewRequest(http.MethodPost, url.String(), bytes.NewReader(requestBody))
+	}
+
+	queryParams := map[string]string{
+		previewQueryParam: "true",
+	}
+
+	var headers = map[string]string{
+		headerXApiKey:     ctr.cfg.ApiKey,
+		headerAccept:      mimeApplicationJson,
+		headerContentType: contentType,
+		HeaderRequestId:   requestId,
+	}
+	if contentEncoding != "" {
+		headers[headerContentEncoding] = contentEncoding
+	}
+
+	processResponse := func(resp *http.Response) error {
+		response.Headers = resp.Header
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return errors.Errorf("Failed to read body from %s: %s", url, err)
+		}
+
+		if ctr.cfg.ResponseAuditSink != nil {
+			ctr.cfg.ResponseAuditSink(url.String(), body, resp.Header)
+		}
+
+		var evaluateResponse struct {
+			EvaluateResponse
+			Token string `json:"token"`
+		}
+		err = json.Unmarshal(body, &evaluateResponse)
+		if err != nil {
+			return errors.Errorf("Failed to decode json from %s: %s", err, string(body))
+		}
+
+		// the server ignored the preview parameter and performed an attestation
+		if evaluateResponse.Token != "" {
+			return ErrEvaluationIssuedToken
+		}
+
+		if len(evaluateResponse.Policies) == 0 {
+			return errors.Errorf("The response from %s does not contain any policy results", url)
+		}
+
+		response.Policies = evaluateResponse.Policies
+		return nil
+	}
+
+	if err := doRequest(*ctr.rclient, nil, newRequest, queryParams, headers, processResponse); err != nil {
+		return response, err
+	}
+
+	return response, nil
+}
+
+// newEvaluateRequest serializes 'evidence' using 'format' (see marshalRequest) and, when
+// provided, replaces its policy ids with 'policyIds'.  It returns the request body and
+// its content type.  An error is returned if the request does not contain any policy ids.
+func newEvaluateRequest(format SerializationFormat, evidence interface{}, policyIds []uuid.UUID) ([]byte, string, error) {
+	body, contentType, err := marshalRequest(format, evidence, MarshalEvidence)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if contentType == mimeApplicationCbor {
+		body, err = setEvaluatePolicyIds[cbor.RawMessage](body, policyIds, cborDecMode.Unmarshal, cborEncMode.Marshal)
+	} else {
+		body, err = setEvaluatePolicyIds[json.RawMessage](body, policyIds, json.Unmarshal, json.Marshal)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return body, contentType, nil
+}
+
+// setEvaluatePolicyIds replaces the "policy_ids" of the serialized evidence in 'body'
+// with 'policyIds' (if any) using the 'unmarshal' and 'marshal' functions of its
+// serialization format.
+func setEvaluatePolicyIds[T json.RawMessage | cbor.RawMessage](body []byte, policyIds []uuid.UUID,
+	unmarshal func([]byte, interface{}) error, marshal func(interface{}) ([]byte, error)) ([]byte, error) {
+
+	fields := map[string]T{}
+	err := unmarshal(body, &fields)
+	if err != nil {
+		return nil, errors.Wrap(err, "Evidence must be serialized as an object")
+	}
+
+	if len(policyIds) != 0 {
+		fields["policy_ids"], err = marshal(policyIds)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to marshal policy ids")
+		}
+	}
+
+	var evidencePolicyIds []uuid.UUID
+	if p, ok := fields["policy_ids"]; ok {
+		if err = unmarshal(p, &evidencePolicyIds); err != nil {
+			return nil, errors.Wrap(err, "Invalid policy ids in evidence")
+		}
+	}
+
+	if len(evidencePolicyIds) == 0 {
+		return nil, errors.New("At least one policy id must be provided to evaluate evidence")
+	}
+
+	return marshal(fields)
+}
@@ -0,0 +1,193 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+func TestEvaluateEvidence(t *testing.T) {
+
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	matchedId := uuid.New()
+	unmatchedId := uuid.New()
+
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get(previewQueryParam) != "true" {
+			t.Errorf("Expected the %q query parameter, got %q", previewQueryParam, r.URL.RawQuery)
+		}
+
+		var body struct {
+			PolicyIds []uuid.UUID `json:"policy_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+
+		if !reflect.DeepEqual(body.PolicyIds, []uuid.UUID{matchedId, unmatchedId}) {
+			t.Errorf("Expected the policy ids in the request body, got %v", body.PolicyIds)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"policies":[` +
+			`{"policy_id":"` + matchedId.String() + `","matched":true},` +
+			`{"policy_id":"` + unmatchedId.String() + `","matched":false,"message":"mrtd mismatch"}]}`))
+	})
+
+	evidence := &CompositeEvidence{
+		Tdx:       map[string]interface{}{"quote": "cXVvdGU="},
+		PolicyIds: []uuid.UUID{uuid.New()}, // replaced by the policy ids argument
+	}
+
+	response, err := connector.EvaluateEvidence(evidence, []uuid.UUID{matchedId, unmatchedId}, "", "")
+	if err != nil {
+		t.Fatalf("EvaluateEvidence returned unexpected error: %v", err)
+	}
+
+	expected := []PolicyResult{
+		{PolicyId: matchedId, Matched: true},
+		{PolicyId: unmatchedId, Matched: false, Message: "mrtd mismatch"},
+	}
+	if !reflect.DeepEqual(response.Policies, expected) {
+		t.Errorf("Expected policy results %v, got %v", expected, response.Policies)
+	}
+}
+
+func TestEvaluateEvidence_evidencePolicyIds(t *testing.T) {
+
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	policyId := uuid.New()
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"policies":[{"policy_id":"` + policyId.String() + `","matched":true}]}`))
+	})
+
+	evidence := &CompositeEvidence{
+		Tdx:       map[string]interface{}{"quote": "cXVvdGU="},
+		PolicyIds: []uuid.UUID{policyId},
+	}
+
+	response, err := connector.EvaluateEvidence(evidence, nil, "", "")
+	if err != nil {
+		t.Fatalf("EvaluateEvidence returned unexpected error: %v", err)
+	}
+
+	if len(response.Policies) != 1 || !response.Policies[0].Matched {
+		t.Errorf("Expected a single matched policy, got %v", response.Policies)
+	}
+}
+
+func TestEvaluateEvidence_missingPolicyIds(t *testing.T) {
+
+	connector, _, _, teardown := setup()
+	defer teardown()
+
+	evidence := &CompositeEvidence{
+		Tdx: map[string]interface{}{"quote": "cXVvdGU="},
+	}
+
+	_, err := connector.EvaluateEvidence(evidence, nil, "", "")
+	if err == nil {
+		t.Error("EvaluateEvidence returned nil, expected error")
+	}
+}
+
+func TestEvaluateEvidence_failedRequest(t *testing.T) {
+
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`invalid evidence`))
+	})
+
+	_, err := connector.EvaluateEvidence(&CompositeEvidence{}, []uuid.UUID{uuid.New()}, "", "")
+	if err == nil {
+		t.Error("EvaluateEvidence returned nil, expected error")
+	}
+}
+
+func TestEvaluateEvidence_cborGzip(t *testing.T) {
+
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	policyId := uuid.New()
+	connector.(*trustAuthorityConnector).cfg.SerializationFormat = CBOR
+	connector.(*trustAuthorityConnector).cfg.RequestCompression = Gzip
+
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(headerContentType) != mimeApplicationCbor {
+			t.Errorf("Expected content type %q, got %q", mimeApplicationCbor, r.Header.Get(headerContentType))
+		}
+
+		var body struct {
+			PolicyIds []uuid.UUID `cbor:"policy_ids"`
+		}
+		if err := cborDecMode.Unmarshal(readGzipRequest(t, r), &body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+
+		if !reflect.DeepEqual(body.PolicyIds, []uuid.UUID{policyId}) {
+			t.Errorf("Expected the policy ids in the request body, got %v", body.PolicyIds)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"policies":[{"policy_id":"` + policyId.String() + `","matched":true}]}`))
+	})
+
+	evidence := &CompositeEvidence{
+		Tdx: map[string]interface{}{"quote": "cXVvdGU="},
+	}
+
+	if _, err := connector.EvaluateEvidence(evidence, []uuid.UUID{policyId}, "", ""); err != nil {
+		t.Fatalf("EvaluateEvidence returned unexpected error: %v", err)
+	}
+}
+
+func TestEvaluateEvidence_unexpectedResponse(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		response    string
+		expectedErr error
+	}{
+		{"token issued", `{"token":"eyJhbGciOiJQUzM4NCIsInR5cCI6IkpXVCJ9"}`, ErrEvaluationIssuedToken},
+		{"no policy results", `{"policies":[]}`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connector, mux, _, teardown := setup()
+			defer teardown()
+
+			mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(tt.response))
+			})
+
+			_, err := connector.EvaluateEvidence(&CompositeEvidence{}, []uuid.UUID{uuid.New()}, "", "")
+			if err == nil {
+				t.Fatal("EvaluateEvidence returned nil, expected error")
+			}
+
+			if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
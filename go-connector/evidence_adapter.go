@@ -5,6 +5,8 @@
  */
 package connector
 
+import "context"
+
 // EvidenceAdapter is an interface which exposes methods for collecting Quote from Platform
 type EvidenceAdapter interface {
 	CollectEvidence(nonce []byte) (*Evidence, error)
@@ -72,3 +74,14 @@ type CompositeEvidenceAdapter interface {
 	// - if both verifier-nonce and user-data are provided:  h(verifier-nonce.Val|verifier-nonce.Iat|user-data)
 	GetEvidence(verifierNonce *VerifierNonce, userData []byte) (interface{}, error)
 }
+
+// ContextEvidenceAdapter is implemented by CompositeEvidenceAdapters whose evidence
+// collection can be cancelled (ex. the TPM adapter).  The EvidenceBuilder cancels the
+// context passed to GetEvidenceContext when the adapter exceeds WithAdapterTimeout.
+type ContextEvidenceAdapter interface {
+	CompositeEvidenceAdapter
+
+	// GetEvidenceContext is similar to GetEvidence but stops collecting evidence and
+	// returns ctx.Err() when 'ctx' is cancelled (or its deadline expires).
+	GetEvidenceContext(ctx context.Context, verifierNonce *VerifierNonce, userData []byte) (interface{}, error)
+}
@@ -6,6 +6,13 @@
 package connector
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode"
+
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 )
@@ -16,7 +23,7 @@ type EvidenceBuilder interface {
 	// Build uses the state of the EvidenceBuilder (ex. evidence adapters, verifier
 	// nonce, etc.) to build an evidence payload suitable for attestation by the
 	// Trust Authority.
-	Build() (interface{}, error)
+	Build() (*CompositeEvidence, error)
 }
 
 type evidenceBuilder struct {
@@ -25,7 +32,14 @@ type evidenceBuilder struct {
 	userData          []byte
 	policyIds         []uuid.UUID
 	tokenSigningAlg   JwtAlg
+	tokenAudience     string
+	tokenType         TokenType
+	evidenceContext   string
+	claimsRequest     map[string]string
 	policiesMustMatch bool
+	platformInfo      bool
+	adapterTimeout    time.Duration
+	evidenceCache     *EvidenceCache
 }
 
 type EvidenceBuilderOption func(*evidenceBuilder) error
@@ -74,6 +88,25 @@ func WithVerifierNonce(connector Connector) EvidenceBuilderOption {
 	}
 }
 
+// WithVerifierNonceValue uses the caller supplied 'verifierNonce' (instead of requesting
+// one from the Trust Authority with WithVerifierNonce).  This allows evidence to be
+// built without network access (ex. replaying a recorded nonce in tests).  The nonce's
+// 'val', 'iat' and 'signature' must not be empty.
+func WithVerifierNonceValue(verifierNonce *VerifierNonce) EvidenceBuilderOption {
+	return func(eb *evidenceBuilder) error {
+		if verifierNonce == nil {
+			return errors.New("The verifier nonce must not be nil")
+		}
+
+		if len(verifierNonce.Val) == 0 || len(verifierNonce.Iat) == 0 || len(verifierNonce.Signature) == 0 {
+			return errors.New("The verifier nonce is missing 'val', 'iat' or 'signature'")
+		}
+
+		eb.verifierNonce = verifierNonce
+		return nil
+	}
+}
+
 // WithPolicyIds sets the policy IDs that will be evaluated remotely by the Trust Authority.
 func WithPolicyIds(policyIds []uuid.UUID) EvidenceBuilderOption {
 	return func(eb *evidenceBuilder) error {
@@ -113,30 +146,191 @@ func WithTokenSigningAlgorithm(tokenSigningAlg JwtAlg) EvidenceBuilderOption {
 	}
 }
 
-func (eb *evidenceBuilder) Build() (interface{}, error) {
-	evidence := map[string]interface{}{}
+// WithTokenAudience requests that ITA bind the attestation token to 'audience'
+// (i.e., the token's 'aud' claim) so that it is not accepted by other services.
+// An ErrInvalidTokenAudience error is returned if 'audience' is not valid (see
+// ValidateTokenAudience).
+func WithTokenAudience(audience string) EvidenceBuilderOption {
+	return func(eb *evidenceBuilder) error {
+		if !ValidateTokenAudience(audience) {
+			return errors.Wrapf(ErrInvalidTokenAudience, "%q", audience)
+		}
+		eb.tokenAudience = audience
+		return nil
+	}
+}
+
+// WithTokenType requests an attestation token of the profile 'tokenType' (ex. "ear"
+// for an Entity Attestation Result).  When not provided, the Trust Authority creates
+// its default token.  An ErrInvalidTokenType error is returned if 'tokenType' is not
+// valid (see ValidateTokenType).
+func WithTokenType(tokenType string) EvidenceBuilderOption {
+	return func(eb *evidenceBuilder) error {
+		if !ValidateTokenType(tokenType) {
+			return errors.Wrapf(ErrInvalidTokenType, "%q, has to be one of %v", tokenType, tokenTypes)
+		}
+		eb.tokenType = TokenType(tokenType)
+		return nil
+	}
+}
+
+// WithEvidenceContext includes a free-form label (ex. a workload name or namespace)
+// in the attestation request that ITA echoes back for correlation.  The context must
+// be at most MaxEvidenceContextLength printable characters.
+func WithEvidenceContext(evidenceContext string) EvidenceBuilderOption {
+	return func(eb *evidenceBuilder) error {
+		if len(evidenceContext) > MaxEvidenceContextLength {
+			return errors.Errorf("The evidence context must be at most %d characters", MaxEvidenceContextLength)
+		}
+
+		for _, c := range evidenceContext {
+			if !unicode.IsPrint(c) {
+				return errors.Errorf("The evidence context %q contains non-printable characters", evidenceContext)
+			}
+		}
+
+		eb.evidenceContext = evidenceContext
+		return nil
+	}
+}
+
+// WithClaimsRequest includes caller-supplied metadata (ex. environment, region or
+// tenant) in the attestation request that Trust Authority policies can key off.  An
+// error wrapping ErrInvalidClaimsRequest is returned if the metadata exceeds the size
+// or charset limits (see ValidateClaimsRequest).
+func WithClaimsRequest(claims map[string]string) EvidenceBuilderOption {
+	return func(eb *evidenceBuilder) error {
+		if err := ValidateClaimsRequest(claims); err != nil {
+			return err
+		}
+
+		eb.claimsRequest = make(map[string]string, len(claims))
+		for key, value := range claims {
+			eb.claimsRequest[key] = value
+		}
+		return nil
+	}
+}
+
+// WithAdapterTimeout bounds the time each evidence adapter's GetEvidence may take
+// during Build() (ex. so that a stuck GPU attester does not block the composite
+// evidence).  When an adapter does not return within 'timeout', Build() fails with an
+// ErrAdapterTimeout error that names the adapter and adapters that implement
+// ContextEvidenceAdapter are cancelled.  A zero timeout (the default) waits for the
+// adapters indefinitely.
+func WithAdapterTimeout(timeout time.Duration) EvidenceBuilderOption {
+	return func(eb *evidenceBuilder) error {
+		if timeout < 0 {
+			return errors.Errorf("The adapter timeout cannot be negative, got %v", timeout)
+		}
+		eb.adapterTimeout = timeout
+		return nil
+	}
+}
+
+func (eb *evidenceBuilder) Build() (*CompositeEvidence, error) {
+	evidence := CompositeEvidence{
+		PolicyIds:       eb.policyIds,
+		PolicyMustMatch: eb.policiesMustMatch,
+		TokenSigningAlg: eb.tokenSigningAlg,
+		TokenAudience:   eb.tokenAudience,
+		TokenType:       eb.tokenType,
+		Context:         eb.evidenceContext,
+		ClaimsRequest:   eb.claimsRequest,
+	}
+
+	if eb.platformInfo {
+		evidence.PlatformInfo = platformInfo()
+	}
 
 	for _, adapter := range eb.adapters {
-		e, err := adapter.GetEvidence(eb.verifierNonce, eb.userData)
+		identifier := adapter.GetEvidenceIdentifier()
+		if eb.evidenceCache != nil {
+			if e, ok := eb.evidenceCache.get(identifier, eb.verifierNonce, eb.userData); ok {
+				evidence.setEvidence(identifier, e)
+				continue
+			}
+		}
+
+		e, err := eb.getEvidence(adapter)
 		if err != nil {
 			return nil, err
 		}
 
-		evidence[adapter.GetEvidenceIdentifier()] = e
+		if eb.evidenceCache != nil {
+			eb.evidenceCache.put(identifier, eb.verifierNonce, eb.userData, e)
+		}
+
+		evidence.setEvidence(identifier, e)
+	}
+
+	return &evidence, nil
+}
+
+// getEvidence collects the evidence from 'adapter', failing with ErrAdapterTimeout if
+// it takes longer than the adapter timeout.  The context of a ContextEvidenceAdapter is
+// cancelled when it times out.  Since GetEvidence cannot be cancelled, other adapters
+// are left to finish in the background and their evidence is discarded.
+func (eb *evidenceBuilder) getEvidence(adapter CompositeEvidenceAdapter) (interface{}, error) {
+	if eb.adapterTimeout == 0 {
+		return adapter.GetEvidence(eb.verifierNonce, eb.userData)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type result struct {
+		evidence interface{}
+		err      error
+	}
+
+	results := make(chan result, 1)
+	go func() {
+		var e interface{}
+		var err error
+		if ctxAdapter, ok := adapter.(ContextEvidenceAdapter); ok {
+			e, err = ctxAdapter.GetEvidenceContext(ctx, eb.verifierNonce, eb.userData)
+		} else {
+			e, err = adapter.GetEvidence(eb.verifierNonce, eb.userData)
+		}
+		results <- result{evidence: e, err: err}
+	}()
+
+	timer := time.NewTimer(eb.adapterTimeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-results:
+		return r.evidence, r.err
+	case <-timer.C:
+		return nil, fmt.Errorf("%w: the %q adapter did not return within %v", ErrAdapterTimeout, adapter.GetEvidenceIdentifier(), eb.adapterTimeout)
 	}
+}
 
-	// add common, top level request parameters (when present)
-	if len(eb.policyIds) > 0 {
-		evidence["policy_ids"] = eb.policyIds
+// MarshalEvidence serializes the evidence created by an EvidenceBuilder into
+// canonical json:  object keys are sorted at every level (including the fields of
+// adapter evidence structs) and insignificant whitespace is removed.  Identical
+// evidence always produces identical bytes, which can be used for caching or
+// debugging.  AttestEvidence uses MarshalEvidence to create the request body.
+func MarshalEvidence(evidence interface{}) ([]byte, error) {
+	b, err := json.Marshal(evidence)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to marshal evidence")
 	}
 
-	if eb.policiesMustMatch {
-		evidence["policy_must_match"] = eb.policiesMustMatch
+	// decode into generic maps (which are marshaled with sorted keys), preserving
+	// the precision of numbers
+	var canonical interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err = dec.Decode(&canonical); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode evidence")
 	}
 
-	if eb.tokenSigningAlg != "" {
-		evidence["token_signing_alg"] = eb.tokenSigningAlg
+	b, err = json.Marshal(canonical)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to marshal canonical evidence")
 	}
 
-	return evidence, nil
+	return b, nil
 }
@@ -6,9 +6,12 @@
 package connector
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/google/uuid"
@@ -28,6 +31,8 @@ func TestEvidenceBuilder(t *testing.T) {
 		connector            Connector
 		policyIds            []uuid.UUID
 		tokenSigningAlg      JwtAlg
+		tokenAudience        string
+		evidenceContext      string
 		policiesMustMatch    bool
 		expectedEvidenceJson string
 		errorExpected        bool
@@ -38,6 +43,8 @@ func TestEvidenceBuilder(t *testing.T) {
 			userData:          make([]byte, 8),
 			policyIds:         []uuid.UUID{uuid.Nil},
 			tokenSigningAlg:   RS256,
+			tokenAudience:     "https://relying-party.example.com",
+			evidenceContext:   "namespace/workload",
 			policiesMustMatch: true,
 			expectedEvidenceJson: `{
 				"test":{
@@ -46,10 +53,33 @@ func TestEvidenceBuilder(t *testing.T) {
 				},
 				"policy_ids":["00000000-0000-0000-0000-000000000000"],
 				"token_signing_alg":"RS256",
+				"token_audience":"https://relying-party.example.com",
+				"context":"namespace/workload",
 				"policy_must_match":true
 			}`,
 			errorExpected: false,
 		},
+		{
+			name:                 "Invalid Token Audience Should Fail",
+			adapter:              &testCompositeEvidenceAdapter{},
+			tokenAudience:        "relying party",
+			expectedEvidenceJson: ``,
+			errorExpected:        true,
+		},
+		{
+			name:                 "Invalid Evidence Context Should Fail",
+			adapter:              &testCompositeEvidenceAdapter{},
+			evidenceContext:      "workload\n",
+			expectedEvidenceJson: ``,
+			errorExpected:        true,
+		},
+		{
+			name:                 "Evidence Context Too Long Should Fail",
+			adapter:              &testCompositeEvidenceAdapter{},
+			evidenceContext:      strings.Repeat("a", MaxEvidenceContextLength+1),
+			expectedEvidenceJson: ``,
+			errorExpected:        true,
+		},
 		{
 			name:                 "No Adapter Should Fail",
 			adapter:              nil,
@@ -89,6 +119,14 @@ func TestEvidenceBuilder(t *testing.T) {
 				opts = append(opts, WithTokenSigningAlgorithm(td.tokenSigningAlg))
 			}
 
+			if td.tokenAudience != "" {
+				opts = append(opts, WithTokenAudience(td.tokenAudience))
+			}
+
+			if td.evidenceContext != "" {
+				opts = append(opts, WithEvidenceContext(td.evidenceContext))
+			}
+
 			if td.policiesMustMatch {
 				opts = append(opts, WithPoliciesMustMatch(td.policiesMustMatch))
 			}
@@ -143,6 +181,68 @@ func TestEvidenceBuilder(t *testing.T) {
 
 }
 
+// testUnsortedEvidenceAdapter returns evidence whose fields are not in alphabetical
+// order and contain numbers that cannot be represented by a float64.
+type testUnsortedEvidenceAdapter struct{}
+
+func (m *testUnsortedEvidenceAdapter) GetEvidenceIdentifier() string {
+	return "unsorted"
+}
+
+func (m *testUnsortedEvidenceAdapter) GetEvidence(verifierNonce *VerifierNonce, userData []byte) (interface{}, error) {
+	return &struct {
+		Z    string            `json:"z"`
+		Max  uint64            `json:"max"`
+		Meta map[string]string `json:"meta"`
+		A    []int             `json:"a"`
+	}{
+		Z:    "last",
+		Max:  18446744073709551615,
+		Meta: map[string]string{"b": "2", "c": "3", "a": "1"},
+		A:    []int{3, 2, 1},
+	}, nil
+}
+
+func TestMarshalEvidence(t *testing.T) {
+	golden := `{"policy_ids":["00000000-0000-0000-0000-000000000000"],"policy_must_match":true,` +
+		`"test":{"quote":"AAAAAAAAAAA=","user_data":"AAAAAAAAAAA="},"token_signing_alg":"RS256",` +
+		`"unsorted":{"a":[3,2,1],"max":18446744073709551615,"meta":{"a":"1","b":"2","c":"3"},"z":"last"}}`
+
+	for i := 0; i < 100; i++ {
+		eb, err := NewEvidenceBuilder(
+			WithEvidenceAdapter(&testUnsortedEvidenceAdapter{}),
+			WithEvidenceAdapter(&testCompositeEvidenceAdapter{}),
+			WithUserData(make([]byte, 8)),
+			WithPolicyIds([]uuid.UUID{uuid.Nil}),
+			WithTokenSigningAlgorithm(RS256),
+			WithPoliciesMustMatch(true),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		evidence, err := eb.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := MarshalEvidence(evidence)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != golden {
+			t.Fatalf("Expected canonical evidence %s, but got %s", golden, string(b))
+		}
+	}
+}
+
+func TestMarshalEvidenceNegative(t *testing.T) {
+	if _, err := MarshalEvidence(make(chan int)); err == nil {
+		t.Fatal("Expected an error marshalling unsupported evidence")
+	}
+}
+
 type testCompositeEvidenceAdapter struct{}
 
 func (m *testCompositeEvidenceAdapter) GetEvidenceIdentifier() string {
@@ -160,3 +260,394 @@ func (m *testCompositeEvidenceAdapter) GetEvidence(verifierNonce *VerifierNonce,
 		V: verifierNonce,
 	}, nil
 }
+
+func TestWithVerifierNonceValue(t *testing.T) {
+	nonce := &VerifierNonce{
+		Val:       []byte{1},
+		Iat:       []byte{2},
+		Signature: []byte{3},
+	}
+
+	eb, err := NewEvidenceBuilder(
+		WithEvidenceAdapter(&testCompositeEvidenceAdapter{}),
+		WithVerifierNonceValue(nonce),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidence, err := eb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := MarshalEvidence(evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"test":{"quote":"AAAAAAAAAAA=","verifier_nonce":{"iat":"Ag==","signature":"Aw==","val":"AQ=="}}}`
+	if string(b) != expected {
+		t.Errorf("Expected evidence %s, but got %s", expected, string(b))
+	}
+
+	invalidNonces := []*VerifierNonce{
+		nil,
+		{},
+		{Val: []byte{1}, Iat: []byte{2}},
+		{Val: []byte{1}, Signature: []byte{3}},
+		{Iat: []byte{2}, Signature: []byte{3}},
+	}
+
+	for _, invalidNonce := range invalidNonces {
+		_, err := NewEvidenceBuilder(
+			WithEvidenceAdapter(&testCompositeEvidenceAdapter{}),
+			WithVerifierNonceValue(invalidNonce),
+		)
+		if err == nil {
+			t.Errorf("Expected an error for verifier nonce %v", invalidNonce)
+		}
+	}
+}
+
+func TestWithVerifierNonceSignature(t *testing.T) {
+	nonce := &VerifierNonce{
+		Val:       []byte{1},
+		Iat:       []byte{2},
+		Signature: []byte{3},
+	}
+
+	ctr := MockConnector{}
+	ctr.On("GetNonce", mock.Anything).Return(GetNonceResponse{Nonce: nonce}, nil)
+
+	eb, err := NewEvidenceBuilder(
+		WithEvidenceAdapter(&testCompositeEvidenceAdapter{}),
+		WithVerifierNonce(&ctr),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidence, err := eb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the nonce's signature is serialized in both formats so that the Trust Authority can
+	// check the freshness of the evidence
+	for _, format := range []SerializationFormat{JSON, CBOR} {
+		b, _, err := marshalRequest(format, evidence, MarshalEvidence)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded CompositeEvidence
+		if format == JSON {
+			err = json.Unmarshal(b, &decoded)
+		} else {
+			err = decoded.UnmarshalCBOR(b)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		test, _ := decoded.Other["test"].(map[string]interface{})
+		verifierNonce, _ := test["verifier_nonce"].(map[string]interface{})
+		if signature, ok := verifierNonce["signature"]; !ok || signature == nil {
+			t.Errorf("Expected the nonce's signature in the %s evidence, got %v", format, decoded.Other)
+		}
+	}
+}
+
+// testSlowEvidenceAdapter blocks in GetEvidence until 'release' is closed.
+type testSlowEvidenceAdapter struct {
+	testCompositeEvidenceAdapter
+	release chan struct{}
+}
+
+func (m *testSlowEvidenceAdapter) GetEvidenceIdentifier() string {
+	return "slow"
+}
+
+func (m *testSlowEvidenceAdapter) GetEvidence(verifierNonce *VerifierNonce, userData []byte) (interface{}, error) {
+	<-m.release
+	return m.testCompositeEvidenceAdapter.GetEvidence(verifierNonce, userData)
+}
+
+func TestWithAdapterTimeout(t *testing.T) {
+	slow := &testSlowEvidenceAdapter{release: make(chan struct{})}
+	defer close(slow.release)
+
+	builder, err := NewEvidenceBuilder(
+		WithEvidenceAdapter(&testCompositeEvidenceAdapter{}),
+		WithEvidenceAdapter(slow),
+		WithAdapterTimeout(50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = builder.Build()
+	if !errors.Is(err, ErrAdapterTimeout) {
+		t.Fatalf("Expected ErrAdapterTimeout, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), `"slow"`) {
+		t.Errorf("Expected the error to name the slow adapter, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected Build to fail after the adapter timeout, took %v", elapsed)
+	}
+}
+
+// testContextEvidenceAdapter blocks in GetEvidenceContext until its context is done and
+// sends the context's error to 'cancelled'.
+type testContextEvidenceAdapter struct {
+	testCompositeEvidenceAdapter
+	cancelled chan error
+}
+
+func (m *testContextEvidenceAdapter) GetEvidenceContext(ctx context.Context, verifierNonce *VerifierNonce, userData []byte) (interface{}, error) {
+	<-ctx.Done()
+	m.cancelled <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func TestWithAdapterTimeoutCancelsContext(t *testing.T) {
+	adapter := &testContextEvidenceAdapter{cancelled: make(chan error, 1)}
+
+	builder, err := NewEvidenceBuilder(
+		WithEvidenceAdapter(adapter),
+		WithAdapterTimeout(50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = builder.Build(); !errors.Is(err, ErrAdapterTimeout) {
+		t.Fatalf("Expected ErrAdapterTimeout, got %v", err)
+	}
+
+	select {
+	case err = <-adapter.cancelled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the adapter's context to be cancelled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the adapter's context to be cancelled after the timeout")
+	}
+}
+
+func TestWithAdapterTimeoutNotExceeded(t *testing.T) {
+	builder, err := NewEvidenceBuilder(
+		WithEvidenceAdapter(&testCompositeEvidenceAdapter{}),
+		WithAdapterTimeout(5*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidence, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Expected Build to succeed, got %v", err)
+	}
+
+	if evidence.Other["test"] == nil {
+		t.Error("Expected evidence from the test adapter")
+	}
+}
+
+func TestWithAdapterTimeoutAdapterError(t *testing.T) {
+	flaky := &testFlakyEvidenceAdapter{failures: []error{errors.New("gpu attester failed")}}
+
+	builder, err := NewEvidenceBuilder(
+		WithEvidenceAdapter(flaky),
+		WithAdapterTimeout(5*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = builder.Build(); err == nil || errors.Is(err, ErrAdapterTimeout) {
+		t.Errorf("Expected the adapter's error, got %v", err)
+	}
+}
+
+func TestWithAdapterTimeoutNegative(t *testing.T) {
+	_, err := NewEvidenceBuilder(
+		WithEvidenceAdapter(&testCompositeEvidenceAdapter{}),
+		WithAdapterTimeout(-time.Second),
+	)
+	if err == nil {
+		t.Error("Expected an error for a negative adapter timeout")
+	}
+}
+
+func TestWithTokenType(t *testing.T) {
+	tests := []struct {
+		name              string
+		opts              []EvidenceBuilderOption
+		expectedTokenType string
+		expectedErr       error
+	}{
+		{
+			name:              "default",
+			expectedTokenType: "",
+		},
+		{
+			name:              "ear",
+			opts:              []EvidenceBuilderOption{WithTokenType("ear")},
+			expectedTokenType: "ear",
+		},
+		{
+			name:              "legacy",
+			opts:              []EvidenceBuilderOption{WithTokenType(string(LegacyTokenType))},
+			expectedTokenType: "legacy",
+		},
+		{
+			name:        "unknown",
+			opts:        []EvidenceBuilderOption{WithTokenType("cwt")},
+			expectedErr: ErrInvalidTokenType,
+		},
+		{
+			name:        "case sensitive",
+			opts:        []EvidenceBuilderOption{WithTokenType("EAR")},
+			expectedErr: ErrInvalidTokenType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]EvidenceBuilderOption{WithEvidenceAdapter(&testCompositeEvidenceAdapter{})}, tt.opts...)
+			builder, err := NewEvidenceBuilder(opts...)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			evidence, err := builder.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			evidenceJson, err := MarshalEvidence(evidence)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fields map[string]interface{}
+			if err = json.Unmarshal(evidenceJson, &fields); err != nil {
+				t.Fatal(err)
+			}
+
+			tokenType, exists := fields["token_type"]
+			if tt.expectedTokenType == "" {
+				if exists {
+					t.Errorf("Expected 'token_type' to be omitted, got %v", tokenType)
+				}
+			} else if tokenType != tt.expectedTokenType {
+				t.Errorf("Expected 'token_type' %q, got %v", tt.expectedTokenType, tokenType)
+			}
+
+			if err = ValidateEvidenceJSON(evidenceJson); err != nil {
+				t.Errorf("Expected the evidence to be valid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWithClaimsRequest(t *testing.T) {
+	tooManyClaims := map[string]string{}
+	for i := 0; i <= MaxClaimsRequestEntries; i++ {
+		tooManyClaims[fmt.Sprintf("key%d", i)] = "value"
+	}
+
+	tests := []struct {
+		name        string
+		claims      map[string]string
+		expected    map[string]interface{}
+		expectedErr error
+	}{
+		{
+			name:     "metadata",
+			claims:   map[string]string{"environment": "prod", "region": "us-west-2", "tenant.id": "Acme Corp"},
+			expected: map[string]interface{}{"environment": "prod", "region": "us-west-2", "tenant.id": "Acme Corp"},
+		},
+		{
+			name:        "too many entries",
+			claims:      tooManyClaims,
+			expectedErr: ErrInvalidClaimsRequest,
+		},
+		{
+			name:        "empty key",
+			claims:      map[string]string{"": "value"},
+			expectedErr: ErrInvalidClaimsRequest,
+		},
+		{
+			name:        "oversized key",
+			claims:      map[string]string{strings.Repeat("k", MaxClaimsRequestKeyLength+1): "value"},
+			expectedErr: ErrInvalidClaimsRequest,
+		},
+		{
+			name:        "invalid key charset",
+			claims:      map[string]string{"tenant id": "value"},
+			expectedErr: ErrInvalidClaimsRequest,
+		},
+		{
+			name:        "oversized value",
+			claims:      map[string]string{"tenant": strings.Repeat("v", MaxClaimsRequestValueLength+1)},
+			expectedErr: ErrInvalidClaimsRequest,
+		},
+		{
+			name:        "non-printable value",
+			claims:      map[string]string{"tenant": "acme\n"},
+			expectedErr: ErrInvalidClaimsRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder, err := NewEvidenceBuilder(WithEvidenceAdapter(&testCompositeEvidenceAdapter{}), WithClaimsRequest(tt.claims))
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			// changes to the caller's map do not affect the builder
+			tt.claims["environment"] = "dev"
+
+			evidence, err := builder.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			evidenceJson, err := MarshalEvidence(evidence)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fields map[string]interface{}
+			if err = json.Unmarshal(evidenceJson, &fields); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(fields["claims_request"], tt.expected) {
+				t.Errorf("Expected 'claims_request' %v, got %v", tt.expected, fields["claims_request"])
+			}
+
+			if err = ValidateEvidenceJSON(evidenceJson); err != nil {
+				t.Errorf("Expected the evidence to be valid, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// EvidenceCache holds the evidence most recently collected by each adapter so that
+// builds with the same verifier nonce and user data (ex. retried attestations) reuse
+// the evidence instead of collecting it again (see WithEvidenceCache).  An
+// EvidenceCache can be shared by EvidenceBuilders and is safe for concurrent use.
+type EvidenceCache struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	entries map[string]*evidenceCacheEntry
+	now     func() time.Time
+}
+
+// evidenceCacheEntry is the evidence collected by an adapter for 'verifierNonce' and
+// 'userData'.
+type evidenceCacheEntry struct {
+	verifierNonce *VerifierNonce
+	userData      []byte
+	evidence      interface{}
+	expires       time.Time
+}
+
+// NewEvidenceCache creates an EvidenceCache whose entries expire 'ttl' after the
+// evidence was collected.  The ttl should be short (ex. a few seconds) since the
+// cached evidence does not reflect changes to the platform made after it was collected.
+func NewEvidenceCache(ttl time.Duration) (*EvidenceCache, error) {
+	if ttl <= 0 {
+		return nil, errors.Errorf("The evidence cache ttl must be positive, got %v", ttl)
+	}
+
+	return &EvidenceCache{
+		ttl:     ttl,
+		entries: map[string]*evidenceCacheEntry{},
+		now:     time.Now,
+	}, nil
+}
+
+// WithEvidenceCache reuses the evidence in 'cache' when an adapter's evidence was
+// collected for the same verifier nonce and user data within the cache's ttl.
+// Evidence collected by Build() is added to the cache.
+func WithEvidenceCache(cache *EvidenceCache) EvidenceBuilderOption {
+	return func(eb *evidenceBuilder) error {
+		if cache == nil {
+			return errors.New("The evidence cache must not be nil")
+		}
+		eb.evidenceCache = cache
+		return nil
+	}
+}
+
+// get returns the evidence cached for the adapter 'identifier' if it was collected for
+// 'verifierNonce' and 'userData' and has not expired.
+func (c *EvidenceCache) get(identifier string, verifierNonce *VerifierNonce, userData []byte) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[identifier]
+	if !ok {
+		return nil, false
+	}
+
+	if !c.now().Before(entry.expires) || !equalVerifierNonces(entry.verifierNonce, verifierNonce) || !bytes.Equal(entry.userData, userData) {
+		delete(c.entries, identifier)
+		return nil, false
+	}
+
+	return entry.evidence, true
+}
+
+// put caches the evidence of the adapter 'identifier', replacing any evidence that was
+// collected with other inputs.
+func (c *EvidenceCache) put(identifier string, verifierNonce *VerifierNonce, userData []byte, evidence interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var nonce *VerifierNonce
+	if verifierNonce != nil {
+		nonce = &VerifierNonce{
+			Val:       bytes.Clone(verifierNonce.Val),
+			Iat:       bytes.Clone(verifierNonce.Iat),
+			Signature: bytes.Clone(verifierNonce.Signature),
+		}
+	}
+
+	c.entries[identifier] = &evidenceCacheEntry{
+		verifierNonce: nonce,
+		userData:      bytes.Clone(userData),
+		evidence:      evidence,
+		expires:       c.now().Add(c.ttl),
+	}
+}
+
+func equalVerifierNonces(a *VerifierNonce, b *VerifierNonce) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return bytes.Equal(a.Val, b.Val) && bytes.Equal(a.Iat, b.Iat) && bytes.Equal(a.Signature, b.Signature)
+}
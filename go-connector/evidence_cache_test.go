@@ -0,0 +1,127 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"testing"
+	"time"
+)
+
+// buildWithCache builds evidence from 'adapter' using 'cache' and returns the number of
+// times the adapter collected evidence.
+func buildWithCache(t *testing.T, cache *EvidenceCache, adapter *testFlakyEvidenceAdapter, nonce *VerifierNonce, userData []byte) int {
+	opts := []EvidenceBuilderOption{
+		WithEvidenceAdapter(adapter),
+		WithEvidenceCache(cache),
+		WithUserData(userData),
+	}
+	if nonce != nil {
+		opts = append(opts, WithVerifierNonceValue(nonce))
+	}
+
+	builder, err := NewEvidenceBuilder(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidence, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Expected Build to succeed, got %v", err)
+	}
+
+	if evidence.Other["test"] == nil {
+		t.Fatal("Expected evidence from the test adapter")
+	}
+
+	return adapter.calls
+}
+
+func TestEvidenceCache(t *testing.T) {
+	nonce := &VerifierNonce{Val: []byte("val"), Iat: []byte("iat"), Signature: []byte("sig")}
+	otherNonce := &VerifierNonce{Val: []byte("val2"), Iat: []byte("iat"), Signature: []byte("sig")}
+
+	now := time.Now()
+	cache, err := NewEvidenceCache(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.now = func() time.Time { return now }
+
+	adapter := &testFlakyEvidenceAdapter{}
+
+	steps := []struct {
+		name          string
+		elapsed       time.Duration
+		nonce         *VerifierNonce
+		userData      []byte
+		expectedCalls int
+	}{
+		{"first build collects evidence", 0, nonce, []byte("user data"), 1},
+		{"identical build within ttl is cached", time.Second, nonce, []byte("user data"), 1},
+		{"copy of the nonce is cached", time.Second, &VerifierNonce{Val: []byte("val"), Iat: []byte("iat"), Signature: []byte("sig")}, []byte("user data"), 1},
+		{"different user data is collected", time.Second, nonce, []byte("other user data"), 2},
+		{"previous user data was invalidated", time.Second, nonce, []byte("user data"), 3},
+		{"different nonce is collected", time.Second, otherNonce, []byte("user data"), 4},
+		{"no nonce is collected", time.Second, nil, []byte("user data"), 5},
+		{"expired evidence is collected", 6 * time.Second, nil, []byte("user data"), 6},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.elapsed)
+		calls := buildWithCache(t, cache, adapter, step.nonce, step.userData)
+		if calls != step.expectedCalls {
+			t.Fatalf("%s: expected %d GetEvidence calls, got %d", step.name, step.expectedCalls, calls)
+		}
+	}
+}
+
+func TestEvidenceCacheSharedByBuilders(t *testing.T) {
+	cache, err := NewEvidenceCache(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// retries create a new builder with the same inputs
+	adapter := &testFlakyEvidenceAdapter{}
+	for i := 0; i < 3; i++ {
+		buildWithCache(t, cache, adapter, nil, []byte("user data"))
+	}
+
+	if adapter.calls != 1 {
+		t.Errorf("Expected 1 GetEvidence call, got %d", adapter.calls)
+	}
+}
+
+func TestEvidenceCacheErrorsNotCached(t *testing.T) {
+	cache, err := NewEvidenceCache(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adapter := &testFlakyEvidenceAdapter{failures: []error{errTestBusy}}
+	builder, err := NewEvidenceBuilder(WithEvidenceAdapter(adapter), WithEvidenceCache(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = builder.Build(); err == nil {
+		t.Fatal("Expected the first build to fail")
+	}
+
+	if calls := buildWithCache(t, cache, adapter, nil, nil); calls != 2 {
+		t.Errorf("Expected 2 GetEvidence calls, got %d", calls)
+	}
+}
+
+func TestNewEvidenceCacheInvalidTtl(t *testing.T) {
+	if _, err := NewEvidenceCache(0); err == nil {
+		t.Error("Expected an error for a zero ttl")
+	}
+
+	if _, err := NewEvidenceBuilder(WithEvidenceAdapter(&testCompositeEvidenceAdapter{}), WithEvidenceCache(nil)); err == nil {
+		t.Error("Expected an error for a nil evidence cache")
+	}
+}
@@ -0,0 +1,289 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// EvidenceFieldError describes an invalid field of evidence json (see ValidateEvidenceJSON).
+type EvidenceFieldError struct {
+	Field  string // the json path of the field (ex. "tdx.quote")
+	Reason string
+}
+
+func (e EvidenceFieldError) String() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// EvidenceValidationError is returned by ValidateEvidenceJSON and contains each invalid
+// field of the evidence.  It wraps ErrInvalidEvidence.
+type EvidenceValidationError struct {
+	Fields []EvidenceFieldError
+}
+
+func (e *EvidenceValidationError) Error() string {
+	fields := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		fields[i] = field.String()
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidEvidence, strings.Join(fields, "; "))
+}
+
+func (e *EvidenceValidationError) Unwrap() error {
+	return ErrInvalidEvidence
+}
+
+// evidenceFieldSchema describes a field of an evidence type (ex. the TDX quote).
+type evidenceFieldSchema struct {
+	required bool
+	validate func(raw json.RawMessage) string // returns the reason the field is invalid or ""
+}
+
+var (
+	evidenceBase64         = evidenceFieldSchema{validate: validateBase64Field}
+	evidenceRequiredBase64 = evidenceFieldSchema{required: true, validate: validateBase64Field}
+	evidenceNumber         = evidenceFieldSchema{validate: validateNumberField}
+	evidenceString         = evidenceFieldSchema{validate: validateStringField}
+	evidenceBool           = evidenceFieldSchema{validate: validateBoolField}
+	evidenceVerifierNonce  = evidenceFieldSchema{validate: validateVerifierNonceField}
+)
+
+// evidenceSchemas are the fields of the evidence created by the TDX and TPM adapters
+// (binary fields are base64 encoded).  The evidence of other types must be a json object.
+var evidenceSchemas = map[string]map[string]evidenceFieldSchema{
+	tdxEvidenceIdentifier: {
+		"quote":          evidenceRequiredBase64,
+		"runtime_data":   evidenceBase64,
+		"user_data":      evidenceBase64,
+		"event_log":      evidenceBase64,
+		"aux_blob":       evidenceBase64,
+		"verifier_nonce": evidenceVerifierNonce,
+	},
+	tpmEvidenceIdentifier: {
+		"quote":                       evidenceRequiredBase64,
+		"signature":                   evidenceRequiredBase64,
+		"pcrs":                        evidenceRequiredBase64,
+		"user_data":                   evidenceBase64,
+		"ima_logs":                    evidenceBase64,
+		"uefi_event_logs":             evidenceBase64,
+		"ima_logs_digest":             evidenceBase64,
+		"uefi_event_logs_digest":      evidenceBase64,
+		"event_logs_digest_algorithm": evidenceString,
+		"ak_certificate_der":          evidenceBase64,
+		"verifier_nonce":              evidenceVerifierNonce,
+		"clock":                       evidenceNumber,
+		"reset_count":                 evidenceNumber,
+		"restart_count":               evidenceNumber,
+		"clock_safe":                  evidenceBool,
+		"firmware_version":            evidenceNumber,
+	},
+}
+
+// ValidateEvidenceJSON checks hand-assembled evidence json (i.e., the body of requests to
+// the Trust Authority's /appraisal/v2/attest endpoint) before it is sent.  It verifies
+// that at least one evidence type is present, that the required fields of TDX and TPM
+// evidence are present and base64 encoded, and that the request options (policy_ids,
+// token_signing_alg, etc.) are valid.  An *EvidenceValidationError listing each invalid
+// field is returned when the evidence is not valid.
+func ValidateEvidenceJSON(evidenceJson []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(evidenceJson, &fields); err != nil {
+		return &EvidenceValidationError{Fields: []EvidenceFieldError{{Field: "$", Reason: fmt.Sprintf("not a json object: %v", err)}}}
+	}
+
+	var fieldErrors []EvidenceFieldError
+	addError := func(field string, format string, args ...interface{}) {
+		fieldErrors = append(fieldErrors, EvidenceFieldError{Field: field, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	evidenceCount := 0
+	for _, name := range sortedKeys(fields) {
+		raw := fields[name]
+		switch name {
+		case "policy_ids":
+			var policyIds []string
+			if err := json.Unmarshal(raw, &policyIds); err != nil {
+				addError(name, "must be an array of policy id strings")
+				continue
+			}
+			for i, policyId := range policyIds {
+				if _, err := uuid.Parse(policyId); err != nil {
+					addError(fmt.Sprintf("%s[%d]", name, i), "%q is not a valid uuid", policyId)
+				}
+			}
+		case "policy_must_match":
+			if reason := validateBoolField(raw); reason != "" {
+				addError(name, reason)
+			}
+		case "token_signing_alg":
+			var alg string
+			if err := json.Unmarshal(raw, &alg); err != nil || !ValidateTokenSigningAlg(alg) {
+				addError(name, "must be one of %v", tokenSigningAlgs)
+			}
+		case "token_audience":
+			var audience string
+			if err := json.Unmarshal(raw, &audience); err != nil || !ValidateTokenAudience(audience) {
+				addError(name, "must be at most %d printable characters without whitespace", MaxTokenAudienceLength)
+			}
+		case "token_type":
+			var tokenType string
+			if err := json.Unmarshal(raw, &tokenType); err != nil || !ValidateTokenType(tokenType) {
+				addError(name, "must be one of %v", tokenTypes)
+			}
+		case "context":
+			var evidenceContext string
+			if err := json.Unmarshal(raw, &evidenceContext); err != nil {
+				addError(name, "must be a string")
+			} else if err = WithEvidenceContext(evidenceContext)(&evidenceBuilder{}); err != nil {
+				addError(name, "%v", err)
+			}
+		case "claims_request":
+			var claims map[string]string
+			if err := json.Unmarshal(raw, &claims); err != nil {
+				addError(name, "must be a json object with string values")
+			} else if err = ValidateClaimsRequest(claims); err != nil {
+				addError(name, "%v", err)
+			}
+		case "platform_info":
+			var platformInfo PlatformInfo
+			if err := json.Unmarshal(raw, &platformInfo); err != nil {
+				addError(name, "must be a json object with 'hostname', 'cloud_provider' and 'instance_id' strings")
+			}
+		default:
+			evidenceCount++
+			fieldErrors = append(fieldErrors, validateEvidenceType(name, raw)...)
+		}
+	}
+
+	if evidenceCount == 0 {
+		addError("$", "at least one evidence type (ex. %q or %q) is required", tdxEvidenceIdentifier, tpmEvidenceIdentifier)
+	}
+
+	if len(fieldErrors) != 0 {
+		return &EvidenceValidationError{Fields: fieldErrors}
+	}
+
+	return nil
+}
+
+// validateEvidenceType validates the evidence of type 'identifier' against its schema (if
+// any) and returns the invalid fields.
+func validateEvidenceType(identifier string, raw json.RawMessage) []EvidenceFieldError {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return []EvidenceFieldError{{Field: identifier, Reason: "must be a json object"}}
+	}
+
+	schema, ok := evidenceSchemas[identifier]
+	if !ok {
+		return nil
+	}
+
+	var fieldErrors []EvidenceFieldError
+	for _, name := range sortedKeys(schema) {
+		if _, exists := fields[name]; !exists && schema[name].required {
+			fieldErrors = append(fieldErrors, EvidenceFieldError{Field: identifier + "." + name, Reason: "is required"})
+		}
+	}
+
+	for _, name := range sortedKeys(fields) {
+		fieldSchema, ok := schema[name]
+		if !ok {
+			fieldErrors = append(fieldErrors, EvidenceFieldError{Field: identifier + "." + name, Reason: "is not a known field"})
+			continue
+		}
+
+		// null is treated as an omitted field (ex. empty runtime_data)
+		if bytes.Equal(fields[name], []byte("null")) {
+			if fieldSchema.required {
+				fieldErrors = append(fieldErrors, EvidenceFieldError{Field: identifier + "." + name, Reason: "is required"})
+			}
+			continue
+		}
+
+		if reason := fieldSchema.validate(fields[name]); reason != "" {
+			fieldErrors = append(fieldErrors, EvidenceFieldError{Field: identifier + "." + name, Reason: reason})
+		}
+	}
+
+	return fieldErrors
+}
+
+func validateBase64Field(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "must be a base64 encoded string"
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+		return fmt.Sprintf("is not valid base64: %v", err)
+	}
+
+	return ""
+}
+
+func validateNumberField(raw json.RawMessage) string {
+	var n uint64
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return "must be a non-negative integer"
+	}
+	return ""
+}
+
+func validateStringField(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "must be a string"
+	}
+	return ""
+}
+
+func validateBoolField(raw json.RawMessage) string {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return "must be a boolean"
+	}
+	return ""
+}
+
+func validateVerifierNonceField(raw json.RawMessage) string {
+	var nonce map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &nonce); err != nil {
+		return "must be a json object with 'val', 'iat' and 'signature'"
+	}
+
+	for _, name := range []string{"val", "iat", "signature"} {
+		value, ok := nonce[name]
+		if !ok {
+			return fmt.Sprintf("is missing '%s'", name)
+		}
+
+		if reason := validateBase64Field(value); reason != "" {
+			return fmt.Sprintf("'%s' %s", name, reason)
+		}
+	}
+
+	return ""
+}
+
+// sortedKeys returns the keys of 'm' in sorted order so that validation errors are
+// reported in a consistent order.
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,184 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+const (
+	validTdxEvidenceJson = `{
+		"tdx": {
+			"quote": "AwACAAAAAAA=",
+			"runtime_data": "cnVudGltZQ==",
+			"user_data": null,
+			"verifier_nonce": {"val": "dmFs", "iat": "aWF0", "signature": "c2ln"}
+		},
+		"policy_ids": ["4b2f1c9e-8e6a-4d2b-9a54-0c3f8a7d6e21"],
+		"policy_must_match": true,
+		"token_signing_alg": "PS384"
+	}`
+
+	validTpmEvidenceJson = `{
+		"tpm": {
+			"quote": "cXVvdGU=",
+			"signature": "c2lnbmF0dXJl",
+			"pcrs": "cGNycw==",
+			"ima_logs": "aW1h",
+			"event_logs_digest_algorithm": "SHA256",
+			"clock": 12345,
+			"clock_safe": true
+		},
+		"token_audience": "my-relying-party"
+	}`
+)
+
+func TestValidateEvidenceJSON_valid(t *testing.T) {
+	for name, evidenceJson := range map[string]string{
+		"tdx":   validTdxEvidenceJson,
+		"tpm":   validTpmEvidenceJson,
+		"other": `{"nvgpu": {"evidence": "anything"}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := ValidateEvidenceJSON([]byte(evidenceJson)); err != nil {
+				t.Errorf("Expected valid evidence, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateEvidenceJSON_marshaledEvidence(t *testing.T) {
+	evidence := CompositeEvidence{
+		Tdx: map[string]interface{}{
+			"quote":        []byte{0x04, 0x00, 0x02, 0x00},
+			"event_log":    []byte("event log"),
+			"user_data":    []byte("user data"),
+			"runtime_data": []byte("runtime data"),
+		},
+		PolicyIds:       []uuid.UUID{uuid.New()},
+		TokenSigningAlg: RS256,
+	}
+
+	evidenceJson, err := MarshalEvidence(&evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ValidateEvidenceJSON(evidenceJson); err != nil {
+		t.Errorf("Expected the marshaled evidence to be valid, got %v", err)
+	}
+}
+
+func TestValidateEvidenceJSON_invalid(t *testing.T) {
+	tests := map[string]struct {
+		evidenceJson   string
+		expectedFields []string
+	}{
+		"not an object": {
+			evidenceJson:   `["tdx"]`,
+			expectedFields: []string{"$"},
+		},
+		"no evidence": {
+			evidenceJson:   `{"policy_ids": ["4b2f1c9e-8e6a-4d2b-9a54-0c3f8a7d6e21"]}`,
+			expectedFields: []string{"$"},
+		},
+		"url encoded quote": {
+			evidenceJson:   `{"tdx": {"quote": "AwACAAAA-_8="}}`,
+			expectedFields: []string{"tdx.quote"},
+		},
+		"misspelled quote": {
+			evidenceJson:   `{"tdx": {"qoute": "AwACAAAAAAA="}}`,
+			expectedFields: []string{"tdx.quote", "tdx.qoute"},
+		},
+		"null quote": {
+			evidenceJson:   `{"tdx": {"quote": null}}`,
+			expectedFields: []string{"tdx.quote"},
+		},
+		"quote as byte array": {
+			evidenceJson:   `{"tdx": {"quote": [3, 0, 2, 0]}}`,
+			expectedFields: []string{"tdx.quote"},
+		},
+		"tdx is not an object": {
+			evidenceJson:   `{"tdx": "AwACAAAAAAA="}`,
+			expectedFields: []string{"tdx"},
+		},
+		"missing tpm signature": {
+			evidenceJson:   `{"tpm": {"quote": "cXVvdGU=", "pcrs": "cGNycw=="}}`,
+			expectedFields: []string{"tpm.signature"},
+		},
+		"negative clock": {
+			evidenceJson:   `{"tpm": {"quote": "cXVvdGU=", "signature": "c2ln", "pcrs": "cGNycw==", "clock": -1}}`,
+			expectedFields: []string{"tpm.clock"},
+		},
+		"clock as string": {
+			evidenceJson:   `{"tpm": {"quote": "cXVvdGU=", "signature": "c2ln", "pcrs": "cGNycw==", "clock": "12345"}}`,
+			expectedFields: []string{"tpm.clock"},
+		},
+		"nonce missing iat": {
+			evidenceJson:   `{"tdx": {"quote": "AwACAAAAAAA=", "verifier_nonce": {"val": "dmFs", "signature": "c2ln"}}}`,
+			expectedFields: []string{"tdx.verifier_nonce"},
+		},
+		"invalid policy id": {
+			evidenceJson:   `{"tdx": {"quote": "AwACAAAAAAA="}, "policy_ids": ["4b2f1c9e-8e6a-4d2b-9a54-0c3f8a7d6e21", "my-policy"]}`,
+			expectedFields: []string{"policy_ids[1]"},
+		},
+		"policy_must_match as string": {
+			evidenceJson:   `{"tdx": {"quote": "AwACAAAAAAA="}, "policy_must_match": "true"}`,
+			expectedFields: []string{"policy_must_match"},
+		},
+		"lowercase token signing alg": {
+			evidenceJson:   `{"tdx": {"quote": "AwACAAAAAAA="}, "token_signing_alg": "ps384"}`,
+			expectedFields: []string{"token_signing_alg"},
+		},
+		"unknown token type": {
+			evidenceJson:   `{"tdx": {"quote": "AwACAAAAAAA="}, "token_type": "jwe"}`,
+			expectedFields: []string{"token_type"},
+		},
+		"invalid claims request key": {
+			evidenceJson:   `{"tdx": {"quote": "AwACAAAAAAA="}, "claims_request": {"tenant id": "acme"}}`,
+			expectedFields: []string{"claims_request"},
+		},
+		"claims request with non-string value": {
+			evidenceJson:   `{"tdx": {"quote": "AwACAAAAAAA="}, "claims_request": {"tenant": 1}}`,
+			expectedFields: []string{"claims_request"},
+		},
+		"token audience with whitespace": {
+			evidenceJson:   `{"tdx": {"quote": "AwACAAAAAAA="}, "token_audience": "my relying party"}`,
+			expectedFields: []string{"token_audience"},
+		},
+		"multiple errors": {
+			evidenceJson:   `{"tdx": {"quote": "%%%", "user_data": "dXNlcg"}, "tpm": {"quote": "cXVvdGU="}}`,
+			expectedFields: []string{"tdx.quote", "tdx.user_data", "tpm.pcrs", "tpm.signature"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateEvidenceJSON([]byte(tc.evidenceJson))
+			if !errors.Is(err, ErrInvalidEvidence) {
+				t.Fatalf("Expected ErrInvalidEvidence, got %v", err)
+			}
+
+			var validationErr *EvidenceValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected an EvidenceValidationError, got %T", err)
+			}
+
+			if len(validationErr.Fields) != len(tc.expectedFields) {
+				t.Fatalf("Expected fields %v, got %v", tc.expectedFields, validationErr.Fields)
+			}
+
+			for i, field := range validationErr.Fields {
+				if field.Field != tc.expectedFields[i] {
+					t.Errorf("Expected field %q at index %d, got %q (%s)", tc.expectedFields[i], i, field.Field, field.Reason)
+				}
+			}
+		})
+	}
+}
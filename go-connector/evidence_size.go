@@ -0,0 +1,63 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// checkEvidenceSize returns ErrEvidenceTooLarge when the serialized request 'body' exceeds
+// Config.MaxEvidenceSize.  The error names the field of 'evidence' that contributes the
+// most to the request (ex. "tpm.ima_logs").
+func (ctr *trustAuthorityConnector) checkEvidenceSize(body []byte, evidence interface{}) error {
+	if ctr.cfg.MaxEvidenceSize <= 0 || len(body) <= ctr.cfg.MaxEvidenceSize {
+		return nil
+	}
+
+	err := fmt.Errorf("%w: %d bytes exceeds the maximum of %d bytes", ErrEvidenceTooLarge, len(body), ctr.cfg.MaxEvidenceSize)
+
+	b, marshalErr := MarshalEvidence(evidence)
+	if marshalErr != nil {
+		return err
+	}
+
+	if field, size := largestEvidenceField(b); field != "" {
+		err = fmt.Errorf("%w (the largest contributor is %q with %d bytes)", err, field, size)
+	}
+
+	return err
+}
+
+// largestEvidenceField returns the path (ex. "tpm.ima_logs") and size of the largest
+// field in the json object 'b', descending into the largest nested objects.  An empty
+// path is returned if 'b' is not a json object.
+func largestEvidenceField(b []byte) (string, int) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return "", 0
+	}
+
+	largest := ""
+	for name, value := range fields {
+		if largest == "" || len(value) > len(fields[largest]) ||
+			(len(value) == len(fields[largest]) && name < largest) {
+			largest = name
+		}
+	}
+
+	if largest == "" {
+		return "", 0
+	}
+
+	value := bytes.TrimSpace(fields[largest])
+	if nested, size := largestEvidenceField(value); nested != "" {
+		return largest + "." + nested, size
+	}
+
+	return largest, len(value)
+}
@@ -0,0 +1,118 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// testTpmEvidence has the same json fields as the TPM adapter's evidence.
+type testTpmEvidence struct {
+	Quote         []byte `json:"quote"`
+	ImaLogs       []byte `json:"ima_logs,omitempty"`
+	UefiEventLogs []byte `json:"uefi_event_logs,omitempty"`
+}
+
+func TestAttestEvidence_maxEvidenceSize(t *testing.T) {
+	tests := []struct {
+		name                string
+		evidence            *CompositeEvidence
+		expectedContributor string
+	}{
+		{
+			name: "Oversized IMA logs",
+			evidence: &CompositeEvidence{
+				Tdx: map[string]interface{}{"quote": "cXVvdGU="},
+				Tpm: testTpmEvidence{
+					Quote:         []byte("quote"),
+					ImaLogs:       bytes.Repeat([]byte("i"), 4096),
+					UefiEventLogs: bytes.Repeat([]byte("u"), 1024),
+				},
+			},
+			expectedContributor: `"tpm.ima_logs"`,
+		},
+		{
+			name: "Oversized UEFI event logs",
+			evidence: &CompositeEvidence{
+				Tpm: testTpmEvidence{
+					Quote:         []byte("quote"),
+					ImaLogs:       bytes.Repeat([]byte("i"), 1024),
+					UefiEventLogs: bytes.Repeat([]byte("u"), 4096),
+				},
+			},
+			expectedContributor: `"tpm.uefi_event_logs"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctr, mux, _, teardown := setup()
+			defer teardown()
+
+			mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+				t.Error("Oversized evidence should not be sent to the Trust Authority")
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+			})
+
+			ctr.(*trustAuthorityConnector).cfg.MaxEvidenceSize = 2048
+
+			_, err := ctr.AttestEvidence(tc.evidence, "", "")
+			if !errors.Is(err, ErrEvidenceTooLarge) {
+				t.Fatalf("Expected ErrEvidenceTooLarge, got %v", err)
+			}
+
+			if !strings.Contains(err.Error(), tc.expectedContributor) {
+				t.Errorf("Expected %s to be named as the largest contributor, got %q", tc.expectedContributor, err.Error())
+			}
+		})
+	}
+}
+
+func TestAttestEvidence_withinMaxEvidenceSize(t *testing.T) {
+	ctr, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"token":"` + token + `"}`))
+	})
+
+	ctr.(*trustAuthorityConnector).cfg.MaxEvidenceSize = 2048
+
+	evidence := &CompositeEvidence{
+		Tpm: testTpmEvidence{Quote: []byte("quote")},
+	}
+
+	_, err := ctr.AttestEvidence(evidence, "", "")
+	if err != nil {
+		t.Errorf("AttestEvidence returned unexpected error: %v", err)
+	}
+}
+
+func TestGetToken_maxEvidenceSize(t *testing.T) {
+	ctr, _, _, teardown := setup()
+	defer teardown()
+
+	ctr.(*trustAuthorityConnector).cfg.MaxEvidenceSize = 1024
+
+	evidence := &Evidence{
+		Evidence: []byte("quote"),
+		EventLog: bytes.Repeat([]byte("e"), 2048),
+	}
+
+	_, err := ctr.GetToken(GetTokenArgs{nil, evidence, nil, "req1", attestEndpoint, "", false})
+	if !errors.Is(err, ErrEvidenceTooLarge) {
+		t.Fatalf("Expected ErrEvidenceTooLarge, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), `"event_log"`) {
+		t.Errorf("Expected the event log to be named as the largest contributor, got %q", err.Error())
+	}
+}
@@ -0,0 +1,319 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// failoverConnector sends the requests to the Trust Authority API to the connector of
+// the active endpoint (see Config.FailoverEndpoints).  The first endpoint is the primary
+// API URL.  Token verification uses the base URL and is not affected by failover.
+type failoverConnector struct {
+	endpoints        []string
+	connectors       []Connector
+	threshold        int
+	recoveryInterval time.Duration
+	now              func() time.Time
+
+	mutex          sync.Mutex
+	active         int       // the index of the endpoint requests are sent to
+	failures       int       // the consecutive failures of the active endpoint
+	recoveryCheck  time.Time // the last time the primary endpoint was checked
+	checkingHealth bool      // true while the primary endpoint is being pinged
+
+	nonces map[string]issuedNonce // the endpoint that issued each nonce (by its signature)
+}
+
+// issuedNonce is the endpoint that issued a nonce (see failoverConnector.GetNonce).
+type issuedNonce struct {
+	index  int
+	issued time.Time
+}
+
+// nonceAffinity is how long the endpoint that issued a nonce is remembered.  A nonce is
+// only valid at the endpoint that issued it, so evidence containing the nonce is sent to
+// that endpoint even if the connector has failed over since.
+const nonceAffinity = 10 * time.Minute
+
+// newFailoverConnector returns a failoverConnector that uses 'primary' for cfg.ApiUrl and
+// a new connector for each of cfg.FailoverEndpoints.
+func newFailoverConnector(cfg *Config, primary Connector) (Connector, error) {
+	fc := &failoverConnector{
+		endpoints:        append([]string{cfg.ApiUrl}, cfg.FailoverEndpoints...),
+		connectors:       []Connector{primary},
+		threshold:        DefaultFailoverThreshold,
+		recoveryInterval: DefaultFailoverRecoverySeconds * time.Second,
+		now:              time.Now,
+		nonces:           map[string]issuedNonce{},
+	}
+
+	if cfg.FailoverThreshold > 0 {
+		fc.threshold = cfg.FailoverThreshold
+	}
+
+	if cfg.FailoverRecoveryInterval > 0 {
+		fc.recoveryInterval = cfg.FailoverRecoveryInterval
+	}
+
+	for _, endpoint := range cfg.FailoverEndpoints {
+		endpointCfg := *cfg
+		endpointCfg.ApiUrl = endpoint
+		endpointCfg.FailoverEndpoints = nil
+
+		ctr, err := New(&endpointCfg)
+		if err != nil {
+			return nil, err
+		}
+		fc.connectors = append(fc.connectors, ctr)
+	}
+
+	return fc, nil
+}
+
+// activeConnector returns the connector (and its index) that requests are sent to.  While
+// failed over, the primary endpoint is pinged every recoveryInterval and becomes active
+// again once it is healthy.
+func (fc *failoverConnector) activeConnector() (Connector, int) {
+	fc.mutex.Lock()
+	if fc.active == 0 || fc.checkingHealth || fc.now().Sub(fc.recoveryCheck) < fc.recoveryInterval {
+		defer fc.mutex.Unlock()
+		return fc.connectors[fc.active], fc.active
+	}
+	fc.checkingHealth = true
+	fc.mutex.Unlock()
+
+	err := fc.connectors[0].Ping()
+
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	fc.checkingHealth = false
+	fc.recoveryCheck = fc.now()
+	if err == nil {
+		logrus.Infof("Trust Authority endpoint %s recovered, failing back from %s", fc.endpoints[0], fc.endpoints[fc.active])
+		fc.active = 0
+		fc.failures = 0
+	} else {
+		logrus.WithError(err).Debugf("Trust Authority endpoint %s has not recovered", fc.endpoints[0])
+	}
+
+	return fc.connectors[fc.active], fc.active
+}
+
+// report records the result of a request sent to the endpoint at 'index' and fails over
+// to the next endpoint after 'threshold' consecutive failures.
+func (fc *failoverConnector) report(index int, err error) {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	// ignore the results of requests sent before the active endpoint changed
+	if index != fc.active {
+		return
+	}
+
+	if !isEndpointFailure(err) {
+		fc.failures = 0
+		return
+	}
+
+	fc.failures++
+	if fc.failures < fc.threshold {
+		return
+	}
+
+	next := (fc.active + 1) % len(fc.endpoints)
+	logrus.Warnf("Failing over from Trust Authority endpoint %s to %s after %d consecutive failures", fc.endpoints[fc.active], fc.endpoints[next], fc.failures)
+	if fc.active == 0 {
+		fc.recoveryCheck = fc.now()
+	}
+	fc.active = next
+	fc.failures = 0
+}
+
+// isEndpointFailure returns true if 'err' may be caused by the endpoint being unavailable
+// (i.e., transport errors and 5xx/429 responses).  Other errors (ex. invalid evidence or
+// API key) are not endpoint failures since they would also fail with the other endpoints.
+func isEndpointFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var statusErr *HttpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// failover sends 'request' to the endpoint that issued one of 'nonces' (if any) or the
+// active endpoint, and records its result.
+func failover[T any](fc *failoverConnector, nonces []*VerifierNonce, request func(Connector) (T, error)) (T, error) {
+	ctr, index, ok := fc.nonceConnector(nonces)
+	if !ok {
+		ctr, index = fc.activeConnector()
+	}
+	result, err := request(ctr)
+	fc.report(index, err)
+	return result, err
+}
+
+// nonceConnector returns the connector (and its index) of the endpoint that issued one of
+// 'nonces', or false if the nonces were not issued by this connector.
+func (fc *failoverConnector) nonceConnector(nonces []*VerifierNonce) (Connector, int, bool) {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	for _, nonce := range nonces {
+		if nonce == nil {
+			continue
+		}
+
+		issued, ok := fc.nonces[string(nonce.Signature)]
+		if ok && fc.now().Sub(issued.issued) < nonceAffinity {
+			return fc.connectors[issued.index], issued.index, true
+		}
+	}
+
+	return nil, 0, false
+}
+
+// recordNonce remembers that 'nonce' was issued by the endpoint at 'index' and forgets
+// the nonces that are older than nonceAffinity.
+func (fc *failoverConnector) recordNonce(nonce *VerifierNonce, index int) {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	now := fc.now()
+	for signature, issued := range fc.nonces {
+		if now.Sub(issued.issued) >= nonceAffinity {
+			delete(fc.nonces, signature)
+		}
+	}
+
+	fc.nonces[string(nonce.Signature)] = issuedNonce{index: index, issued: now}
+}
+
+// evidenceNonces returns the verifier nonces of each evidence type in 'evidence' (ex.
+// "tdx" and "tpm" evidence built with WithVerifierNonce).
+func (fc *failoverConnector) evidenceNonces(evidence interface{}) []*VerifierNonce {
+	fc.mutex.Lock()
+	noNonces := len(fc.nonces) == 0
+	fc.mutex.Unlock()
+	if noNonces {
+		return nil
+	}
+
+	evidenceJson, err := MarshalEvidence(evidence)
+	if err != nil {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(evidenceJson, &fields); err != nil {
+		return nil
+	}
+
+	var nonces []*VerifierNonce
+	for _, raw := range fields {
+		var e struct {
+			VerifierNonce *VerifierNonce `json:"verifier_nonce"`
+		}
+		if err = json.Unmarshal(raw, &e); err == nil && e.VerifierNonce != nil {
+			nonces = append(nonces, e.VerifierNonce)
+		}
+	}
+
+	return nonces
+}
+
+func (fc *failoverConnector) GetTokenSigningCertificates() ([]byte, error) {
+	return fc.connectors[0].GetTokenSigningCertificates()
+}
+
+// GetNonce requests a nonce from the active endpoint and remembers the endpoint so that
+// the evidence containing the nonce is sent to the same endpoint.
+func (fc *failoverConnector) GetNonce(args GetNonceArgs) (GetNonceResponse, error) {
+	ctr, index := fc.activeConnector()
+	response, err := ctr.GetNonce(args)
+	fc.report(index, err)
+	if err == nil && response.Nonce != nil {
+		fc.recordNonce(response.Nonce, index)
+	}
+	return response, err
+}
+
+func (fc *failoverConnector) GetToken(args GetTokenArgs) (GetTokenResponse, error) {
+	return failover(fc, []*VerifierNonce{args.Nonce}, func(ctr Connector) (GetTokenResponse, error) {
+		return ctr.GetToken(args)
+	})
+}
+
+// Attest requests the nonce and token from the same endpoint (see trustAuthorityConnector.Attest).
+func (fc *failoverConnector) Attest(args AttestArgs) (AttestResponse, error) {
+	return failover(fc, nil, func(ctr Connector) (AttestResponse, error) {
+		return ctr.Attest(args)
+	})
+}
+
+func (fc *failoverConnector) VerifyToken(token string) (*jwt.Token, error) {
+	return fc.connectors[0].VerifyToken(token)
+}
+
+func (fc *failoverConnector) AttestEvidence(evidence interface{}, cloudProvider string, reqId string) (AttestResponse, error) {
+	return failover(fc, fc.evidenceNonces(evidence), func(ctr Connector) (AttestResponse, error) {
+		return ctr.AttestEvidence(evidence, cloudProvider, reqId)
+	})
+}
+
+func (fc *failoverConnector) AttestEvidenceStream(evidence interface{}, cloudProvider string, reqId string, onStatus func(AttestStatus)) (AttestResponse, error) {
+	return failover(fc, fc.evidenceNonces(evidence), func(ctr Connector) (AttestResponse, error) {
+		return ctr.AttestEvidenceStream(evidence, cloudProvider, reqId, onStatus)
+	})
+}
+
+func (fc *failoverConnector) EvaluateEvidence(evidence interface{}, policyIds []uuid.UUID, cloudProvider string, reqId string) (EvaluateResponse, error) {
+	return failover(fc, fc.evidenceNonces(evidence), func(ctr Connector) (EvaluateResponse, error) {
+		return ctr.EvaluateEvidence(evidence, policyIds, cloudProvider, reqId)
+	})
+}
+
+func (fc *failoverConnector) GetAKCertificate(ekCert *x509.Certificate, akTpmtPublic []byte) ([]byte, []byte, []byte, error) {
+	var secret, credentialBlob []byte
+	akCert, err := failover(fc, nil, func(ctr Connector) ([]byte, error) {
+		var akCert []byte
+		var err error
+		akCert, secret, credentialBlob, err = ctr.GetAKCertificate(ekCert, akTpmtPublic)
+		return akCert, err
+	})
+	return akCert, secret, credentialBlob, err
+}
+
+func (fc *failoverConnector) Ping() error {
+	_, err := failover(fc, nil, func(ctr Connector) (struct{}, error) {
+		return struct{}{}, ctr.Ping()
+	})
+	return err
+}
+
+func (fc *failoverConnector) GetTokenSigningCaBundle() ([]*x509.Certificate, error) {
+	return fc.connectors[0].GetTokenSigningCaBundle()
+}
+
+func (fc *failoverConnector) GetCRL(crlUrl string) (*x509.RevocationList, error) {
+	return fc.connectors[0].GetCRL(crlUrl)
+}
@@ -0,0 +1,250 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// newFailoverTestServer returns a server that responds to nonce requests with
+// '*statusCode' and counts the requests.
+func newFailoverTestServer(t *testing.T, statusCode *int32, requests *int32) *httptest.Server {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		status := int(atomic.LoadInt32(statusCode))
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"val":"dmFs","iat":"aWF0","signature":"c2ln"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestFailoverConnector(t *testing.T, primaryUrl string, secondaryUrl string) *failoverConnector {
+	retryMax := 0
+	ctr, err := NewFromOptions(
+		WithApiUrl(primaryUrl),
+		WithApiKey("YXBpa2V5"),
+		WithTlsConfig(&tls.Config{InsecureSkipVerify: true}),
+		WithRetryConfig(&RetryConfig{RetryMax: &retryMax}),
+		WithFailoverEndpoints([]string{secondaryUrl}),
+		WithFailoverPolicy(2, time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fc, ok := ctr.(*failoverConnector)
+	if !ok {
+		t.Fatalf("Expected a failover connector, got %T", ctr)
+	}
+	return fc
+}
+
+func TestFailoverConnector(t *testing.T) {
+	var primaryStatus, secondaryStatus int32 = http.StatusServiceUnavailable, http.StatusOK
+	var primaryRequests, secondaryRequests int32
+	primary := newFailoverTestServer(t, &primaryStatus, &primaryRequests)
+	secondary := newFailoverTestServer(t, &secondaryStatus, &secondaryRequests)
+
+	fc := newTestFailoverConnector(t, primary.URL, secondary.URL)
+	now := time.Now()
+	fc.now = func() time.Time { return now }
+
+	// the primary is down, requests fail until the threshold is reached
+	for i := 0; i < 2; i++ {
+		if _, err := fc.GetNonce(GetNonceArgs{}); err == nil {
+			t.Fatal("Expected GetNonce to fail while the primary is down")
+		}
+	}
+
+	// subsequent requests are sent to the secondary
+	if _, err := fc.GetNonce(GetNonceArgs{}); err != nil {
+		t.Fatalf("Expected GetNonce to fail over to the secondary, got %v", err)
+	}
+
+	if primaryRequests != 2 || secondaryRequests != 1 {
+		t.Fatalf("Expected 2 primary and 1 secondary requests, got %d and %d", primaryRequests, secondaryRequests)
+	}
+
+	// the primary is pinged after the recovery interval and is still down
+	now = now.Add(time.Minute)
+	if _, err := fc.GetNonce(GetNonceArgs{}); err != nil {
+		t.Fatalf("GetNonce returned unexpected error: %v", err)
+	}
+
+	if primaryRequests != 3 || secondaryRequests != 2 {
+		t.Fatalf("Expected 3 primary and 2 secondary requests, got %d and %d", primaryRequests, secondaryRequests)
+	}
+
+	// the primary is not pinged again before the recovery interval
+	atomic.StoreInt32(&primaryStatus, http.StatusOK)
+	if _, err := fc.GetNonce(GetNonceArgs{}); err != nil {
+		t.Fatalf("GetNonce returned unexpected error: %v", err)
+	}
+
+	if primaryRequests != 3 || secondaryRequests != 3 {
+		t.Fatalf("Expected 3 primary and 3 secondary requests, got %d and %d", primaryRequests, secondaryRequests)
+	}
+
+	// requests return to the primary once it is healthy
+	now = now.Add(time.Minute)
+	if _, err := fc.GetNonce(GetNonceArgs{}); err != nil {
+		t.Fatalf("GetNonce returned unexpected error: %v", err)
+	}
+
+	// the ping and the request
+	if primaryRequests != 5 || secondaryRequests != 3 {
+		t.Fatalf("Expected 5 primary and 3 secondary requests, got %d and %d", primaryRequests, secondaryRequests)
+	}
+}
+
+func TestFailoverConnectorRequestErrors(t *testing.T) {
+	var primaryStatus, secondaryStatus int32 = http.StatusUnauthorized, http.StatusOK
+	var primaryRequests, secondaryRequests int32
+	primary := newFailoverTestServer(t, &primaryStatus, &primaryRequests)
+	secondary := newFailoverTestServer(t, &secondaryStatus, &secondaryRequests)
+
+	fc := newTestFailoverConnector(t, primary.URL, secondary.URL)
+
+	// an invalid API key is not an endpoint failure
+	for i := 0; i < 3; i++ {
+		if _, err := fc.GetNonce(GetNonceArgs{}); !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("Expected ErrUnauthorized, got %v", err)
+		}
+	}
+
+	if primaryRequests != 3 || secondaryRequests != 0 {
+		t.Fatalf("Expected 3 primary and no secondary requests, got %d and %d", primaryRequests, secondaryRequests)
+	}
+}
+
+func TestFailoverConnectorNonceAffinity(t *testing.T) {
+	var primaryStatus, secondaryStatus int32 = http.StatusOK, http.StatusOK
+	var primaryRequests, secondaryRequests int32
+	primary := newFailoverTestServer(t, &primaryStatus, &primaryRequests)
+	secondary := newFailoverTestServer(t, &secondaryStatus, &secondaryRequests)
+
+	fc := newTestFailoverConnector(t, primary.URL, secondary.URL)
+	now := time.Now()
+	fc.now = func() time.Time { return now }
+
+	nonceResponse, err := fc.GetNonce(GetNonceArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fail over to the secondary after the nonce was issued by the primary
+	atomic.StoreInt32(&primaryStatus, http.StatusServiceUnavailable)
+	for i := 0; i < 2; i++ {
+		if _, err = fc.GetNonce(GetNonceArgs{}); err == nil {
+			t.Fatal("Expected GetNonce to fail while the primary is down")
+		}
+	}
+	atomic.StoreInt32(&primaryStatus, http.StatusOK)
+
+	// evidence containing the primary's nonce is sent to the primary
+	evidence := &CompositeEvidence{
+		Tdx: map[string]interface{}{"quote": []byte("quote"), "verifier_nonce": nonceResponse.Nonce},
+	}
+	fc.AttestEvidence(evidence, "", "")
+
+	if primaryRequests != 4 || secondaryRequests != 0 {
+		t.Fatalf("Expected 4 primary and no secondary requests, got %d and %d", primaryRequests, secondaryRequests)
+	}
+
+	// other requests are sent to the secondary
+	fc.AttestEvidence(&CompositeEvidence{Tdx: map[string]interface{}{"quote": []byte("quote")}}, "", "")
+
+	if primaryRequests != 4 || secondaryRequests != 1 {
+		t.Fatalf("Expected 4 primary and 1 secondary requests, got %d and %d", primaryRequests, secondaryRequests)
+	}
+
+	// the nonce's endpoint is forgotten after nonceAffinity
+	now = now.Add(nonceAffinity)
+	fc.recoveryCheck = now
+	fc.AttestEvidence(evidence, "", "")
+
+	if primaryRequests != 4 || secondaryRequests != 2 {
+		t.Fatalf("Expected 4 primary and 2 secondary requests, got %d and %d", primaryRequests, secondaryRequests)
+	}
+}
+
+func TestIsEndpointFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"No Error", nil, false},
+		{"Transport Error", fmt.Errorf("Request failed: %w", &url.Error{Op: "Get", URL: "https://localhost", Err: errors.New("connection refused")}), true},
+		{"Internal Server Error", newHttpStatusError(http.StatusInternalServerError, errors.New("failed")), true},
+		{"Service Unavailable", newHttpStatusError(http.StatusServiceUnavailable, errors.New("failed")), true},
+		{"Too Many Requests", newHttpStatusError(http.StatusTooManyRequests, errors.New("failed")), true},
+		{"Bad Request", newHttpStatusError(http.StatusBadRequest, errors.New("failed")), false},
+		{"Unauthorized", newHttpStatusError(http.StatusUnauthorized, errors.New("failed")), false},
+		{"Not Found", newHttpStatusError(http.StatusNotFound, errors.New("failed")), false},
+		{"Invalid Evidence", ErrInvalidEvidence, false},
+		{"Decode Error", errors.New("Failed to decode json"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if isEndpointFailure(tt.err) != tt.expected {
+				t.Fatalf("Expected isEndpointFailure to return %t for %v", tt.expected, tt.err)
+			}
+		})
+	}
+}
+
+func TestHttpStatusError(t *testing.T) {
+	err := newHttpStatusError(http.StatusUnauthorized, errors.New("failed"))
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Expected ErrUnauthorized, got %v", err)
+	}
+
+	var statusErr *HttpStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected an HttpStatusError with status code 401, got %v", err)
+	}
+}
+
+func TestWithFailoverEndpoints(t *testing.T) {
+	cfg := Config{}
+	if err := WithFailoverEndpoints([]string{"https://eu.trustauthority.example.com/"})(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.FailoverEndpoints) != 1 || cfg.FailoverEndpoints[0] != "https://eu.trustauthority.example.com" {
+		t.Errorf("Unexpected failover endpoints %v", cfg.FailoverEndpoints)
+	}
+
+	if err := WithFailoverEndpoints(nil)(&cfg); err == nil {
+		t.Error("Expected an error without failover endpoints")
+	}
+
+	if err := WithFailoverEndpoints([]string{"http://eu.trustauthority.example.com"})(&cfg); !errors.Is(err, ErrInvalidApiUrl) {
+		t.Errorf("Expected ErrInvalidApiUrl, got %v", err)
+	}
+
+	if err := WithFailoverPolicy(0, time.Minute)(&cfg); err == nil {
+		t.Error("Expected an error for a failover threshold of zero")
+	}
+
+	if _, err := New(&Config{ApiUrl: "https://us.trustauthority.example.com", FailoverEndpoints: []string{"not a url"}}); !errors.Is(err, ErrInvalidApiUrl) {
+		t.Errorf("Expected ErrInvalidApiUrl, got %v", err)
+	}
+}
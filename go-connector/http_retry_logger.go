@@ -0,0 +1,56 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// HttpRetryLogger receives the connector's debug messages about retried requests (see
+// Config.HttpRetryLogger).  It is implemented by logrus loggers.
+type HttpRetryLogger interface {
+	Debugf(format string, args ...interface{})
+}
+
+// httpRetryLogger logs the retries of the connector's client to an HttpRetryLogger when
+// Config.HttpRetryLogger is provided.  The failed attempts and the wait before the next
+// attempt are logged by wrapping the client's Backoff and the retried requests by its
+// RequestLogHook.
+type httpRetryLogger struct {
+	logger   HttpRetryLogger
+	retryMax int
+}
+
+// backoff wraps 'backoff' to log the failed attempt (its status code when the server
+// responded) and the wait before it is retried.  retryablehttp only calls the Backoff
+// when the request will be retried.
+func (l *httpRetryLogger) backoff(backoff retryablehttp.Backoff) retryablehttp.Backoff {
+	return func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+		wait := backoff(min, max, attemptNum, resp)
+
+		// 'attemptNum' is zero for the first attempt
+		if resp != nil && resp.Request != nil {
+			l.logger.Debugf("%s %s (status: %d): attempt %d of %d failed, retrying in %v", resp.Request.Method, resp.Request.URL.Redacted(), resp.StatusCode, attemptNum+1, l.retryMax+1, wait)
+		} else {
+			l.logger.Debugf("Attempt %d of %d failed, retrying in %v", attemptNum+1, l.retryMax+1, wait)
+		}
+
+		return wait
+	}
+}
+
+// requestLogHook is the client's retryablehttp.RequestLogHook and logs each retried
+// request (i.e., not the first attempt).
+func (l *httpRetryLogger) requestLogHook(_ retryablehttp.Logger, req *http.Request, attemptNum int) {
+	if attemptNum == 0 {
+		return
+	}
+
+	l.logger.Debugf("%s %s: attempt %d of %d", req.Method, req.URL.Redacted(), attemptNum+1, l.retryMax+1)
+}
@@ -0,0 +1,108 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newRetryLoggingConnector returns a connector to a server that responds with 503 to the
+// first two nonce requests and logs retries to a logrus logger at 'level'.
+func newRetryLoggingConnector(t *testing.T, level logrus.Level) (Connector, *bytes.Buffer, *int32) {
+	var attempts int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"val":"","iat":"","signature":""}`))
+	}))
+	t.Cleanup(server.Close)
+
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&logs)
+	logger.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	logger.SetLevel(level)
+
+	retryWait := 10 * time.Millisecond
+	retryMax := 3
+	ctr, err := NewFromOptions(
+		WithApiUrl(server.URL),
+		WithApiKey("YXBpa2V5"),
+		WithTlsConfig(&tls.Config{InsecureSkipVerify: true}),
+		WithRetryConfig(&RetryConfig{RetryWaitMin: &retryWait, RetryWaitMax: &retryWait, RetryMax: &retryMax}),
+		WithHttpRetryLogger(logger),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ctr, &logs, &attempts
+}
+
+func TestHttpRetryLogger(t *testing.T) {
+	ctr, logs, attempts := newRetryLoggingConnector(t, logrus.DebugLevel)
+
+	if _, err := ctr.GetNonce(GetNonceArgs{}); err != nil {
+		t.Fatalf("GetNonce returned unexpected error: %v", err)
+	}
+
+	if atomic.LoadInt32(attempts) != 3 {
+		t.Errorf("expected 3 attempts, got %d", *attempts)
+	}
+
+	// each failed attempt and the retry that follows it are logged
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected two log lines per retry, got %q", logs.String())
+	}
+
+	for i := 0; i < 2; i++ {
+		failed, retry := lines[2*i], lines[2*i+1]
+		if !strings.Contains(failed, "level=debug") ||
+			!strings.Contains(failed, "GET ") ||
+			!strings.Contains(failed, "(status: 503)") ||
+			!strings.Contains(failed, fmt.Sprintf("attempt %d of 4 failed, retrying in 10ms", i+1)) {
+			t.Errorf("unexpected failed attempt log line %q", failed)
+		}
+
+		if !strings.Contains(retry, "level=debug") ||
+			!strings.Contains(retry, "GET ") ||
+			!strings.HasSuffix(retry, fmt.Sprintf("attempt %d of 4\"", i+2)) {
+			t.Errorf("unexpected retry log line %q", retry)
+		}
+	}
+}
+
+func TestHttpRetryLoggerInfoLevel(t *testing.T) {
+	ctr, logs, _ := newRetryLoggingConnector(t, logrus.InfoLevel)
+
+	if _, err := ctr.GetNonce(GetNonceArgs{}); err != nil {
+		t.Fatalf("GetNonce returned unexpected error: %v", err)
+	}
+
+	if logs.Len() != 0 {
+		t.Errorf("expected retries not to be logged at info level, got %q", logs.String())
+	}
+}
+
+func TestWithHttpRetryLoggerNil(t *testing.T) {
+	if err := WithHttpRetryLogger(nil)(&Config{}); err == nil {
+		t.Error("expected an error for a nil http retry logger")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"crypto/x509"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -49,7 +50,32 @@ func (m *MockConnector) AttestEvidence(evidence interface{}, cloudProvider strin
 	return args.Get(0).(AttestResponse), args.Error(1)
 }
 
+func (m *MockConnector) AttestEvidenceStream(evidence interface{}, cloudProvider string, reqId string, onStatus func(AttestStatus)) (AttestResponse, error) {
+	args := m.Called(evidence, cloudProvider, reqId, onStatus)
+	return args.Get(0).(AttestResponse), args.Error(1)
+}
+
+func (m *MockConnector) EvaluateEvidence(evidence interface{}, policyIds []uuid.UUID, cloudProvider string, reqId string) (EvaluateResponse, error) {
+	args := m.Called(evidence, policyIds, cloudProvider, reqId)
+	return args.Get(0).(EvaluateResponse), args.Error(1)
+}
+
 func (m *MockConnector) GetAKCertificate(ekCert *x509.Certificate, akTpmtPublic []byte) ([]byte, []byte, []byte, error) {
 	args := m.Called(ekCert, akTpmtPublic)
 	return args.Get(0).([]byte), args.Get(1).([]byte), args.Get(2).([]byte), args.Error(3)
 }
+
+func (m *MockConnector) Ping() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockConnector) GetTokenSigningCaBundle() ([]*x509.Certificate, error) {
+	args := m.Called()
+	return args.Get(0).([]*x509.Certificate), args.Error(1)
+}
+
+func (m *MockConnector) GetCRL(crlUrl string) (*x509.RevocationList, error) {
+	args := m.Called(crlUrl)
+	return args.Get(0).(*x509.RevocationList), args.Error(1)
+}
@@ -6,16 +6,24 @@
 package connector
 
 import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/x509"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
 // GetNonce is used to get Intel Trust Authority signed nonce
 func (connector *trustAuthorityConnector) GetNonce(args GetNonceArgs) (GetNonceResponse, error) {
 	url := connector.cfg.ApiUrl + nonceEndpoint
+	logrus.Debugf("Requesting verifier nonce from %s", url)
 
 	newRequest := func() (*http.Request, error) {
 		return http.NewRequest(http.MethodGet, url, nil)
@@ -43,9 +51,48 @@ func (connector *trustAuthorityConnector) GetNonce(args GetNonceArgs) (GetNonceR
 		return nil
 	}
 
-	if err := doRequest(*connector.rclient, connector.cfg.TlsCfg, newRequest, nil, headers, processResponse); err != nil {
+	if err := doRequest(*connector.rclient, nil, newRequest, nil, headers, processResponse); err != nil {
 		return response, err
 	}
 
 	return response, nil
 }
+
+// Verify checks the nonce's signature using the Trust Authority's nonce signing
+// certificate.  The signature is RSASSA-PSS (SHA-384) over the concatenation of the
+// nonce's 'val' and 'iat'.  ErrInvalidNonceSignature is returned when the signature
+// does not match (ex. the nonce was tampered with).
+func (nonce VerifierNonce) Verify(signingCert *x509.Certificate) error {
+	if signingCert == nil {
+		return errors.New("A nonce signing certificate must be provided")
+	}
+
+	if len(nonce.Val) == 0 || len(nonce.Iat) == 0 || len(nonce.Signature) == 0 {
+		return errors.New("The nonce is missing 'val', 'iat' or 'signature'")
+	}
+
+	publicKey, ok := signingCert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.Errorf("Unsupported nonce signing certificate public key type %T", signingCert.PublicKey)
+	}
+
+	digest := sha512.Sum384(append(append([]byte{}, nonce.Val...), nonce.Iat...))
+	err := rsa.VerifyPSS(publicKey, crypto.SHA384, digest[:], nonce.Signature, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidNonceSignature, err)
+	}
+
+	return nil
+}
+
+// IssuedAt returns the time the nonce was issued by the Trust Authority (from the
+// nonce's 'iat').  Callers can use it to check the nonce's freshness before
+// building evidence.
+func (nonce VerifierNonce) IssuedAt() (time.Time, error) {
+	iat, err := time.Parse(nonceIatLayout, string(nonce.Iat))
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "Failed to parse the nonce's 'iat'")
+	}
+
+	return iat, nil
+}
@@ -6,10 +6,21 @@
 package connector
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"errors"
+	"math/big"
 	"net/http"
 	"reflect"
 	"testing"
+	"time"
 )
 
 var (
@@ -61,3 +72,167 @@ func TestGetNonce_invalidNonce(t *testing.T) {
 		t.Error("GetNonce returned nil, expected error")
 	}
 }
+
+func newTestNonceSigningCertificate(t *testing.T, publicKey, privateKey interface{}) *x509.Certificate {
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Nonce Signing"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, publicKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func newTestSignedNonce(t *testing.T, key *rsa.PrivateKey) VerifierNonce {
+	val, _ := base64.StdEncoding.DecodeString(nonceVal)
+	iat, _ := base64.StdEncoding.DecodeString(nonceIat)
+
+	digest := sha512.Sum384(append(append([]byte{}, val...), iat...))
+	sig, err := rsa.SignPSS(rand.Reader, key, crypto.SHA384, digest[:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return VerifierNonce{
+		Val:       val,
+		Iat:       iat,
+		Signature: sig,
+	}
+}
+
+func TestVerifierNonceVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingCert := newTestNonceSigningCertificate(t, &key.PublicKey, key)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCert := newTestNonceSigningCertificate(t, &otherKey.PublicKey, otherKey)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecCert := newTestNonceSigningCertificate(t, &ecKey.PublicKey, ecKey)
+
+	nonce := newTestSignedNonce(t, key)
+
+	tamper := func(b []byte) []byte {
+		tampered := append([]byte{}, b...)
+		tampered[0] ^= 0xff
+		return tampered
+	}
+
+	tests := []struct {
+		name        string
+		nonce       VerifierNonce
+		cert        *x509.Certificate
+		expectError bool
+		expectedErr error
+	}{
+		{
+			name:  "Valid Nonce",
+			nonce: nonce,
+			cert:  signingCert,
+		},
+		{
+			name:        "Tampered Val",
+			nonce:       VerifierNonce{Val: tamper(nonce.Val), Iat: nonce.Iat, Signature: nonce.Signature},
+			cert:        signingCert,
+			expectError: true,
+			expectedErr: ErrInvalidNonceSignature,
+		},
+		{
+			name:        "Tampered Iat",
+			nonce:       VerifierNonce{Val: nonce.Val, Iat: tamper(nonce.Iat), Signature: nonce.Signature},
+			cert:        signingCert,
+			expectError: true,
+			expectedErr: ErrInvalidNonceSignature,
+		},
+		{
+			name:        "Tampered Signature",
+			nonce:       VerifierNonce{Val: nonce.Val, Iat: nonce.Iat, Signature: tamper(nonce.Signature)},
+			cert:        signingCert,
+			expectError: true,
+			expectedErr: ErrInvalidNonceSignature,
+		},
+		{
+			name:        "Wrong Signing Certificate",
+			nonce:       nonce,
+			cert:        otherCert,
+			expectError: true,
+			expectedErr: ErrInvalidNonceSignature,
+		},
+		{
+			name:        "Missing Signature",
+			nonce:       VerifierNonce{Val: nonce.Val, Iat: nonce.Iat},
+			cert:        signingCert,
+			expectError: true,
+		},
+		{
+			name:        "Nil Signing Certificate",
+			nonce:       nonce,
+			cert:        nil,
+			expectError: true,
+		},
+		{
+			name:        "Unsupported Public Key",
+			nonce:       nonce,
+			cert:        ecCert,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.nonce.Verify(tt.cert)
+			if !tt.expectError {
+				if err != nil {
+					t.Fatalf("Verify returned unexpected error: %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("Verify returned nil, expected error")
+			}
+			if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestVerifierNonceIssuedAt(t *testing.T) {
+	iat, _ := base64.StdEncoding.DecodeString(nonceIat)
+
+	issuedAt, err := VerifierNonce{Iat: iat}.IssuedAt()
+	if err != nil {
+		t.Fatalf("IssuedAt returned unexpected error: %v", err)
+	}
+
+	expected := time.Date(2022, 8, 24, 12, 36, 32, 929722075, time.UTC)
+	if !issuedAt.Equal(expected) {
+		t.Errorf("IssuedAt returned %v, want %v", issuedAt, expected)
+	}
+
+	for _, invalidIat := range [][]byte{nil, []byte("invalid"), []byte("2022-08-24")} {
+		if _, err := (VerifierNonce{Iat: invalidIat}).IssuedAt(); err == nil {
+			t.Errorf("IssuedAt(%q) returned nil, expected error", invalidIat)
+		}
+	}
+}
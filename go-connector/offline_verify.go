@@ -0,0 +1,105 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"bytes"
+	"crypto/x509"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// VerificationBundle contains the data needed to verify attestation tokens without
+// access to the Trust Authority (see VerifyTokenOffline):  the token signing
+// certificates (JWKS), the CA certificates they chain to (see GetTokenSigningCaBundle)
+// and the CRLs of the CAs.
+type VerificationBundle struct {
+	Jwks           []byte
+	CaCertificates []*x509.Certificate
+	Crls           []*x509.RevocationList
+}
+
+// VerifyTokenOffline is similar to VerifyToken but uses the certificates and CRLs in
+// 'bundle' instead of downloading them.  The token signing certificate must chain to
+// one of the bundle's self-signed CA certificates and must not be revoked by the CRLs of
+// its chain.  Since the bundle may have been exported some time ago, a warning is
+// logged (instead of failing) when a CRL's NextUpdate has passed.  The token's issuer is
+// checked with the WithExpectedIssuer and WithTrustedIssuer options (the bundle's
+// certificates are used regardless of the issuer's base URL), other options are ignored.
+func VerifyTokenOffline(token string, bundle *VerificationBundle, opts ...ConfigOption) (*jwt.Token, error) {
+	if bundle == nil {
+		return nil, errors.New("The verification bundle must not be nil")
+	}
+
+	var cfg Config
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	roots := x509.NewCertPool()
+	intermediates := x509.NewCertPool()
+	for _, caCert := range bundle.CaCertificates {
+		if bytes.Equal(caCert.RawIssuer, caCert.RawSubject) {
+			roots.AddCert(caCert)
+		} else {
+			intermediates.AddCert(caCert)
+		}
+	}
+
+	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		kid, err := tokenKeyId(token)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = checkExpectedIssuer(&cfg, token); err != nil {
+			return nil, err
+		}
+
+		if len(cfg.TrustedIssuers) != 0 {
+			if _, ok := cfg.TrustedIssuers[tokenIssuer(token)]; !ok {
+				return nil, errors.Wrapf(ErrUntrustedIssuer, "issuer %q", tokenIssuer(token))
+			}
+		}
+
+		return verifyTokenSigningKey(bundle.Jwks, kid, roots, intermediates, func(c *x509.Certificate, issuer *x509.Certificate) error {
+			return checkOfflineRevocation(c, issuer, bundle.Crls)
+		})
+	}, jwt.WithValidMethods(validTokenSigningMethods()))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to verify jwt token")
+	}
+
+	return parsedToken, nil
+}
+
+// checkOfflineRevocation checks 'c' against the CRL in 'crls' signed by 'issuer'.
+func checkOfflineRevocation(c *x509.Certificate, issuer *x509.Certificate, crls []*x509.RevocationList) error {
+	for _, crl := range crls {
+		if crl.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+
+		if crl.NextUpdate.Before(time.Now()) {
+			logrus.Warnf("The CRL of %q expired at %s, the bundle's revocation data may be stale", issuer.Subject.CommonName, crl.NextUpdate.UTC().Format(time.RFC3339))
+		}
+
+		for _, revoked := range crl.RevokedCertificateEntries {
+			if revoked.SerialNumber.Cmp(c.SerialNumber) == 0 {
+				return errors.Errorf("Certificate %q was Revoked", c.Subject.CommonName)
+			}
+		}
+
+		return nil
+	}
+
+	return errors.Errorf("The bundle does not contain the CRL of %q", issuer.Subject.CommonName)
+}
@@ -0,0 +1,271 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+// testOfflineIssuer is the 'iss' claim of the tokens of testOfflineChain.
+const testOfflineIssuer = "https://us.trustauthority.example.com"
+
+// testOfflineChain is a root CA that issued a token signing certificate.
+type testOfflineChain struct {
+	rootKey *rsa.PrivateKey
+	root    *x509.Certificate
+	leafKey *rsa.PrivateKey
+	leaf    *x509.Certificate
+	jwks    []byte
+}
+
+func newTestOfflineChain(t *testing.T) *testOfflineChain {
+	rootKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootTemplate := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	rootDer, err := x509.CreateCertificate(rand.Reader, &rootTemplate, &rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := x509.ParseCertificate(rootDer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leafKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTemplate := x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test Token Signing"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	leafDer, err := x509.CreateCertificate(rand.Reader, &leafTemplate, root, &leafKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(leafDer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{
+				"kty": "RSA",
+				"kid": "test",
+				"alg": "PS384",
+				"n":   base64.RawURLEncoding.EncodeToString(leafKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(leafKey.E)).Bytes()),
+				"x5c": []string{
+					base64.StdEncoding.EncodeToString(leafDer),
+					base64.StdEncoding.EncodeToString(rootDer),
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testOfflineChain{rootKey: rootKey, root: root, leafKey: leafKey, leaf: leaf, jwks: jwks}
+}
+
+// crl returns a CRL signed by the root CA that expires at 'nextUpdate'.
+func (c *testOfflineChain) crl(t *testing.T, nextUpdate time.Time, revoked ...*big.Int) *x509.RevocationList {
+	var entries []x509.RevocationListEntry
+	for _, serialNumber := range revoked {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: serialNumber, RevocationTime: time.Now()})
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                nextUpdate.Add(-2 * time.Hour),
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: entries,
+	}, c.root, c.rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crl
+}
+
+// token returns a token signed by the token signing certificate.
+func (c *testOfflineChain) token(t *testing.T) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodPS384, jwt.MapClaims{
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iss": testOfflineIssuer,
+	})
+	token.Header["kid"] = "test"
+
+	signed, err := token.SignedString(c.leafKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifyTokenOffline(t *testing.T) {
+	chain := newTestOfflineChain(t)
+	otherChain := newTestOfflineChain(t)
+
+	tests := []struct {
+		name          string
+		bundle        *VerificationBundle
+		expectedError string
+		expectWarning bool
+	}{
+		{
+			name: "valid bundle",
+			bundle: &VerificationBundle{
+				Jwks:           chain.jwks,
+				CaCertificates: []*x509.Certificate{chain.root},
+				Crls:           []*x509.RevocationList{chain.crl(t, time.Now().Add(time.Hour))},
+			},
+		},
+		{
+			name: "expired crl",
+			bundle: &VerificationBundle{
+				Jwks:           chain.jwks,
+				CaCertificates: []*x509.Certificate{chain.root},
+				Crls:           []*x509.RevocationList{chain.crl(t, time.Now().Add(-time.Hour))},
+			},
+			expectWarning: true,
+		},
+		{
+			name: "revoked certificate",
+			bundle: &VerificationBundle{
+				Jwks:           chain.jwks,
+				CaCertificates: []*x509.Certificate{chain.root},
+				Crls:           []*x509.RevocationList{chain.crl(t, time.Now().Add(time.Hour), chain.leaf.SerialNumber)},
+			},
+			expectedError: "Revoked",
+		},
+		{
+			name: "missing crl",
+			bundle: &VerificationBundle{
+				Jwks:           chain.jwks,
+				CaCertificates: []*x509.Certificate{chain.root},
+				Crls:           []*x509.RevocationList{otherChain.crl(t, time.Now().Add(time.Hour))},
+			},
+			expectedError: "does not contain the CRL",
+		},
+		{
+			name: "untrusted ca",
+			bundle: &VerificationBundle{
+				Jwks:           chain.jwks,
+				CaCertificates: []*x509.Certificate{otherChain.root},
+				Crls:           []*x509.RevocationList{chain.crl(t, time.Now().Add(time.Hour))},
+			},
+			expectedError: "Failed to verify cert chain",
+		},
+		{
+			name: "jwk does not match its certificate",
+			bundle: &VerificationBundle{
+				Jwks:           []byte(strings.Replace(string(otherChain.jwks), base64.StdEncoding.EncodeToString(otherChain.leaf.Raw), base64.StdEncoding.EncodeToString(chain.leaf.Raw), 1)),
+				CaCertificates: []*x509.Certificate{chain.root},
+				Crls:           []*x509.RevocationList{chain.crl(t, time.Now().Add(time.Hour))},
+			},
+			expectedError: "does not match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := logrustest.NewGlobal()
+			defer hook.Reset()
+
+			token, err := VerifyTokenOffline(chain.token(t), tt.bundle)
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected the token to be verified, got %v", err)
+			}
+			if !token.Valid {
+				t.Error("Expected a valid token")
+			}
+
+			warned := false
+			for _, entry := range hook.AllEntries() {
+				if entry.Level == logrus.WarnLevel && strings.Contains(entry.Message, "stale") {
+					warned = true
+				}
+			}
+			if warned != tt.expectWarning {
+				t.Errorf("Expected stale CRL warning %v, got %v", tt.expectWarning, warned)
+			}
+		})
+	}
+}
+
+func TestVerifyTokenOffline_issuer(t *testing.T) {
+	chain := newTestOfflineChain(t)
+	bundle := &VerificationBundle{
+		Jwks:           chain.jwks,
+		CaCertificates: []*x509.Certificate{chain.root},
+		Crls:           []*x509.RevocationList{chain.crl(t, time.Now().Add(time.Hour))},
+	}
+
+	tests := []struct {
+		name        string
+		opts        []ConfigOption
+		expectedErr error
+	}{
+		{name: "No Issuer Options"},
+		{name: "Expected Issuer", opts: []ConfigOption{WithExpectedIssuer(testOfflineIssuer)}},
+		{name: "Unexpected Issuer", opts: []ConfigOption{WithExpectedIssuer("https://eu.trustauthority.example.com")}, expectedErr: ErrUnexpectedIssuer},
+		{name: "Trusted Issuer", opts: []ConfigOption{WithTrustedIssuer(testOfflineIssuer, testOfflineIssuer)}},
+		{name: "Untrusted Issuer", opts: []ConfigOption{WithTrustedIssuer("https://eu.trustauthority.example.com", "https://eu.trustauthority.example.com")}, expectedErr: ErrUntrustedIssuer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := VerifyTokenOffline(chain.token(t), bundle, tt.opts...)
+			if tt.expectedErr == nil && err != nil {
+				t.Fatalf("Expected the token to be verified, got %v", err)
+			} else if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
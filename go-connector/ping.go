@@ -0,0 +1,32 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Ping is used to check that Intel Trust Authority is reachable before attempting attestation.
+// When the connector is configured with an API URL, an (authenticated) nonce request is made
+// so that invalid API keys are also detected.  Otherwise, the token signing certificates are
+// requested from the base URL.
+func (connector *trustAuthorityConnector) Ping() error {
+	if connector.cfg.ApiUrl == "" {
+		_, err := connector.GetTokenSigningCertificates()
+		if err != nil {
+			return errors.Wrap(err, "Failed to reach Trust Authority")
+		}
+		return nil
+	}
+
+	_, err := connector.GetNonce(GetNonceArgs{RequestId: uuid.New().String()})
+	if err != nil {
+		return errors.Wrap(err, "Failed to reach Trust Authority")
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc(nonceEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"val":"` + nonceVal + `","iat":"` + nonceIat + `","signature":"` + nonceSig + `"}`))
+	})
+
+	err := connector.Ping()
+	if err != nil {
+		t.Errorf("Ping returned unexpected error: %v", err)
+	}
+}
+
+func TestPing_baseUrl(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"keys":[]}`))
+	})
+
+	connector, err := New(&Config{
+		BaseUrl: server.URL,
+		TlsCfg: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = connector.Ping()
+	if err != nil {
+		t.Errorf("Ping returned unexpected error: %v", err)
+	}
+}
+
+func TestPing_unauthorized(t *testing.T) {
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc(nonceEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`Unauthorized`))
+	})
+
+	err := connector.Ping()
+	if err == nil {
+		t.Error("Ping returned nil, expected error")
+	}
+}
+
+func TestPing_unreachable(t *testing.T) {
+	server := httptest.NewTLSServer(http.NewServeMux())
+	server.Close()
+
+	connector, err := New(&Config{
+		ApiUrl: server.URL,
+		TlsCfg: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = connector.Ping()
+	if err == nil {
+		t.Error("Ping returned nil, expected error")
+	}
+}
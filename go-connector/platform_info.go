@@ -0,0 +1,186 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// PlatformInfo identifies the host that collected the evidence (see WithPlatformInfo)
+// so that attestations can be correlated across a fleet.
+type PlatformInfo struct {
+	Hostname      string `json:"hostname,omitempty"`
+	CloudProvider string `json:"cloud_provider,omitempty"`
+	InstanceId    string `json:"instance_id,omitempty"`
+}
+
+// instanceMetadataSource describes how the instance id is retrieved from a cloud
+// provider's instance metadata service.
+type instanceMetadataSource struct {
+	provider string
+	path     string
+	headers  map[string]string
+	// tokenPath/tokenHeaders are used to request a session token that is passed to
+	// the metadata service in 'tokenHeader' (i.e., AWS IMDSv2)
+	tokenPath    string
+	tokenHeaders map[string]string
+	tokenHeader  string
+}
+
+var instanceMetadataSources = []instanceMetadataSource{
+	{
+		provider: "azure",
+		path:     "/metadata/instance/compute/vmId?api-version=2021-02-01&format=text",
+		headers:  map[string]string{"Metadata": "true"},
+	},
+	{
+		provider: "gcp",
+		path:     "/computeMetadata/v1/instance/id",
+		headers:  map[string]string{"Metadata-Flavor": "Google"},
+	},
+	{
+		provider:     "aws",
+		path:         "/latest/meta-data/instance-id",
+		tokenPath:    "/latest/api/token",
+		tokenHeaders: map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"},
+		tokenHeader:  "X-aws-ec2-metadata-token",
+	},
+}
+
+// These can be replaced by unit tests to mock the platform's identity.
+var (
+	getHostname         = os.Hostname
+	instanceMetadataUrl = "http://169.254.169.254"
+)
+
+// platformInfo returns the host's platform info, which is only collected once per
+// process since the host's identity does not change between attestations.
+var platformInfo = sync.OnceValue(collectPlatformInfo)
+
+// WithPlatformInfo includes the host's identity (its hostname and, when running in
+// Azure, GCP or AWS, the cloud instance id) in the attestation request's
+// 'platform_info' field when 'auto' is true.  Collecting the platform info is best
+// effort:  fields that cannot be determined are omitted and do not fail Build().
+func WithPlatformInfo(auto bool) EvidenceBuilderOption {
+	return func(eb *evidenceBuilder) error {
+		eb.platformInfo = auto
+		return nil
+	}
+}
+
+// collectPlatformInfo returns the hostname and cloud instance id of the host or nil if
+// neither is available.  The cloud providers' metadata services are queried
+// concurrently so that, outside of a cloud, collection takes at most one metadata
+// timeout.
+func collectPlatformInfo() *PlatformInfo {
+	var info PlatformInfo
+
+	hostname, err := getHostname()
+	if err != nil {
+		logrus.WithError(err).Debug("Failed to get the hostname")
+	} else {
+		info.Hostname = hostname
+	}
+
+	// the link-local metadata services must not be reached through a proxy
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: nil},
+		Timeout:   DefaultInstanceMetadataTimeoutSeconds * time.Second,
+	}
+
+	instanceIds := make([]string, len(instanceMetadataSources))
+	var wg sync.WaitGroup
+	for i := range instanceMetadataSources {
+		wg.Add(1)
+		go func(source *instanceMetadataSource) {
+			defer wg.Done()
+
+			instanceId, err := source.getInstanceId(client)
+			if err != nil {
+				logrus.WithError(err).Debugf("Failed to get the %s instance id", source.provider)
+				return
+			}
+			instanceIds[i] = instanceId
+		}(&instanceMetadataSources[i])
+	}
+	wg.Wait()
+
+	for i, instanceId := range instanceIds {
+		if instanceId != "" {
+			info.CloudProvider = instanceMetadataSources[i].provider
+			info.InstanceId = instanceId
+			break
+		}
+	}
+
+	if info == (PlatformInfo{}) {
+		return nil
+	}
+
+	return &info
+}
+
+func (source *instanceMetadataSource) getInstanceId(client *http.Client) (string, error) {
+	headers := map[string]string{}
+	for name, value := range source.headers {
+		headers[name] = value
+	}
+
+	if source.tokenPath != "" {
+		token, err := getInstanceMetadata(client, http.MethodPut, source.tokenPath, source.tokenHeaders)
+		if err != nil {
+			return "", err
+		}
+		headers[source.tokenHeader] = token
+	}
+
+	instanceId, err := getInstanceMetadata(client, http.MethodGet, source.path, headers)
+	if err != nil {
+		return "", err
+	}
+
+	if instanceId == "" {
+		return "", errors.Errorf("Empty %s instance id", source.provider)
+	}
+
+	return instanceId, nil
+}
+
+func getInstanceMetadata(client *http.Client, method string, path string, headers map[string]string) (string, error) {
+	req, err := http.NewRequest(method, instanceMetadataUrl+path, nil)
+	if err != nil {
+		return "", err
+	}
+
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("%s %s returned status %d", method, path, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
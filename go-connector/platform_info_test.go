@@ -0,0 +1,183 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// mockPlatform replaces the hostname and instance metadata service with 'hostname'
+// and 'handler' until the returned function is called.
+func mockPlatform(hostname string, hostnameErr error, handler http.HandlerFunc) func() {
+	server := httptest.NewServer(handler)
+	origHostname, origUrl := getHostname, instanceMetadataUrl
+
+	getHostname = func() (string, error) { return hostname, hostnameErr }
+	instanceMetadataUrl = server.URL
+	platformInfo = sync.OnceValue(collectPlatformInfo)
+
+	return func() {
+		getHostname, instanceMetadataUrl = origHostname, origUrl
+		platformInfo = sync.OnceValue(collectPlatformInfo)
+		server.Close()
+	}
+}
+
+func buildWithPlatformInfo(t *testing.T) *CompositeEvidence {
+	builder, err := NewEvidenceBuilder(
+		WithEvidenceAdapter(&testCompositeEvidenceAdapter{}),
+		WithPlatformInfo(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidence, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Expected Build to succeed, got %v", err)
+	}
+
+	return evidence
+}
+
+func TestWithPlatformInfo_azure(t *testing.T) {
+	defer mockPlatform("host-1", nil, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metadata/instance/compute/vmId" || r.Header.Get("Metadata") != "true" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("1ee8a6a6-9d2b-4c3f-8a0e-3f4f7b2a9c11\n"))
+	})()
+
+	evidence := buildWithPlatformInfo(t)
+	expected := PlatformInfo{
+		Hostname:      "host-1",
+		CloudProvider: "azure",
+		InstanceId:    "1ee8a6a6-9d2b-4c3f-8a0e-3f4f7b2a9c11",
+	}
+	if evidence.PlatformInfo == nil || *evidence.PlatformInfo != expected {
+		t.Fatalf("Expected platform info %+v, got %+v", expected, evidence.PlatformInfo)
+	}
+
+	evidenceJson, err := MarshalEvidence(evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ValidateEvidenceJSON(evidenceJson); err != nil {
+		t.Errorf("Expected evidence with platform info to be valid, got %v", err)
+	}
+}
+
+func TestWithPlatformInfo_aws(t *testing.T) {
+	defer mockPlatform("host-2", nil, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			w.Write([]byte("session-token"))
+		case r.URL.Path == "/latest/meta-data/instance-id" && r.Header.Get("X-aws-ec2-metadata-token") == "session-token":
+			w.Write([]byte("i-0123456789abcdef0"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})()
+
+	evidence := buildWithPlatformInfo(t)
+	expected := PlatformInfo{
+		Hostname:      "host-2",
+		CloudProvider: "aws",
+		InstanceId:    "i-0123456789abcdef0",
+	}
+	if evidence.PlatformInfo == nil || *evidence.PlatformInfo != expected {
+		t.Fatalf("Expected platform info %+v, got %+v", expected, evidence.PlatformInfo)
+	}
+}
+
+func TestWithPlatformInfo_cached(t *testing.T) {
+	var requests int32
+	defer mockPlatform("host-5", nil, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotFound)
+	})()
+
+	buildWithPlatformInfo(t)
+	queried := atomic.LoadInt32(&requests)
+	if queried == 0 {
+		t.Fatal("Expected the instance metadata service to be queried")
+	}
+
+	// the platform info is only collected once
+	buildWithPlatformInfo(t)
+	if atomic.LoadInt32(&requests) != queried {
+		t.Errorf("Expected the instance metadata service to be queried once, got %d requests", requests)
+	}
+}
+
+func TestWithPlatformInfo_noMetadata(t *testing.T) {
+	defer mockPlatform("host-3", nil, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})()
+
+	evidence := buildWithPlatformInfo(t)
+	expected := PlatformInfo{Hostname: "host-3"}
+	if evidence.PlatformInfo == nil || *evidence.PlatformInfo != expected {
+		t.Fatalf("Expected platform info %+v, got %+v", expected, evidence.PlatformInfo)
+	}
+}
+
+func TestWithPlatformInfo_omittedOnFailure(t *testing.T) {
+	defer mockPlatform("", errors.New("no hostname"), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})()
+
+	evidence := buildWithPlatformInfo(t)
+	if evidence.PlatformInfo != nil {
+		t.Fatalf("Expected no platform info, got %+v", evidence.PlatformInfo)
+	}
+
+	evidenceJson, err := json.Marshal(evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	if err = json.Unmarshal(evidenceJson, &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, exists := fields["platform_info"]; exists {
+		t.Errorf("Expected 'platform_info' to be omitted, got %s", evidenceJson)
+	}
+}
+
+func TestWithPlatformInfo_disabled(t *testing.T) {
+	defer mockPlatform("host-4", nil, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("The instance metadata service should not be queried")
+	})()
+
+	builder, err := NewEvidenceBuilder(
+		WithEvidenceAdapter(&testCompositeEvidenceAdapter{}),
+		WithPlatformInfo(false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidence, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if evidence.PlatformInfo != nil {
+		t.Errorf("Expected no platform info, got %+v", evidence.PlatformInfo)
+	}
+}
@@ -0,0 +1,268 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// RefreshingToken holds an attestation token that is automatically renewed (i.e.,
+// re-attested) before it expires.  It is safe to use from multiple goroutines.
+type RefreshingToken interface {
+	// Token returns the current attestation token.  If the token is within the
+	// refresh lead time of its expiration, Token re-attests before returning.
+	Token() (string, error)
+
+	// Close stops the background refresh.  Token returns an error after Close
+	// has been called.
+	Close() error
+}
+
+// EvidenceBuilderFactory creates a new EvidenceBuilder for each attestation performed
+// by RefreshingToken.  A new builder is needed for each attestation so that evidence
+// can be bound to a fresh verifier nonce (see WithVerifierNonce).
+type EvidenceBuilderFactory func() (EvidenceBuilder, error)
+
+type refreshingToken struct {
+	ctr           Connector
+	newBuilder    EvidenceBuilderFactory
+	cloudProvider string
+	leadTime      time.Duration
+	retryInterval time.Duration
+
+	// refreshMutex serializes attestations so that the mutex guarding the
+	// token is not held during the network round trip.
+	refreshMutex sync.Mutex
+
+	mutex      sync.Mutex
+	token      string
+	expiration time.Time
+	closed     bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+type RefreshingTokenOption func(*refreshingToken) error
+
+// NewRefreshingToken attests the evidence created by 'newBuilder' using 'ctr' and
+// starts a background goroutine that re-attests before the token's 'exp' claim.
+// An error is returned if the initial attestation fails.
+func NewRefreshingToken(ctr Connector, newBuilder EvidenceBuilderFactory, opts ...RefreshingTokenOption) (RefreshingToken, error) {
+	if ctr == nil || newBuilder == nil {
+		return nil, errors.New("A connector and evidence builder factory must be provided")
+	}
+
+	rt := &refreshingToken{
+		ctr:           ctr,
+		newBuilder:    newBuilder,
+		leadTime:      DefaultRefreshLeadTimeSeconds * time.Second,
+		retryInterval: DefaultRefreshRetrySeconds * time.Second,
+		done:          make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		if err := opt(rt); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rt.refresh(true); err != nil {
+		return nil, err
+	}
+
+	rt.wg.Add(1)
+	go rt.refreshLoop()
+
+	return rt, nil
+}
+
+// WithRefreshLeadTime sets how long before the token's expiration it is renewed.
+func WithRefreshLeadTime(leadTime time.Duration) RefreshingTokenOption {
+	return func(rt *refreshingToken) error {
+		if leadTime < 0 {
+			return errors.Errorf("Invalid refresh lead time %v", leadTime)
+		}
+		rt.leadTime = leadTime
+		return nil
+	}
+}
+
+// WithRefreshCloudProvider sets the cloud provider passed to Connector.AttestEvidence
+// (ex. "azure" when attesting Azure TDX evidence).
+func WithRefreshCloudProvider(cloudProvider string) RefreshingTokenOption {
+	return func(rt *refreshingToken) error {
+		rt.cloudProvider = cloudProvider
+		return nil
+	}
+}
+
+// WithRefreshRetryInterval sets how long to wait before retrying a failed refresh.
+// Consecutive failures double the wait, up to MaxRefreshRetrySeconds.
+func WithRefreshRetryInterval(retryInterval time.Duration) RefreshingTokenOption {
+	return func(rt *refreshingToken) error {
+		if retryInterval <= 0 {
+			return errors.Errorf("Invalid refresh retry interval %v", retryInterval)
+		}
+		rt.retryInterval = retryInterval
+		return nil
+	}
+}
+
+func (rt *refreshingToken) Token() (string, error) {
+	rt.mutex.Lock()
+	closed, needsRefresh := rt.closed, rt.needsRefresh()
+	rt.mutex.Unlock()
+
+	if closed {
+		return "", errors.New("The refreshing token has been closed")
+	}
+
+	if needsRefresh {
+		if err := rt.refresh(false); err != nil {
+			return "", err
+		}
+	}
+
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	return rt.token, nil
+}
+
+func (rt *refreshingToken) Close() error {
+	rt.closeOnce.Do(func() {
+		rt.mutex.Lock()
+		rt.closed = true
+		rt.mutex.Unlock()
+
+		close(rt.done)
+	})
+
+	rt.wg.Wait()
+	return nil
+}
+
+// refreshLoop sleeps until the token is within the lead time of its expiration and
+// then re-attests.  The wait is never shorter than 'retryInterval' so that tokens whose
+// lifetime is within the lead time are not re-attested continuously.  Failures are
+// retried with a backoff starting at 'retryInterval' (clients calling Token() in the
+// meantime will attempt to refresh synchronously).
+func (rt *refreshingToken) refreshLoop() {
+	defer rt.wg.Done()
+
+	backoff := rt.retryInterval
+	wait := rt.untilRefresh()
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-rt.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := rt.refresh(true); err != nil {
+			logrus.WithError(err).Warnf("Failed to refresh the attestation token, retrying in %v", backoff)
+			wait = backoff
+			backoff = min(2*backoff, max(rt.retryInterval, MaxRefreshRetrySeconds*time.Second))
+			continue
+		}
+
+		backoff = rt.retryInterval
+		wait = rt.untilRefresh()
+	}
+}
+
+// refresh re-attests and replaces the token.  Unless 'force' is true, the attestation
+// is skipped when another goroutine renewed the token in the meantime.
+func (rt *refreshingToken) refresh(force bool) error {
+	rt.refreshMutex.Lock()
+	defer rt.refreshMutex.Unlock()
+
+	rt.mutex.Lock()
+	skip := rt.closed || (!force && !rt.needsRefresh())
+	rt.mutex.Unlock()
+	if skip {
+		return nil
+	}
+
+	token, expiration, err := rt.attest()
+	if err != nil {
+		return err
+	}
+
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	rt.token = token
+	rt.expiration = expiration
+	return nil
+}
+
+// attest collects new evidence and returns the token (and its expiration) issued for it.
+func (rt *refreshingToken) attest() (string, time.Time, error) {
+	builder, err := rt.newBuilder()
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "Failed to create evidence builder")
+	}
+
+	evidence, err := builder.Build()
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "Failed to collect evidence")
+	}
+
+	response, err := rt.ctr.AttestEvidence(evidence, rt.cloudProvider, uuid.New().String())
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "Failed to attest evidence")
+	}
+
+	expiration, err := tokenExpiration(response.Token)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return response.Token, expiration, nil
+}
+
+// needsRefresh must be called with the mutex held.
+func (rt *refreshingToken) needsRefresh() bool {
+	return !time.Now().Before(rt.expiration.Add(-rt.leadTime))
+}
+
+// untilRefresh returns how long to wait before the next refresh (at least
+// 'retryInterval').
+func (rt *refreshingToken) untilRefresh() time.Duration {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	wait := time.Until(rt.expiration.Add(-rt.leadTime))
+	if wait < rt.retryInterval {
+		wait = rt.retryInterval
+	}
+	return wait
+}
+
+// tokenExpiration returns the time from the token's 'exp' claim.  The token's
+// signature is not verified (see Connector.VerifyToken).
+func tokenExpiration(token string) (time.Time, error) {
+	claims := jwt.RegisteredClaims{}
+	_, _, err := jwt.NewParser().ParseUnverified(token, &claims)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "Failed to parse attestation token")
+	}
+
+	if claims.ExpiresAt == nil {
+		return time.Time{}, errors.New("The attestation token does not have an 'exp' claim")
+	}
+
+	return claims.ExpiresAt.Time, nil
+}
@@ -0,0 +1,218 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestToken(t *testing.T, expiration time.Time) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(expiration),
+	})
+
+	signed, err := token.SignedString([]byte("testkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func testEvidenceBuilderFactory() (EvidenceBuilder, error) {
+	return NewEvidenceBuilder(WithEvidenceAdapter(&testCompositeEvidenceAdapter{}))
+}
+
+func TestRefreshingTokenRefreshes(t *testing.T) {
+	shortToken := newTestToken(t, time.Now().Add(2*time.Second))
+	longToken := newTestToken(t, time.Now().Add(time.Hour))
+
+	ctr := MockConnector{}
+	ctr.On("AttestEvidence", mock.Anything, "", mock.Anything).Return(AttestResponse{Token: shortToken}, nil).Once()
+	ctr.On("AttestEvidence", mock.Anything, "", mock.Anything).Return(AttestResponse{Token: longToken}, nil)
+
+	rt, err := NewRefreshingToken(&ctr, testEvidenceBuilderFactory, WithRefreshLeadTime(time.Second), WithRefreshRetryInterval(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	token, err := rt.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != shortToken {
+		t.Fatal("expected the initial token")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for token != longToken && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		token, err = rt.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if token != longToken {
+		t.Fatal("expected the token to be refreshed before it expired")
+	}
+}
+
+func TestRefreshingTokenCloudProvider(t *testing.T) {
+	ctr := MockConnector{}
+	ctr.On("AttestEvidence", mock.Anything, "azure", mock.Anything).Return(AttestResponse{Token: newTestToken(t, time.Now().Add(time.Hour))}, nil)
+
+	rt, err := NewRefreshingToken(&ctr, testEvidenceBuilderFactory, WithRefreshCloudProvider("azure"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	ctr.AssertCalled(t, "AttestEvidence", mock.Anything, "azure", mock.Anything)
+}
+
+func TestRefreshingTokenShortLifetime(t *testing.T) {
+	// the token's lifetime is shorter than the lead time, so it always needs a refresh
+	ctr := MockConnector{}
+	ctr.On("AttestEvidence", mock.Anything, "", mock.Anything).Return(AttestResponse{Token: newTestToken(t, time.Now().Add(time.Second))}, nil)
+
+	rt, err := NewRefreshingToken(&ctr, testEvidenceBuilderFactory, WithRefreshRetryInterval(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(350 * time.Millisecond)
+	rt.Close()
+
+	// the initial attestation and at most one per retry interval
+	if calls := len(ctr.Calls); calls > 4 {
+		t.Fatalf("expected at most 4 attestations, got %d", calls)
+	}
+}
+
+func TestRefreshingTokenSynchronousRefresh(t *testing.T) {
+	expiredToken := newTestToken(t, time.Now().Add(-time.Minute))
+	longToken := newTestToken(t, time.Now().Add(time.Hour))
+
+	ctr := MockConnector{}
+	ctr.On("AttestEvidence", mock.Anything, "", mock.Anything).Return(AttestResponse{Token: expiredToken}, nil).Once()
+	ctr.On("AttestEvidence", mock.Anything, "", mock.Anything).Return(AttestResponse{Token: longToken}, nil)
+
+	rt, err := NewRefreshingToken(&ctr, testEvidenceBuilderFactory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	token, err := rt.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != longToken {
+		t.Fatal("expected an expired token to be refreshed by Token()")
+	}
+}
+
+func TestRefreshingTokenClose(t *testing.T) {
+	ctr := MockConnector{}
+	ctr.On("AttestEvidence", mock.Anything, "", mock.Anything).Return(AttestResponse{Token: newTestToken(t, time.Now().Add(time.Hour))}, nil)
+
+	rt, err := NewRefreshingToken(&ctr, testEvidenceBuilderFactory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = rt.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// closing more than once should not fail
+	if err = rt.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = rt.Token(); err == nil {
+		t.Fatal("expected an error after Close")
+	}
+}
+
+func TestRefreshingTokenNegative(t *testing.T) {
+	invalidCtr := MockConnector{}
+	invalidCtr.On("AttestEvidence", mock.Anything, "", mock.Anything).Return(AttestResponse{Token: "invalid"}, nil)
+
+	failingCtr := MockConnector{}
+	failingCtr.On("AttestEvidence", mock.Anything, "", mock.Anything).Return(AttestResponse{}, errors.New("error"))
+
+	noExpCtr := MockConnector{}
+	noExpToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{}).SignedString([]byte("testkey"))
+	noExpCtr.On("AttestEvidence", mock.Anything, "", mock.Anything).Return(AttestResponse{Token: noExpToken}, nil)
+
+	tests := []struct {
+		name       string
+		ctr        Connector
+		newBuilder EvidenceBuilderFactory
+		opts       []RefreshingTokenOption
+	}{
+		{
+			name:       "Nil Connector",
+			ctr:        nil,
+			newBuilder: testEvidenceBuilderFactory,
+		},
+		{
+			name:       "Nil Builder Factory",
+			ctr:        &failingCtr,
+			newBuilder: nil,
+		},
+		{
+			name: "Builder Factory Failure",
+			ctr:  &failingCtr,
+			newBuilder: func() (EvidenceBuilder, error) {
+				return nil, errors.New("error")
+			},
+		},
+		{
+			name:       "Attestation Failure",
+			ctr:        &failingCtr,
+			newBuilder: testEvidenceBuilderFactory,
+		},
+		{
+			name:       "Invalid Token",
+			ctr:        &invalidCtr,
+			newBuilder: testEvidenceBuilderFactory,
+		},
+		{
+			name:       "Token Without Expiration",
+			ctr:        &noExpCtr,
+			newBuilder: testEvidenceBuilderFactory,
+		},
+		{
+			name:       "Invalid Lead Time",
+			ctr:        &failingCtr,
+			newBuilder: testEvidenceBuilderFactory,
+			opts:       []RefreshingTokenOption{WithRefreshLeadTime(-time.Second)},
+		},
+		{
+			name:       "Invalid Retry Interval",
+			ctr:        &failingCtr,
+			newBuilder: testEvidenceBuilderFactory,
+			opts:       []RefreshingTokenOption{WithRefreshRetryInterval(0)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRefreshingToken(tt.ctr, tt.newBuilder, tt.opts...)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -7,14 +7,17 @@ package connector
 
 import (
 	"crypto/tls"
+	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/pkg/errors"
 )
 
-// doRequest creates an API request, sends the API request and returns the API response
+// doRequest creates an API request, sends the API request and returns the API response.
+// When 'tlsCfg' is nil, the request is sent using the client's existing configuration.
 func doRequest(rclient retryablehttp.Client, tlsCfg *tls.Config,
 	newRequest func() (*http.Request, error),
 	queryParams map[string]string,
@@ -40,18 +43,33 @@ func doRequest(rclient retryablehttp.Client, tlsCfg *tls.Config,
 		req.Header.Add(name, val)
 	}
 
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: tlsCfg,
-			Proxy:           http.ProxyFromEnvironment,
-		},
-	}
+	// The connector's client is configured in connector.New and is used as-is so that
+	// connections are reused.  When a different tls configuration is requested, a copy
+	// of the client's transport (and its timeouts) is used instead.
+	if tlsCfg != nil || rclient.HTTPClient == nil {
+		transport := &http.Transport{}
+		if rclient.HTTPClient != nil {
+			if t, ok := rclient.HTTPClient.Transport.(*http.Transport); ok {
+				transport = t.Clone()
+			}
+		}
+		transport.TLSClientConfig = tlsCfg
+		transport.Proxy = http.ProxyFromEnvironment
+
+		var timeout time.Duration
+		if rclient.HTTPClient != nil {
+			timeout = rclient.HTTPClient.Timeout
+		}
 
-	rclient.HTTPClient = httpClient
+		rclient.HTTPClient = &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+		}
+	}
 
 	var resp *http.Response
 	if resp, err = rclient.StandardClient().Do(req); err != nil {
-		return errors.Errorf("Request to %q failed: %s", req.URL, err)
+		return fmt.Errorf("Request to %q failed: %w", req.URL, err)
 	}
 
 	if resp != nil {
@@ -63,14 +81,53 @@ func doRequest(rclient retryablehttp.Client, tlsCfg *tls.Config,
 		}()
 	}
 
+	// conditional requests (ex. for the token signing certificates) let the caller
+	// handle "not modified" responses
+	if resp.StatusCode == http.StatusNotModified && isConditionalRequest(req) {
+		return processResponse(resp)
+	}
+
 	if resp.StatusCode != http.StatusOK || resp.ContentLength == 0 {
 		traceId, requestId := resp.Header.Get(HeaderTraceId), resp.Header.Get(HeaderRequestId)
 		response, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return errors.Errorf("Failed to read response body: %s, Trace-Id = %s, Request-Id = %s", err, traceId, requestId)
 		}
-		return errors.Errorf("Request to %q failed: StatusCode = %d, Response = %s, Trace-Id = %s, Request-Id = %s", req.URL, resp.StatusCode, string(response), traceId, requestId)
+		err = errors.Errorf("Request to %q failed: StatusCode = %d, Response = %s, Trace-Id = %s, Request-Id = %s", req.URL, resp.StatusCode, string(response), traceId, requestId)
+		return newHttpStatusError(resp.StatusCode, err)
 	}
 
 	return processResponse(resp)
 }
+
+// HttpStatusError is returned when the Trust Authority responds with an unexpected HTTP
+// status code.  Errors for 401 and 403 responses also wrap ErrUnauthorized and
+// ErrForbidden (ex. to provide guidance about the API key).
+type HttpStatusError struct {
+	StatusCode int
+	err        error
+}
+
+func (e *HttpStatusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *HttpStatusError) Unwrap() error {
+	return e.err
+}
+
+// newHttpStatusError returns an *HttpStatusError for the response's 'statusCode' that wraps
+// 'err'.
+func newHttpStatusError(statusCode int, err error) error {
+	switch statusCode {
+	case http.StatusUnauthorized:
+		err = fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	case http.StatusForbidden:
+		err = fmt.Errorf("%w: %w", ErrForbidden, err)
+	}
+	return &HttpStatusError{StatusCode: statusCode, err: err}
+}
+
+func isConditionalRequest(req *http.Request) bool {
+	return req.Header.Get(headerIfNoneMatch) != "" || req.Header.Get(headerIfModifiedSince) != ""
+}
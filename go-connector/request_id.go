@@ -0,0 +1,28 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// deterministicRequestId returns the request id used for 'requestBody' when
+// Config.DeterministicRequestId is set: the hex encoded SHA-256 digest of the serialized
+// evidence (64 characters, satisfying Trust Authority's request id constraints).
+func deterministicRequestId(requestBody []byte) string {
+	digest := sha256.Sum256(requestBody)
+	return hex.EncodeToString(digest[:])
+}
+
+// requestIdFor returns 'requestId' or, when it is empty and Config.DeterministicRequestId
+// is set, a request id derived from 'requestBody'.
+func (connector *trustAuthorityConnector) requestIdFor(requestId string, requestBody []byte) string {
+	if requestId == "" && connector.cfg.DeterministicRequestId {
+		return deterministicRequestId(requestBody)
+	}
+	return requestId
+}
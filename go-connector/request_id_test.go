@@ -0,0 +1,88 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+// requestIdRegex is the request id format accepted by Trust Authority
+var requestIdRegex = regexp.MustCompile(`^[a-zA-Z0-9_ \/.-]{1,128}$`)
+
+func TestDeterministicRequestId(t *testing.T) {
+	ctr, mux, _, teardown := setup()
+	defer teardown()
+
+	ctr.(*trustAuthorityConnector).cfg.DeterministicRequestId = true
+
+	var receivedRequestId string
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		receivedRequestId = r.Header.Get(HeaderRequestId)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"token":"` + token + `"}`))
+	})
+
+	attest := func(evidence interface{}, requestId string) string {
+		if _, err := ctr.AttestEvidence(evidence, "", requestId); err != nil {
+			t.Fatal(err)
+		}
+		return receivedRequestId
+	}
+
+	first := attest(map[string]string{"quote": "AAAA"}, "")
+	if !requestIdRegex.MatchString(first) {
+		t.Fatalf("request id %q does not satisfy the request id constraints", first)
+	}
+
+	if second := attest(map[string]string{"quote": "AAAA"}, ""); second != first {
+		t.Errorf("expected identical evidence to have the same request id, got %q and %q", first, second)
+	}
+
+	if other := attest(map[string]string{"quote": "BBBB"}, ""); other == first {
+		t.Errorf("expected different evidence to have a different request id, got %q", other)
+	}
+
+	if provided := attest(map[string]string{"quote": "AAAA"}, "req1"); provided != "req1" {
+		t.Errorf("expected the provided request id to be used, got %q", provided)
+	}
+}
+
+func TestDeterministicRequestId_getToken(t *testing.T) {
+	ctr, mux, _, teardown := setup()
+	defer teardown()
+
+	ctr.(*trustAuthorityConnector).cfg.DeterministicRequestId = true
+
+	var receivedRequestIds []string
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		receivedRequestIds = append(receivedRequestIds, r.Header.Get(HeaderRequestId))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"token":"` + token + `"}`))
+	})
+
+	for i := 0; i < 2; i++ {
+		_, err := ctr.GetToken(GetTokenArgs{&VerifierNonce{}, &Evidence{Evidence: []byte("quote")}, nil, "", attestEndpoint, string(PS384), false})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(receivedRequestIds) != 2 || receivedRequestIds[0] == "" || receivedRequestIds[0] != receivedRequestIds[1] {
+		t.Errorf("expected identical evidence to have the same request id, got %v", receivedRequestIds)
+	}
+}
+
+func TestWithDeterministicRequestId(t *testing.T) {
+	cfg := Config{}
+	if err := WithDeterministicRequestId(true)(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.DeterministicRequestId {
+		t.Fatal("expected deterministic request ids to be enabled")
+	}
+}
@@ -8,8 +8,13 @@ package connector
 import (
 	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/pkg/errors"
@@ -108,3 +113,161 @@ func TestDoRequest_InternalServerError(t *testing.T) {
 		t.Error("doRequest returned nil, expected error")
 	}
 }
+
+func TestDoRequest_tlsHandshakeTimeout(t *testing.T) {
+	// a listener that accepts connections but never completes the TLS handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	retryMax := 0
+	ctr, err := New(&Config{
+		ApiUrl:              "https://" + listener.Addr().String(),
+		TlsCfg:              &tls.Config{InsecureSkipVerify: true},
+		ApiKey:              "YXBpa2V5",
+		TLSHandshakeTimeout: 100 * time.Millisecond,
+		RetryConfig: &RetryConfig{
+			RetryMax: &retryMax,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = ctr.GetNonce(GetNonceArgs{})
+	if err == nil {
+		t.Fatal("GetNonce returned nil, expected a timeout error")
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the TLS handshake to time out, took %v", elapsed)
+	}
+}
+
+func TestNewTimeoutDefaults(t *testing.T) {
+	ctr, err := New(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := ctr.(*trustAuthorityConnector).rclient.HTTPClient.Transport.(*http.Transport)
+	if transport.TLSHandshakeTimeout != DefaultTLSHandshakeSeconds*time.Second {
+		t.Errorf("expected the default TLS handshake timeout, got %v", transport.TLSHandshakeTimeout)
+	}
+
+	if transport.DialContext == nil {
+		t.Error("expected the transport to have a dialer")
+	}
+
+	if !reflect.DeepEqual(transport.TLSClientConfig, DefaultTlsConfig()) {
+		t.Errorf("expected the default TLS config, got %+v", transport.TLSClientConfig)
+	}
+}
+
+func TestNewConnectionPool(t *testing.T) {
+	ctr, err := NewFromOptions(
+		WithApiUrl("https://api.trustauthority.intel.com"),
+		WithApiKey("YXBpa2V5"),
+		WithConnectionPool(50, 10, 30*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := ctr.(*trustAuthorityConnector).rclient.HTTPClient.Transport.(*http.Transport)
+	if transport.MaxIdleConns != 50 {
+		t.Errorf("expected 50 max idle connections, got %d", transport.MaxIdleConns)
+	}
+
+	if transport.MaxIdleConnsPerHost != 10 {
+		t.Errorf("expected 10 max idle connections per host, got %d", transport.MaxIdleConnsPerHost)
+	}
+
+	if transport.IdleConnTimeout != 30*time.Second {
+		t.Errorf("expected an idle connection timeout of 30s, got %v", transport.IdleConnTimeout)
+	}
+
+	if err = WithConnectionPool(-1, 0, 0)(&Config{}); err == nil {
+		t.Error("expected an error for negative connection pool settings")
+	}
+}
+
+func TestPerAttemptTimeout(t *testing.T) {
+	var attempts int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first attempt is delayed past the per-attempt timeout
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			select {
+			case <-time.After(5 * time.Second):
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"val":"","iat":"","signature":""}`))
+	}))
+	defer server.Close()
+
+	retryWait := 10 * time.Millisecond
+	ctr, err := NewFromOptions(
+		WithApiUrl(server.URL),
+		WithApiKey("YXBpa2V5"),
+		WithTlsConfig(&tls.Config{InsecureSkipVerify: true}),
+		WithRetryConfig(&RetryConfig{RetryWaitMin: &retryWait, RetryWaitMax: &retryWait}),
+		WithPerAttemptTimeout(200*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err = ctr.GetNonce(GetNonceArgs{}); err != nil {
+		t.Fatalf("GetNonce returned unexpected error: %v", err)
+	}
+
+	if atomic.LoadInt32(&attempts) != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("expected the first attempt to time out after 200ms, took %v", time.Since(start))
+	}
+
+	if err = WithPerAttemptTimeout(0)(&Config{}); err == nil {
+		t.Error("expected an error for a per-attempt timeout of zero")
+	}
+}
+
+func TestPerAttemptTimeoutSharedClient(t *testing.T) {
+	sharedClient := &http.Client{Transport: &http.Transport{}}
+	ctr, err := New(&Config{
+		ApiUrl:            "https://api.trustauthority.intel.com",
+		ApiKey:            "YXBpa2V5",
+		HttpClient:        sharedClient,
+		PerAttemptTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ctr.(*trustAuthorityConnector).rclient.HTTPClient.Timeout != time.Second {
+		t.Error("expected the connector's client to have the per-attempt timeout")
+	}
+
+	if sharedClient.Timeout != 0 {
+		t.Error("expected the shared client not to be modified")
+	}
+}
@@ -0,0 +1,137 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// retryingAdapter is a CompositeEvidenceAdapter that retries the GetEvidence function of
+// another adapter when it fails with a transient error (see NewRetryingAdapter).
+type retryingAdapter struct {
+	adapter         CompositeEvidenceAdapter
+	retryableErrors []error
+	isRetryable     func(error) bool
+	retryMax        int
+	retryWaitMin    time.Duration
+	retryWaitMax    time.Duration
+}
+
+type RetryingAdapterOption func(*retryingAdapter) error
+
+// NewRetryingAdapter wraps 'adapter' so that evidence collection is retried (with an
+// exponential backoff) when GetEvidence fails with a transient error (ex. the TPM is
+// busy or the quote generation service is unavailable).  The transient errors must be
+// provided by WithRetryableErrors and/or WithRetryableErrorFunc.  The returned adapter
+// can be used with WithEvidenceAdapter like any other adapter.
+func NewRetryingAdapter(adapter CompositeEvidenceAdapter, opts ...RetryingAdapterOption) (CompositeEvidenceAdapter, error) {
+	if adapter == nil {
+		return nil, errors.New("The evidence adapter must not be nil")
+	}
+
+	ra := &retryingAdapter{
+		adapter:      adapter,
+		retryMax:     MaxRetries,
+		retryWaitMin: DefaultCollectionRetryWaitMinSeconds * time.Second,
+		retryWaitMax: DefaultCollectionRetryWaitMaxSeconds * time.Second,
+	}
+
+	for _, opt := range opts {
+		if err := opt(ra); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(ra.retryableErrors) == 0 && ra.isRetryable == nil {
+		return nil, errors.New("At least one retryable error or a retryable error function must be provided")
+	}
+
+	return ra, nil
+}
+
+// WithRetryableErrors retries evidence collection when GetEvidence returns an error
+// that matches (see errors.Is) one of 'errs'.
+func WithRetryableErrors(errs ...error) RetryingAdapterOption {
+	return func(ra *retryingAdapter) error {
+		ra.retryableErrors = append(ra.retryableErrors, errs...)
+		return nil
+	}
+}
+
+// WithRetryableErrorFunc retries evidence collection when 'isRetryable' returns true
+// for the error returned by GetEvidence.
+func WithRetryableErrorFunc(isRetryable func(error) bool) RetryingAdapterOption {
+	return func(ra *retryingAdapter) error {
+		if isRetryable == nil {
+			return errors.New("The retryable error function must not be nil")
+		}
+		ra.isRetryable = isRetryable
+		return nil
+	}
+}
+
+// WithCollectionRetryMax sets the maximum number of times evidence collection is retried
+// (defaults to MaxRetries).
+func WithCollectionRetryMax(retryMax int) RetryingAdapterOption {
+	return func(ra *retryingAdapter) error {
+		if retryMax < 0 {
+			return errors.Errorf("The maximum number of retries cannot be negative, got %d", retryMax)
+		}
+		ra.retryMax = retryMax
+		return nil
+	}
+}
+
+// WithCollectionRetryWait sets the time waited before the first retry ('retryWaitMin'),
+// which doubles for each subsequent retry up to 'retryWaitMax' (defaults to
+// DefaultCollectionRetryWaitMinSeconds and DefaultCollectionRetryWaitMaxSeconds).
+func WithCollectionRetryWait(retryWaitMin time.Duration, retryWaitMax time.Duration) RetryingAdapterOption {
+	return func(ra *retryingAdapter) error {
+		if retryWaitMin < 0 || retryWaitMax < retryWaitMin {
+			return errors.Errorf("Invalid retry wait times, min %v and max %v", retryWaitMin, retryWaitMax)
+		}
+		ra.retryWaitMin = retryWaitMin
+		ra.retryWaitMax = retryWaitMax
+		return nil
+	}
+}
+
+func (ra *retryingAdapter) GetEvidenceIdentifier() string {
+	return ra.adapter.GetEvidenceIdentifier()
+}
+
+func (ra *retryingAdapter) GetEvidence(verifierNonce *VerifierNonce, userData []byte) (interface{}, error) {
+	wait := ra.retryWaitMin
+	for attempt := 0; ; attempt++ {
+		evidence, err := ra.adapter.GetEvidence(verifierNonce, userData)
+		if err == nil || attempt >= ra.retryMax || !ra.retryable(err) {
+			return evidence, err
+		}
+
+		logrus.WithError(err).Warnf("Failed to collect %s evidence, retrying in %v", ra.GetEvidenceIdentifier(), wait)
+		time.Sleep(wait)
+
+		wait *= 2
+		if wait > ra.retryWaitMax {
+			wait = ra.retryWaitMax
+		}
+	}
+}
+
+// retryable returns true if 'err' is one of the retryable errors or the retryable error
+// function returns true.
+func (ra *retryingAdapter) retryable(err error) bool {
+	for _, retryableErr := range ra.retryableErrors {
+		if errors.Is(err, retryableErr) {
+			return true
+		}
+	}
+
+	return ra.isRetryable != nil && ra.isRetryable(err)
+}
@@ -0,0 +1,146 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var errTestBusy = errors.New("busy")
+
+// testFlakyEvidenceAdapter fails with the errors in 'failures' before returning evidence.
+type testFlakyEvidenceAdapter struct {
+	testCompositeEvidenceAdapter
+	failures []error
+	calls    int
+}
+
+func (m *testFlakyEvidenceAdapter) GetEvidence(verifierNonce *VerifierNonce, userData []byte) (interface{}, error) {
+	m.calls++
+	if m.calls <= len(m.failures) {
+		return nil, m.failures[m.calls-1]
+	}
+	return m.testCompositeEvidenceAdapter.GetEvidence(verifierNonce, userData)
+}
+
+func TestRetryingAdapter(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      []error
+		opts          []RetryingAdapterOption
+		expectedCalls int
+		expectedErr   error
+	}{
+		{
+			name:          "Fails Once Then Succeeds",
+			failures:      []error{fmt.Errorf("quote failed: %w", errTestBusy)},
+			opts:          []RetryingAdapterOption{WithRetryableErrors(errTestBusy)},
+			expectedCalls: 2,
+		},
+		{
+			name:          "Retryable Error Func",
+			failures:      []error{errors.New("503 service unavailable")},
+			opts:          []RetryingAdapterOption{WithRetryableErrorFunc(func(err error) bool { return true })},
+			expectedCalls: 2,
+		},
+		{
+			name:          "Not Retryable",
+			failures:      []error{errors.New("permanent")},
+			opts:          []RetryingAdapterOption{WithRetryableErrors(errTestBusy)},
+			expectedCalls: 1,
+			expectedErr:   errors.New("permanent"),
+		},
+		{
+			name:          "Retries Exhausted",
+			failures:      []error{errTestBusy, errTestBusy, errTestBusy},
+			opts:          []RetryingAdapterOption{WithRetryableErrors(errTestBusy), WithCollectionRetryMax(1)},
+			expectedCalls: 2,
+			expectedErr:   errTestBusy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flaky := &testFlakyEvidenceAdapter{failures: tt.failures}
+			opts := append([]RetryingAdapterOption{WithCollectionRetryWait(time.Millisecond, time.Millisecond)}, tt.opts...)
+
+			adapter, err := NewRetryingAdapter(flaky, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if adapter.GetEvidenceIdentifier() != "test" {
+				t.Errorf("Expected the wrapped adapter's identifier, got %q", adapter.GetEvidenceIdentifier())
+			}
+
+			evidence, err := adapter.GetEvidence(nil, nil)
+			if tt.expectedErr == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if evidence == nil {
+					t.Error("Expected evidence")
+				}
+			} else if err == nil || err.Error() != tt.expectedErr.Error() {
+				t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+
+			if flaky.calls != tt.expectedCalls {
+				t.Errorf("Expected %d calls to GetEvidence, got %d", tt.expectedCalls, flaky.calls)
+			}
+		})
+	}
+}
+
+func TestRetryingAdapterWithEvidenceBuilder(t *testing.T) {
+	flaky := &testFlakyEvidenceAdapter{failures: []error{errTestBusy}}
+	adapter, err := NewRetryingAdapter(flaky,
+		WithRetryableErrors(errTestBusy),
+		WithCollectionRetryWait(time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eb, err := NewEvidenceBuilder(WithEvidenceAdapter(adapter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidence, err := eb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := evidence.Other["test"]; !ok {
+		t.Errorf("Expected the wrapped adapter's evidence, got %+v", evidence)
+	}
+}
+
+func TestNewRetryingAdapterInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		adapter CompositeEvidenceAdapter
+		opts    []RetryingAdapterOption
+	}{
+		{name: "Nil Adapter", opts: []RetryingAdapterOption{WithRetryableErrors(errTestBusy)}},
+		{name: "No Retryable Errors", adapter: &testCompositeEvidenceAdapter{}},
+		{name: "Nil Retryable Error Func", adapter: &testCompositeEvidenceAdapter{}, opts: []RetryingAdapterOption{WithRetryableErrorFunc(nil)}},
+		{name: "Negative Retry Max", adapter: &testCompositeEvidenceAdapter{}, opts: []RetryingAdapterOption{WithRetryableErrors(errTestBusy), WithCollectionRetryMax(-1)}},
+		{name: "Invalid Retry Wait", adapter: &testCompositeEvidenceAdapter{}, opts: []RetryingAdapterOption{WithRetryableErrors(errTestBusy), WithCollectionRetryWait(time.Second, time.Millisecond)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRetryingAdapter(tt.adapter, tt.opts...); err == nil {
+				t.Fatal("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/pkg/errors"
+)
+
+// SerializationFormat determines how attestation requests (i.e., the evidence sent by
+// AttestEvidence and GetToken) are encoded (see Config.SerializationFormat).
+type SerializationFormat string
+
+const (
+	JSON SerializationFormat = "json"
+	CBOR SerializationFormat = "cbor"
+)
+
+// cborEncMode encodes CBOR deterministically (i.e., with sorted map keys) so that,
+// like MarshalEvidence, identical evidence always produces identical bytes.
+var cborEncMode, _ = cbor.CoreDetEncOptions().EncMode()
+
+// cborDecMode decodes CBOR maps into map[string]interface{} (rather than the default
+// map[interface{}]interface{}) so that decoded evidence has the same structure as JSON.
+var cborDecMode, _ = cbor.DecOptions{
+	DefaultMapType: reflect.TypeOf(map[string]interface{}{}),
+}.DecMode()
+
+// validateSerializationFormat returns an error if 'format' is not supported.  The
+// empty string is allowed and defaults to JSON.
+func validateSerializationFormat(format SerializationFormat) error {
+	switch format {
+	case "", JSON, CBOR:
+		return nil
+	default:
+		return errors.Errorf("Unsupported serialization format %q", format)
+	}
+}
+
+// marshalRequest encodes 'v' using 'format' and returns the request body and its
+// content type.  'marshalJson' is used to encode JSON (ex. MarshalEvidence).
+func marshalRequest(format SerializationFormat, v interface{}, marshalJson func(interface{}) ([]byte, error)) ([]byte, string, error) {
+	switch format {
+	case "", JSON:
+		b, err := marshalJson(v)
+		if err != nil {
+			return nil, "", err
+		}
+		return b, mimeApplicationJson, nil
+	case CBOR:
+		b, err := cborEncMode.Marshal(v)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "Failed to marshal cbor")
+		}
+		return b, mimeApplicationCbor, nil
+	default:
+		return nil, "", validateSerializationFormat(format)
+	}
+}
+
+// MarshalCBOR serializes CompositeEvidence as a single CBOR map, merging the evidence
+// in 'Other' into the top level (see MarshalJSON).
+func (ce CompositeEvidence) MarshalCBOR() ([]byte, error) {
+	b, err := cborEncMode.Marshal(compositeEvidence(ce))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]cbor.RawMessage{}
+	if err = cborDecMode.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	for identifier, e := range ce.Other {
+		if _, exists := fields[identifier]; exists {
+			return nil, errors.Errorf("Evidence identifier %q conflicts with a composite evidence field", identifier)
+		}
+
+		fields[identifier], err = cborEncMode.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cborEncMode.Marshal(fields)
+}
+
+// UnmarshalCBOR populates CompositeEvidence from a CBOR map, storing evidence that
+// does not correspond to a CompositeEvidence field in 'Other' (see UnmarshalJSON).
+func (ce *CompositeEvidence) UnmarshalCBOR(data []byte) error {
+	var known compositeEvidence
+	if err := cborDecMode.Unmarshal(data, &known); err != nil {
+		return err
+	}
+
+	fields := map[string]cbor.RawMessage{}
+	if err := cborDecMode.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	// the fields that are not part of CompositeEvidence are from other adapters
+	for _, name := range compositeEvidenceFields {
+		delete(fields, name)
+	}
+
+	for identifier, raw := range fields {
+		var e interface{}
+		if err := cborDecMode.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+
+		if known.Other == nil {
+			known.Other = map[string]interface{}{}
+		}
+		known.Other[identifier] = e
+	}
+
+	*ce = CompositeEvidence(known)
+	return nil
+}
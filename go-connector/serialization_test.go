@@ -0,0 +1,168 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// normalizeJson unmarshals and re-marshals 'v' so that json documents can be compared
+// independently of their field order.
+func normalizeJson(t *testing.T, v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n interface{}
+	if err = json.Unmarshal(b, &n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestSerializationRoundTrip_compositeEvidence(t *testing.T) {
+	evidence := CompositeEvidence{
+		Tdx: map[string]interface{}{
+			"quote":         []byte{0x01, 0x02, 0x03},
+			"verifier_type": "tdx",
+		},
+		Other: map[string]interface{}{
+			"custom": map[string]interface{}{
+				"runtime_data": []byte("runtime data"),
+			},
+		},
+		PolicyIds:       []uuid.UUID{uuid.MustParse("4d4f0f6c-6c1a-4b0e-a4d1-1f3c1b8c7e11")},
+		PolicyMustMatch: true,
+		TokenSigningAlg: PS384,
+		TokenAudience:   "https://relying-party.example.com",
+	}
+
+	jsonBody, contentType, err := marshalRequest(JSON, evidence, MarshalEvidence)
+	if err != nil {
+		t.Fatal(err)
+	} else if contentType != mimeApplicationJson {
+		t.Fatalf("Expected content type %q, got %q", mimeApplicationJson, contentType)
+	}
+
+	cborBody, contentType, err := marshalRequest(CBOR, evidence, MarshalEvidence)
+	if err != nil {
+		t.Fatal(err)
+	} else if contentType != mimeApplicationCbor {
+		t.Fatalf("Expected content type %q, got %q", mimeApplicationCbor, contentType)
+	}
+
+	var fromJson, fromCbor CompositeEvidence
+	if err = json.Unmarshal(jsonBody, &fromJson); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cborDecMode.Unmarshal(cborBody, &fromCbor); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(normalizeJson(t, fromJson), normalizeJson(t, fromCbor)) {
+		t.Fatalf("CBOR evidence %+v does not match JSON evidence %+v", fromCbor, fromJson)
+	}
+
+	if _, ok := fromCbor.Other["custom"]; !ok {
+		t.Fatalf("Expected the custom evidence to be decoded, got %+v", fromCbor.Other)
+	}
+}
+
+func TestSerializationRoundTrip_tokenRequest(t *testing.T) {
+	tr := tokenRequest{
+		Quote:           []byte("quote"),
+		VerifierNonce:   &VerifierNonce{Val: []byte("val"), Iat: []byte("iat"), Signature: []byte("signature")},
+		RuntimeData:     []byte("runtime data"),
+		PolicyIds:       []uuid.UUID{uuid.MustParse("4d4f0f6c-6c1a-4b0e-a4d1-1f3c1b8c7e11")},
+		UserData:        []byte("user data"),
+		EventLog:        []byte("event log"),
+		TokenSigningAlg: string(RS256),
+		PolicyMustMatch: true,
+	}
+
+	jsonBody, _, err := marshalRequest(JSON, tr, json.Marshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cborBody, _, err := marshalRequest(CBOR, tr, json.Marshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fromJson, fromCbor tokenRequest
+	if err = json.Unmarshal(jsonBody, &fromJson); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cborDecMode.Unmarshal(cborBody, &fromCbor); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(fromJson, fromCbor) || !reflect.DeepEqual(tr, fromCbor) {
+		t.Fatalf("CBOR token request %+v does not match JSON token request %+v", fromCbor, fromJson)
+	}
+}
+
+func TestAttestEvidence_cbor(t *testing.T) {
+	connector, mux, _, teardown := setup()
+	defer teardown()
+
+	connector.(*trustAuthorityConnector).cfg.SerializationFormat = CBOR
+
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(headerContentType) != mimeApplicationCbor {
+			t.Errorf("Expected content type %q, got %q", mimeApplicationCbor, r.Header.Get(headerContentType))
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var evidence CompositeEvidence
+		if err = cborDecMode.Unmarshal(body, &evidence); err != nil {
+			t.Errorf("Failed to decode cbor request body: %v", err)
+		} else if evidence.TokenAudience != "audience" {
+			t.Errorf("Expected token_audience %q, got %q", "audience", evidence.TokenAudience)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"token":"` + token + `"}`))
+	})
+
+	_, err := connector.AttestEvidence(&CompositeEvidence{TokenAudience: "audience"}, "", "")
+	if err != nil {
+		t.Errorf("AttestEvidence returned unexpected error: %v", err)
+	}
+}
+
+func TestWithSerializationFormat(t *testing.T) {
+	cfg := &Config{}
+	if err := WithSerializationFormat(CBOR)(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.SerializationFormat != CBOR {
+		t.Fatalf("Expected serialization format %q, got %q", CBOR, cfg.SerializationFormat)
+	}
+
+	if err := WithSerializationFormat("xml")(cfg); err == nil {
+		t.Fatal("Expected an error for an unsupported serialization format")
+	}
+
+	if _, err := New(&Config{ApiUrl: "https://custom-url/api/v1", SerializationFormat: "xml"}); err == nil {
+		t.Fatal("Expected New to fail with an unsupported serialization format")
+	}
+}
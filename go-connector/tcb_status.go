@@ -0,0 +1,137 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+const attesterTcbStatusClaim = "attester_tcb_status"
+
+// TcbStatus is the 'attester_tcb_status' claim of an attestation token, i.e., the status
+// of the attester's TCB (platform firmware, microcode, etc.) at the time of appraisal.
+type TcbStatus string
+
+// The TCB statuses reported by Trust Authority, from the most to the least trusted (see
+// TcbStatus.AtLeast).
+const (
+	TcbStatusUpToDate                          TcbStatus = "UpToDate"
+	TcbStatusSWHardeningNeeded                 TcbStatus = "SWHardeningNeeded"
+	TcbStatusConfigurationNeeded               TcbStatus = "ConfigurationNeeded"
+	TcbStatusConfigurationAndSWHardeningNeeded TcbStatus = "ConfigurationAndSWHardeningNeeded"
+	TcbStatusOutOfDate                         TcbStatus = "OutOfDate"
+	TcbStatusOutOfDateConfigurationNeeded      TcbStatus = "OutOfDateConfigurationNeeded"
+	TcbStatusRevoked                           TcbStatus = "Revoked"
+)
+
+// tcbStatuses are the known TCB statuses, ordered from the most to the least trusted.
+var tcbStatuses = []TcbStatus{
+	TcbStatusUpToDate,
+	TcbStatusSWHardeningNeeded,
+	TcbStatusConfigurationNeeded,
+	TcbStatusConfigurationAndSWHardeningNeeded,
+	TcbStatusOutOfDate,
+	TcbStatusOutOfDateConfigurationNeeded,
+	TcbStatusRevoked,
+}
+
+// rank returns the position of 's' in tcbStatuses (lower is more trusted), or -1 if the
+// status is unknown.
+func (s TcbStatus) rank() int {
+	for i, status := range tcbStatuses {
+		if s == status {
+			return i
+		}
+	}
+	return -1
+}
+
+// AtLeast returns true when 's' is as trusted as 'min' or more (ex. UpToDate is at least
+// OutOfDate).  Unknown statuses are never at least 'min'.
+func (s TcbStatus) AtLeast(min TcbStatus) bool {
+	rank := s.rank()
+	minRank := min.rank()
+	return rank >= 0 && minRank >= 0 && rank <= minRank
+}
+
+// ParseTcbStatus returns the TcbStatus named 's'.  The name is case insensitive and may be
+// written with underscores (ex. "OUT_OF_DATE" is TcbStatusOutOfDate).
+func ParseTcbStatus(s string) (TcbStatus, error) {
+	normalized := strings.ReplaceAll(s, "_", "")
+	for _, status := range tcbStatuses {
+		if strings.EqualFold(normalized, string(status)) {
+			return status, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w %q, expected one of %v", ErrInvalidTcbStatus, s, tcbStatuses)
+}
+
+// GetTcbStatus returns the 'attester_tcb_status' claim of 'token' (ex. returned by
+// VerifyToken).  The claims of composite tokens are nested in the claims of each evidence
+// type (ex. "tdx"), in which case the least trusted status is returned.
+func GetTcbStatus(token *jwt.Token) (TcbStatus, error) {
+	if token == nil {
+		return "", errors.New("The token cannot be nil")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", errors.New("The token's claims are not a json object")
+	}
+
+	var tcbStatus TcbStatus
+	for _, scope := range claimScopes(claims) {
+		value, ok := scope[attesterTcbStatusClaim]
+		if !ok {
+			continue
+		}
+
+		name, ok := value.(string)
+		if !ok {
+			return "", errors.Errorf("Invalid %q claim, expected a string", attesterTcbStatusClaim)
+		}
+
+		status, err := ParseTcbStatus(name)
+		if err != nil {
+			return "", err
+		}
+
+		if tcbStatus == "" || tcbStatus.AtLeast(status) {
+			tcbStatus = status
+		}
+	}
+
+	if tcbStatus == "" {
+		return "", errors.Errorf("The token does not contain the %q claim", attesterTcbStatusClaim)
+	}
+
+	return tcbStatus, nil
+}
+
+// CheckMinTcbStatus returns ErrTcbStatusBelowMin unless the TCB status of 'token' (see
+// GetTcbStatus) is at least 'min', ex. to reject tokens of attesters that are OutOfDate
+// or Revoked with TcbStatusUpToDate.
+func CheckMinTcbStatus(token *jwt.Token, min TcbStatus) error {
+	if min.rank() < 0 {
+		return fmt.Errorf("%w %q, expected one of %v", ErrInvalidTcbStatus, min, tcbStatuses)
+	}
+
+	tcbStatus, err := GetTcbStatus(token)
+	if err != nil {
+		return err
+	}
+
+	if !tcbStatus.AtLeast(min) {
+		return errors.Wrapf(ErrTcbStatusBelowMin, "%s is below %s", tcbStatus, min)
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package connector
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+func TestParseTcbStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected TcbStatus
+	}{
+		{"UpToDate", TcbStatusUpToDate},
+		{"uptodate", TcbStatusUpToDate},
+		{"OUT_OF_DATE", TcbStatusOutOfDate},
+		{"REVOKED", TcbStatusRevoked},
+		{"ConfigurationAndSWHardeningNeeded", TcbStatusConfigurationAndSWHardeningNeeded},
+	}
+
+	for _, tt := range tests {
+		status, err := ParseTcbStatus(tt.name)
+		if err != nil {
+			t.Fatalf("ParseTcbStatus(%q) returned unexpected error: %v", tt.name, err)
+		}
+		if status != tt.expected {
+			t.Errorf("ParseTcbStatus(%q) returned %q, expected %q", tt.name, status, tt.expected)
+		}
+	}
+
+	for _, name := range []string{"", "Unknown", "Up To Date"} {
+		if _, err := ParseTcbStatus(name); !errors.Is(err, ErrInvalidTcbStatus) {
+			t.Errorf("Expected ErrInvalidTcbStatus for %q, got %v", name, err)
+		}
+	}
+}
+
+func TestTcbStatusAtLeast(t *testing.T) {
+	if !TcbStatusUpToDate.AtLeast(TcbStatusUpToDate) || !TcbStatusUpToDate.AtLeast(TcbStatusOutOfDate) {
+		t.Error("Expected UpToDate to be at least UpToDate and OutOfDate")
+	}
+
+	if TcbStatusOutOfDate.AtLeast(TcbStatusSWHardeningNeeded) || TcbStatusRevoked.AtLeast(TcbStatusOutOfDateConfigurationNeeded) {
+		t.Error("Expected OutOfDate and Revoked to be below the minimum")
+	}
+
+	if TcbStatus("Unknown").AtLeast(TcbStatusRevoked) || TcbStatusUpToDate.AtLeast(TcbStatus("Unknown")) {
+		t.Error("Expected unknown statuses not to be comparable")
+	}
+}
+
+func TestCheckMinTcbStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		claims      jwt.MapClaims
+		min         TcbStatus
+		expectedErr error
+	}{
+		{
+			name:   "UpToDate token, UpToDate minimum",
+			claims: jwt.MapClaims{"attester_tcb_status": "UpToDate"},
+			min:    TcbStatusUpToDate,
+		},
+		{
+			name:   "SWHardeningNeeded token, OutOfDate minimum",
+			claims: jwt.MapClaims{"attester_tcb_status": "SWHardeningNeeded"},
+			min:    TcbStatusOutOfDate,
+		},
+		{
+			name:        "OutOfDate token, UpToDate minimum",
+			claims:      jwt.MapClaims{"attester_tcb_status": "OutOfDate"},
+			min:         TcbStatusUpToDate,
+			expectedErr: ErrTcbStatusBelowMin,
+		},
+		{
+			name:        "Revoked token, OutOfDate minimum",
+			claims:      jwt.MapClaims{"attester_tcb_status": "Revoked"},
+			min:         TcbStatusOutOfDate,
+			expectedErr: ErrTcbStatusBelowMin,
+		},
+		{
+			name: "Composite token, least trusted status",
+			claims: jwt.MapClaims{
+				"tdx":    map[string]interface{}{"attester_tcb_status": "UpToDate"},
+				"sevsnp": map[string]interface{}{"attester_tcb_status": "OutOfDate"},
+			},
+			min:         TcbStatusUpToDate,
+			expectedErr: ErrTcbStatusBelowMin,
+		},
+		{
+			name:   "Composite token, UpToDate",
+			claims: jwt.MapClaims{"tdx": map[string]interface{}{"attester_tcb_status": "UpToDate"}},
+			min:    TcbStatusUpToDate,
+		},
+		{
+			name:        "Unknown token status",
+			claims:      jwt.MapClaims{"attester_tcb_status": "Unknown"},
+			min:         TcbStatusRevoked,
+			expectedErr: ErrInvalidTcbStatus,
+		},
+		{
+			name:        "Invalid minimum",
+			claims:      jwt.MapClaims{"attester_tcb_status": "UpToDate"},
+			min:         TcbStatus("Unknown"),
+			expectedErr: ErrInvalidTcbStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckMinTcbStatus(&jwt.Token{Claims: tt.claims}, tt.min)
+			if tt.expectedErr == nil && err != nil {
+				t.Fatalf("CheckMinTcbStatus returned unexpected error: %v", err)
+			} else if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+
+	// tokens without the claim (ex. TPM only tokens) do not meet any minimum
+	err := CheckMinTcbStatus(&jwt.Token{Claims: jwt.MapClaims{"tpm": map[string]interface{}{}}}, TcbStatusRevoked)
+	if err == nil {
+		t.Error("Expected an error for a token without a TCB status")
+	}
+}
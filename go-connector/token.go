@@ -7,14 +7,13 @@ package connector
 
 import (
 	"bytes"
-	"crypto/tls"
+	"crypto"
 	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -46,6 +45,16 @@ type AttestationTokenResponse struct {
 func (connector *trustAuthorityConnector) GetToken(args GetTokenArgs) (GetTokenResponse, error) {
 	url := connector.cfg.ApiUrl + args.attestEndpoint
 
+	var headers = map[string]string{
+		headerXApiKey:     connector.cfg.ApiKey,
+		headerAccept:      mimeApplicationJson,
+		headerContentType: mimeApplicationJson,
+		HeaderRequestId:   args.RequestId,
+	}
+	if connector.cfg.SerializationFormat == CBOR {
+		headers[headerContentType] = mimeApplicationCbor
+	}
+
 	newRequest := func() (*http.Request, error) {
 		tr := tokenRequest{
 			Quote:           args.Evidence.Evidence,
@@ -58,19 +67,31 @@ func (connector *trustAuthorityConnector) GetToken(args GetTokenArgs) (GetTokenR
 			PolicyMustMatch: args.PolicyMustMatch,
 		}
 
-		body, err := json.Marshal(tr)
+		body, _, err := marshalRequest(connector.cfg.SerializationFormat, tr, json.Marshal)
 		if err != nil {
 			return nil, err
 		}
 
-		return http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
-	}
+		if err = connector.checkEvidenceSize(body, tr); err != nil {
+			return nil, err
+		}
 
-	var headers = map[string]string{
-		headerXApiKey:     connector.cfg.ApiKey,
-		headerAccept:      mimeApplicationJson,
-		headerContentType: mimeApplicationJson,
-		HeaderRequestId:   args.RequestId,
+		// the request id header is added after the request is created
+		headers[HeaderRequestId] = connector.requestIdFor(args.RequestId, body)
+
+		if connector.cfg.RequestAuditSink != nil {
+			connector.cfg.RequestAuditSink(url, body)
+		}
+
+		body, contentEncoding, err := compressRequest(connector.cfg.RequestCompression, body)
+		if err != nil {
+			return nil, err
+		}
+		if contentEncoding != "" {
+			headers[headerContentEncoding] = contentEncoding
+		}
+
+		return http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
 	}
 
 	var response GetTokenResponse
@@ -81,6 +102,10 @@ func (connector *trustAuthorityConnector) GetToken(args GetTokenArgs) (GetTokenR
 			return errors.Errorf("Failed to read body from %s: %s", url, err)
 		}
 
+		if connector.cfg.ResponseAuditSink != nil {
+			connector.cfg.ResponseAuditSink(url, body, resp.Header)
+		}
+
 		var tokenResponse AttestationTokenResponse
 		err = json.Unmarshal(body, &tokenResponse)
 		if err != nil {
@@ -90,13 +115,18 @@ func (connector *trustAuthorityConnector) GetToken(args GetTokenArgs) (GetTokenR
 		return nil
 	}
 
-	if err := doRequest(*connector.rclient, connector.cfg.TlsCfg, newRequest, nil, headers, processResponse); err != nil {
+	if err := doRequest(*connector.rclient, nil, newRequest, nil, headers, processResponse); err != nil {
 		return response, err
 	}
 
 	return response, nil
 }
 
+// GetCRL is used to get the CRL Object from the CRL distribution point 'crlUrl'
+func (connector *trustAuthorityConnector) GetCRL(crlUrl string) (*x509.RevocationList, error) {
+	return getCRL(*connector.rclient, []string{crlUrl})
+}
+
 // getCRL is used to get CRL Object from CRL distribution points
 func getCRL(rclient retryablehttp.Client, crlArr []string) (*x509.RevocationList, error) {
 
@@ -115,11 +145,15 @@ func getCRL(rclient retryablehttp.Client, crlArr []string) (*x509.RevocationList
 
 	var crlObj *x509.RevocationList
 	processResponse := func(resp *http.Response) error {
-		crlBytes, err := io.ReadAll(resp.Body)
+		crlBytes, err := io.ReadAll(io.LimitReader(resp.Body, MaxCrlSize+1))
 		if err != nil {
 			return errors.Wrapf(err, "Failed to read body from %s", crlArr[0])
 		}
 
+		if len(crlBytes) > MaxCrlSize {
+			return errors.Errorf("The CRL from %s exceeds the maximum size of %d bytes", crlArr[0], MaxCrlSize)
+		}
+
 		crlObj, err = x509.ParseRevocationList([]byte(crlBytes))
 		if err != nil {
 			return errors.Wrap(err, "Failed to parse revocation list")
@@ -127,15 +161,7 @@ func getCRL(rclient retryablehttp.Client, crlArr []string) (*x509.RevocationList
 		return nil
 	}
 
-	tlsConfig := &tls.Config{
-		CipherSuites: []uint16{
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-		},
-		InsecureSkipVerify: false,
-		MinVersion:         tls.VersionTLS12,
-	}
-	if err := doRequest(rclient, tlsConfig, newRequest, nil, nil, processResponse); err != nil {
+	if err := doRequest(rclient, nil, newRequest, nil, nil, processResponse); err != nil {
 		return nil, err
 	}
 	return crlObj, nil
@@ -167,124 +193,198 @@ func verifyCRL(crl *x509.RevocationList, leafCert *x509.Certificate, caCert *x50
 
 // VerifyToken is used to do signature verification of attestation token recieved from Intel Trust Authority
 func (connector *trustAuthorityConnector) VerifyToken(token string) (*jwt.Token, error) {
-
 	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-
-		var kid string
-		keyIDValue, keyIDExists := token.Header["kid"]
-		if !keyIDExists {
-			return nil, errors.New("kid field missing in token header")
-		} else {
-			var ok bool
-			kid, ok = keyIDValue.(string)
-			if !ok {
-				return nil, errors.Errorf("kid field in jwt header is not a valid string: %v", kid)
-			}
+		kid, err := tokenKeyId(token)
+		if err != nil {
+			return nil, err
 		}
 
-		algValue, algExists := token.Header["alg"]
-		if !algExists {
-			return nil, errors.New("alg field missing in token header")
-		} else {
-			alg, ok := algValue.(string)
-			if !ok {
-				return nil, errors.Errorf("alg field in jwt header is not a valid string: %v", alg)
-			}
-			if !ValidateTokenSigningAlg(alg) {
-				return nil, fmt.Errorf("unsupported token signing algorithm, has to be RS256 or PS384")
-			}
+		// Tokens from an unexpected issuer are rejected before the signing certificates
+		// are downloaded
+		if err := checkExpectedIssuer(connector.cfg, token); err != nil {
+			return nil, err
 		}
 
-		// Get the JWT Signing Certificates from Intel Trust Authority
-		jwks, err := connector.GetTokenSigningCertificates()
-		if err != nil {
+		// Get the JWT Signing Certificates from Intel Trust Authority (or the token's
+		// issuer when Config.TrustedIssuers is provided)
+		jwks, err := connector.getIssuerSigningCertificates(token)
+		if errors.Is(err, ErrUntrustedIssuer) {
+			return nil, err
+		} else if err != nil {
 			return nil, errors.Errorf("Failed to get token signing certificates: %s", err)
 		}
 
-		// Unmarshal the JWKS
-		jwkSet, err := jwk.Parse(jwks)
-		if err != nil {
-			return nil, errors.Errorf("Unable to unmarshal response into a JWT Key Set: %s", err)
-		}
+		// the x5c chain was downloaded from the Trust Authority, its root CA is trusted and
+		// the CRLs are downloaded from the distribution points of each certificate
+		return verifyTokenSigningKey(jwks, kid, nil, nil, func(c *x509.Certificate, issuer *x509.Certificate) error {
+			crl, err := getCRL(*connector.rclient, c.CRLDistributionPoints)
+			if err != nil {
+				return errors.Errorf("Failed to get the CRL of %q: %v", c.Subject.CommonName, err)
+			}
 
-		jwkKey, found := jwkSet.LookupKeyID(kid)
-		if !found {
-			return nil, errors.New("Could not find Key matching the key id")
-		}
+			if err = verifyCRL(crl, c, issuer); err != nil {
+				return errors.Errorf("Failed to check %q against the CRL of %q: %v", c.Subject.CommonName, issuer.Subject.CommonName, err)
+			}
+			return nil
+		})
+	}, jwt.WithValidMethods(validTokenSigningMethods()))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to verify jwt token")
+	}
 
-		// Verify the cert chain. x5c field in the JWKS would contain the cert chain
-		atsCerts := jwkKey.X509CertChain()
-		if atsCerts.Len() > AtsCertChainMaxLen {
-			return nil, errors.Errorf("Token Signing Cert chain has more than %d certificates", AtsCertChainMaxLen)
-		}
+	return parsedToken, nil
+}
+
+// validTokenSigningMethods returns the jwt signing methods of tokenSigningAlgs.
+func validTokenSigningMethods() []string {
+	validMethods := make([]string, len(tokenSigningAlgs))
+	for i, alg := range tokenSigningAlgs {
+		validMethods[i] = string(alg)
+	}
+	return validMethods
+}
 
-		root := x509.NewCertPool()
-		intermediate := x509.NewCertPool()
-		var leafCert *x509.Certificate
-		var interCACert *x509.Certificate
-		var rootCert *x509.Certificate
+// tokenKeyId checks the 'alg' of the token's header and returns its 'kid'.
+func tokenKeyId(token *jwt.Token) (string, error) {
+	keyIDValue, keyIDExists := token.Header["kid"]
+	if !keyIDExists {
+		return "", errors.New("kid field missing in token header")
+	}
 
-		for i := 0; i < atsCerts.Len(); i++ {
-			atsCert, ok := atsCerts.Get(i)
-			if !ok {
-				return nil, errors.Errorf("Failed to fetch certificate at index %d", i)
-			}
+	kid, ok := keyIDValue.(string)
+	if !ok {
+		return "", errors.Errorf("kid field in jwt header is not a valid string: %v", keyIDValue)
+	}
 
-			cer, err := cert.Parse(atsCert)
-			if err != nil {
-				return nil, errors.Errorf("Failed to parse x509 certificate[%d]: %v", i, err)
-			}
+	algValue, algExists := token.Header["alg"]
+	if !algExists {
+		return "", errors.New("alg field missing in token header")
+	}
 
-			if cer.IsCA && cer.BasicConstraintsValid && strings.Contains(cer.Subject.CommonName, "Root CA") {
-				root.AddCert(cer)
-				rootCert = cer
-			} else if strings.Contains(cer.Subject.CommonName, "Signing CA") {
-				intermediate.AddCert(cer)
-				interCACert = cer
-			} else {
-				leafCert = cer
-			}
-		}
+	alg, ok := algValue.(string)
+	if !ok {
+		return "", errors.Errorf("alg field in jwt header is not a valid string: %v", algValue)
+	}
 
-		rootCrl, err := getCRL(*connector.rclient, interCACert.CRLDistributionPoints)
-		if err != nil {
-			return nil, errors.Errorf("Failed to get ROOT CA CRL Object: %v", err.Error())
-		}
+	if !ValidateTokenSigningAlg(alg) {
+		return "", fmt.Errorf("unsupported token signing algorithm %q, has to be one of %v", alg, tokenSigningAlgs)
+	}
 
-		if err = verifyCRL(rootCrl, interCACert, rootCert); err != nil {
-			return nil, errors.Errorf("Failed to check ATS CA Certificate against Root CA CRL: %v", err.Error())
+	return kid, nil
+}
+
+// verifyTokenSigningKey returns the public key of the 'kid' key in 'jwks' after verifying
+// the token signing certificate of its x5c chain against 'roots' and 'intermediates' (and
+// the chain's CA certificates).  When 'roots' is nil, the self-signed CA certificates of the
+// x5c chain are the roots.  'checkRevocation' is called with each certificate of the
+// verified chain (except the root) and its issuer.
+func verifyTokenSigningKey(jwks []byte, kid string, roots *x509.CertPool, intermediates *x509.CertPool, checkRevocation func(c *x509.Certificate, issuer *x509.Certificate) error) (interface{}, error) {
+	jwkSet, err := jwk.Parse(jwks)
+	if err != nil {
+		return nil, errors.Errorf("Unable to unmarshal response into a JWT Key Set: %s", err)
+	}
+
+	jwkKey, found := jwkSet.LookupKeyID(kid)
+	if !found {
+		return nil, errors.New("Could not find Key matching the key id")
+	}
+
+	// Verify the cert chain. x5c field in the JWKS would contain the cert chain
+	atsCerts := jwkKey.X509CertChain()
+	if atsCerts == nil || atsCerts.Len() == 0 {
+		return nil, errors.New("The token signing key does not contain an x5c certificate chain")
+	}
+	if atsCerts.Len() > AtsCertChainMaxLen {
+		return nil, errors.Errorf("Token Signing Cert chain has more than %d certificates", AtsCertChainMaxLen)
+	}
+
+	x5cRoots := x509.NewCertPool()
+	if intermediates == nil {
+		intermediates = x509.NewCertPool()
+	}
+
+	var leafCert *x509.Certificate
+	for i := 0; i < atsCerts.Len(); i++ {
+		atsCert, ok := atsCerts.Get(i)
+		if !ok {
+			return nil, errors.Errorf("Failed to fetch certificate at index %d", i)
 		}
 
-		atsCrl, err := getCRL(*connector.rclient, leafCert.CRLDistributionPoints)
+		cer, err := cert.Parse(atsCert)
 		if err != nil {
-			return nil, errors.Errorf("Failed to get ATS CRL Object: %v", err.Error())
+			return nil, errors.Errorf("Failed to parse x509 certificate[%d]: %v", i, err)
 		}
 
-		if err = verifyCRL(atsCrl, leafCert, interCACert); err != nil {
-			return nil, errors.Errorf("Failed to check ATS Leaf certificate against ATS CRL: %v", err.Error())
+		if cer.IsCA && cer.BasicConstraintsValid && bytes.Equal(cer.RawIssuer, cer.RawSubject) {
+			x5cRoots.AddCert(cer)
+		} else if cer.IsCA {
+			intermediates.AddCert(cer)
+		} else if leafCert == nil {
+			leafCert = cer
 		}
+	}
 
-		// Verify the Leaf certificate against the CA
-		opts := x509.VerifyOptions{
-			Roots:         root,
-			Intermediates: intermediate,
-		}
+	if leafCert == nil {
+		return nil, errors.New("The x5c certificate chain does not contain a token signing certificate")
+	}
 
-		if _, err := leafCert.Verify(opts); err != nil {
-			return nil, errors.Errorf("Failed to verify cert chain: %v", err)
-		}
+	if roots == nil {
+		roots = x5cRoots
+	}
 
-		// Extract the public key from JWK using exponent and modulus
-		var pubKey interface{}
-		err = jwkKey.Raw(&pubKey)
-		if err != nil {
-			return nil, errors.Errorf("Failed to extract Public Key from Certificate: %s", err)
-		}
-		return pubKey, nil
-	})
+	// Verify the Leaf certificate against the CA
+	chains, err := leafCert.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
 	if err != nil {
-		return nil, errors.Errorf("Failed to verify jwt token: %s", err)
+		return nil, errors.Errorf("Failed to verify cert chain: %v", err)
 	}
 
-	return parsedToken, nil
+	// check each certificate (except the root) against its issuer's CRL
+	chain := chains[0]
+	for i := 0; i < len(chain)-1; i++ {
+		if err = checkRevocation(chain[i], chain[i+1]); err != nil {
+			return nil, err
+		}
+	}
+
+	var pubKey interface{}
+	if err = jwkKey.Raw(&pubKey); err != nil {
+		return nil, errors.Errorf("Failed to extract Public Key from Certificate: %s", err)
+	}
+
+	// the jwk must be the key of the verified certificate
+	if !publicKeysEqual(pubKey, leafCert.PublicKey) {
+		return nil, errors.New("The token signing key does not match its certificate")
+	}
+
+	return pubKey, nil
+}
+
+// publicKeysEqual returns true when 'a' and 'b' are the same public key.
+func publicKeysEqual(a interface{}, b interface{}) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
+
+// tokenIssuer returns the 'iss' claim of 'token' (or "").
+func tokenIssuer(token *jwt.Token) string {
+	var issuer string
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		issuer, _ = claims["iss"].(string)
+	}
+	return issuer
+}
+
+// checkExpectedIssuer returns ErrUnexpectedIssuer when Config.ExpectedIssuer is provided
+// and does not match the 'iss' claim of 'token'.
+func checkExpectedIssuer(cfg *Config, token *jwt.Token) error {
+	if cfg.ExpectedIssuer == "" {
+		return nil
+	}
+
+	issuer := tokenIssuer(token)
+	if issuer != cfg.ExpectedIssuer {
+		return errors.Wrapf(ErrUnexpectedIssuer, "issuer %q, expected %q", issuer, cfg.ExpectedIssuer)
+	}
+
+	return nil
 }
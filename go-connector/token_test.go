@@ -6,13 +6,23 @@
 package connector
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/hex"
+	"encoding/json"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
 )
 
 var (
@@ -46,6 +56,81 @@ func TestGetToken(t *testing.T) {
 	}
 }
 
+func TestGetToken_statusErrors(t *testing.T) {
+	tests := []struct {
+		statusCode  int
+		expectedErr error
+	}{
+		{statusCode: http.StatusUnauthorized, expectedErr: ErrUnauthorized},
+		{statusCode: http.StatusForbidden, expectedErr: ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.statusCode), func(t *testing.T) {
+			connector, mux, _, teardown := setup()
+			defer teardown()
+
+			mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(`{"error":"unit test"}`))
+			})
+
+			_, err := connector.GetToken(GetTokenArgs{&VerifierNonce{}, &Evidence{}, nil, "req1", attestEndpoint, string(PS384), false})
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestGetToken_requestAuditSink(t *testing.T) {
+	ctr, mux, serverUrl, teardown := setup()
+	defer teardown()
+
+	var auditEndpoint string
+	var auditBody []byte
+	ctr.(*trustAuthorityConnector).cfg.RequestAuditSink = func(endpoint string, body []byte) {
+		auditEndpoint = endpoint
+		auditBody = body
+	}
+
+	var requestBody []byte
+	mux.HandleFunc(attestEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		requestBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"token":"` + token + `"}`))
+	})
+
+	nonce := &VerifierNonce{Val: []byte("val"), Iat: []byte("iat"), Signature: []byte("sig")}
+	evidence := &Evidence{Evidence: []byte("quote"), UserData: []byte("userdata")}
+	_, err := ctr.GetToken(GetTokenArgs{nonce, evidence, nil, "req1", attestEndpoint, string(PS384), false})
+	if err != nil {
+		t.Fatalf("GetToken returned unexpected error: %v", err)
+	}
+
+	expectedBody, err := json.Marshal(tokenRequest{
+		Quote:           evidence.Evidence,
+		VerifierNonce:   nonce,
+		UserData:        evidence.UserData,
+		TokenSigningAlg: string(PS384),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if auditEndpoint != serverUrl+attestEndpoint {
+		t.Errorf("Expected audit endpoint %q, got %q", serverUrl+attestEndpoint, auditEndpoint)
+	}
+
+	if !bytes.Equal(auditBody, expectedBody) {
+		t.Errorf("Expected audit body %s, got %s", expectedBody, auditBody)
+	}
+
+	if !bytes.Equal(auditBody, requestBody) {
+		t.Errorf("The audit body %s does not match the request body %s", auditBody, requestBody)
+	}
+}
+
 func TestGetToken_invalidToken(t *testing.T) {
 	connector, mux, _, teardown := setup()
 	defer teardown()
@@ -194,6 +279,46 @@ func TestGetCRLObject_invalidCRL(t *testing.T) {
 	}
 }
 
+func TestGetCRLObject_tooLarge(t *testing.T) {
+	// CRL distribution points are typically http
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write(make([]byte, MaxCrlSize+1))
+	}))
+	defer server.Close()
+
+	_, err := getCRL(*retryablehttp.NewClient(), []string{server.URL + "/ats.crl"})
+	if err == nil || !strings.Contains(err.Error(), "maximum size") {
+		t.Errorf("GetCRL returned %v, expected the CRL to exceed the maximum size", err)
+	}
+}
+
+func TestGetCRL_tlsConfig(t *testing.T) {
+	crlBytes, _ := hex.DecodeString(crlHex)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write(crlBytes)
+	}))
+	defer server.Close()
+
+	// the server's certificate is only trusted by the connector's tls configuration
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+
+	ctr, err := NewFromOptions(
+		WithApiUrl(server.URL),
+		WithApiKey("YXBpa2V5"),
+		WithTlsConfig(&tls.Config{RootCAs: rootCAs}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ctr.GetCRL(server.URL + "/ats.crl"); err != nil {
+		t.Errorf("GetCRL returned err, expected nil: %v", err)
+	}
+}
+
 func TestVerifyCRL_nullCerts(t *testing.T) {
 	var leafCert *x509.Certificate
 	var interCaCert *x509.Certificate
@@ -273,3 +398,210 @@ func TestVerifyCRL_revokedCert(t *testing.T) {
 		t.Error("verifyCRL returned nil, expected error")
 	}
 }
+
+func TestVerifyToken_signingAlgs(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method    jwt.SigningMethod
+		key       interface{}
+		supported bool
+	}{
+		{jwt.SigningMethodRS256, privateKey, true},
+		{jwt.SigningMethodRS384, privateKey, true},
+		{jwt.SigningMethodPS256, privateKey, true},
+		{jwt.SigningMethodPS384, privateKey, true},
+		{jwt.SigningMethodRS512, privateKey, false},
+		{jwt.SigningMethodPS512, privateKey, false},
+		{jwt.SigningMethodHS256, []byte("secret"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method.Alg(), func(t *testing.T) {
+			connector, mux, _, teardown := setup()
+			defer teardown()
+
+			// the signing certificates are only requested when the algorithm is supported
+			certsRequested := false
+			mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+				certsRequested = true
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(jwks))
+			})
+
+			jwtToken := jwt.NewWithClaims(tt.method, jwt.RegisteredClaims{})
+			jwtToken.Header["kid"] = "1234"
+			signedToken, err := jwtToken.SignedString(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = connector.VerifyToken(signedToken)
+			if err == nil {
+				t.Fatal("VerifyToken returned nil, expected error")
+			}
+
+			if certsRequested != tt.supported {
+				t.Errorf("expected algorithm %q supported=%v: %v", tt.method.Alg(), tt.supported, err)
+			}
+		})
+	}
+}
+
+// newRegionServer returns a test server for a Trust Authority region that counts
+// the requests for token signing certificates.
+func newRegionServer(t *testing.T, certRequests *int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+		*certRequests++
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(jwks))
+	})
+
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newIssuerToken returns an (unsigned) token with the 'iss' claim.
+func newIssuerToken(t *testing.T, issuer string) string {
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodPS384, jwt.MapClaims{"iss": issuer})
+	jwtToken.Header["kid"] = "1234"
+
+	signingString, err := jwtToken.SigningString()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signingString + ".c2lnbmF0dXJl"
+}
+
+func TestVerifyToken_trustedIssuers(t *testing.T) {
+	var usCertRequests, euCertRequests int
+	usServer := newRegionServer(t, &usCertRequests)
+	euServer := newRegionServer(t, &euCertRequests)
+
+	cfg := Config{
+		ApiUrl: "https://custom-url/api/v1",
+		TlsCfg: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+		TrustedIssuers: map[string]string{
+			"https://us.trustauthority.example.com": usServer.URL,
+			"https://eu.trustauthority.example.com": euServer.URL + "/",
+		},
+	}
+
+	connector, err := New(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the test tokens are not signed by the regional certificates, verification fails
+	// after the certificates are downloaded from the issuer's region
+	_, err = connector.VerifyToken(newIssuerToken(t, "https://us.trustauthority.example.com"))
+	if err == nil {
+		t.Fatal("VerifyToken returned nil, expected error")
+	}
+
+	if usCertRequests != 1 || euCertRequests != 0 {
+		t.Fatalf("Expected the US region's certificates, got %d US and %d EU requests", usCertRequests, euCertRequests)
+	}
+
+	_, err = connector.VerifyToken(newIssuerToken(t, "https://eu.trustauthority.example.com"))
+	if err == nil {
+		t.Fatal("VerifyToken returned nil, expected error")
+	}
+
+	if usCertRequests != 1 || euCertRequests != 1 {
+		t.Fatalf("Expected the EU region's certificates, got %d US and %d EU requests", usCertRequests, euCertRequests)
+	}
+
+	_, err = connector.VerifyToken(newIssuerToken(t, "https://untrusted.example.com"))
+	if !errors.Is(err, ErrUntrustedIssuer) {
+		t.Fatalf("Expected ErrUntrustedIssuer, got %v", err)
+	}
+
+	if usCertRequests != 1 || euCertRequests != 1 {
+		t.Fatalf("Did not expect certificate requests for an untrusted issuer, got %d US and %d EU requests", usCertRequests, euCertRequests)
+	}
+}
+
+func TestWithTrustedIssuer(t *testing.T) {
+	cfg := Config{}
+	err := WithTrustedIssuer("https://us.trustauthority.example.com", "https://us.trustauthority.example.com/")(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.TrustedIssuers["https://us.trustauthority.example.com"] != "https://us.trustauthority.example.com" {
+		t.Errorf("Unexpected trusted issuers %v", cfg.TrustedIssuers)
+	}
+
+	err = WithTrustedIssuer("https://eu.trustauthority.example.com", "http://eu.trustauthority.example.com")(&cfg)
+	if !errors.Is(err, ErrInvalidBaseUrl) {
+		t.Errorf("Expected ErrInvalidBaseUrl, got %v", err)
+	}
+
+	_, err = New(&Config{TrustedIssuers: map[string]string{"issuer": "ftp://invalid"}})
+	if !errors.Is(err, ErrInvalidBaseUrl) {
+		t.Errorf("Expected ErrInvalidBaseUrl, got %v", err)
+	}
+}
+
+func TestVerifyToken_expectedIssuer(t *testing.T) {
+	var certRequests int
+	server := newRegionServer(t, &certRequests)
+
+	connector, err := New(&Config{
+		BaseUrl: server.URL,
+		ApiUrl:  server.URL,
+		TlsCfg: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+		ExpectedIssuer: "https://us.trustauthority.example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the test token is not signed by the test certificates, verification fails after
+	// the certificates are downloaded
+	_, err = connector.VerifyToken(newIssuerToken(t, "https://us.trustauthority.example.com"))
+	if err == nil || errors.Is(err, ErrUnexpectedIssuer) {
+		t.Fatalf("Expected a signature error, got %v", err)
+	}
+
+	if certRequests != 1 {
+		t.Fatalf("Expected the certificates to be requested for the expected issuer, got %d requests", certRequests)
+	}
+
+	for _, issuer := range []string{"https://spoofed.example.com", ""} {
+		_, err = connector.VerifyToken(newIssuerToken(t, issuer))
+		if !errors.Is(err, ErrUnexpectedIssuer) {
+			t.Fatalf("Expected ErrUnexpectedIssuer for issuer %q, got %v", issuer, err)
+		}
+	}
+
+	if certRequests != 1 {
+		t.Fatalf("Did not expect certificate requests for an unexpected issuer, got %d requests", certRequests)
+	}
+}
+
+func TestWithExpectedIssuer(t *testing.T) {
+	cfg := Config{}
+	if err := WithExpectedIssuer("https://us.trustauthority.example.com")(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ExpectedIssuer != "https://us.trustauthority.example.com" {
+		t.Errorf("Unexpected expected issuer %q", cfg.ExpectedIssuer)
+	}
+
+	if err := WithExpectedIssuer("")(&cfg); err == nil {
+		t.Error("Expected an error for an empty issuer")
+	}
+}
@@ -21,6 +21,10 @@ const (
 	ccelType       = 2
 	ccelSubType    = 0
 	maxEventLength = 0x8000 // assume event will not exceed 8k (the event log can contain cert chains)
+
+	sha1DigestSize  = 20
+	algSha384       = 0xC
+	ccelEndOfEvents = 0xffffffff
 )
 
 var (
@@ -114,50 +118,81 @@ func validateCcelData(tableBytes, dataBytes []byte) error {
 // the last event.  Invalid event data (i.e., that is not TCG 2.0) will result
 // in errors.
 func parseCcelLength(ccelBytes []byte) (int64, error) {
-	reader := bytes.NewReader(ccelBytes)
-	tmpInt32 := uint32(0)
+	return walkCcel(ccelBytes, func(*ccelEvent) error { return nil })
+}
+
+// ccelEvent is a TCG_PCR_EVENT2 event of the CCEL.
+type ccelEvent struct {
+	rtmr         int // the RTMR (0-3) extended by the event
+	eventType    uint32
+	sha384Digest []byte // nil if the event does not have a SHA384 digest
+}
+
+// walkCcel calls 'fn' for each TCG_PCR_EVENT2 event in 'ccel' and returns the position
+// in the array at the end of the last event.  The first event is the
+// TCG_PCClientPCREvent (SHA1) header that is skipped.  The events' MR indexes follow the
+// UEFI CC measurement register mapping, where 1-4 correspond to RTMR0-3 (MR index 0 is the
+// MRTD, which is not extended by the event log).
+func walkCcel(ccel []byte, fn func(event *ccelEvent) error) (int64, error) {
+	reader := bytes.NewReader(ccel)
+
+	// skip the header event (mr index, event type, sha1 digest, event size and event)
+	var header struct {
+		MrIndex   uint32
+		EventType uint32
+		Digest    [sha1DigestSize]byte
+		EventSize uint32
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return 0, fmt.Errorf("%w: failed to read the header event %v", ErrorInvalidEventLog, err)
+	}
+
+	if header.EventSize > maxEventLength {
+		return 0, fmt.Errorf("%w: header event with size %d exceeded maximum size %d", ErrorInvalidEventLog, header.EventSize, maxEventLength)
+	}
+
+	if _, err := reader.Seek(int64(header.EventSize), io.SeekCurrent); err != nil {
+		return 0, fmt.Errorf("%w: failed to read the header event bytes %v", ErrorInvalidEventLog, err)
+	}
 
 	for {
-		// read RTMR index
-		err := binary.Read(reader, binary.LittleEndian, &tmpInt32)
+		offset, err := reader.Seek(0, io.SeekCurrent)
 		if err != nil {
-			if err == io.EOF {
-				break
-			}
-
-			return 0, fmt.Errorf("%w: failed to read rtmr value %v", ErrorInvalidEventLog, err)
+			return 0, err
 		}
 
-		if tmpInt32 == 0xffffffff {
-			break // 0xFFFFFFF indicates end of event log
+		var mrIndex, eventType, digestCount uint32
+		err = binary.Read(reader, binary.LittleEndian, &mrIndex)
+		if err == io.EOF || (err == nil && mrIndex == ccelEndOfEvents) {
+			return offset, nil // 0xFFFFFFFF indicates end of event log
+		} else if err != nil {
+			return 0, fmt.Errorf("%w: failed to read mr index %v", ErrorInvalidEventLog, err)
 		}
 
-		// check for valid RTMR values (there are only 4)
-		if tmpInt32 < 0 || tmpInt32 > 3 {
-			return 0, fmt.Errorf("%w: invalid rtmr value", ErrorInvalidEventLog)
+		if mrIndex < 1 || mrIndex > rtmrCount {
+			return 0, fmt.Errorf("%w: invalid mr index %d", ErrorInvalidEventLog, mrIndex)
 		}
 
-		// read event type
-		err = binary.Read(reader, binary.LittleEndian, &tmpInt32)
-		if err != nil {
+		if err = binary.Read(reader, binary.LittleEndian, &eventType); err != nil {
 			return 0, fmt.Errorf("%w: failed to read event type %v", ErrorInvalidEventLog, err)
 		}
 
-		// number of digests in event
-		err = binary.Read(reader, binary.LittleEndian, &tmpInt32)
-		if err != nil {
+		if err = binary.Read(reader, binary.LittleEndian, &digestCount); err != nil {
 			return 0, fmt.Errorf("%w: failed to read digest count %v", ErrorInvalidEventLog, err)
 		}
 
-		if tmpInt32 > 4 { // assume 4 max (sha1, sha256, sha384, sha512)
-			return 0, fmt.Errorf("%w: invalid digest count %d", ErrorInvalidEventLog, tmpInt32)
+		if digestCount > 4 { // assume 4 max (sha1, sha256, sha384, sha512)
+			return 0, fmt.Errorf("%w: invalid digest count %d", ErrorInvalidEventLog, digestCount)
+		}
+
+		event := ccelEvent{
+			rtmr:      int(mrIndex) - 1,
+			eventType: eventType,
 		}
 
-		for i := 0; i < int(tmpInt32); i++ {
-			// digest algorithm
+		for i := 0; i < int(digestCount); i++ {
 			alg := uint16(0)
-			err = binary.Read(reader, binary.LittleEndian, &alg)
-			if err != nil {
+			if err = binary.Read(reader, binary.LittleEndian, &alg); err != nil {
 				return 0, fmt.Errorf("%w: failed to read digest algorithm %v", ErrorInvalidEventLog, err)
 			}
 
@@ -167,7 +202,7 @@ func parseCcelLength(ccelBytes []byte) (int64, error) {
 				h = crypto.SHA1
 			case 0xB:
 				h = crypto.SHA256
-			case 0xC:
+			case algSha384:
 				h = crypto.SHA384
 			case 0xD:
 				h = crypto.SHA512
@@ -175,36 +210,34 @@ func parseCcelLength(ccelBytes []byte) (int64, error) {
 				return 0, fmt.Errorf("%w: unsupported digest algorithm %d", ErrorInvalidEventLog, alg)
 			}
 
-			// skip the length of the digest
-			_, err := reader.Seek(int64(h.Size()), io.SeekCurrent)
-			if err != nil {
+			digest := make([]byte, h.Size())
+			if _, err = io.ReadFull(reader, digest); err != nil {
 				return 0, fmt.Errorf("%w: failed to read digest bytes %v", ErrorInvalidEventLog, err)
 			}
+
+			if alg == algSha384 {
+				event.sha384Digest = digest
+			}
 		}
 
-		// read event size
-		err = binary.Read(reader, binary.LittleEndian, &tmpInt32)
-		if err != nil {
+		var eventSize uint32
+		if err = binary.Read(reader, binary.LittleEndian, &eventSize); err != nil {
 			return 0, fmt.Errorf("%w: failed to read event size %v", ErrorInvalidEventLog, err)
 		}
 
-		if tmpInt32 < 0 || tmpInt32 > maxEventLength {
-			return 0, fmt.Errorf("%w: event entry with size %d exceeded maximum size %d", ErrorInvalidEventLog, tmpInt32, maxEventLength)
+		if eventSize > maxEventLength {
+			return 0, fmt.Errorf("%w: event entry with size %d exceeded maximum size %d", ErrorInvalidEventLog, eventSize, maxEventLength)
 		}
 
 		// skip the length of the event data
-		_, err = reader.Seek(int64(tmpInt32), io.SeekCurrent)
-		if err != nil {
+		if _, err = reader.Seek(int64(eventSize), io.SeekCurrent); err != nil {
 			return 0, fmt.Errorf("%w: failed to read event bytes %v", ErrorInvalidEventLog, err)
 		}
-	}
 
-	offset, err := reader.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return 0, err
+		if err = fn(&event); err != nil {
+			return 0, err
+		}
 	}
-
-	return offset, nil
 }
 
 // https://github.com/torvalds/linux/blob/cdd30ebb1b9f36159d66f088b61aee264e649d7a/include/acpi/actbl.h#L68
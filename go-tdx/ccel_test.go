@@ -138,22 +138,46 @@ func TestInvalidMinimumLength(t *testing.T) {
 }
 
 func TestInvalidPcr(t *testing.T) {
-	nelEvent := &testNelEvent{
-		pcr: 4, // RTMRs should be between 0 and 3
+	for _, mrIndex := range []uint32{0, 5} { // MR indexes 1-4 correspond to RTMR0-3
+		nelEvent := &testNelEvent{
+			pcr: mrIndex,
+		}
+
+		_, err := parseCcelLength(nelEvent.marshalWithHeader())
+		if !errors.Is(err, ErrorInvalidEventLog) {
+			t.Fatalf("Expected error ErrorInvalidEventLog for mr index %d", mrIndex)
+		}
 	}
+}
 
-	_, err := parseCcelLength(nelEvent.marshal())
-	if !errors.Is(err, ErrorInvalidEventLog) {
-		t.Fatal("Expected error ErrorInvalidEventLog")
+func TestValidPcr(t *testing.T) {
+	for mrIndex := uint32(1); mrIndex <= rtmrCount; mrIndex++ {
+		nelEvent := &testNelEvent{
+			pcr:         mrIndex,
+			digestCount: 1,
+			alg:         0x0004,
+			eventSize:   32,
+		}
+
+		ccel := nelEvent.marshalWithHeader()
+		length, err := parseCcelLength(ccel)
+		if err != nil {
+			t.Fatalf("Unexpected error for mr index %d: %v", mrIndex, err)
+		}
+
+		if length != int64(len(ccel)) {
+			t.Fatalf("Expected length %d, got %d", len(ccel), length)
+		}
 	}
 }
 
 func TestInvalidDigestCount(t *testing.T) {
 	nelEvent := &testNelEvent{
+		pcr:         1,
 		digestCount: 5,
 	}
 
-	_, err := parseCcelLength(nelEvent.marshal())
+	_, err := parseCcelLength(nelEvent.marshalWithHeader())
 	if !errors.Is(err, ErrorInvalidEventLog) {
 		t.Fatal("Expected error ErrorInvalidEventLog")
 	}
@@ -161,11 +185,12 @@ func TestInvalidDigestCount(t *testing.T) {
 
 func TestInvalidAlg(t *testing.T) {
 	nelEvent := &testNelEvent{
+		pcr:         1,
 		digestCount: 1,
 		alg:         uint16(0xFFFF),
 	}
 
-	_, err := parseCcelLength(nelEvent.marshal())
+	_, err := parseCcelLength(nelEvent.marshalWithHeader())
 	if !errors.Is(err, ErrorInvalidEventLog) {
 		t.Fatal("Expected error ErrorInvalidEventLog")
 	}
@@ -173,12 +198,13 @@ func TestInvalidAlg(t *testing.T) {
 
 func TestInvalidEventSize(t *testing.T) {
 	nelEvent := &testNelEvent{
+		pcr:         1,
 		digestCount: 1,
 		alg:         0x0004,
 		eventSize:   0x8001,
 	}
 
-	_, err := parseCcelLength(nelEvent.marshal())
+	_, err := parseCcelLength(nelEvent.marshalWithHeader())
 	if !errors.Is(err, ErrorInvalidEventLog) {
 		t.Fatal("Expected error ErrorInvalidEventLog")
 	}
@@ -213,3 +239,26 @@ func (evt testNelEvent) marshal() []byte {
 
 	return buf.Bytes()
 }
+
+// marshalWithHeader returns the event preceded by a TCG_PCClientPCREvent header event.
+func (evt testNelEvent) marshalWithHeader() []byte {
+	header := struct {
+		MrIndex   uint32
+		EventType uint32
+		Digest    [sha1DigestSize]byte
+		EventSize uint32
+		Event     [16]byte
+	}{
+		MrIndex:   1,
+		EventType: evNoAction,
+		EventSize: 16,
+	}
+
+	buf := new(bytes.Buffer)
+	err := binary.Write(buf, binary.LittleEndian, header)
+	if err != nil {
+		log.Fatalf("Failed to encode the header event: %v", err)
+	}
+
+	return append(buf.Bytes(), evt.marshal()...)
+}
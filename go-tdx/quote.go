@@ -0,0 +1,299 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package tdx
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	quoteHeaderSize  = 48
+	tdReportBodySize = 584
+	mrtdSize         = 48
+	quoteVersion4    = 4
+	quoteVersion5    = 5
+	teeTypeTdx       = 0x00000081
+	teeTypeSgx       = 0x00000000 // reserved (zero) in v3 SGX quotes
+
+	// the smallest possible quote: header, td report body and the signature data length
+	minQuoteSize = quoteHeaderSize + tdReportBodySize + 4
+
+	// v5 quotes include the body's type and size before the td report body
+	quoteV5BodyDescriptorSize = 2 + 4
+	tdReportBody15Size        = 648 // TDX 1.5 (v5 body type 3)
+
+	// ecdsa signature and attestation public key in the quote's signature data
+	quoteSignatureSize = 64 + 64
+	qeReportSize       = 384
+	qeReportSigSize    = 64
+
+	certDataTypePckCertChain = 5
+	certDataTypeQeReport     = 6
+)
+
+var (
+	// SGX extensions in PCK certificates (see "Intel SGX PCK Certificate and Certificate
+	// Revocation List Profile Specification")
+	oidSgxExtensions = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1}
+	oidPceId         = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 3}
+	oidFmspc         = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 4}
+)
+
+var (
+	ErrorInvalidQuoteSize     = errors.New("invalid quote size")
+	ErrorInvalidQuoteVersion  = errors.New("invalid quote version")
+	ErrorInvalidQuoteTeeType  = errors.New("invalid quote tee type")
+	ErrorInvalidQuoteCertData = errors.New("invalid quote certification data")
+
+	// ErrUnexpectedTeeType is returned when the quote's header identifies a TEE other
+	// than TDX (ex. the quote service of a misconfigured host returned an SGX quote).
+	ErrUnexpectedTeeType = fmt.Errorf("%w: unexpected TEE type", ErrorInvalidQuoteTeeType)
+)
+
+// https://download.01.org/intel-sgx/latest/dcap-latest/linux/docs/Intel_TDX_DCAP_Quoting_Library_API.pdf
+// (see "Quote Header" in appendix A.3)
+//
+//	typedef struct {
+//		uint16_t version;
+//		uint16_t att_key_type;
+//		uint32_t tee_type;
+//		uint16_t reserved_1;
+//		uint16_t reserved_2;
+//		uint8_t qe_vendor_id[16];
+//		uint8_t user_data[20];
+//	} sgx_quote_header_t;
+type quoteHeader struct {
+	Version    uint16
+	AttKeyType uint16
+	TeeType    uint32
+	Reserved1  uint16
+	Reserved2  uint16
+	QeVendorId [16]byte
+	UserData   [20]byte
+}
+
+// validateQuoteHeader performs basic sanity checks on the quote's header (i.e., its
+// size, version and TEE type) so that malformed quotes are reported on the client
+// as opposed to later in the backend.
+func validateQuoteHeader(quote []byte) error {
+	if len(quote) < minQuoteSize {
+		return fmt.Errorf("%w: the quote's length %d is less than the minimum %d", ErrorInvalidQuoteSize, len(quote), minQuoteSize)
+	}
+
+	var header quoteHeader
+	err := binary.Read(bytes.NewReader(quote[:quoteHeaderSize]), binary.LittleEndian, &header)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrorInvalidQuoteSize, err)
+	}
+
+	// check the TEE type first, SGX quotes are typically v3
+	if err = checkTeeType(&header); err != nil {
+		return err
+	}
+
+	if header.Version != quoteVersion4 && header.Version != quoteVersion5 {
+		return fmt.Errorf("%w: %d", ErrorInvalidQuoteVersion, header.Version)
+	}
+
+	return nil
+}
+
+// checkQuoteTeeType returns ErrUnexpectedTeeType when the header of 'quote' identifies
+// a TEE other than TDX.  Quotes that are too short to include a header are left to
+// validateQuoteHeader (or the backend).
+func checkQuoteTeeType(quote []byte) error {
+	if len(quote) < quoteHeaderSize {
+		return nil
+	}
+
+	var header quoteHeader
+	err := binary.Read(bytes.NewReader(quote[:quoteHeaderSize]), binary.LittleEndian, &header)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrorInvalidQuoteSize, err)
+	}
+
+	return checkTeeType(&header)
+}
+
+func checkTeeType(header *quoteHeader) error {
+	if header.TeeType != teeTypeTdx {
+		return fmt.Errorf("%w: found %s (version %d), expected %s", ErrUnexpectedTeeType, teeTypeName(header.TeeType), header.Version, teeTypeName(teeTypeTdx))
+	}
+
+	return nil
+}
+
+// teeTypeName returns the name of the quote header's tee_type (ex. "TDX").
+func teeTypeName(teeType uint32) string {
+	switch teeType {
+	case teeTypeTdx:
+		return "TDX"
+	case teeTypeSgx:
+		return "SGX"
+	default:
+		return fmt.Sprintf("unknown TEE type 0x%x", teeType)
+	}
+}
+
+// QuoteInfo contains TCB related fields of a TD quote that are useful for logging/inventory
+// on the client (i.e., before the quote is appraised by ITA).
+type QuoteInfo struct {
+	Version   uint16   // quote version (4 or 5)
+	TeeTcbSvn [16]byte // TEE_TCB_SVN from the td report body
+	Fmspc     [6]byte  // FMSPC from the PCK certificate
+	PceId     [2]byte  // PCE ID from the PCK certificate
+}
+
+func (qi *QuoteInfo) String() string {
+	return fmt.Sprintf("version=%d tee_tcb_svn=%s fmspc=%s pce_id=%s",
+		qi.Version,
+		hex.EncodeToString(qi.TeeTcbSvn[:]),
+		hex.EncodeToString(qi.Fmspc[:]),
+		hex.EncodeToString(qi.PceId[:]))
+}
+
+// ParseQuoteInfo returns the TEE TCB SVN, FMSPC and PCE ID of a raw (v4 or v5) TD quote.
+// The FMSPC and PCE ID are parsed from the PCK certificate in the quote's certification
+// data.
+func ParseQuoteInfo(quote []byte) (*QuoteInfo, error) {
+	decoded, err := DecodeQuote(quote)
+	if err != nil {
+		return nil, err
+	}
+
+	return decoded.Info()
+}
+
+// Info returns the TEE TCB SVN, FMSPC and PCE ID of the quote (see ParseQuoteInfo).
+func (q *Quote) Info() (*QuoteInfo, error) {
+	info := q.tcbInfo()
+
+	pckCert, err := q.SignatureData.pckCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ext := range pckCert.Extensions {
+		if !ext.Id.Equal(oidSgxExtensions) {
+			continue
+		}
+
+		var sgxExtensions []struct {
+			Id    asn1.ObjectIdentifier
+			Value asn1.RawValue
+		}
+		if _, err = asn1.Unmarshal(ext.Value, &sgxExtensions); err != nil {
+			return nil, fmt.Errorf("%w: failed to parse the PCK certificate's SGX extensions: %v", ErrorInvalidQuoteCertData, err)
+		}
+
+		for _, sgxExt := range sgxExtensions {
+			switch {
+			case sgxExt.Id.Equal(oidFmspc) && len(sgxExt.Value.Bytes) == len(info.Fmspc):
+				copy(info.Fmspc[:], sgxExt.Value.Bytes)
+			case sgxExt.Id.Equal(oidPceId) && len(sgxExt.Value.Bytes) == len(info.PceId):
+				copy(info.PceId[:], sgxExt.Value.Bytes)
+			}
+		}
+
+		return info, nil
+	}
+
+	return nil, fmt.Errorf("%w: the PCK certificate does not contain SGX extensions", ErrorInvalidQuoteCertData)
+}
+
+// tcbInfo returns the quote's version and TEE TCB SVN (i.e., without the FMSPC and PCE ID
+// from the PCK certificate).
+func (q *Quote) tcbInfo() *QuoteInfo {
+	info := QuoteInfo{
+		Version: q.Header.Version,
+	}
+	copy(info.TeeTcbSvn[:], q.TdReportBody.TeeTcbSvn)
+	return &info
+}
+
+// pckCertificate returns the PCK (leaf) certificate of the signature data's PCK
+// certificate chain.
+func (sd *QuoteSignatureData) pckCertificate() (*x509.Certificate, error) {
+	if sd.CertificationDataType != certDataTypeQeReport {
+		return nil, fmt.Errorf("%w: expected certification data type %d, got %d", ErrorInvalidQuoteCertData, certDataTypeQeReport, sd.CertificationDataType)
+	}
+
+	block, _ := pem.Decode([]byte(sd.PckCertChain))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%w: failed to decode the PCK certificate", ErrorInvalidQuoteCertData)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidQuoteCertData, err)
+	}
+
+	return cert, nil
+}
+
+// readTdReportBody returns the td report body of a (validated) v4 or v5 quote and a reader
+// positioned at the quote's signature data.
+func readTdReportBody(quote []byte) ([]byte, *bytes.Reader, error) {
+	// locate the td report body (v5 quotes describe the body's size)
+	reader := bytes.NewReader(quote[quoteHeaderSize:])
+	bodySize := tdReportBodySize
+	if binary.LittleEndian.Uint16(quote) == quoteVersion5 {
+		var bodyType uint16
+		var size uint32
+		if err := binary.Read(reader, binary.LittleEndian, &bodyType); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrorInvalidQuoteSize, err)
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrorInvalidQuoteSize, err)
+		}
+		if size != tdReportBodySize && size != tdReportBody15Size {
+			return nil, nil, fmt.Errorf("%w: unexpected td report body size %d", ErrorInvalidQuoteSize, size)
+		}
+		bodySize = int(size)
+	}
+
+	body := make([]byte, bodySize)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to read the td report body", ErrorInvalidQuoteSize)
+	}
+
+	return body, reader, nil
+}
+
+// readCertificationData reads certification data of 'expectedType' from 'reader'.
+func readCertificationData(reader *bytes.Reader, expectedType uint16) ([]byte, error) {
+	var header struct {
+		Type uint16
+		Size uint32
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidQuoteCertData, err)
+	}
+
+	if header.Type != expectedType {
+		return nil, fmt.Errorf("%w: expected certification data type %d, got %d", ErrorInvalidQuoteCertData, expectedType, header.Type)
+	}
+
+	if int64(header.Size) > int64(reader.Len()) {
+		return nil, fmt.Errorf("%w: the certification data size %d exceeds the remaining %d bytes", ErrorInvalidQuoteCertData, header.Size, reader.Len())
+	}
+
+	data := make([]byte, header.Size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidQuoteCertData, err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,295 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package tdx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// HexBytes are binary quote fields that are hex encoded in json.
+type HexBytes []byte
+
+func (hb HexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(hb))
+}
+
+func (hb *HexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+
+	*hb = b
+	return nil
+}
+
+// QuoteHeader is the header of a TD quote (see "Quote Header" in appendix A.3 of the
+// Intel TDX DCAP Quoting Library API).
+type QuoteHeader struct {
+	Version    uint16   `json:"version"`
+	AttKeyType uint16   `json:"att_key_type"`
+	TeeType    uint32   `json:"tee_type"`
+	QeVendorId HexBytes `json:"qe_vendor_id"`
+	UserData   HexBytes `json:"user_data"`
+}
+
+// TdReportBody contains the measurements of a TD quote's report body.  TeeTcbSvn2 and
+// MrServiceTd are only populated for TDX 1.5 (v5 quotes with a type 3 body).
+type TdReportBody struct {
+	TeeTcbSvn      HexBytes    `json:"tee_tcb_svn"`
+	MrSeam         HexBytes    `json:"mr_seam"`
+	MrSignerSeam   HexBytes    `json:"mr_signer_seam"`
+	SeamAttributes HexBytes    `json:"seam_attributes"`
+	TdAttributes   HexBytes    `json:"td_attributes"`
+	Xfam           HexBytes    `json:"xfam"`
+	MrTd           HexBytes    `json:"mr_td"`
+	MrConfigId     HexBytes    `json:"mr_config_id"`
+	MrOwner        HexBytes    `json:"mr_owner"`
+	MrOwnerConfig  HexBytes    `json:"mr_owner_config"`
+	Rtmrs          [4]HexBytes `json:"rtmrs"`
+	ReportData     HexBytes    `json:"report_data"`
+	TeeTcbSvn2     HexBytes    `json:"tee_tcb_svn2,omitempty"`
+	MrServiceTd    HexBytes    `json:"mr_service_td,omitempty"`
+}
+
+// QuoteSignatureData is the signature section of a TD quote.  The QE report, its signature
+// and the PCK certificate chain are only populated when the quote contains QE report
+// certification data (type 6).
+type QuoteSignatureData struct {
+	Signature             HexBytes `json:"signature"`
+	AttestationKey        HexBytes `json:"attestation_key"`
+	CertificationDataType uint16   `json:"certification_data_type"`
+	CertificationDataSize uint32   `json:"certification_data_size"`
+	QeReport              HexBytes `json:"qe_report,omitempty"`
+	QeReportSignature     HexBytes `json:"qe_report_signature,omitempty"`
+	PckCertChain          string   `json:"pck_cert_chain,omitempty"`
+}
+
+// Quote is a decoded TD quote (see DecodeQuote).
+type Quote struct {
+	Header        QuoteHeader        `json:"header"`
+	TdReportBody  TdReportBody       `json:"td_report_body"`
+	SignatureData QuoteSignatureData `json:"signature_data"`
+}
+
+// DecodeQuote decodes the header, td report body and signature data of a raw (v4 or v5)
+// TD quote.  It is intended for troubleshooting (ex. comparing MRTD/RTMRs with the values
+// expected by a policy) and does not verify the quote's signature.
+func DecodeQuote(rawQuote []byte) (*Quote, error) {
+	err := validateQuoteHeader(rawQuote)
+	if err != nil {
+		return nil, err
+	}
+
+	var header quoteHeader
+	err = binary.Read(bytes.NewReader(rawQuote[:quoteHeaderSize]), binary.LittleEndian, &header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidQuoteSize, err)
+	}
+
+	body, reader, err := readTdReportBody(rawQuote)
+	if err != nil {
+		return nil, err
+	}
+
+	signatureData, err := decodeQuoteSignatureData(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Quote{
+		Header: QuoteHeader{
+			Version:    header.Version,
+			AttKeyType: header.AttKeyType,
+			TeeType:    header.TeeType,
+			QeVendorId: header.QeVendorId[:],
+			UserData:   header.UserData[:],
+		},
+		TdReportBody:  decodeTdReportBody(body),
+		SignatureData: *signatureData,
+	}, nil
+}
+
+// decodeTdReportBody splits the td report body returned by readTdReportBody into its
+// fields.
+//
+//	tee_tcb_svn        [16]byte
+//	mr_seam            [48]byte
+//	mr_signer_seam     [48]byte
+//	seam_attributes    [8]byte
+//	td_attributes      [8]byte
+//	xfam               [8]byte
+//	mr_td              [48]byte
+//	mr_config_id       [48]byte
+//	mr_owner           [48]byte
+//	mr_owner_config    [48]byte
+//	rtmr0-3            [4][48]byte
+//	report_data        [64]byte
+//	tee_tcb_svn2       [16]byte (TDX 1.5)
+//	mr_service_td      [48]byte (TDX 1.5)
+func decodeTdReportBody(body []byte) TdReportBody {
+	offset := 0
+	next := func(size int) HexBytes {
+		field := HexBytes(body[offset : offset+size])
+		offset += size
+		return field
+	}
+
+	var reportBody TdReportBody
+	reportBody.TeeTcbSvn = next(16)
+	reportBody.MrSeam = next(48)
+	reportBody.MrSignerSeam = next(48)
+	reportBody.SeamAttributes = next(8)
+	reportBody.TdAttributes = next(8)
+	reportBody.Xfam = next(8)
+	reportBody.MrTd = next(mrtdSize)
+	reportBody.MrConfigId = next(48)
+	reportBody.MrOwner = next(48)
+	reportBody.MrOwnerConfig = next(48)
+	for i := range reportBody.Rtmrs {
+		reportBody.Rtmrs[i] = next(rtmrSize)
+	}
+	reportBody.ReportData = next(reportDataSize)
+
+	if len(body) == tdReportBody15Size {
+		reportBody.TeeTcbSvn2 = next(16)
+		reportBody.MrServiceTd = next(48)
+	}
+
+	return reportBody
+}
+
+// decodeQuoteSignatureData reads the quote's signature data from 'reader'.  The QE
+// report, its signature and the PCK certificate chain are only read when the certification
+// data is a QE report (type 6).
+//
+//	signature data length      uint32
+//	ecdsa signature            [64]byte
+//	attestation public key     [64]byte
+//	certification data type    uint16 (6: QE report certification data)
+//	certification data size    uint32
+//	qe report                  [384]byte
+//	qe report signature        [64]byte
+//	qe auth data size          uint16
+//	qe auth data               [size]byte
+//	certification data type    uint16 (5: PCK certificate chain)
+//	certification data size    uint32
+//	pem certificate chain      [size]byte
+func decodeQuoteSignatureData(reader *bytes.Reader) (*QuoteSignatureData, error) {
+	var sigDataSize uint32
+	if err := binary.Read(reader, binary.LittleEndian, &sigDataSize); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidQuoteCertData, err)
+	}
+
+	var sigData struct {
+		Signature      [64]byte
+		AttestationKey [64]byte
+		CertDataType   uint16
+		CertDataSize   uint32
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &sigData); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidQuoteCertData, err)
+	}
+
+	signatureData := QuoteSignatureData{
+		Signature:             sigData.Signature[:],
+		AttestationKey:        sigData.AttestationKey[:],
+		CertificationDataType: sigData.CertDataType,
+		CertificationDataSize: sigData.CertDataSize,
+	}
+
+	if sigData.CertDataType != certDataTypeQeReport {
+		return &signatureData, nil
+	}
+
+	if int64(sigData.CertDataSize) > int64(reader.Len()) {
+		return nil, fmt.Errorf("%w: the certification data size %d exceeds the remaining %d bytes", ErrorInvalidQuoteCertData, sigData.CertDataSize, reader.Len())
+	}
+
+	certData := make([]byte, sigData.CertDataSize)
+	if _, err := io.ReadFull(reader, certData); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidQuoteCertData, err)
+	}
+
+	qeReader := bytes.NewReader(certData)
+	qeReport := make([]byte, qeReportSize)
+	qeReportSig := make([]byte, qeReportSigSize)
+	var authDataSize uint16
+	if _, err := io.ReadFull(qeReader, qeReport); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidQuoteCertData, err)
+	}
+	if _, err := io.ReadFull(qeReader, qeReportSig); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidQuoteCertData, err)
+	}
+	if err := binary.Read(qeReader, binary.LittleEndian, &authDataSize); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidQuoteCertData, err)
+	}
+	if _, err := qeReader.Seek(int64(authDataSize), io.SeekCurrent); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidQuoteCertData, err)
+	}
+
+	pemChain, err := readCertificationData(qeReader, certDataTypePckCertChain)
+	if err != nil {
+		return nil, err
+	}
+
+	signatureData.QeReport = qeReport
+	signatureData.QeReportSignature = qeReportSig
+	signatureData.PckCertChain = string(bytes.TrimRight(pemChain, "\x00"))
+	return &signatureData, nil
+}
+
+// String returns the quote's header and td report body in a human readable format.  The
+// first line is the quote's QuoteInfo (the FMSPC and PCE ID are zero when the quote does not
+// contain a PCK certificate).
+func (q *Quote) String() string {
+	var sb strings.Builder
+	line := func(name string, value interface{}) {
+		fmt.Fprintf(&sb, "%-18s %v\n", name+":", value)
+	}
+
+	info, err := q.Info()
+	if err != nil {
+		info = q.tcbInfo()
+	}
+	fmt.Fprintln(&sb, info)
+
+	line("att_key_type", q.Header.AttKeyType)
+	line("tee_type", fmt.Sprintf("0x%x", q.Header.TeeType))
+	line("qe_vendor_id", hex.EncodeToString(q.Header.QeVendorId))
+	line("mr_seam", hex.EncodeToString(q.TdReportBody.MrSeam))
+	line("mr_signer_seam", hex.EncodeToString(q.TdReportBody.MrSignerSeam))
+	line("seam_attributes", hex.EncodeToString(q.TdReportBody.SeamAttributes))
+	line("td_attributes", hex.EncodeToString(q.TdReportBody.TdAttributes))
+	line("xfam", hex.EncodeToString(q.TdReportBody.Xfam))
+	line("mr_td", hex.EncodeToString(q.TdReportBody.MrTd))
+	line("mr_config_id", hex.EncodeToString(q.TdReportBody.MrConfigId))
+	line("mr_owner", hex.EncodeToString(q.TdReportBody.MrOwner))
+	line("mr_owner_config", hex.EncodeToString(q.TdReportBody.MrOwnerConfig))
+	for i, rtmr := range q.TdReportBody.Rtmrs {
+		line(fmt.Sprintf("rtmr%d", i), hex.EncodeToString(rtmr))
+	}
+	line("report_data", hex.EncodeToString(q.TdReportBody.ReportData))
+	if q.TdReportBody.TeeTcbSvn2 != nil {
+		line("tee_tcb_svn2", hex.EncodeToString(q.TdReportBody.TeeTcbSvn2))
+		line("mr_service_td", hex.EncodeToString(q.TdReportBody.MrServiceTd))
+	}
+	line("cert_data_type", q.SignatureData.CertificationDataType)
+
+	return sb.String()
+}
@@ -0,0 +1,147 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package tdx
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testAzureQuoteMrTd = "bb379f8e734a755832509f61403f99db2258a70a01e1172a499d6d364101b0675455b4e372a35c1f006541f2de0d7154"
+
+func TestDecodeQuote(t *testing.T) {
+	rawQuote, err := os.ReadFile(testAzureQuotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quote, err := DecodeQuote(rawQuote)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if quote.Header.Version != quoteVersion4 || quote.Header.TeeType != teeTypeTdx {
+		t.Errorf("unexpected quote header %+v", quote.Header)
+	}
+
+	if hex.EncodeToString(quote.TdReportBody.MrTd) != testAzureQuoteMrTd {
+		t.Errorf("expected mr_td %s, got %x", testAzureQuoteMrTd, quote.TdReportBody.MrTd)
+	}
+
+	if hex.EncodeToString(quote.TdReportBody.TeeTcbSvn) != "04010700000000000000000000000000" {
+		t.Errorf("unexpected tee_tcb_svn %x", quote.TdReportBody.TeeTcbSvn)
+	}
+
+	// the RTMRs are at the same offset used to verify quotes against the CCEL
+	body, _, err := readTdReportBody(rawQuote)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, rtmr := range quote.TdReportBody.Rtmrs {
+		offset := rtmrOffset + i*rtmrSize
+		if !reflect.DeepEqual([]byte(rtmr), body[offset:offset+rtmrSize]) {
+			t.Errorf("unexpected rtmr%d %x", i, rtmr)
+		}
+	}
+
+	if len(quote.TdReportBody.ReportData) != reportDataSize {
+		t.Errorf("expected %d bytes of report data, got %d", reportDataSize, len(quote.TdReportBody.ReportData))
+	}
+
+	if quote.TdReportBody.TeeTcbSvn2 != nil || quote.TdReportBody.MrServiceTd != nil {
+		t.Error("did not expect TDX 1.5 fields in a v4 quote")
+	}
+
+	if quote.SignatureData.CertificationDataType != certDataTypeQeReport {
+		t.Errorf("expected certification data type %d, got %d", certDataTypeQeReport, quote.SignatureData.CertificationDataType)
+	}
+
+	if len(quote.SignatureData.QeReport) != qeReportSize {
+		t.Errorf("expected a %d byte qe report, got %d", qeReportSize, len(quote.SignatureData.QeReport))
+	}
+
+	if !strings.HasPrefix(quote.SignatureData.PckCertChain, "-----BEGIN CERTIFICATE-----") {
+		t.Errorf("expected a pem certificate chain, got %q", quote.SignatureData.PckCertChain)
+	}
+
+	if !strings.Contains(quote.String(), "mr_td:             "+testAzureQuoteMrTd+"\n") {
+		t.Errorf("expected mr_td in %s", quote.String())
+	}
+
+	// the quote's info matches ParseQuoteInfo and is the first line of String()
+	info, err := quote.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsedInfo, err := ParseQuoteInfo(rawQuote)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *info != *parsedInfo {
+		t.Errorf("expected quote info %v, got %v", parsedInfo, info)
+	}
+
+	if !strings.HasPrefix(quote.String(), info.String()+"\n") {
+		t.Errorf("expected %q as the first line of %s", info, quote.String())
+	}
+}
+
+func TestDecodeQuoteJson(t *testing.T) {
+	rawQuote, err := os.ReadFile(testAzureQuotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quote, err := DecodeQuote(rawQuote)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quoteJson, err := json.Marshal(quote)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(quoteJson), `"mr_td":"`+testAzureQuoteMrTd+`"`) {
+		t.Errorf("expected a hex encoded mr_td in %s", quoteJson)
+	}
+
+	var decoded Quote
+	if err = json.Unmarshal(quoteJson, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(&decoded, quote) {
+		t.Errorf("expected %+v, got %+v", quote, decoded)
+	}
+}
+
+func TestDecodeQuoteInvalid(t *testing.T) {
+	_, err := DecodeQuote([]byte("quote"))
+	if !errors.Is(err, ErrorInvalidQuoteSize) {
+		t.Fatalf("expected ErrorInvalidQuoteSize, got %v", err)
+	}
+
+	// the test quote's signature data is truncated
+	rawQuote, err := os.ReadFile(testQuotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = DecodeQuote(rawQuote)
+	if !errors.Is(err, ErrorInvalidQuoteCertData) {
+		t.Fatalf("expected ErrorInvalidQuoteCertData, got %v", err)
+	}
+}
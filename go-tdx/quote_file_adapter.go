@@ -0,0 +1,106 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package tdx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"os"
+
+	"github.com/intel/trustauthority-client/go-connector"
+	"github.com/pkg/errors"
+)
+
+// quoteFileAdapter implements CompositeEvidenceAdapter using a TD quote that
+// was previously captured to a file (i.e., it does not collect a quote from the
+// host).
+type quoteFileAdapter struct {
+	quote    []byte
+	userData []byte
+}
+
+// NewQuoteFileAdapter returns a CompositeEvidenceAdapter that loads a TD quote from
+// 'path' and includes it in TDX evidence.  This is useful for attesting quotes that
+// were collected separately (ex. during support/debugging) without re-running the
+// collection on the TDX host.
+//
+// Since the quote already exists, its report data cannot be bound to a new verifier
+// nonce or user data.  'userData' (when not nil) is included as the evidence's runtime
+// data and must match the data that was hashed into the quote when it was created.
+func NewQuoteFileAdapter(path string, userData []byte) (connector.CompositeEvidenceAdapter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read quote file %q", path)
+	}
+	defer f.Close()
+
+	adapter, err := NewQuoteReaderAdapter(f, userData)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid quote in file %q", path)
+	}
+
+	return adapter, nil
+}
+
+// NewQuoteReaderAdapter is similar to NewQuoteFileAdapter but reads the TD quote
+// from 'reader' (ex. stdin).  The quote can either be binary or base64 encoded.
+func NewQuoteReaderAdapter(reader io.Reader, userData []byte) (connector.CompositeEvidenceAdapter, error) {
+	quoteBytes, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read quote")
+	}
+
+	quote, err := decodeQuote(quoteBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &quoteFileAdapter{
+		quote:    quote,
+		userData: userData,
+	}, nil
+}
+
+// decodeQuote returns the binary quote from 'quoteBytes' when it has a valid quote
+// header.  Otherwise, it attempts to base64 decode the data and validate the result.
+func decodeQuote(quoteBytes []byte) ([]byte, error) {
+	headerErr := validateQuoteHeader(quoteBytes)
+	if headerErr == nil {
+		return quoteBytes, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(quoteBytes)))
+	if err != nil {
+		// the data was not base64, report the error from the binary quote
+		return nil, headerErr
+	}
+
+	err = validateQuoteHeader(decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return decoded, nil
+}
+
+func (adapter *quoteFileAdapter) GetEvidenceIdentifier() string {
+	return "tdx"
+}
+
+func (adapter *quoteFileAdapter) GetEvidence(verifierNonce *connector.VerifierNonce, userData []byte) (interface{}, error) {
+	runtimeData := adapter.userData
+	if runtimeData == nil {
+		runtimeData = userData
+	}
+
+	return &compositeTdxEvidence{
+		RuntimeData:   runtimeData,
+		Quote:         adapter.quote,
+		VerifierNonce: verifierNonce,
+	}, nil
+}
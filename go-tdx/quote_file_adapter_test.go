@@ -0,0 +1,177 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package tdx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/intel/trustauthority-client/go-connector"
+)
+
+const (
+	testQuotePath = "test/resources/quote.bin"
+)
+
+func TestQuoteFileAdapterPositive(t *testing.T) {
+	userData := []byte("userdata")
+	adapter, err := NewQuoteFileAdapter(testQuotePath, userData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if adapter.GetEvidenceIdentifier() != "tdx" {
+		t.Errorf("expected tdx")
+	}
+
+	verifierNonce := &connector.VerifierNonce{
+		Val: make([]byte, 32),
+		Iat: make([]byte, 32),
+	}
+
+	evidence, err := adapter.GetEvidence(verifierNonce, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedQuote, err := os.ReadFile(testQuotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tdxEvidence := evidence.(*compositeTdxEvidence)
+	if !bytes.Equal(tdxEvidence.Quote, expectedQuote) {
+		t.Errorf("expected the quote to match the contents of %q", testQuotePath)
+	}
+
+	if !bytes.Equal(tdxEvidence.RuntimeData, userData) {
+		t.Errorf("expected runtime data %q, got %q", userData, tdxEvidence.RuntimeData)
+	}
+
+	if tdxEvidence.VerifierNonce != verifierNonce {
+		t.Errorf("expected the verifier nonce to be included in evidence")
+	}
+}
+
+func TestQuoteFileAdapterGetEvidenceUserData(t *testing.T) {
+	adapter, err := NewQuoteFileAdapter(testQuotePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userData := []byte("userdata")
+	evidence, err := adapter.GetEvidence(nil, userData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(evidence.(*compositeTdxEvidence).RuntimeData, userData) {
+		t.Errorf("expected runtime data %q", userData)
+	}
+}
+
+func TestQuoteFileAdapterInvalidPath(t *testing.T) {
+	_, err := NewQuoteFileAdapter(testInvalidPath, nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestQuoteFileAdapterInvalidQuote(t *testing.T) {
+	_, err := NewQuoteFileAdapter(testCcelTablePath, nil)
+	if !errors.Is(err, ErrorInvalidQuoteSize) {
+		t.Fatalf("expected ErrorInvalidQuoteSize, got %v", err)
+	}
+}
+
+func TestQuoteReaderAdapter(t *testing.T) {
+	quote, err := os.ReadFile(testQuotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		input         []byte
+		errorExpected bool
+	}{
+		{
+			name:          "Binary Quote",
+			input:         quote,
+			errorExpected: false,
+		},
+		{
+			name:          "Base64 Quote",
+			input:         []byte(base64.StdEncoding.EncodeToString(quote) + "\n"),
+			errorExpected: false,
+		},
+		{
+			name:          "Invalid Base64 Quote",
+			input:         []byte(base64.StdEncoding.EncodeToString([]byte("notaquote"))),
+			errorExpected: true,
+		},
+		{
+			name:          "Invalid Quote",
+			input:         []byte("notaquote!"),
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter, err := NewQuoteReaderAdapter(bytes.NewReader(tt.input), nil)
+			if tt.errorExpected {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			evidence, err := adapter.GetEvidence(nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(evidence.(*compositeTdxEvidence).Quote, quote) {
+				t.Errorf("expected the decoded quote to match %q", testQuotePath)
+			}
+		})
+	}
+}
+
+func TestValidateQuoteHeader(t *testing.T) {
+	quote, err := os.ReadFile(testQuotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = validateQuoteHeader(quote); err != nil {
+		t.Fatal(err)
+	}
+
+	badVersion := bytes.Clone(quote)
+	binary.LittleEndian.PutUint16(badVersion[0:2], 3)
+	if err = validateQuoteHeader(badVersion); !errors.Is(err, ErrorInvalidQuoteVersion) {
+		t.Errorf("expected ErrorInvalidQuoteVersion, got %v", err)
+	}
+
+	badTeeType := bytes.Clone(quote)
+	binary.LittleEndian.PutUint32(badTeeType[4:8], 0)
+	if err = validateQuoteHeader(badTeeType); !errors.Is(err, ErrorInvalidQuoteTeeType) || !errors.Is(err, ErrUnexpectedTeeType) {
+		t.Errorf("expected ErrUnexpectedTeeType, got %v", err)
+	}
+
+	if err = validateQuoteHeader(quote[:quoteHeaderSize]); !errors.Is(err, ErrorInvalidQuoteSize) {
+		t.Errorf("expected ErrorInvalidQuoteSize, got %v", err)
+	}
+}
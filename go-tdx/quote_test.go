@@ -0,0 +1,69 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package tdx
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"testing"
+)
+
+// TD quote collected on an Azure TDX CVM.
+const testAzureQuotePath = "test/resources/azure_quote.bin"
+
+func TestParseQuoteInfo(t *testing.T) {
+	quote, err := os.ReadFile(testAzureQuotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := ParseQuoteInfo(quote)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Version != quoteVersion4 {
+		t.Errorf("expected version %d, got %d", quoteVersion4, info.Version)
+	}
+
+	expected := map[string][]byte{
+		"04010700000000000000000000000000": info.TeeTcbSvn[:],
+		"00806f050000":                     info.Fmspc[:],
+		"0000":                             info.PceId[:],
+	}
+
+	for expectedHex, actual := range expected {
+		if hex.EncodeToString(actual) != expectedHex {
+			t.Errorf("expected %s, got %x", expectedHex, actual)
+		}
+	}
+
+	if info.String() != "version=4 tee_tcb_svn=04010700000000000000000000000000 fmspc=00806f050000 pce_id=0000" {
+		t.Errorf("unexpected quote info string %q", info.String())
+	}
+}
+
+func TestParseQuoteInfoNoCertificationData(t *testing.T) {
+	// the test quote does not include certification data
+	quote, err := os.ReadFile(testQuotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ParseQuoteInfo(quote)
+	if !errors.Is(err, ErrorInvalidQuoteCertData) {
+		t.Fatalf("expected ErrorInvalidQuoteCertData, got %v", err)
+	}
+}
+
+func TestParseQuoteInfoInvalidQuote(t *testing.T) {
+	_, err := ParseQuoteInfo([]byte("quote"))
+	if !errors.Is(err, ErrorInvalidQuoteSize) {
+		t.Fatalf("expected ErrorInvalidQuoteSize, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package tdx
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+)
+
+const (
+	rtmrCount  = 4
+	rtmrSize   = sha512.Size384
+	rtmrOffset = 328 // offset of RTMR0 in the td report body
+	evNoAction = 0x00000003
+)
+
+var ErrorRtmrMismatch = errors.New("the quote's RTMR does not match the value replayed from the CCEL")
+
+// VerifyQuoteAgainstCcel replays the SHA384 digests of the events in 'ccel' (the raw TCG 2.0
+// event log returned by GetCcel) and compares the results with RTMR0-3 of the v4 or v5 TD
+// 'quote'.  ErrorRtmrMismatch is returned when they differ (i.e., the event log does not
+// reflect the measurements in the quote and would fail appraisal).
+func VerifyQuoteAgainstCcel(quote, ccel []byte) error {
+	err := validateQuoteHeader(quote)
+	if err != nil {
+		return err
+	}
+
+	body, _, err := readTdReportBody(quote)
+	if err != nil {
+		return err
+	}
+
+	replayed, err := replayCcel(ccel)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < rtmrCount; i++ {
+		offset := rtmrOffset + i*rtmrSize
+		rtmr := body[offset : offset+rtmrSize]
+		if !bytes.Equal(rtmr, replayed[i][:]) {
+			return fmt.Errorf("%w: rtmr%d is %x, the CCEL replayed %x", ErrorRtmrMismatch, i, rtmr, replayed[i])
+		}
+	}
+
+	return nil
+}
+
+// ReplayCcel returns RTMR0-3 replayed from the SHA384 digests of the events in 'ccel'
+// (the raw TCG 2.0 event log returned by GetCcel), ex. to compare them with the RTMRs
+// expected from a golden image.
+func ReplayCcel(ccel []byte) ([rtmrCount]HexBytes, error) {
+	var rtmrs [rtmrCount]HexBytes
+
+	replayed, err := replayCcel(ccel)
+	if err != nil {
+		return rtmrs, err
+	}
+
+	for i := range replayed {
+		rtmrs[i] = bytes.Clone(replayed[i][:])
+	}
+
+	return rtmrs, nil
+}
+
+// replayCcel extends the SHA384 digest of each event in 'ccel' into the RTMR referenced by
+// the event's MR index (see walkCcel) and returns the resulting RTMR values.
+func replayCcel(ccel []byte) ([rtmrCount][rtmrSize]byte, error) {
+	var rtmrs [rtmrCount][rtmrSize]byte
+
+	_, err := walkCcel(ccel, func(event *ccelEvent) error {
+		// EV_NO_ACTION events are informational and are not extended
+		if event.eventType == evNoAction {
+			return nil
+		}
+
+		if event.sha384Digest == nil {
+			return fmt.Errorf("%w: event for rtmr%d does not have a sha384 digest", ErrorInvalidEventLog, event.rtmr)
+		}
+
+		rtmr := &rtmrs[event.rtmr]
+		*rtmr = sha512.Sum384(append(rtmr[:], event.sha384Digest...))
+		return nil
+	})
+
+	return rtmrs, err
+}
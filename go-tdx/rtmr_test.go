@@ -0,0 +1,123 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package tdx
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"testing"
+)
+
+// RTMR values replayed from test/resources/CCEL.data.bin
+var testCcelRtmrs = []string{
+	"8083cd6898cc52a90231cdf9c0532bf9513c40465c6f71e56cbe32ee2c11a9dfc030297ca3ca0f62477d6d1f610d3fdb",
+	"6484f0d72c03521c0434553be34e8db8228b729e799666d2b7754085c77aa9981f5a440df3047194b24f212ff1160c1e",
+	"c3e7ed9d7e909b29732f676d01dc63de869b049362b522a315cb042689670be07344c347cf85d985c7b928d4934e41e1",
+	"000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000",
+}
+
+// readTestQuoteWithRtmrs returns the test quote with its RTMRs replaced by 'rtmrs'.
+func readTestQuoteWithRtmrs(t *testing.T, rtmrs []string) []byte {
+	quote, err := os.ReadFile(testQuotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, rtmr := range rtmrs {
+		b, err := hex.DecodeString(rtmr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(quote[quoteHeaderSize+rtmrOffset+i*rtmrSize:], b)
+	}
+
+	return quote
+}
+
+func TestReplayCcel(t *testing.T) {
+	ccel, err := getCcel(testCcelTablePath, testCcelDataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rtmrs, err := replayCcel(ccel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range testCcelRtmrs {
+		if hex.EncodeToString(rtmrs[i][:]) != expected {
+			t.Errorf("expected rtmr%d %s, got %x", i, expected, rtmrs[i])
+		}
+	}
+}
+
+func TestReplayCcelExported(t *testing.T) {
+	ccel, err := getCcel(testCcelTablePath, testCcelDataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rtmrs, err := ReplayCcel(ccel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range testCcelRtmrs {
+		if hex.EncodeToString(rtmrs[i]) != expected {
+			t.Errorf("expected rtmr%d %s, got %x", i, expected, rtmrs[i])
+		}
+	}
+
+	if _, err = ReplayCcel(ccel[:10]); !errors.Is(err, ErrorInvalidEventLog) {
+		t.Errorf("expected ErrorInvalidEventLog, got %v", err)
+	}
+}
+
+func TestVerifyQuoteAgainstCcel(t *testing.T) {
+	ccel, err := getCcel(testCcelTablePath, testCcelDataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quote := readTestQuoteWithRtmrs(t, testCcelRtmrs)
+	if err = VerifyQuoteAgainstCcel(quote, ccel); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyQuoteAgainstCcelMismatch(t *testing.T) {
+	ccel, err := getCcel(testCcelTablePath, testCcelDataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the test quote's RTMRs are zeros
+	quote := readTestQuoteWithRtmrs(t, nil)
+	err = VerifyQuoteAgainstCcel(quote, ccel)
+	if !errors.Is(err, ErrorRtmrMismatch) {
+		t.Fatalf("expected ErrorRtmrMismatch, got %v", err)
+	}
+
+	// only rtmr2 is different
+	rtmrs := append([]string{}, testCcelRtmrs...)
+	rtmrs[2] = testCcelRtmrs[0]
+	quote = readTestQuoteWithRtmrs(t, rtmrs)
+	err = VerifyQuoteAgainstCcel(quote, ccel)
+	if !errors.Is(err, ErrorRtmrMismatch) {
+		t.Fatalf("expected ErrorRtmrMismatch, got %v", err)
+	}
+}
+
+func TestVerifyQuoteAgainstCcelInvalidEventLog(t *testing.T) {
+	quote := readTestQuoteWithRtmrs(t, testCcelRtmrs)
+	err := VerifyQuoteAgainstCcel(quote, make([]byte, 10))
+	if !errors.Is(err, ErrorInvalidEventLog) {
+		t.Fatalf("expected ErrorInvalidEventLog, got %v", err)
+	}
+}
@@ -6,51 +6,138 @@
 package tdx
 
 import (
+	"bytes"
 	"crypto/sha512"
+	"fmt"
+
+	"github.com/pkg/errors"
 
 	"github.com/google/go-configfs-tsm/configfs/linuxtsm"
 	"github.com/google/go-configfs-tsm/report"
 	"github.com/intel/trustauthority-client/go-connector"
 )
 
+// The size of the report data in a TD quote.
+const reportDataSize = 64
+
+// ErrorAuxBlobNotFound is returned when WithAuxBlob is enabled and configfs-tsm did not
+// provide an auxiliary blob (ex. the kernel does not support 'auxblob').
+var ErrorAuxBlobNotFound = errors.New("the configfs-tsm auxblob was not found")
+
+// ErrorAuxBlobNotSupported is returned by CollectEvidence when WithAuxBlob is enabled
+// because connector.Evidence cannot carry the auxblob (use GetEvidence instead).
+var ErrorAuxBlobNotSupported = errors.New("the auxblob is only supported by composite evidence (GetEvidence)")
+
+// ErrorMrtdMismatch is returned when the MRTD of the collected quote does not match the
+// value provided to WithExpectedMrtd.
+var ErrorMrtdMismatch = errors.New("the quote's MRTD does not match the expected MRTD")
+
+// TdxAdapterOptions for creating a TDX evidence adapter (see NewCompositeEvidenceAdapter).
+type TdxAdapterOptions func(*tdxAdapter) error
+
 // TdxAdapter manages TDX Quote collection from TDX enabled platform
 type tdxAdapter struct {
 	uData            []byte
 	withCcel         bool
+	reportData       []byte
+	withAuxBlob      bool
+	expectedMrtd     []byte
 	cfsQuoteProvider cfsQuoteProvider
 }
 
+// WithReportData specifies the exact 64 bytes of report data that are included in the
+// TD quote.  By default, the report data is the SHA-512 hash of the verifier-nonce and
+// user-data.  This option is intended for flows that compute the report data externally
+// (ex. binding the quote to a TLS key) and the caller is responsible for ensuring that
+// ITA can verify the binding.
+func WithReportData(reportData []byte) TdxAdapterOptions {
+	return func(adapter *tdxAdapter) error {
+		if len(reportData) != reportDataSize {
+			return errors.Errorf("The report data must be %d bytes, got %d", reportDataSize, len(reportData))
+		}
+		adapter.reportData = reportData
+		return nil
+	}
+}
+
+// WithAuxBlob includes the auxiliary blob provided by configfs-tsm (ex. runtime
+// measurement register extension data that is required by some policies) in the
+// evidence.  When enabled, evidence collection fails with ErrorAuxBlobNotFound if the
+// kernel does not provide an auxblob.  The auxblob is only included in composite evidence
+// (GetEvidence), CollectEvidence fails with ErrorAuxBlobNotSupported.
+func WithAuxBlob(enabled bool) TdxAdapterOptions {
+	return func(adapter *tdxAdapter) error {
+		adapter.withAuxBlob = enabled
+		return nil
+	}
+}
+
+// WithExpectedMrtd compares the MRTD of collected quotes with 'mrtd' (48 bytes) and fails
+// with ErrorMrtdMismatch when they differ.  This allows deployments of a known TD image to
+// detect a misconfigured image/firmware before the quote is submitted to ITA.
+func WithExpectedMrtd(mrtd []byte) TdxAdapterOptions {
+	return func(adapter *tdxAdapter) error {
+		if len(mrtd) != mrtdSize {
+			return errors.Errorf("The expected MRTD must be %d bytes, got %d", mrtdSize, len(mrtd))
+		}
+		adapter.expectedMrtd = mrtd
+		return nil
+	}
+}
+
 type compositeTdxEvidence struct {
 	RuntimeData   []byte                   `json:"runtime_data"`
 	Quote         []byte                   `json:"quote"`
 	EventLog      []byte                   `json:"event_log,omitempty"`
+	AuxBlob       []byte                   `json:"aux_blob,omitempty"`
 	VerifierNonce *connector.VerifierNonce `json:"verifier_nonce,omitempty"`
 }
 
 // CollectEvidence is used to get TDX quote using TDX Quote Generation service
 func (adapter *tdxAdapter) CollectEvidence(nonce []byte) (*connector.Evidence, error) {
+	// don't silently drop the auxblob that was requested by WithAuxBlob
+	if adapter.withAuxBlob {
+		return nil, ErrorAuxBlobNotSupported
+	}
 
-	hash := sha512.New()
-	_, err := hash.Write(nonce)
+	evidence, _, err := adapter.collectEvidence(nonce)
+	return evidence, err
+}
+
+// collectEvidence is similar to CollectEvidence but also returns the auxblob when
+// WithAuxBlob is enabled.
+func (adapter *tdxAdapter) collectEvidence(nonce []byte) (*connector.Evidence, []byte, error) {
+
+	reportData, err := adapter.getReportData(nonce)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	_, err = hash.Write(adapter.uData)
+
+	quote, auxBlob, err := adapter.cfsQuoteProvider.getQuoteFromConfigFS(reportData, adapter.withAuxBlob)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	reportData := hash.Sum(nil)
 
-	quote, err := adapter.cfsQuoteProvider.getQuoteFromConfigFS(reportData)
-	if err != nil {
-		return nil, err
+	// don't let an SGX quote (ex. from a misconfigured quote service) flow as TDX evidence
+	if err = checkQuoteTeeType(quote); err != nil {
+		return nil, nil, err
+	}
+
+	if adapter.withAuxBlob && len(auxBlob) == 0 {
+		return nil, nil, ErrorAuxBlobNotFound
+	}
+
+	if adapter.expectedMrtd != nil {
+		if err = checkMrtd(quote, adapter.expectedMrtd); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	var ccelBytes []byte
 	if adapter.withCcel {
 		ccelBytes, err = GetCcel()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
@@ -59,38 +146,88 @@ func (adapter *tdxAdapter) CollectEvidence(nonce []byte) (*connector.Evidence, e
 		Evidence:    quote,
 		RuntimeData: adapter.uData,
 		EventLog:    ccelBytes,
-	}, nil
+	}, auxBlob, nil
+}
+
+// getReportData returns the report data provided by WithReportData or the SHA-512
+// hash of 'nonce' and the adapter's user-data.
+func (adapter *tdxAdapter) getReportData(nonce []byte) ([]byte, error) {
+	if adapter.reportData != nil {
+		return adapter.reportData, nil
+	}
+
+	hash := sha512.New()
+	_, err := hash.Write(nonce)
+	if err != nil {
+		return nil, err
+	}
+	_, err = hash.Write(adapter.uData)
+	if err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// checkMrtd returns ErrorMrtdMismatch if the MRTD in the td report body of 'quote' is
+// not 'expectedMrtd'.
+func checkMrtd(quote []byte, expectedMrtd []byte) error {
+	err := validateQuoteHeader(quote)
+	if err != nil {
+		return err
+	}
+
+	body, _, err := readTdReportBody(quote)
+	if err != nil {
+		return err
+	}
+
+	mrtd := decodeTdReportBody(body).MrTd
+	if !bytes.Equal(mrtd, expectedMrtd) {
+		return fmt.Errorf("%w: the quote's MRTD is %x, expected %x", ErrorMrtdMismatch, []byte(mrtd), expectedMrtd)
+	}
+
+	return nil
 }
 
 type cfsQuoteProvider interface {
-	getQuoteFromConfigFS(reportData []byte) ([]byte, error)
+	// getQuoteFromConfigFS returns the TD quote and, when 'withAuxBlob' is true, the
+	// auxblob from configfs-tsm.
+	getQuoteFromConfigFS(reportData []byte, withAuxBlob bool) ([]byte, []byte, error)
 }
 
 type cfsQuoteProviderImpl struct{}
 
-func (cp *cfsQuoteProviderImpl) getQuoteFromConfigFS(reportData []byte) ([]byte, error) {
+func (cp *cfsQuoteProviderImpl) getQuoteFromConfigFS(reportData []byte, withAuxBlob bool) ([]byte, []byte, error) {
 	_, err := linuxtsm.MakeClient()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	req := &report.Request{
 		InBlob:     reportData[:],
-		GetAuxBlob: false,
+		GetAuxBlob: withAuxBlob,
 	}
 	resp, err := linuxtsm.GetReport(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return resp.OutBlob, nil
+	return resp.OutBlob, resp.AuxBlob, nil
 }
 
-func NewCompositeEvidenceAdapter(withCcel bool) (connector.CompositeEvidenceAdapter, error) {
-	return &tdxAdapter{
+func NewCompositeEvidenceAdapter(withCcel bool, opts ...TdxAdapterOptions) (connector.CompositeEvidenceAdapter, error) {
+	adapter := &tdxAdapter{
 		withCcel:         withCcel,
 		cfsQuoteProvider: &cfsQuoteProviderImpl{},
-	}, nil
+	}
+
+	for _, option := range opts {
+		if err := option(adapter); err != nil {
+			return nil, err
+		}
+	}
+
+	return adapter, nil
 }
 
 func (adapter *tdxAdapter) GetEvidenceIdentifier() string {
@@ -105,7 +242,7 @@ func (adapter *tdxAdapter) GetEvidence(verifierNonce *connector.VerifierNonce, u
 		nonce = append(verifierNonce.Val, verifierNonce.Iat[:]...)
 	}
 
-	quote, err := adapter.CollectEvidence(nonce)
+	quote, auxBlob, err := adapter.collectEvidence(nonce)
 	if err != nil {
 		return nil, err
 	}
@@ -114,6 +251,7 @@ func (adapter *tdxAdapter) GetEvidence(verifierNonce *connector.VerifierNonce, u
 		RuntimeData:   quote.RuntimeData,
 		Quote:         quote.Evidence,
 		EventLog:      quote.EventLog,
+		AuxBlob:       auxBlob,
 		VerifierNonce: verifierNonce,
 	}, nil
 }
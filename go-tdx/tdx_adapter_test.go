@@ -7,6 +7,12 @@
 package tdx
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/intel/trustauthority-client/go-connector"
@@ -17,7 +23,7 @@ import (
 func TestCollectEvidencePositive(t *testing.T) {
 
 	mockCfsQuoteProvider := &MockCfsQuoteProvider{}
-	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything).Return([]byte("quote"), nil)
+	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything, false).Return([]byte("quote"), []byte(nil), nil)
 
 	adapter := tdxAdapter{
 		withCcel:         false,
@@ -33,7 +39,7 @@ func TestCollectEvidencePositive(t *testing.T) {
 func TestCollectEvidenceConfigFsError(t *testing.T) {
 
 	mockCfsQuoteProvider := &MockCfsQuoteProvider{}
-	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything).Return([]byte{}, errors.New("unit test failure"))
+	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything, false).Return([]byte{}, []byte(nil), errors.New("unit test failure"))
 
 	adapter := tdxAdapter{
 		withCcel:         false,
@@ -48,7 +54,7 @@ func TestCollectEvidenceConfigFsError(t *testing.T) {
 
 func TestCompositeAdapterPositive(t *testing.T) {
 	mockCfsQuoteProvider := &MockCfsQuoteProvider{}
-	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything).Return([]byte("quote"), nil)
+	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything, false).Return([]byte("quote"), []byte(nil), nil)
 
 	adapter := tdxAdapter{
 		withCcel:         false,
@@ -66,7 +72,7 @@ func TestCompositeAdapterPositive(t *testing.T) {
 
 func TestCompositeAdapterConfigFsError(t *testing.T) {
 	mockCfsQuoteProvider := &MockCfsQuoteProvider{}
-	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything).Return([]byte{}, errors.New("unit test failure"))
+	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything, false).Return([]byte{}, []byte(nil), errors.New("unit test failure"))
 
 	adapter := tdxAdapter{
 		withCcel:         false,
@@ -86,7 +92,7 @@ func TestCompositeAdapterCcelPositive(t *testing.T) {
 	ccelDataPath = testCcelDataPath
 
 	mockCfsQuoteProvider := &MockCfsQuoteProvider{}
-	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything).Return([]byte("quote"), nil)
+	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything, false).Return([]byte("quote"), []byte(nil), nil)
 
 	adapter := tdxAdapter{
 		withCcel:         true,
@@ -110,7 +116,7 @@ func TestCompositeAdapterCcelBadTablePath(t *testing.T) {
 	ccelDataPath = testCcelDataPath
 
 	mockCfsQuoteProvider := &MockCfsQuoteProvider{}
-	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything).Return([]byte("quote"), nil)
+	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything, false).Return([]byte("quote"), []byte(nil), nil)
 
 	adapter := tdxAdapter{
 		withCcel:         true,
@@ -146,11 +152,239 @@ func TestCompositeAdapterNew(t *testing.T) {
 	}
 }
 
+func TestCompositeAdapterWithReportData(t *testing.T) {
+	reportData := make([]byte, reportDataSize)
+	for i := range reportData {
+		reportData[i] = byte(i)
+	}
+
+	mockCfsQuoteProvider := &MockCfsQuoteProvider{}
+	mockCfsQuoteProvider.On("getQuoteFromConfigFS", reportData, false).Return([]byte("quote"), []byte(nil), nil)
+
+	adapter, err := NewCompositeEvidenceAdapter(false, WithReportData(reportData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter.(*tdxAdapter).cfsQuoteProvider = mockCfsQuoteProvider
+
+	_, err = adapter.GetEvidence(&connector.VerifierNonce{
+		Iat: make([]byte, 32),
+		Val: make([]byte, 32),
+	}, []byte("user data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the supplied report data (not the nonce/user-data hash) is used in the quote request
+	mockCfsQuoteProvider.AssertCalled(t, "getQuoteFromConfigFS", reportData, false)
+}
+
+func TestCompositeAdapterWithReportDataInvalidLength(t *testing.T) {
+	for _, length := range []int{0, 32, reportDataSize + 1} {
+		_, err := NewCompositeEvidenceAdapter(false, WithReportData(make([]byte, length)))
+		if err == nil {
+			t.Errorf("expected an error for report data of length %d", length)
+		}
+	}
+}
+
+func TestCompositeAdapterWithAuxBlob(t *testing.T) {
+	auxBlob := []byte("auxblob")
+
+	mockCfsQuoteProvider := &MockCfsQuoteProvider{}
+	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything, true).Return([]byte("quote"), auxBlob, nil)
+
+	adapter, err := NewCompositeEvidenceAdapter(false, WithAuxBlob(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter.(*tdxAdapter).cfsQuoteProvider = mockCfsQuoteProvider
+
+	evidence, err := adapter.GetEvidence(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tdxEvidence := evidence.(*compositeTdxEvidence)
+	if !bytes.Equal(tdxEvidence.AuxBlob, auxBlob) {
+		t.Errorf("expected auxblob %q, got %q", auxBlob, tdxEvidence.AuxBlob)
+	}
+
+	evidenceJson, err := json.Marshal(evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(evidenceJson, []byte(`"aux_blob"`)) {
+		t.Errorf("expected aux_blob in %s", evidenceJson)
+	}
+}
+
+func TestCompositeAdapterWithAuxBlobMissing(t *testing.T) {
+	mockCfsQuoteProvider := &MockCfsQuoteProvider{}
+	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything, true).Return([]byte("quote"), []byte(nil), nil)
+
+	adapter, err := NewCompositeEvidenceAdapter(false, WithAuxBlob(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter.(*tdxAdapter).cfsQuoteProvider = mockCfsQuoteProvider
+
+	_, err = adapter.GetEvidence(nil, nil)
+	if !errors.Is(err, ErrorAuxBlobNotFound) {
+		t.Errorf("expected ErrorAuxBlobNotFound, got %v", err)
+	}
+}
+
+func TestCollectEvidenceWithAuxBlob(t *testing.T) {
+	mockCfsQuoteProvider := &MockCfsQuoteProvider{}
+	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything, true).Return([]byte("quote"), []byte("auxblob"), nil)
+
+	adapter, err := NewCompositeEvidenceAdapter(false, WithAuxBlob(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter.(*tdxAdapter).cfsQuoteProvider = mockCfsQuoteProvider
+
+	_, err = adapter.(*tdxAdapter).CollectEvidence([]byte("nonce"))
+	if !errors.Is(err, ErrorAuxBlobNotSupported) {
+		t.Errorf("expected ErrorAuxBlobNotSupported, got %v", err)
+	}
+}
+
+func TestCompositeAdapterWithoutAuxBlob(t *testing.T) {
+	mockCfsQuoteProvider := &MockCfsQuoteProvider{}
+	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything, false).Return([]byte("quote"), []byte(nil), nil)
+
+	adapter, err := NewCompositeEvidenceAdapter(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter.(*tdxAdapter).cfsQuoteProvider = mockCfsQuoteProvider
+
+	evidence, err := adapter.GetEvidence(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidenceJson, err := json.Marshal(evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(evidenceJson, []byte(`"aux_blob"`)) {
+		t.Errorf("did not expect aux_blob in %s", evidenceJson)
+	}
+}
+
+func TestCompositeAdapterWithExpectedMrtd(t *testing.T) {
+	azureQuote, err := os.ReadFile(testAzureQuotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedMrtd, err := hex.DecodeString(testAzureQuoteMrTd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherMrtd := bytes.Repeat([]byte{0xff}, len(expectedMrtd))
+
+	tt := []struct {
+		description string
+		mrtd        []byte
+		expectedErr error
+	}{
+		{"Matching MRTD", expectedMrtd, nil},
+		{"Mismatched MRTD", otherMrtd, ErrorMrtdMismatch},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			mockCfsQuoteProvider := &MockCfsQuoteProvider{}
+			mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything, false).Return(azureQuote, []byte(nil), nil)
+
+			adapter, err := NewCompositeEvidenceAdapter(false, WithExpectedMrtd(tc.mrtd))
+			if err != nil {
+				t.Fatal(err)
+			}
+			adapter.(*tdxAdapter).cfsQuoteProvider = mockCfsQuoteProvider
+
+			_, err = adapter.GetEvidence(nil, nil)
+			if tc.expectedErr == nil && err != nil {
+				t.Fatal(err)
+			} else if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected %v, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestCompositeAdapterWithExpectedMrtdInvalidLength(t *testing.T) {
+	for _, length := range []int{0, 32, mrtdSize + 1} {
+		_, err := NewCompositeEvidenceAdapter(false, WithExpectedMrtd(make([]byte, length)))
+		if err == nil {
+			t.Errorf("expected an error for an MRTD of length %d", length)
+		}
+	}
+}
+
 type MockCfsQuoteProvider struct {
 	mock.Mock
 }
 
-func (m *MockCfsQuoteProvider) getQuoteFromConfigFS(reportData []byte) ([]byte, error) {
-	args := m.Called(reportData)
-	return args.Get(0).([]byte), args.Error(1)
+func (m *MockCfsQuoteProvider) getQuoteFromConfigFS(reportData []byte, withAuxBlob bool) ([]byte, []byte, error) {
+	args := m.Called(reportData, withAuxBlob)
+	return args.Get(0).([]byte), args.Get(1).([]byte), args.Error(2)
+}
+
+func TestCompositeAdapterSgxQuote(t *testing.T) {
+	tdxQuote, err := os.ReadFile(testQuotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// SGX v3 quotes have a reserved (zero) tee_type while SGX v4 quotes have tee_type 0
+	sgxV3Quote := bytes.Clone(tdxQuote)
+	binary.LittleEndian.PutUint16(sgxV3Quote[0:2], 3)
+	binary.LittleEndian.PutUint32(sgxV3Quote[4:8], 0)
+
+	sgxV4Quote := bytes.Clone(tdxQuote)
+	binary.LittleEndian.PutUint32(sgxV4Quote[4:8], 0)
+
+	for name, quote := range map[string][]byte{"v3": sgxV3Quote, "v4": sgxV4Quote} {
+		t.Run(name, func(t *testing.T) {
+			mockCfsQuoteProvider := &MockCfsQuoteProvider{}
+			mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything, false).Return(quote, []byte(nil), nil)
+
+			adapter, err := NewCompositeEvidenceAdapter(false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			adapter.(*tdxAdapter).cfsQuoteProvider = mockCfsQuoteProvider
+
+			_, err = adapter.GetEvidence(nil, nil)
+			if !errors.Is(err, ErrUnexpectedTeeType) {
+				t.Fatalf("expected ErrUnexpectedTeeType, got %v", err)
+			}
+
+			if !strings.Contains(err.Error(), "found SGX") || !strings.Contains(err.Error(), "expected TDX") {
+				t.Errorf("expected the error to name the found and expected TEE types, got %q", err.Error())
+			}
+		})
+	}
+
+	// TDX quotes are not affected
+	mockCfsQuoteProvider := &MockCfsQuoteProvider{}
+	mockCfsQuoteProvider.On("getQuoteFromConfigFS", mock.Anything, false).Return(tdxQuote, []byte(nil), nil)
+
+	adapter, err := NewCompositeEvidenceAdapter(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter.(*tdxAdapter).cfsQuoteProvider = mockCfsQuoteProvider
+
+	if _, err = adapter.GetEvidence(nil, nil); err != nil {
+		t.Fatal(err)
+	}
 }
@@ -7,13 +7,23 @@
 package tpm
 
 import (
-	"crypto/sha256"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	_ "crypto/sha256" // register the nonce hash algorithms
+	_ "crypto/sha512"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
+	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/intel/trustauthority-client/go-connector"
 	"github.com/sirupsen/logrus"
@@ -24,14 +34,46 @@ import (
 // TpmAdapterOptions for creating an evidence adapter using the host's TPM.
 type TpmAdapterOptions func(*tpmAdapter) error
 
+// FileReader returns the contents of the file at 'path' (see WithFileReader).
+type FileReader func(path string) ([]byte, error)
+
+// StageObserver is called after each stage of TPM evidence collection (see
+// WithStageObserver) with the stage's name (ex. StageGetQuote), how long it took and the
+// error it returned (nil on success).
+type StageObserver func(stage string, duration time.Duration, err error)
+
+// The stages of TPM evidence collection reported to a StageObserver.
+const (
+	StageGetQuote    = "get_quote"
+	StageGetPcrs     = "get_pcrs"
+	StageReadImaLog  = "read_ima_log"
+	StageReadUefiLog = "read_uefi_event_log"
+)
+
 type tpmAdapter struct {
-	akHandle         int
-	pcrSelections    []PcrSelection
-	deviceType       TpmDeviceType
-	ownerAuth        string
-	withImaLogs      bool
-	withUefiLogs     bool
-	akCertificateUri *url.URL
+	akHandle           int
+	pcrSelections      []PcrSelection
+	deviceType         TpmDeviceType
+	ownerAuth          string
+	withImaLogs        bool
+	imaLogFields       []string
+	withUefiLogs       bool
+	akCertificateUri   *url.URL
+	maxLogSize         int
+	fileReader         FileReader
+	skipAkCertValidity bool
+	akCertValiditySkew time.Duration
+	nonceHashAlgorithm crypto.Hash
+	tpmFactory         TpmFactory
+	eventLogTracker    *eventLogTracker
+	eventLogDigest     crypto.Hash
+	optionalLogs       bool
+	filterLogger       logrus.FieldLogger
+	withClockInfo      bool
+	akName             []byte
+	stageObserver      StageObserver
+	sessionEkHandle    int
+	sessionEkPublic    crypto.PublicKey
 }
 
 var defaultAdapter = tpmAdapter{
@@ -41,6 +83,10 @@ var defaultAdapter = tpmAdapter{
 	ownerAuth:     "",
 	withImaLogs:   false,
 	withUefiLogs:  false,
+	maxLogSize:    DefaultMaxLogSize,
+
+	akCertValiditySkew: DefaultAkCertValiditySkew,
+	nonceHashAlgorithm: DefaultNonceHashAlgorithm,
 }
 
 type TpmAdapterFactory interface {
@@ -54,6 +100,10 @@ type tpmAdapterFactory struct {
 func (t *tpmAdapterFactory) New(opts ...TpmAdapterOptions) (connector.CompositeEvidenceAdapter, error) {
 	// create an adapter with default values
 	tca := defaultAdapter
+	tca.tpmFactory = t.tpmFactory
+	if tca.tpmFactory == nil {
+		tca.tpmFactory = NewTpmFactory()
+	}
 
 	// iterate over the options and apply them to the adapter
 	for _, option := range opts {
@@ -79,7 +129,9 @@ func WithOwnerAuth(ownerAuth string) TpmAdapterOptions {
 }
 
 // WithDeviceType specifies the type of TPM device to use.  By default,
-// the Linux device is used (/dev/tpmrm0).
+// the Linux device is used (/dev/tpmrm0).  TpmDeviceAuto selects /dev/tpmrm0
+// or /dev/tpm0, whichever is available, and TpmDeviceLinuxRaw uses /dev/tpm0
+// on systems without a resource manager.
 func WithDeviceType(deviceType TpmDeviceType) TpmAdapterOptions {
 	return func(tca *tpmAdapter) error {
 		tca.deviceType = deviceType
@@ -103,7 +155,7 @@ func WithAkHandle(akHandle int) TpmAdapterOptions {
 // WithPcrSelections configures which PCRs to include during TPM quote generation.
 func WithPcrSelections(selections string) TpmAdapterOptions {
 	return func(tca *tpmAdapter) error {
-		pcrSelections, err := parsePcrSelections(selections)
+		pcrSelections, err := ParsePcrSelections(selections)
 		if err != nil {
 			return err
 		}
@@ -112,6 +164,18 @@ func WithPcrSelections(selections string) TpmAdapterOptions {
 	}
 }
 
+// WithPcrSelectionList is similar to WithPcrSelections but accepts selections created
+// in code (ex. using NewPcrSelectionBuilder).
+func WithPcrSelectionList(selections []PcrSelection) TpmAdapterOptions {
+	return func(tca *tpmAdapter) error {
+		if len(selections) == 0 {
+			return errors.New("The PCR selections cannot be empty")
+		}
+		tca.pcrSelections = selections
+		return nil
+	}
+}
+
 // WithImaLogs controls the inclusion of IMA logs into TPM evidence.  When enabled,
 // logs from "/sys/kernel/security/ima/ascii_runtime_measurements" will be included
 // in evidence.
@@ -122,6 +186,22 @@ func WithImaLogs(enabled bool) TpmAdapterOptions {
 	}
 }
 
+// WithImaLogFields limits the IMA template fields (ex. "d-ng", "n-ng", "sig") that are
+// included in TPM evidence when WithImaLogs is enabled.  Excluded fields are emptied
+// from each measurement while the pcr, template-hash and template-name columns are kept
+// so that ITA can still replay the log.  By default, all fields are included.
+func WithImaLogFields(fields []string) TpmAdapterOptions {
+	return func(tca *tpmAdapter) error {
+		for _, field := range fields {
+			if !isImaTemplateField(field) {
+				return errors.Errorf("Unknown IMA template field %q", field)
+			}
+		}
+		tca.imaLogFields = fields
+		return nil
+	}
+}
+
 // WithUefiEventLogs controls the inclusion of UEFI event logs into TPM evidence.  When enabled,
 // logs from "/sys/kernel/security/tpm0/binary_bios_measurements" will be included
 // in evidence.
@@ -132,6 +212,160 @@ func WithUefiEventLogs(enabled bool) TpmAdapterOptions {
 	}
 }
 
+// WithOptionalLogs controls how TPM evidence is collected when the IMA or UEFI event log
+// requested by WithImaLogs/WithUefiEventLogs does not exist (ex. securityfs is not mounted
+// in a container).  When enabled, a warning is logged and the missing log is omitted from
+// the evidence.  By default, collection fails with ErrFailedToReadIMALogs or
+// ErrFailedToReadUEFILogs.
+func WithOptionalLogs(enabled bool) TpmAdapterOptions {
+	return func(tca *tpmAdapter) error {
+		tca.optionalLogs = enabled
+		return nil
+	}
+}
+
+// WithEventLogFilterLogger reports how the UEFI event log was filtered by the PCR
+// selections (see WithPcrSelections) to 'logger' (ex. logrus.StandardLogger()).  For each
+// PCR, the number of events that were retained and dropped is logged, along with why they
+// were dropped (the PCR was not selected or the event did not have digests in the selected
+// banks).  By default, filtering is not reported.
+func WithEventLogFilterLogger(logger logrus.FieldLogger) TpmAdapterOptions {
+	return func(tca *tpmAdapter) error {
+		tca.filterLogger = logger
+		return nil
+	}
+}
+
+// WithClockInfo controls whether TPM evidence includes the clock information of the quote
+// (the "clock", "reset_count", "restart_count", "clock_safe" and "firmware_version" fields,
+// see QuoteClockInfo) for policies that check the freshness of the quote.  The values are
+// parsed from the quote's TPMS_ATTEST structure.  By default, they are not included.
+func WithClockInfo(enabled bool) TpmAdapterOptions {
+	return func(tca *tpmAdapter) error {
+		tca.withClockInfo = enabled
+		return nil
+	}
+}
+
+// WithDeltaEventLogs controls whether TPM evidence includes only the IMA and UEFI events
+// that were appended since the verifier accepted the previous evidence (see
+// CommitEventLogOffsets in DeltaEventLogAdapter).  An empty log (i.e., "") is included
+// when no events were appended.  The full logs are included in the first evidence, when a
+// log changed (ex. after a reboot), or after ResetEventLogOffsets is called -- for
+// example, when the verifier requires the full logs.  The offsets of the included events
+// are not part of the evidence, so this must only be enabled with a verifier that keeps
+// the events of previous attestations.
+func WithDeltaEventLogs(enabled bool) TpmAdapterOptions {
+	return func(tca *tpmAdapter) error {
+		tca.eventLogTracker = nil
+		if enabled {
+			tca.eventLogTracker = newEventLogTracker()
+		}
+		return nil
+	}
+}
+
+// WithEventLogDigest replaces the IMA and UEFI event logs in TPM evidence with their
+// digest (using 'hashAlgorithm', ex. crypto.SHA256) for transports that limit the size
+// of the attestation request.  The digests are included in the "ima_logs_digest" and
+// "uefi_event_logs_digest" fields and the algorithm in "event_logs_digest_algorithm".
+//
+// Without the raw logs, ITA cannot replay the events against the PCRs or evaluate policies
+// that reference individual events -- only use this option when the attestation policy
+// allows the logs to be omitted (ex. it only appraises PCR values).
+func WithEventLogDigest(hashAlgorithm crypto.Hash) TpmAdapterOptions {
+	return func(tca *tpmAdapter) error {
+		if _, err := hashAlgorithmName(hashAlgorithm); err != nil {
+			return err
+		}
+		tca.eventLogDigest = hashAlgorithm
+		return nil
+	}
+}
+
+// WithMaxLogSize limits the size (in bytes) of the IMA and UEFI event logs that
+// are read when collecting evidence.  Reading logs that exceed the limit fails with
+// ErrLogTooLarge.  By default, DefaultMaxLogSize is used.
+func WithMaxLogSize(bytes int) TpmAdapterOptions {
+	return func(tca *tpmAdapter) error {
+		if bytes <= 0 {
+			return errors.Errorf("Invalid maximum log size %d", bytes)
+		}
+		tca.maxLogSize = bytes
+		return nil
+	}
+}
+
+// WithFileReader overrides how the IMA log, UEFI event log and AK certificate files
+// are read (ex. to source them from a secure store).  By default, files are read from
+// the local file system after checking for symlinks and path traversal.  The size
+// limit from WithMaxLogSize is applied to logs returned by 'fileReader'.
+func WithFileReader(fileReader FileReader) TpmAdapterOptions {
+	return func(tca *tpmAdapter) error {
+		if fileReader == nil {
+			return errors.New("The file reader cannot be nil")
+		}
+		tca.fileReader = fileReader
+		return nil
+	}
+}
+
+// WithSkipAkCertValidity disables checking the AK certificate's validity period
+// (NotBefore/NotAfter) when it is read during evidence collection.  By default,
+// expired or not-yet-valid certificates fail with ErrAkCertificateExpired.
+func WithSkipAkCertValidity(skip bool) TpmAdapterOptions {
+	return func(tca *tpmAdapter) error {
+		tca.skipAkCertValidity = skip
+		return nil
+	}
+}
+
+// WithAkName provides the expected name of the AK (ex. recorded when the AK was
+// provisioned), as returned by TrustedPlatformModule.ReadPublic.  When set, evidence
+// collection fails with ErrAkCertMismatch if the key at the AK handle has a different
+// name.  Independent of this option, the AK's public key is always compared to the AK
+// certificate when one is included in the evidence.
+func WithAkName(akName []byte) TpmAdapterOptions {
+	return func(tca *tpmAdapter) error {
+		if len(akName) == 0 {
+			return errors.New("The AK name cannot be empty")
+		}
+		tca.akName = akName
+		return nil
+	}
+}
+
+// WithAkCertValiditySkew sets the clock skew that is tolerated when checking the
+// AK certificate's validity period.  By default, DefaultAkCertValiditySkew is used.
+func WithAkCertValiditySkew(skew time.Duration) TpmAdapterOptions {
+	return func(tca *tpmAdapter) error {
+		if skew < 0 {
+			return errors.Errorf("Invalid AK certificate validity skew %v", skew)
+		}
+		tca.akCertValiditySkew = skew
+		return nil
+	}
+}
+
+// WithNonceHashAlgorithm sets the hash algorithm used to digest the verifier-nonce
+// and user-data into the quote's extra-data.  The digest must fit in the TPM's
+// extra-data field (ex. SHA-256 or SHA-384).  By default, DefaultNonceHashAlgorithm
+// (SHA-256) is used.
+func WithNonceHashAlgorithm(hashAlgorithm crypto.Hash) TpmAdapterOptions {
+	return func(tca *tpmAdapter) error {
+		if !hashAlgorithm.Available() {
+			return errors.Errorf("The nonce hash algorithm %v is not available", hashAlgorithm)
+		}
+
+		if hashAlgorithm.Size() > maxQuoteExtraDataSize {
+			return errors.Errorf("The nonce hash algorithm %v exceeds the maximum quote extra-data size of %d bytes", hashAlgorithm, maxQuoteExtraDataSize)
+		}
+
+		tca.nonceHashAlgorithm = hashAlgorithm
+		return nil
+	}
+}
+
 // WithAkCertificateUri specifies the full path to an AK certificate file
 // in PEM format that will be used by ITA to verify the TPM quotes.
 func WithAkCertificateUri(uriString string) TpmAdapterOptions {
@@ -158,50 +392,155 @@ func WithAkCertificateUri(uriString string) TpmAdapterOptions {
 	}
 }
 
+// WithStageObserver calls 'observer' after the adapter gets the TPM quote and PCRs and
+// reads the IMA/UEFI event logs, allowing operators to export the latency and failures
+// of each stage to their metrics system (ex. a Prometheus histogram and counter).
+func WithStageObserver(observer StageObserver) TpmAdapterOptions {
+	return func(tca *tpmAdapter) error {
+		tca.stageObserver = observer
+		return nil
+	}
+}
+
+// WithTpmSession encrypts the TPM quote's parameters using an HMAC session salted with
+// the EK at 'ekHandle' (ex. DefaultEkHandle), protecting the nonce and quote from an
+// attacker with physical access to the TPM interface (see StartEncryptedSession).  The
+// session is only started if the EK's public key is 'ekPublic'.  When 'ekPublic' is nil,
+// the public key of the EK certificate at DefaultEkNvIndex is expected instead.  By
+// default, quotes are not encrypted.
+func WithTpmSession(ekHandle int, ekPublic crypto.PublicKey) TpmAdapterOptions {
+	return func(tca *tpmAdapter) error {
+		if ekHandle < minPersistentHandle || ekHandle > maxPersistentHandle {
+			return errors.Wrapf(ErrHandleOutOfRange, "Invalid EK handle 0x%x", ekHandle)
+		}
+
+		tca.sessionEkHandle = ekHandle
+		tca.sessionEkPublic = ekPublic
+		return nil
+	}
+}
+
 func (tca *tpmAdapter) GetEvidenceIdentifier() string {
 	return "tpm"
 }
 
 func (tca *tpmAdapter) GetEvidence(verifierNonce *connector.VerifierNonce, userData []byte) (interface{}, error) {
+	return tca.GetEvidenceContext(context.Background(), verifierNonce, userData)
+}
 
-	tpm, err := NewTpmFactory().New(tca.deviceType, tca.ownerAuth)
+// GetEvidenceContext implements connector.ContextEvidenceAdapter, stopping the IMA and
+// UEFI event log reads when 'ctx' is cancelled.  TPM commands (ex. the quote) are not interrupted.
+func (tca *tpmAdapter) GetEvidenceContext(ctx context.Context, verifierNonce *connector.VerifierNonce, userData []byte) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	tpm, err := tca.tpmFactory.New(tca.deviceType, tca.ownerAuth)
 	if err != nil {
-		return nil, errors.Wrap(err, "Failed to open TPM")
+		return nil, fmt.Errorf("%w: %w", ErrTpmOpenFailure, err)
 	}
 	defer tpm.Close()
 
-	// Create a sha256 hash of the verifier-nonce and user-data.
-	nonceHash, err := createNonceHash(verifierNonce, userData)
+	if tca.sessionEkHandle != 0 {
+		if err = tca.startEncryptedSession(tpm); err != nil {
+			return nil, WrapTpmError(err)
+		}
+	}
+
+	evidence, err := tca.getEvidence(ctx, tpm, verifierNonce, userData)
+	if err != nil {
+		return nil, WrapTpmError(err)
+	}
+
+	return evidence, nil
+}
+
+// startEncryptedSession starts the session requested by WithTpmSession.
+func (tca *tpmAdapter) startEncryptedSession(tpm TrustedPlatformModule) error {
+	ekPublic := tca.sessionEkPublic
+	if ekPublic == nil {
+		ekCert, err := tpm.GetEKCertificate(DefaultEkNvIndex)
+		if err != nil {
+			return errors.Wrap(err, "Failed to read the EK certificate")
+		}
+		ekPublic = ekCert.PublicKey
+	}
+
+	return tpm.StartEncryptedSession(tca.sessionEkHandle, ekPublic)
+}
+
+func (tca *tpmAdapter) getEvidence(ctx context.Context, tpm TrustedPlatformModule, verifierNonce *connector.VerifierNonce, userData []byte) (interface{}, error) {
+
+	// Create a hash of the verifier-nonce and user-data (see WithNonceHashAlgorithm).
+	nonceHash, err := createNonceHash(tca.nonceHashAlgorithm, verifierNonce, userData)
 	if err != nil {
 		return nil, err
 	}
 
+	start := time.Now()
 	quote, signature, err := tpm.GetQuote(tca.akHandle, nonceHash, tca.pcrSelections...)
+	tca.observeStage(StageGetQuote, start, err)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Failed to get quote using AK handle 0x%x", tca.akHandle)
 	}
 
+	start = time.Now()
 	pcrs, err := tpm.GetPcrs(tca.pcrSelections...)
+	tca.observeStage(StageGetPcrs, start, err)
 	if err != nil {
 		return nil, err
 	}
 
+	var clockInfo *QuoteClockInfo
+	if tca.withClockInfo {
+		clockInfo, err = parseQuoteClockInfo(quote)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var imaLogs []byte
 	if tca.withImaLogs {
-		imaLogs, err = readFile(DefaultImaPath)
+		start = time.Now()
+		imaLogs, err = tca.readEventLog(ctx, DefaultImaPath, ErrFailedToReadIMALogs)
+		tca.observeStage(StageReadImaLog, start, err)
 		if err != nil {
-			return nil, errors.Wrapf(err, "Failed to read ima log file %q", DefaultImaPath)
+			return nil, err
+		}
+		if imaLogs != nil {
+			logrus.Debugf("Read IMA log %q from %s (%d bytes)", DefaultImaPath, tca.logSource(), len(imaLogs))
+		}
+	}
+
+	if imaLogs != nil {
+		if len(tca.imaLogFields) > 0 {
+			imaLogs, err = filterImaLog(imaLogs, tca.imaLogFields)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to filter ima log file %q", DefaultImaPath)
+			}
+		}
+
+		if tca.eventLogTracker != nil {
+			imaLogs, _ = tca.eventLogTracker.delta(DefaultImaPath, imaLogs)
 		}
 	}
 
 	var uefiEventLogs []byte
+	var uefiBytes []byte
 	if tca.withUefiLogs {
-		uefiBytes, err := readFile(DefaultUefiEventLogPath)
+		start = time.Now()
+		uefiBytes, err = tca.readEventLog(ctx, DefaultUefiEventLogPath, ErrFailedToReadUEFILogs)
+		tca.observeStage(StageReadUefiLog, start, err)
 		if err != nil {
-			return nil, errors.Wrapf(err, "Failed to open uefi log file %q", DefaultUefiEventLogPath)
+			return nil, err
 		}
+		if uefiBytes != nil {
+			logrus.Debugf("Read UEFI event log %q from %s (%d bytes)", DefaultUefiEventLogPath, tca.logSource(), len(uefiBytes))
+		}
+	}
 
-		eventLogFilter, err := newEventLogFilter(uefiBytes, tca.pcrSelections...)
+	if uefiBytes != nil {
+		eventLogFilter, err := newEventLogFilter(uefiBytes, tca.filterLogger, tca.pcrSelections...)
 		if err != nil {
 			return nil, errors.Wrap(err, "Failed to create event log filter for file")
 		}
@@ -210,42 +549,136 @@ func (tca *tpmAdapter) GetEvidence(verifierNonce *connector.VerifierNonce, userD
 		if err != nil {
 			return nil, errors.Wrap(err, "Failed to parse uefi event log file")
 		}
+
+		if tca.eventLogTracker != nil {
+			uefiEventLogs, _ = tca.eventLogTracker.delta(DefaultUefiEventLogPath, uefiEventLogs)
+		}
 	}
 
 	// When specified by WithAkCertificatePath, read the AK certificate from the
 	// file system, convert it to der format so that it is included in the evidence.
 	var akDer []byte
 	if tca.akCertificateUri != nil {
-		akDer, err = readAkCertificate(tca.akCertificateUri, tpm)
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		akDer, err = tca.readAkCertificate(tpm)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	tpmEvidence := struct {
-		Q []byte                   `json:"quote"`
-		S []byte                   `json:"signature"`
-		P []byte                   `json:"pcrs"`
-		U []byte                   `json:"user_data,omitempty"`
-		I []byte                   `json:"ima_logs,omitempty"`
-		E []byte                   `json:"uefi_event_logs,omitempty"`
-		V *connector.VerifierNonce `json:"verifier_nonce,omitempty"`
-		A []byte                   `json:"ak_certificate_der,omitempty"`
-	}{
+	// Make sure quotes are signed by the AK in the certificate (and/or the AK from
+	// WithAkName) rather than failing attestation due to a misconfigured AK handle.
+	if err = tca.checkAkBinding(tpm, akDer); err != nil {
+		return nil, err
+	}
+
+	// When specified by WithEventLogDigest, replace the logs with their digests.
+	var imaLogsDigest, uefiEventLogsDigest []byte
+	var eventLogsDigestAlgorithm string
+	if tca.eventLogDigest != 0 {
+		eventLogsDigestAlgorithm, err = hashAlgorithmName(tca.eventLogDigest)
+		if err != nil {
+			return nil, err
+		}
+
+		if imaLogs != nil {
+			imaLogsDigest = digest(tca.eventLogDigest, imaLogs)
+			imaLogs = nil
+		}
+
+		if uefiEventLogs != nil {
+			uefiEventLogsDigest = digest(tca.eventLogDigest, uefiEventLogs)
+			uefiEventLogs = nil
+		}
+	}
+
+	evidence := tpmEvidence{
+		Q:  quote,
+		S:  signature,
+		P:  pcrs,
+		U:  userData,
+		I:  eventLogField(imaLogs),
+		E:  eventLogField(uefiEventLogs),
+		ID: imaLogsDigest,
+		ED: uefiEventLogsDigest,
+		DA: eventLogsDigestAlgorithm,
+		V:  verifierNonce,
+		A:  akDer,
+
+		QuoteClockInfo: clockInfo,
+	}
+
+	return &evidence, nil
+}
+
+// tpmEvidence is the "tpm" evidence sent to the Trust Authority (see BuildTpmEvidence).
+type tpmEvidence struct {
+	Q  []byte                   `json:"quote"`
+	S  []byte                   `json:"signature"`
+	P  []byte                   `json:"pcrs"`
+	U  []byte                   `json:"user_data,omitempty"`
+	I  *[]byte                  `json:"ima_logs,omitempty"`
+	E  *[]byte                  `json:"uefi_event_logs,omitempty"`
+	ID []byte                   `json:"ima_logs_digest,omitempty"`
+	ED []byte                   `json:"uefi_event_logs_digest,omitempty"`
+	DA string                   `json:"event_logs_digest_algorithm,omitempty"`
+	V  *connector.VerifierNonce `json:"verifier_nonce,omitempty"`
+	A  []byte                   `json:"ak_certificate_der,omitempty"`
+	*QuoteClockInfo
+}
+
+// eventLogField returns the value of the "ima_logs" or "uefi_event_logs" evidence field,
+// which is omitted when the log was not collected (nil) and is empty when the log did not
+// contain any (new) events (see WithDeltaEventLogs).
+func eventLogField(eventLog []byte) *[]byte {
+	if eventLog == nil {
+		return nil
+	}
+	return &eventLog
+}
+
+// BuildTpmEvidence returns the json "tpm" evidence expected by the Trust Authority from
+// components collected outside of this library (ex. a quote, signature and PCRs read
+// with tpm2-tools).  'pcrs' are the concatenated PCR values (see GetPcrs), the quote
+// must be over the hash of the 'nonce' and 'userData' (see WithNonceHashAlgorithm) and
+// the optional 'akDer' is the AK certificate in der format.
+func BuildTpmEvidence(quote, signature, pcrs, userData, imaLogs, uefiLogs, akDer []byte, nonce *connector.VerifierNonce) ([]byte, error) {
+	if len(quote) == 0 || len(signature) == 0 || len(pcrs) == 0 {
+		return nil, errors.New("The quote, signature and pcrs are required")
+	}
+
+	// logs that are not provided are omitted
+	if len(imaLogs) == 0 {
+		imaLogs = nil
+	}
+
+	if len(uefiLogs) == 0 {
+		uefiLogs = nil
+	}
+
+	evidence := tpmEvidence{
 		Q: quote,
 		S: signature,
 		P: pcrs,
 		U: userData,
-		I: imaLogs,
-		E: uefiEventLogs,
-		V: verifierNonce,
+		I: eventLogField(imaLogs),
+		E: eventLogField(uefiLogs),
+		V: nonce,
 		A: akDer,
 	}
 
-	return &tpmEvidence, nil
+	b, err := json.Marshal(&evidence)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to marshal the TPM evidence")
+	}
+
+	return b, nil
 }
 
-func createNonceHash(verifierNonce *connector.VerifierNonce, userData []byte) ([]byte, error) {
+func createNonceHash(hashAlgorithm crypto.Hash, verifierNonce *connector.VerifierNonce, userData []byte) ([]byte, error) {
 	if verifierNonce == nil && len(userData) == 0 {
 		return nil, nil
 	}
@@ -267,7 +700,11 @@ func createNonceHash(verifierNonce *connector.VerifierNonce, userData []byte) ([
 		nonceBytes = append(nonceBytes, userData...)
 	}
 
-	h := sha256.New()
+	if !hashAlgorithm.Available() || hashAlgorithm.Size() > maxQuoteExtraDataSize {
+		return nil, errors.Errorf("Unsupported nonce hash algorithm %v", hashAlgorithm)
+	}
+
+	h := hashAlgorithm.New()
 	_, err := h.Write(nonceBytes)
 	if err != nil {
 		return nil, err
@@ -276,6 +713,99 @@ func createNonceHash(verifierNonce *connector.VerifierNonce, userData []byte) ([
 	return h.Sum(nil), nil
 }
 
+// CommitEventLogOffsets implements DeltaEventLogAdapter.  It has no effect unless
+// WithDeltaEventLogs is enabled.
+func (tca *tpmAdapter) CommitEventLogOffsets() {
+	if tca.eventLogTracker != nil {
+		tca.eventLogTracker.commit()
+	}
+}
+
+// ResetEventLogOffsets implements DeltaEventLogAdapter.  It has no effect unless
+// WithDeltaEventLogs is enabled.
+func (tca *tpmAdapter) ResetEventLogOffsets() {
+	if tca.eventLogTracker != nil {
+		tca.eventLogTracker.reset()
+	}
+}
+
+// readFile reads 'filePath' using the adapter's FileReader (when provided by
+// WithFileReader) or from the local file system.
+func (tca *tpmAdapter) readFile(filePath string) ([]byte, error) {
+	if tca.fileReader != nil {
+		return tca.fileReader(filePath)
+	}
+
+	return readFile(filePath)
+}
+
+// readLogFile is similar to readFile but also applies the adapter's maximum log size and
+// stops reading when 'ctx' is done.  A FileReader cannot be interrupted, so its data is
+// discarded when 'ctx' is done by the time it returns.
+func (tca *tpmAdapter) readLogFile(ctx context.Context, filePath string) ([]byte, error) {
+	if tca.fileReader == nil {
+		return readLimitedFile(ctx, filePath, tca.maxLogSize)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := tca.fileReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return readLimited(ctx, filePath, bytes.NewReader(data), tca.maxLogSize)
+}
+
+// logSource describes where the adapter reads files from in debug messages.
+func (tca *tpmAdapter) logSource() string {
+	if tca.fileReader != nil {
+		return "the FileReader"
+	}
+	return "the file system"
+}
+
+// observeStage reports the duration (since 'start') and result of 'stage' to the
+// adapter's StageObserver (if any).
+func (tca *tpmAdapter) observeStage(stage string, start time.Time, err error) {
+	if tca.stageObserver != nil {
+		tca.stageObserver(stage, time.Since(start), err)
+	}
+}
+
+// readEventLog reads the IMA or UEFI event log at 'filePath', wrapping failures with
+// 'readErr'.  When WithOptionalLogs is enabled and the log does not exist, a warning is
+// logged and nil is returned (i.e., the log is omitted from evidence).
+func (tca *tpmAdapter) readEventLog(ctx context.Context, filePath string, readErr error) ([]byte, error) {
+	eventLog, err := tca.readLogFile(ctx, filePath)
+	if err == nil {
+		return eventLog, nil
+	}
+
+	if tca.optionalLogs && errors.Is(err, os.ErrNotExist) {
+		logrus.Warnf("The log file %q does not exist and will not be included in TPM evidence", filePath)
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("%w: %w", readErr, errors.Wrapf(err, "Failed to read log file %q", filePath))
+}
+
+// contextReader fails with ctx.Err() once 'ctx' is done so that reading a large log
+// stops (between reads) when evidence collection is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
 func readFile(filePath string) ([]byte, error) {
 	err := validateFilePath(filePath)
 	if err != nil {
@@ -285,6 +815,56 @@ func readFile(filePath string) ([]byte, error) {
 	return os.ReadFile(filePath)
 }
 
+// readLimitedFile is similar to readFile but fails with ErrLogTooLarge when the
+// file contains more than 'maxSize' bytes.  The file is read up to the limit since
+// files in securityfs (ex. the IMA log) do not report their size.  Gzip compressed
+// files (detected by their magic bytes) are decompressed and the limit is applied
+// to the decompressed data.  Reading stops with ctx.Err() when 'ctx' is done.
+func readLimitedFile(ctx context.Context, filePath string, maxSize int) ([]byte, error) {
+	err := validateFilePath(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readLimited(ctx, filePath, f, maxSize)
+}
+
+// readLimited reads the contents of 'filePath' from 'r', decompressing gzip compressed
+// data and failing with ErrLogTooLarge when it contains more than 'maxSize' bytes (see
+// readLimitedFile).
+func readLimited(ctx context.Context, filePath string, r io.Reader, maxSize int) ([]byte, error) {
+	reader := bufio.NewReader(&contextReader{ctx: ctx, r: r})
+	var src io.Reader = reader
+
+	// files shorter than the magic bytes are not compressed (Peek returns an error)
+	magic, err := reader.Peek(len(gzipMagic))
+	if err == nil && bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to decompress %q", filePath)
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	data, err := io.ReadAll(io.LimitReader(src, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > maxSize {
+		return nil, errors.Wrapf(ErrLogTooLarge, "%q exceeds the maximum size of %d bytes", filePath, maxSize)
+	}
+
+	return data, nil
+}
+
 // validateFilePath performs checks fo path traversal (CT203 and T162),
 // and symlinks (T572) and assumes that os.Lstat (aka "linux") will
 // perform checks for the file's existence, unallowed characters (T34),
@@ -307,12 +887,14 @@ func validateFilePath(filePath string) error {
 	return nil
 }
 
-func readAkCertificate(akUri *url.URL, tpm TrustedPlatformModule) ([]byte, error) {
+func (tca *tpmAdapter) readAkCertificate(tpm TrustedPlatformModule) ([]byte, error) {
 	var akPemBytes []byte
 	var err error
 
+	akUri := tca.akCertificateUri
+	logrus.Debugf("Reading AK certificate from %s", akUri)
 	if akUri.Scheme == "file" {
-		akPemBytes, err = readFile(akUri.Path)
+		akPemBytes, err = tca.readFile(akUri.Path)
 		if err != nil {
 			return nil, errors.Wrapf(err, "Failed to read AK certificate PEM from file %s", akUri.Path)
 		}
@@ -343,5 +925,58 @@ func readAkCertificate(akUri *url.URL, tpm TrustedPlatformModule) ([]byte, error
 		return nil, errors.Wrap(err, "Failed to parse AK certificate")
 	}
 
+	if !tca.skipAkCertValidity {
+		err = checkCertificateValidity(akCert, time.Now(), tca.akCertValiditySkew)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return akCert.Raw, nil
 }
+
+// checkAkBinding returns ErrAkCertMismatch when the key at the AK handle does not have
+// the public key of the AK certificate in 'akDer' (optional) or the name provided by
+// WithAkName.
+func (tca *tpmAdapter) checkAkBinding(tpm TrustedPlatformModule, akDer []byte) error {
+	if akDer == nil && tca.akName == nil {
+		return nil
+	}
+
+	akPublic, _, akName, err := tpm.ReadPublic(tca.akHandle)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to read the public area of AK handle 0x%x", tca.akHandle)
+	}
+
+	if tca.akName != nil && !bytes.Equal(akName, tca.akName) {
+		return errors.Wrapf(ErrAkCertMismatch, "the name of AK handle 0x%x is %x, expected %x", tca.akHandle, akName, tca.akName)
+	}
+
+	if akDer != nil {
+		akCert, err := x509.ParseCertificate(akDer)
+		if err != nil {
+			return errors.Wrap(err, "Failed to parse AK certificate")
+		}
+
+		certPublic, ok := akCert.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+		if !ok || !certPublic.Equal(akPublic) {
+			return errors.Wrapf(ErrAkCertMismatch, "the public key of AK handle 0x%x does not match the AK certificate", tca.akHandle)
+		}
+	}
+
+	return nil
+}
+
+// checkCertificateValidity returns ErrAkCertificateExpired when 'now' is outside of the
+// certificate's validity period (allowing for 'skew').
+func checkCertificateValidity(cert *x509.Certificate, now time.Time, skew time.Duration) error {
+	if now.Add(skew).Before(cert.NotBefore) {
+		return errors.Wrapf(ErrAkCertificateExpired, "the AK certificate is not valid until %s, the AK may need to be re-provisioned", cert.NotBefore)
+	}
+
+	if now.Add(-skew).After(cert.NotAfter) {
+		return errors.Wrapf(ErrAkCertificateExpired, "the AK certificate expired on %s, the AK must be re-provisioned", cert.NotAfter)
+	}
+
+	return nil
+}
@@ -7,14 +7,35 @@
 package tpm
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/x509"
+	_ "embed"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"syscall"
 	"testing"
+	"time"
 
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
 	"github.com/intel/trustauthority-client/go-connector"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
 )
 
 func TestAdapterNewWithOptions(t *testing.T) {
@@ -44,13 +65,16 @@ func TestAdapterNewWithOptions(t *testing.T) {
 				WithDeviceType(TpmDeviceMSSIM),
 			},
 			expectedAdapter: &tpmAdapter{
-				akHandle:         DefaultAkHandle,
-				pcrSelections:    defaultPcrSelections,
-				deviceType:       TpmDeviceMSSIM,
-				ownerAuth:        "",
-				withImaLogs:      false,
-				withUefiLogs:     false,
-				akCertificateUri: nil,
+				akHandle:           DefaultAkHandle,
+				pcrSelections:      defaultPcrSelections,
+				deviceType:         TpmDeviceMSSIM,
+				ownerAuth:          "",
+				withImaLogs:        false,
+				withUefiLogs:       false,
+				akCertificateUri:   nil,
+				maxLogSize:         DefaultMaxLogSize,
+				akCertValiditySkew: DefaultAkCertValiditySkew,
+				nonceHashAlgorithm: DefaultNonceHashAlgorithm,
 			},
 			expectError: false,
 		},
@@ -60,13 +84,16 @@ func TestAdapterNewWithOptions(t *testing.T) {
 				WithOwnerAuth("ownerX"),
 			},
 			expectedAdapter: &tpmAdapter{
-				akHandle:         DefaultAkHandle,
-				pcrSelections:    defaultPcrSelections,
-				deviceType:       TpmDeviceLinux,
-				ownerAuth:        "ownerX",
-				withImaLogs:      false,
-				withUefiLogs:     false,
-				akCertificateUri: nil,
+				akHandle:           DefaultAkHandle,
+				pcrSelections:      defaultPcrSelections,
+				deviceType:         TpmDeviceLinux,
+				ownerAuth:          "ownerX",
+				withImaLogs:        false,
+				withUefiLogs:       false,
+				akCertificateUri:   nil,
+				maxLogSize:         DefaultMaxLogSize,
+				akCertValiditySkew: DefaultAkCertValiditySkew,
+				nonceHashAlgorithm: DefaultNonceHashAlgorithm,
 			},
 			expectError: false,
 		},
@@ -76,13 +103,16 @@ func TestAdapterNewWithOptions(t *testing.T) {
 				WithPcrSelections("sha256:all"),
 			},
 			expectedAdapter: &tpmAdapter{
-				akHandle:         DefaultAkHandle,
-				pcrSelections:    defaultPcrSelections,
-				deviceType:       TpmDeviceLinux,
-				ownerAuth:        "",
-				withImaLogs:      false,
-				withUefiLogs:     false,
-				akCertificateUri: nil,
+				akHandle:           DefaultAkHandle,
+				pcrSelections:      defaultPcrSelections,
+				deviceType:         TpmDeviceLinux,
+				ownerAuth:          "",
+				withImaLogs:        false,
+				withUefiLogs:       false,
+				akCertificateUri:   nil,
+				maxLogSize:         DefaultMaxLogSize,
+				akCertValiditySkew: DefaultAkCertValiditySkew,
+				nonceHashAlgorithm: DefaultNonceHashAlgorithm,
 			},
 			expectError: false,
 		},
@@ -100,13 +130,16 @@ func TestAdapterNewWithOptions(t *testing.T) {
 				WithImaLogs(true),
 			},
 			expectedAdapter: &tpmAdapter{
-				akHandle:         DefaultAkHandle,
-				pcrSelections:    defaultPcrSelections,
-				deviceType:       TpmDeviceLinux,
-				ownerAuth:        "",
-				withImaLogs:      true,
-				withUefiLogs:     false,
-				akCertificateUri: nil,
+				akHandle:           DefaultAkHandle,
+				pcrSelections:      defaultPcrSelections,
+				deviceType:         TpmDeviceLinux,
+				ownerAuth:          "",
+				withImaLogs:        true,
+				withUefiLogs:       false,
+				akCertificateUri:   nil,
+				maxLogSize:         DefaultMaxLogSize,
+				akCertValiditySkew: DefaultAkCertValiditySkew,
+				nonceHashAlgorithm: DefaultNonceHashAlgorithm,
 			},
 			expectError: false,
 		},
@@ -116,13 +149,16 @@ func TestAdapterNewWithOptions(t *testing.T) {
 				WithUefiEventLogs(true),
 			},
 			expectedAdapter: &tpmAdapter{
-				akHandle:         DefaultAkHandle,
-				pcrSelections:    defaultPcrSelections,
-				deviceType:       TpmDeviceLinux,
-				ownerAuth:        "",
-				withImaLogs:      false,
-				withUefiLogs:     true,
-				akCertificateUri: nil,
+				akHandle:           DefaultAkHandle,
+				pcrSelections:      defaultPcrSelections,
+				deviceType:         TpmDeviceLinux,
+				ownerAuth:          "",
+				withImaLogs:        false,
+				withUefiLogs:       true,
+				akCertificateUri:   nil,
+				maxLogSize:         DefaultMaxLogSize,
+				akCertValiditySkew: DefaultAkCertValiditySkew,
+				nonceHashAlgorithm: DefaultNonceHashAlgorithm,
 			},
 			expectError: false,
 		},
@@ -132,13 +168,16 @@ func TestAdapterNewWithOptions(t *testing.T) {
 				WithAkCertificateUri(""), // an empty path is allowed for Azure TDX runtime-data scenarios
 			},
 			expectedAdapter: &tpmAdapter{
-				akHandle:         DefaultAkHandle,
-				pcrSelections:    defaultPcrSelections,
-				deviceType:       TpmDeviceLinux,
-				ownerAuth:        "",
-				withImaLogs:      false,
-				withUefiLogs:     false,
-				akCertificateUri: nil,
+				akHandle:           DefaultAkHandle,
+				pcrSelections:      defaultPcrSelections,
+				deviceType:         TpmDeviceLinux,
+				ownerAuth:          "",
+				withImaLogs:        false,
+				withUefiLogs:       false,
+				akCertificateUri:   nil,
+				maxLogSize:         DefaultMaxLogSize,
+				akCertValiditySkew: DefaultAkCertValiditySkew,
+				nonceHashAlgorithm: DefaultNonceHashAlgorithm,
 			},
 			expectError: false,
 		},
@@ -158,6 +197,9 @@ func TestAdapterNewWithOptions(t *testing.T) {
 					Scheme: "file",
 					Path:   "/dir/myak.pem",
 				},
+				maxLogSize:         DefaultMaxLogSize,
+				akCertValiditySkew: DefaultAkCertValiditySkew,
+				nonceHashAlgorithm: DefaultNonceHashAlgorithm,
 			},
 			expectError: false,
 		},
@@ -177,22 +219,55 @@ func TestAdapterNewWithOptions(t *testing.T) {
 					Scheme: "nvram",
 					Host:   "0x81010001",
 				},
+				maxLogSize:         DefaultMaxLogSize,
+				akCertValiditySkew: DefaultAkCertValiditySkew,
+				nonceHashAlgorithm: DefaultNonceHashAlgorithm,
 			},
 			expectError: false,
 		},
+		{
+			testName: "Test adapter with max log size",
+			options: []TpmAdapterOptions{
+				WithMaxLogSize(1024),
+			},
+			expectedAdapter: &tpmAdapter{
+				akHandle:           DefaultAkHandle,
+				pcrSelections:      defaultPcrSelections,
+				deviceType:         TpmDeviceLinux,
+				ownerAuth:          "",
+				withImaLogs:        false,
+				withUefiLogs:       false,
+				akCertificateUri:   nil,
+				maxLogSize:         1024,
+				akCertValiditySkew: DefaultAkCertValiditySkew,
+				nonceHashAlgorithm: DefaultNonceHashAlgorithm,
+			},
+			expectError: false,
+		},
+		{
+			testName: "Test adapter with invalid max log size",
+			options: []TpmAdapterOptions{
+				WithMaxLogSize(0),
+			},
+			expectedAdapter: nil,
+			expectError:     true,
+		},
 		{
 			testName: "Test adapter invalid ak certificate uri",
 			options: []TpmAdapterOptions{
 				WithAkCertificateUri("xyz://123"),
 			},
 			expectedAdapter: &tpmAdapter{
-				akHandle:         DefaultAkHandle,
-				pcrSelections:    defaultPcrSelections,
-				deviceType:       TpmDeviceLinux,
-				ownerAuth:        "",
-				withImaLogs:      false,
-				withUefiLogs:     false,
-				akCertificateUri: nil,
+				akHandle:           DefaultAkHandle,
+				pcrSelections:      defaultPcrSelections,
+				deviceType:         TpmDeviceLinux,
+				ownerAuth:          "",
+				withImaLogs:        false,
+				withUefiLogs:       false,
+				akCertificateUri:   nil,
+				maxLogSize:         DefaultMaxLogSize,
+				akCertValiditySkew: DefaultAkCertValiditySkew,
+				nonceHashAlgorithm: DefaultNonceHashAlgorithm,
 			},
 			expectError: true,
 		},
@@ -200,7 +275,8 @@ func TestAdapterNewWithOptions(t *testing.T) {
 
 	for _, tt := range testData {
 		t.Run(tt.testName, func(t *testing.T) {
-			adapter, err := NewTpmAdapterFactory(NewTpmFactory()).New(tt.options...)
+			tpmFactory := NewTpmFactory()
+			adapter, err := NewTpmAdapterFactory(tpmFactory).New(tt.options...)
 			if !tt.expectError && err != nil {
 				// not expecting an error but got one
 				t.Fatal(err)
@@ -212,8 +288,12 @@ func TestAdapterNewWithOptions(t *testing.T) {
 				return
 			}
 
-			if !reflect.DeepEqual(adapter, tt.expectedAdapter) {
-				t.Fatalf("NewCompositeEvidenceAdapterWithOptions() returned unexpected result: expected %v, got %v", tt.expectedAdapter, adapter)
+			// the adapter should use the TpmFactory provided to NewTpmAdapterFactory
+			expectedAdapter := *tt.expectedAdapter
+			expectedAdapter.tpmFactory = tpmFactory
+
+			if !reflect.DeepEqual(adapter, &expectedAdapter) {
+				t.Fatalf("NewCompositeEvidenceAdapterWithOptions() returned unexpected result: expected %v, got %v", &expectedAdapter, adapter)
 			}
 		})
 	}
@@ -246,9 +326,86 @@ func TestAdapterGetEvidencePositive(t *testing.T) {
 	}
 }
 
+func TestAdapterGetEvidenceEncryptedSession(t *testing.T) {
+	tpm, err := newTestTpm()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = provisionTestAk(tpm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpm.Close()
+
+	adapter, err := NewTpmAdapterFactory(NewTpmFactory()).New(
+		WithDeviceType(TpmDeviceMSSIM),
+		WithAkHandle(testAkHandle),
+		WithTpmSession(testEkHandle, nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = adapter.GetEvidence(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAdapterClockInfoSimulator(t *testing.T) {
+	tpm, err := newTestTpm()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = provisionTestAk(tpm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpm.Close()
+
+	adapter, err := NewTpmAdapterFactory(NewTpmFactory()).New(
+		WithDeviceType(TpmDeviceMSSIM),
+		WithAkHandle(testAkHandle),
+		WithClockInfo(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidence, err := adapter.GetEvidence(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	if err = json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{"clock", "reset_count", "restart_count", "clock_safe", "firmware_version"} {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("Expected %q in the TPM evidence", field)
+		}
+	}
+
+	if fields["firmware_version"].(float64) == 0 {
+		t.Error("Expected the simulator's firmware version in the TPM evidence")
+	}
+}
+
 func TestAdapterNonceHash(t *testing.T) {
 	testData := []struct {
 		testName       string
+		hashAlgorithm  crypto.Hash
 		verifierNonce  *connector.VerifierNonce
 		userData       []byte
 		expectedLength int
@@ -256,6 +413,7 @@ func TestAdapterNonceHash(t *testing.T) {
 	}{
 		{
 			"Test nil nonce",
+			crypto.SHA256,
 			nil,
 			nil,
 			0,
@@ -263,6 +421,7 @@ func TestAdapterNonceHash(t *testing.T) {
 		},
 		{
 			"Test with just VerifyNonce",
+			crypto.SHA256,
 			&connector.VerifierNonce{
 				Iat: make([]byte, crypto.SHA256.Size()),
 				Val: make([]byte, crypto.SHA256.Size()),
@@ -273,16 +432,36 @@ func TestAdapterNonceHash(t *testing.T) {
 		},
 		{
 			"Test with just user data",
+			crypto.SHA256,
 			nil,
 			make([]byte, 2),
 			crypto.SHA256.Size(),
 			false,
 		},
+		{
+			"Test SHA-384",
+			crypto.SHA384,
+			&connector.VerifierNonce{
+				Iat: make([]byte, crypto.SHA256.Size()),
+				Val: make([]byte, crypto.SHA256.Size()),
+			},
+			make([]byte, 2),
+			crypto.SHA384.Size(),
+			false,
+		},
+		{
+			"Test SHA-512 exceeds extra-data",
+			crypto.SHA512,
+			nil,
+			make([]byte, 2),
+			0,
+			true,
+		},
 	}
 
 	for _, td := range testData {
 		t.Run(td.testName, func(t *testing.T) {
-			h, err := createNonceHash(td.verifierNonce, td.userData)
+			h, err := createNonceHash(td.hashAlgorithm, td.verifierNonce, td.userData)
 			if !td.errorExpected && err != nil {
 				// not expecting an error but got one
 				t.Fatal(err)
@@ -301,6 +480,46 @@ func TestAdapterNonceHash(t *testing.T) {
 	}
 }
 
+func TestWithNonceHashAlgorithm(t *testing.T) {
+	testData := []struct {
+		testName      string
+		hashAlgorithm crypto.Hash
+		errorExpected bool
+	}{
+		{"SHA-256", crypto.SHA256, false},
+		{"SHA-384", crypto.SHA384, false},
+		{"SHA-512 exceeds extra-data", crypto.SHA512, true},
+		{"Unavailable hash", crypto.Hash(0), true},
+	}
+
+	for _, td := range testData {
+		t.Run(td.testName, func(t *testing.T) {
+			adapter, err := NewTpmAdapterFactory(nil).New(WithNonceHashAlgorithm(td.hashAlgorithm))
+			if td.errorExpected {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if adapter.(*tpmAdapter).nonceHashAlgorithm != td.hashAlgorithm {
+				t.Errorf("Expected nonce hash algorithm %v, got %v", td.hashAlgorithm, adapter.(*tpmAdapter).nonceHashAlgorithm)
+			}
+		})
+	}
+
+	adapter, err := NewTpmAdapterFactory(nil).New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if adapter.(*tpmAdapter).nonceHashAlgorithm != crypto.SHA256 {
+		t.Errorf("Expected the default nonce hash algorithm to be SHA-256")
+	}
+}
+
 func TestValidFilePaths(t *testing.T) {
 	testData := []struct {
 		testName      string
@@ -330,3 +549,1017 @@ func TestValidFilePaths(t *testing.T) {
 		})
 	}
 }
+
+func TestReadLimitedFile(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "ima_log")
+	err := os.WriteFile(logFile, make([]byte, 1024), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testData := []struct {
+		testName      string
+		maxSize       int
+		expectedError error
+	}{
+		{"Log smaller than maximum", 2048, nil},
+		{"Log equal to maximum", 1024, nil},
+		{"Log larger than maximum should fail", 1023, ErrLogTooLarge},
+	}
+
+	for _, td := range testData {
+		t.Run(td.testName, func(t *testing.T) {
+			data, err := readLimitedFile(context.Background(), logFile, td.maxSize)
+			if td.expectedError != nil {
+				if !errors.Is(err, td.expectedError) {
+					t.Fatalf("Expected error %v, but got %v", td.expectedError, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(data) != 1024 {
+				t.Fatalf("Expected 1024 bytes, got %d", len(data))
+			}
+		})
+	}
+}
+
+func TestReadLimitedFileGzip(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write(binary_bios_measurements20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gz.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	plainFile := filepath.Join(dir, "binary_bios_measurements")
+	gzFile := filepath.Join(dir, "binary_bios_measurements.gz")
+	if err = os.WriteFile(plainFile, binary_bios_measurements20, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(gzFile, compressed.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	filterFile := func(path string) []byte {
+		evl, err := readLimitedFile(context.Background(), path, DefaultMaxLogSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		eventLogFilter, err := newEventLogFilter(evl, nil, defaultPcrSelections...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		filtered, err := eventLogFilter.FilterEventLogs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return filtered
+	}
+
+	if !bytes.Equal(filterFile(plainFile), filterFile(gzFile)) {
+		t.Fatal("Expected the gzip compressed event log to produce the same results as the uncompressed log")
+	}
+
+	// the maximum size applies to the decompressed data
+	_, err = readLimitedFile(context.Background(), gzFile, compressed.Len())
+	if !errors.Is(err, ErrLogTooLarge) {
+		t.Fatalf("Expected error %v, but got %v", ErrLogTooLarge, err)
+	}
+
+	// corrupt gzip data should fail
+	corruptFile := filepath.Join(dir, "corrupt.gz")
+	if err = os.WriteFile(corruptFile, append(bytes.Clone(gzipMagic), 0, 0, 0), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = readLimitedFile(context.Background(), corruptFile, DefaultMaxLogSize); err == nil {
+		t.Fatal("Expected an error reading corrupt gzip data")
+	}
+}
+
+// newTestAkCertificate returns a self-signed certificate (in der format) with the
+// specified validity period.
+func newTestAkCertificate(t *testing.T, notBefore time.Time, notAfter time.Time) []byte {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+
+	akDer, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return akDer
+}
+
+func TestAdapterWithFileReader(t *testing.T) {
+	akDer := newTestAkCertificate(t, time.Now(), time.Now().AddDate(1, 0, 0))
+
+	akPath := "/secure/store/ak.pem"
+	files := map[string][]byte{
+		DefaultImaPath: []byte("ima log"),
+		akPath:         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: akDer}),
+	}
+
+	fakeReader := func(path string) ([]byte, error) {
+		if data, ok := files[path]; ok {
+			return data, nil
+		}
+		return nil, os.ErrNotExist
+	}
+
+	adapter, err := NewTpmAdapterFactory(NewTpmFactory()).New(
+		WithFileReader(fakeReader),
+		WithAkCertificateUri("file://"+akPath),
+		WithMaxLogSize(len(files[DefaultImaPath])),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tca := adapter.(*tpmAdapter)
+
+	imaLogs, err := tca.readLogFile(context.Background(), DefaultImaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(imaLogs, files[DefaultImaPath]) {
+		t.Fatalf("Expected the IMA log from the file reader")
+	}
+
+	certificate, err := tca.readAkCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(certificate, akDer) {
+		t.Fatalf("Expected the AK certificate from the file reader")
+	}
+
+	// files that are not provided by the reader should fail
+	if _, err = tca.readLogFile(context.Background(), DefaultUefiEventLogPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected error %v, but got %v", os.ErrNotExist, err)
+	}
+
+	// the maximum log size applies to the file reader's logs
+	files[DefaultImaPath] = append(files[DefaultImaPath], '!')
+	if _, err = tca.readLogFile(context.Background(), DefaultImaPath); !errors.Is(err, ErrLogTooLarge) {
+		t.Fatalf("Expected error %v, but got %v", ErrLogTooLarge, err)
+	}
+
+	// gzip compressed logs from the file reader are decompressed
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err = gz.Write([]byte("ima log")); err != nil {
+		t.Fatal(err)
+	}
+	if err = gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files[DefaultImaPath] = compressed.Bytes()
+
+	imaLogs, err = tca.readLogFile(context.Background(), DefaultImaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(imaLogs, []byte("ima log")) {
+		t.Fatalf("Expected the decompressed IMA log from the file reader, got %q", imaLogs)
+	}
+
+	// a nil reader is not allowed
+	if _, err = NewTpmAdapterFactory(NewTpmFactory()).New(WithFileReader(nil)); err == nil {
+		t.Fatal("Expected an error for a nil file reader")
+	}
+}
+
+func TestAdapterAkCertificateValidity(t *testing.T) {
+	now := time.Now()
+
+	testData := []struct {
+		testName      string
+		notBefore     time.Time
+		notAfter      time.Time
+		options       []TpmAdapterOptions
+		expectedError error
+	}{
+		{"Valid AK certificate", now.Add(-time.Hour), now.Add(time.Hour), nil, nil},
+		{"Expired AK certificate should fail", now.AddDate(-1, 0, 0), now.Add(-time.Hour), nil, ErrAkCertificateExpired},
+		{"Not yet valid AK certificate should fail", now.Add(time.Hour), now.AddDate(1, 0, 0), nil, ErrAkCertificateExpired},
+		{"Recently expired AK certificate within skew", now.AddDate(-1, 0, 0), now.Add(-time.Minute), nil, nil},
+		{"Expired AK certificate with reduced skew should fail", now.AddDate(-1, 0, 0), now.Add(-time.Minute), []TpmAdapterOptions{WithAkCertValiditySkew(0)}, ErrAkCertificateExpired},
+		{"Expired AK certificate with skipped validity", now.AddDate(-1, 0, 0), now.Add(-time.Hour), []TpmAdapterOptions{WithSkipAkCertValidity(true)}, nil},
+	}
+
+	for _, td := range testData {
+		t.Run(td.testName, func(t *testing.T) {
+			akPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: newTestAkCertificate(t, td.notBefore, td.notAfter)})
+			fakeReader := func(path string) ([]byte, error) {
+				return akPem, nil
+			}
+
+			options := append([]TpmAdapterOptions{
+				WithFileReader(fakeReader),
+				WithAkCertificateUri("file:///ak.pem"),
+			}, td.options...)
+
+			adapter, err := NewTpmAdapterFactory(NewTpmFactory()).New(options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = adapter.(*tpmAdapter).readAkCertificate(nil)
+			if td.expectedError == nil && err != nil {
+				t.Fatal(err)
+			} else if !errors.Is(err, td.expectedError) {
+				t.Fatalf("Expected error %v, but got %v", td.expectedError, err)
+			}
+		})
+	}
+
+	if _, err := NewTpmAdapterFactory(NewTpmFactory()).New(WithAkCertValiditySkew(-time.Second)); err == nil {
+		t.Fatal("Expected an error for a negative skew")
+	}
+}
+
+// failingTpm is a TrustedPlatformModule whose quotes fail with 'quoteErr' (quotes and
+// pcrs are empty when it is nil).
+type failingTpm struct {
+	TrustedPlatformModule
+	quoteErr error
+	quote    []byte
+	akPublic crypto.PublicKey
+	akName   []byte
+
+	sessionEkHandle int
+	sessionEkPublic crypto.PublicKey
+	sessionErr      error
+	ekCert          *x509.Certificate
+}
+
+func (l *failingTpm) StartEncryptedSession(ekHandle int, ekPublic crypto.PublicKey) error {
+	l.sessionEkHandle = ekHandle
+	l.sessionEkPublic = ekPublic
+	return l.sessionErr
+}
+
+func (l *failingTpm) GetEKCertificate(nvIndex int) (*x509.Certificate, error) {
+	if l.ekCert == nil {
+		return nil, ErrorNvIndexDoesNotExist
+	}
+	return l.ekCert, nil
+}
+
+func (l *failingTpm) GetQuote(akHandle int, nonce []byte, selection ...PcrSelection) ([]byte, []byte, error) {
+	if l.quoteErr != nil {
+		return nil, nil, l.quoteErr
+	}
+	if l.quote != nil {
+		return l.quote, []byte{}, nil
+	}
+	return []byte{}, []byte{}, nil
+}
+
+func (l *failingTpm) GetPcrs(selection ...PcrSelection) ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (l *failingTpm) ReadPcrValues(selection ...PcrSelection) ([]PcrValue, error) {
+	return []PcrValue{}, nil
+}
+
+func (l *failingTpm) ReadPublic(handle int) (crypto.PublicKey, []byte, []byte, error) {
+	return l.akPublic, []byte{}, l.akName, nil
+}
+
+func (l *failingTpm) Close() {}
+
+type failingTpmFactory struct {
+	tpm     TrustedPlatformModule
+	openErr error
+}
+
+func (f *failingTpmFactory) New(deviceType TpmDeviceType, ownerAuth string) (TrustedPlatformModule, error) {
+	return f.tpm, f.openErr
+}
+
+func TestAdapterClockInfo(t *testing.T) {
+	quote, err := mu.MarshalToBytes(&tpm2.Attest{
+		Magic:           tpm2.TPMGeneratedValue,
+		Type:            tpm2.TagAttestQuote,
+		ClockInfo:       tpm2.ClockInfo{Clock: 1000, ResetCount: 3, RestartCount: 2, Safe: true},
+		FirmwareVersion: 0x2000100000000,
+		Attested:        &tpm2.AttestU{Quote: &tpm2.QuoteInfo{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testData := []struct {
+		testName      string
+		withClockInfo bool
+		quote         []byte
+		expectError   bool
+	}{
+		{"Clock info included", true, quote, false},
+		{"Clock info not included by default", false, quote, false},
+		{"Invalid quote", true, []byte{0x01, 0x02}, true},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.testName, func(t *testing.T) {
+			adapter, err := NewTpmAdapterFactory(&failingTpmFactory{tpm: &failingTpm{quote: tc.quote}}).New(
+				WithClockInfo(tc.withClockInfo),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			evidence, err := adapter.GetEvidence(nil, nil)
+			if tc.expectError {
+				if err == nil {
+					t.Fatal("Expected an error for an invalid quote")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := json.Marshal(evidence)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fields map[string]interface{}
+			if err = json.Unmarshal(b, &fields); err != nil {
+				t.Fatal(err)
+			}
+
+			if !tc.withClockInfo {
+				if _, ok := fields["reset_count"]; ok {
+					t.Error("Did not expect the clock info in the TPM evidence")
+				}
+				return
+			}
+
+			expected := map[string]interface{}{
+				"clock":            float64(1000),
+				"reset_count":      float64(3),
+				"restart_count":    float64(2),
+				"clock_safe":       true,
+				"firmware_version": float64(0x2000100000000),
+			}
+			for field, value := range expected {
+				if fields[field] != value {
+					t.Errorf("Expected %q to be %v, got %v", field, value, fields[field])
+				}
+			}
+		})
+	}
+}
+
+func TestAdapterGetEvidenceTpmErrors(t *testing.T) {
+	lockoutErr := &tpm2.TPMWarning{Command: tpm2.CommandQuote, Code: tpm2.WarningLockout}
+
+	testData := []struct {
+		testName      string
+		tpmFactory    TpmFactory
+		expectedError error
+		unexpectedErr error
+	}{
+		{
+			"Lockout returns ErrTpmLockout",
+			&failingTpmFactory{tpm: &failingTpm{quoteErr: lockoutErr}},
+			ErrTpmLockout,
+			ErrTpmOpenFailure,
+		},
+		{
+			"Open failure returns ErrTpmOpenFailure",
+			&failingTpmFactory{openErr: errors.New("no such device")},
+			ErrTpmOpenFailure,
+			ErrTpmLockout,
+		},
+		{
+			"Other TPM warnings are not ErrTpmLockout",
+			&failingTpmFactory{tpm: &failingTpm{quoteErr: &tpm2.TPMWarning{Command: tpm2.CommandQuote, Code: tpm2.WarningRetry}}},
+			nil,
+			ErrTpmLockout,
+		},
+	}
+
+	for _, td := range testData {
+		t.Run(td.testName, func(t *testing.T) {
+			adapter, err := NewTpmAdapterFactory(td.tpmFactory).New()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = adapter.GetEvidence(nil, nil)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+
+			if td.expectedError != nil && !errors.Is(err, td.expectedError) {
+				t.Fatalf("Expected error %v, but got %v", td.expectedError, err)
+			}
+
+			if errors.Is(err, td.unexpectedErr) {
+				t.Fatalf("Did not expect error %v, but got %v", td.unexpectedErr, err)
+			}
+		})
+	}
+}
+
+func TestWrapTpmError(t *testing.T) {
+	lockoutErr := &tpm2.TPMWarning{Command: tpm2.CommandCreatePrimary, Code: tpm2.WarningLockout}
+
+	err := WrapTpmError(errors.Wrap(lockoutErr, "Failed to create EK"))
+	if !errors.Is(err, ErrTpmLockout) {
+		t.Fatalf("Expected error %v, but got %v", ErrTpmLockout, err)
+	}
+
+	// wrapping twice should not repeat the guidance
+	if WrapTpmError(err) != err {
+		t.Fatal("Expected an ErrTpmLockout error to be returned unchanged")
+	}
+
+	other := errors.New("other")
+	if WrapTpmError(other) != other {
+		t.Fatal("Expected a non-lockout error to be returned unchanged")
+	}
+
+	if WrapTpmError(nil) != nil {
+		t.Fatal("Expected nil")
+	}
+}
+
+func TestAdapterGetEvidenceContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the context is cancelled while the IMA log is being read
+	var reads []string
+	cancellingFileReader := func(path string) ([]byte, error) {
+		reads = append(reads, path)
+		cancel()
+		return []byte("10 aa ima-ng sha256:01 /usr/bin/a\n"), nil
+	}
+
+	adapter, err := NewTpmAdapterFactory(&failingTpmFactory{tpm: &failingTpm{}}).New(
+		WithFileReader(cancellingFileReader),
+		WithImaLogs(true),
+		WithUefiEventLogs(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = adapter.(connector.ContextEvidenceAdapter).GetEvidenceContext(ctx, nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected error %v, but got %v", context.Canceled, err)
+	}
+
+	// collection stopped after the IMA log (the UEFI event log was not read)
+	if len(reads) != 1 || reads[0] != DefaultImaPath {
+		t.Fatalf("Expected only the IMA log to be read, got %v", reads)
+	}
+
+	// an already cancelled context does not open the TPM
+	_, err = adapter.(connector.ContextEvidenceAdapter).GetEvidenceContext(ctx, nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected error %v, but got %v", context.Canceled, err)
+	}
+	if len(reads) != 1 {
+		t.Fatalf("Expected no reads after the context was cancelled, got %v", reads)
+	}
+}
+
+// cancellingReader cancels 'ctx' after the first read.
+type cancellingReader struct {
+	r      io.Reader
+	cancel context.CancelFunc
+	reads  int
+}
+
+func (cr *cancellingReader) Read(p []byte) (int, error) {
+	cr.reads++
+	cr.cancel()
+	return cr.r.Read(p[:min(len(p), 16)])
+}
+
+func TestReadLimitedContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := &cancellingReader{r: bytes.NewReader(make([]byte, DefaultMaxLogSize)), cancel: cancel}
+	_, err := readLimited(ctx, DefaultImaPath, r, DefaultMaxLogSize)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected error %v, but got %v", context.Canceled, err)
+	}
+
+	if r.reads != 1 {
+		t.Fatalf("Expected reading to stop after the context was cancelled, got %d reads", r.reads)
+	}
+}
+
+func TestAdapterEventLogDigest(t *testing.T) {
+	imaLog := []byte("10 aa ima-ng sha256:01 /usr/bin/a\n")
+
+	adapter, err := NewTpmAdapterFactory(&failingTpmFactory{tpm: &failingTpm{}}).New(
+		WithFileReader(func(path string) ([]byte, error) { return imaLog, nil }),
+		WithImaLogs(true),
+		WithEventLogDigest(crypto.SHA384),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidence, err := adapter.GetEvidence(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidenceJson, err := json.Marshal(evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var digestEvidence struct {
+		ImaLogs         []byte `json:"ima_logs"`
+		ImaLogsDigest   []byte `json:"ima_logs_digest"`
+		DigestAlgorithm string `json:"event_logs_digest_algorithm"`
+	}
+	if err = json.Unmarshal(evidenceJson, &digestEvidence); err != nil {
+		t.Fatal(err)
+	}
+
+	if digestEvidence.ImaLogs != nil {
+		t.Errorf("Expected the raw ima log to be omitted, got %q", digestEvidence.ImaLogs)
+	}
+
+	expectedDigest := sha512.Sum384(imaLog)
+	if !bytes.Equal(digestEvidence.ImaLogsDigest, expectedDigest[:]) {
+		t.Errorf("Expected ima log digest %x, got %x", expectedDigest, digestEvidence.ImaLogsDigest)
+	}
+
+	if digestEvidence.DigestAlgorithm != "sha384" {
+		t.Errorf("Expected digest algorithm sha384, got %q", digestEvidence.DigestAlgorithm)
+	}
+
+	if bytes.Contains(evidenceJson, []byte("uefi_event_logs_digest")) {
+		t.Errorf("Did not expect a uefi event log digest in %s", evidenceJson)
+	}
+
+	if _, err = NewTpmAdapterFactory(nil).New(WithEventLogDigest(crypto.SHA1)); err == nil {
+		t.Fatal("Expected an error for an unsupported digest algorithm")
+	}
+}
+
+func TestAdapterProgressMessages(t *testing.T) {
+	imaLog := []byte("10 aa ima-ng sha256:01 /usr/bin/a\n")
+
+	adapter, err := NewTpmAdapterFactory(&failingTpmFactory{tpm: &failingTpm{}}).New(
+		WithAkHandle(DefaultAkHandle),
+		WithFileReader(func(path string) ([]byte, error) { return imaLog, nil }),
+		WithImaLogs(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer logrus.SetLevel(logrus.GetLevel())
+	hook := logrustest.NewGlobal()
+	defer hook.Reset()
+
+	expectedMessages := []string{
+		fmt.Sprintf("Read IMA log %q from the FileReader (%d bytes)", DefaultImaPath, len(imaLog)),
+	}
+
+	logrus.SetLevel(logrus.DebugLevel)
+	if _, err = adapter.GetEvidence(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []string
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.DebugLevel {
+			messages = append(messages, entry.Message)
+		}
+	}
+
+	if !reflect.DeepEqual(messages, expectedMessages) {
+		t.Errorf("Expected debug messages %q, got %q", expectedMessages, messages)
+	}
+
+	// progress is not reported at the default log level
+	hook.Reset()
+	logrus.SetLevel(logrus.InfoLevel)
+	if _, err = adapter.GetEvidence(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hook.AllEntries()) != 0 {
+		t.Errorf("Expected no messages at info level, got %d", len(hook.AllEntries()))
+	}
+}
+
+func TestAdapterOptionalLogs(t *testing.T) {
+	imaLog := []byte("10 aa ima-ng sha256:01 /usr/bin/a\n")
+
+	// the ima log exists but the uefi event log does not (ex. in a container)
+	fakeReader := func(path string) ([]byte, error) {
+		if path == DefaultImaPath {
+			return imaLog, nil
+		}
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+
+	tests := []struct {
+		name          string
+		optionalLogs  bool
+		expectedError error
+	}{
+		{
+			name:          "strict",
+			optionalLogs:  false,
+			expectedError: ErrFailedToReadUEFILogs,
+		},
+		{
+			name:         "optional",
+			optionalLogs: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logOutput bytes.Buffer
+			logrus.SetOutput(&logOutput)
+			defer logrus.SetOutput(os.Stderr)
+
+			adapter, err := NewTpmAdapterFactory(&failingTpmFactory{tpm: &failingTpm{}}).New(
+				WithFileReader(fakeReader),
+				WithImaLogs(true),
+				WithUefiEventLogs(true),
+				WithOptionalLogs(tt.optionalLogs),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			evidence, err := adapter.GetEvidence(nil, nil)
+			if tt.expectedError != nil {
+				if !errors.Is(err, tt.expectedError) {
+					t.Fatalf("Expected error %v, got %v", tt.expectedError, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			evidenceJson, err := json.Marshal(evidence)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var logEvidence struct {
+				ImaLogs       []byte `json:"ima_logs"`
+				UefiEventLogs []byte `json:"uefi_event_logs"`
+			}
+			if err = json.Unmarshal(evidenceJson, &logEvidence); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(logEvidence.ImaLogs, imaLog) {
+				t.Errorf("Expected the ima log to be included, got %q", logEvidence.ImaLogs)
+			}
+
+			if logEvidence.UefiEventLogs != nil {
+				t.Errorf("Expected the uefi event log to be omitted, got %x", logEvidence.UefiEventLogs)
+			}
+
+			if !bytes.Contains(logOutput.Bytes(), []byte(DefaultUefiEventLogPath)) {
+				t.Errorf("Expected a warning about the missing uefi event log, got %q", logOutput.String())
+			}
+		})
+	}
+}
+
+func TestAdapterOptionalLogsReadFailure(t *testing.T) {
+	// only missing logs are optional, other failures are still reported
+	adapter, err := NewTpmAdapterFactory(&failingTpmFactory{tpm: &failingTpm{}}).New(
+		WithFileReader(func(path string) ([]byte, error) { return nil, os.ErrPermission }),
+		WithImaLogs(true),
+		WithOptionalLogs(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = adapter.GetEvidence(nil, nil)
+	if !errors.Is(err, ErrFailedToReadIMALogs) || !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("Expected ErrFailedToReadIMALogs, got %v", err)
+	}
+}
+
+func TestAdapterAkBinding(t *testing.T) {
+	akDer := newTestAkCertificate(t, time.Now().Add(-time.Hour), time.Now().AddDate(1, 0, 0))
+	akCert, err := x509.ParseCertificate(akDer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherAkCert, err := x509.ParseCertificate(newTestAkCertificate(t, time.Now().Add(-time.Hour), time.Now().AddDate(1, 0, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	akName := []byte{0x00, 0x0b, 0x01, 0x02}
+	akPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: akDer})
+
+	testData := []struct {
+		testName      string
+		tpm           *failingTpm
+		options       []TpmAdapterOptions
+		expectedError error
+	}{
+		{
+			"Matching AK certificate",
+			&failingTpm{akPublic: akCert.PublicKey, akName: akName},
+			[]TpmAdapterOptions{WithAkCertificateUri("file:///ak.pem")},
+			nil,
+		},
+		{
+			"AK handle with a different key than the AK certificate should fail",
+			&failingTpm{akPublic: otherAkCert.PublicKey, akName: akName},
+			[]TpmAdapterOptions{WithAkCertificateUri("file:///ak.pem")},
+			ErrAkCertMismatch,
+		},
+		{
+			"Matching AK name",
+			&failingTpm{akPublic: akCert.PublicKey, akName: akName},
+			[]TpmAdapterOptions{WithAkCertificateUri("file:///ak.pem"), WithAkName(akName)},
+			nil,
+		},
+		{
+			"AK handle with a different name should fail",
+			&failingTpm{akPublic: akCert.PublicKey, akName: []byte{0x00, 0x0b, 0xff}},
+			[]TpmAdapterOptions{WithAkName(akName)},
+			ErrAkCertMismatch,
+		},
+	}
+
+	for _, td := range testData {
+		t.Run(td.testName, func(t *testing.T) {
+			options := append([]TpmAdapterOptions{
+				WithFileReader(func(path string) ([]byte, error) { return akPem, nil }),
+			}, td.options...)
+
+			adapter, err := NewTpmAdapterFactory(&failingTpmFactory{tpm: td.tpm}).New(options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = adapter.GetEvidence(nil, nil)
+			if td.expectedError == nil && err != nil {
+				t.Fatal(err)
+			} else if !errors.Is(err, td.expectedError) {
+				t.Fatalf("Expected error %v, but got %v", td.expectedError, err)
+			}
+		})
+	}
+
+	if _, err := NewTpmAdapterFactory(NewTpmFactory()).New(WithAkName(nil)); err == nil {
+		t.Fatal("Expected an error for an empty AK name")
+	}
+}
+
+// observedStage is a call to a StageObserver.
+type observedStage struct {
+	stage string
+	err   error
+}
+
+func TestAdapterStageObserver(t *testing.T) {
+	imaLog := []byte("10 aa ima-ng sha256:01 /usr/bin/a\n")
+	quoteErr := errors.New("tpm busy")
+
+	fakeReader := func(path string) ([]byte, error) {
+		if path == DefaultImaPath {
+			return imaLog, nil
+		}
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+
+	tests := []struct {
+		name     string
+		tpm      *failingTpm
+		expected []observedStage
+	}{
+		{
+			name: "all stages",
+			tpm:  &failingTpm{},
+			expected: []observedStage{
+				{stage: StageGetQuote},
+				{stage: StageGetPcrs},
+				{stage: StageReadImaLog},
+				{stage: StageReadUefiLog, err: ErrFailedToReadUEFILogs},
+			},
+		},
+		{
+			name: "quote failure",
+			tpm:  &failingTpm{quoteErr: quoteErr},
+			expected: []observedStage{
+				{stage: StageGetQuote, err: quoteErr},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var observed []observedStage
+			observer := func(stage string, duration time.Duration, err error) {
+				if duration < 0 {
+					t.Errorf("Expected a non-negative duration for stage %q, got %v", stage, duration)
+				}
+				observed = append(observed, observedStage{stage: stage, err: err})
+			}
+
+			adapter, err := NewTpmAdapterFactory(&failingTpmFactory{tpm: tt.tpm}).New(
+				WithFileReader(fakeReader),
+				WithImaLogs(true),
+				WithUefiEventLogs(true),
+				WithStageObserver(observer),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = adapter.GetEvidence(nil, nil); err == nil {
+				t.Fatal("Expected GetEvidence to fail")
+			}
+
+			if len(observed) != len(tt.expected) {
+				t.Fatalf("Expected stages %v, got %v", tt.expected, observed)
+			}
+
+			for i, expected := range tt.expected {
+				if observed[i].stage != expected.stage {
+					t.Errorf("Expected stage %q at index %d, got %q", expected.stage, i, observed[i].stage)
+				}
+
+				if expected.err == nil && observed[i].err != nil {
+					t.Errorf("Expected stage %q to succeed, got %v", expected.stage, observed[i].err)
+				} else if expected.err != nil && !errors.Is(observed[i].err, expected.err) {
+					t.Errorf("Expected stage %q to fail with %v, got %v", expected.stage, expected.err, observed[i].err)
+				}
+			}
+		})
+	}
+}
+
+func TestWithTpmSession(t *testing.T) {
+	if _, err := NewTpmAdapterFactory(&failingTpmFactory{}).New(WithTpmSession(0x1, nil)); !errors.Is(err, ErrHandleOutOfRange) {
+		t.Fatalf("Expected ErrHandleOutOfRange, got %v", err)
+	}
+
+	ekKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpm := &failingTpm{}
+	adapter, err := NewTpmAdapterFactory(&failingTpmFactory{tpm: tpm}).New(WithTpmSession(DefaultEkHandle, ekKey.Public()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = adapter.GetEvidence(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if tpm.sessionEkHandle != DefaultEkHandle {
+		t.Fatalf("Expected an encrypted session salted with the EK at 0x%x, got 0x%x", DefaultEkHandle, tpm.sessionEkHandle)
+	}
+
+	if tpm.sessionEkPublic != ekKey.Public() {
+		t.Fatal("Expected the session to be started with the provided EK public key")
+	}
+
+	// without an EK public key, the key of the EK certificate is expected
+	tpm = &failingTpm{ekCert: &x509.Certificate{PublicKey: ekKey.Public()}}
+	adapter, err = NewTpmAdapterFactory(&failingTpmFactory{tpm: tpm}).New(WithTpmSession(DefaultEkHandle, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = adapter.GetEvidence(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if tpm.sessionEkPublic != ekKey.Public() {
+		t.Fatal("Expected the session to be started with the EK certificate's public key")
+	}
+
+	// the session is not started without the EK certificate
+	tpm = &failingTpm{}
+	adapter, err = NewTpmAdapterFactory(&failingTpmFactory{tpm: tpm}).New(WithTpmSession(DefaultEkHandle, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = adapter.GetEvidence(nil, nil); !errors.Is(err, ErrorNvIndexDoesNotExist) {
+		t.Fatalf("Expected ErrorNvIndexDoesNotExist, got %v", err)
+	}
+
+	if tpm.sessionEkHandle != 0 {
+		t.Fatal("Expected the session not to be started without the EK certificate")
+	}
+
+	tpm = &failingTpm{sessionErr: ErrEkPublicMismatch}
+	adapter, err = NewTpmAdapterFactory(&failingTpmFactory{tpm: tpm}).New(WithTpmSession(DefaultEkHandle, ekKey.Public()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = adapter.GetEvidence(nil, nil); !errors.Is(err, ErrEkPublicMismatch) {
+		t.Fatalf("Expected ErrEkPublicMismatch, got %v", err)
+	}
+}
+
+// The json tpm evidence expected by the Trust Authority (see TestBuildTpmEvidenceGolden).
+//
+//go:embed test_data/tpm_evidence.json
+var tpmEvidenceGolden []byte
+
+func TestBuildTpmEvidenceGolden(t *testing.T) {
+	evidence, err := BuildTpmEvidence(
+		[]byte("quote"),
+		[]byte("signature"),
+		[]byte("pcrs"),
+		[]byte("user data"),
+		[]byte("ima logs"),
+		[]byte("uefi logs"),
+		[]byte("ak der"),
+		&connector.VerifierNonce{Val: []byte("val"), Iat: []byte("iat"), Signature: []byte("sig")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(evidence, bytes.TrimSpace(tpmEvidenceGolden)) {
+		t.Fatalf("Expected evidence %s, got %s", tpmEvidenceGolden, evidence)
+	}
+}
+
+func TestBuildTpmEvidenceMatchesAdapter(t *testing.T) {
+	tpm := &failingTpm{quote: []byte("quote")}
+	adapter, err := NewTpmAdapterFactory(&failingTpmFactory{tpm: tpm}).New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := &connector.VerifierNonce{Val: []byte("val"), Iat: []byte("iat"), Signature: []byte("sig")}
+	userData := []byte("user data")
+	adapterEvidence, err := adapter.GetEvidence(nonce, userData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the stub returns an empty signature and pcrs which BuildTpmEvidence rejects, so
+	// compare the evidence with placeholders for both
+	tpmEvidence := adapterEvidence.(*tpmEvidence)
+	tpmEvidence.S = []byte("signature")
+	tpmEvidence.P = []byte("pcrs")
+	expected, err := json.Marshal(tpmEvidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evidence, err := BuildTpmEvidence(tpmEvidence.Q, tpmEvidence.S, tpmEvidence.P, userData, nil, nil, nil, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(evidence, expected) {
+		t.Fatalf("Expected evidence %s, got %s", expected, evidence)
+	}
+}
+
+func TestBuildTpmEvidenceMissingQuote(t *testing.T) {
+	if _, err := BuildTpmEvidence(nil, []byte("signature"), []byte("pcrs"), nil, nil, nil, nil, nil); err == nil {
+		t.Fatal("Expected an error when the quote is missing")
+	}
+}
@@ -91,3 +91,33 @@ func (tpm *trustedPlatformModule) CreateAK(akHandle int, ekHandle int) error {
 
 	return nil
 }
+
+func (tpm *trustedPlatformModule) EvictHandle(handle int) error {
+
+	// make sure the handle is within range, a valid persistent handle and it DOES exist
+	if handle < minPersistentHandle || handle > maxPersistentHandle {
+		return ErrHandleOutOfRange
+	}
+
+	h := tpm2.Handle(handle)
+	if h.Type() != tpm2.HandleTypePersistent {
+		return ErrInvalidHandle
+	}
+
+	if !tpm.ctx.DoesHandleExist(h) {
+		return ErrHandleDoesNotExist
+	}
+
+	handleContext, err := tpm.ctx.NewResourceContext(h)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to create resource context for handle 0x%x", handle)
+	}
+
+	// evicting a persistent object removes it from the TPM
+	_, err = tpm.ctx.EvictControl(tpm.ctx.OwnerHandleContext(), handleContext, h, nil, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to evict handle 0x%x", handle)
+	}
+
+	return nil
+}
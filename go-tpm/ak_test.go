@@ -6,4 +6,16 @@
 
 package tpm
 
+import "testing"
+
 // TODO:  negative unit tests for CreateAK (positive test are in tpm_e2e_test.go)
+
+func TestEvictHandleNegative(t *testing.T) {
+	tpm := &trustedPlatformModule{}
+
+	for _, handle := range []int{0, minPersistentHandle - 1, maxPersistentHandle + 1} {
+		if err := tpm.EvictHandle(handle); err != ErrHandleOutOfRange {
+			t.Errorf("Expected ErrHandleOutOfRange for handle 0x%x, but got %v", handle, err)
+		}
+	}
+}
@@ -6,7 +6,10 @@
 
 package tpm
 
-import "crypto"
+import (
+	"crypto"
+	"time"
+)
 
 const (
 	maxNvSize        = 1024 * 8 // 8k
@@ -27,12 +30,28 @@ const (
 	DefaultImaPath          = "/sys/kernel/security/ima/ascii_runtime_measurements"
 	DefaultUefiEventLogPath = "/sys/kernel/security/tpm0/binary_bios_measurements"
 
+	// The default maximum size of IMA/UEFI event logs (see WithMaxLogSize)
+	DefaultMaxLogSize = 64 * 1024 * 1024
+
+	// The default clock skew tolerated when checking the AK certificate's validity
+	DefaultAkCertValiditySkew = 5 * time.Minute
+
+	// The maximum size of a quote's extra-data (TPM2B_DATA is limited to sizeof(TPMT_HA),
+	// which is 50 bytes on TPMs that implement SHA-384).
+	maxQuoteExtraDataSize = 50
+
+	// The default hash algorithm of the quote's extra-data (see WithNonceHashAlgorithm)
+	DefaultNonceHashAlgorithm = crypto.SHA256
+
 	// TCG event log constants
 	specIdEvent03   = "Spec ID Event03"
 	startupLocality = "StartupLocality"
 )
 
 var (
+	// the first bytes of gzip compressed files (see RFC 1952)
+	gzipMagic = []byte{0x1f, 0x8b}
+
 	defaultPcrSelections = []PcrSelection{
 		{
 			Hash: crypto.SHA256,
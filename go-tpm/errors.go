@@ -7,6 +7,9 @@
 package tpm
 
 import (
+	"fmt"
+
+	"github.com/canonical/go-tpm2"
 	"github.com/pkg/errors"
 )
 
@@ -23,4 +26,25 @@ var (
 	ErrNvInvalidSize         = errors.New("invalid data size for nv ram")
 	ErrSymlinksNotAllowed    = errors.New("symlinks are not allowed")
 	ErrPathTraversal         = errors.New("path traversal detected")
+	ErrCorruptEventLog       = errors.New("the event log is corrupt")
+	ErrLogTooLarge           = errors.New("the log exceeds the maximum size")
+	ErrAkCertificateExpired  = errors.New("the AK certificate is expired or not yet valid")
+	ErrAkCertMismatch        = errors.New("the AK handle does not refer to the AK of the AK certificate")
+	ErrEkPublicMismatch      = errors.New("the EK handle does not refer to the expected EK public key")
+	ErrTpmOpenFailure        = errors.New("failed to open the TPM")
+	ErrFailedToReadIMALogs   = errors.New("failed to read the IMA log")
+	ErrFailedToReadUEFILogs  = errors.New("failed to read the UEFI event log")
+	ErrTpmDeviceNotFound     = errors.New("no TPM device node was found")
+	ErrTpmLockout            = errors.New("the TPM is in dictionary attack lockout, wait for the lockout to expire or reset it (ex. 'tpm2_dictionarylockout --clear-lockout')")
 )
+
+// WrapTpmError returns an error that wraps ErrTpmLockout when 'err' is the TPM's
+// lockout response code (TPM_RC_LOCKOUT).  Otherwise (or if 'err' already wraps
+// ErrTpmLockout), 'err' is returned unchanged.
+func WrapTpmError(err error) error {
+	if err != nil && !errors.Is(err, ErrTpmLockout) && tpm2.IsTPMWarning(err, tpm2.WarningLockout, tpm2.AnyCommandCode) {
+		return fmt.Errorf("%w: %w", ErrTpmLockout, err)
+	}
+
+	return err
+}
@@ -0,0 +1,92 @@
+/*
+ *   Copyright (c) 2022-2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package tpm
+
+import (
+	"crypto/sha256"
+	"sync"
+)
+
+// DeltaEventLogAdapter is implemented by the TPM adapter and is used to control
+// delta event-log submission (see WithDeltaEventLogs).
+type DeltaEventLogAdapter interface {
+	// CommitEventLogOffsets records that the events included in the last evidence were
+	// accepted by the verifier (i.e., it must be called after a successful attestation).
+	// Until it is called, later evidence includes the same events again so that events
+	// are not lost when an attestation fails.
+	CommitEventLogOffsets()
+
+	// ResetEventLogOffsets forgets the portion of the event logs that were included
+	// in previous evidence so that the next evidence includes the full logs (ex. when
+	// the verifier rejected the previous attestation or requires the full logs).
+	ResetEventLogOffsets()
+}
+
+// eventLogOffset records the length and digest of an event log when it was last
+// included in evidence.
+type eventLogOffset struct {
+	offset int
+	digest [sha256.Size]byte
+}
+
+// eventLogTracker keeps track of how much of each event log has already been
+// accepted by the verifier so that only the new events are sent in later evidence.
+type eventLogTracker struct {
+	mutex     sync.Mutex
+	committed map[string]eventLogOffset
+	pending   map[string]eventLogOffset
+}
+
+func newEventLogTracker() *eventLogTracker {
+	return &eventLogTracker{
+		committed: make(map[string]eventLogOffset),
+		pending:   make(map[string]eventLogOffset),
+	}
+}
+
+// delta returns the events in 'eventLog' that were appended since the offsets were last
+// committed (under the same 'name') and the offset of those events in 'eventLog'.  The
+// full log (with an offset of zero) is returned the first time, after reset, or when the
+// previously included events changed (ex. the log was truncated after a reboot).  The
+// returned slice is empty (not nil) when no events were appended.
+func (t *eventLogTracker) delta(name string, eventLog []byte) ([]byte, int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	start := 0
+	if previous, ok := t.committed[name]; ok && previous.offset <= len(eventLog) {
+		if sha256.Sum256(eventLog[:previous.offset]) == previous.digest {
+			start = previous.offset
+		}
+	}
+
+	t.pending[name] = eventLogOffset{
+		offset: len(eventLog),
+		digest: sha256.Sum256(eventLog),
+	}
+
+	return eventLog[start:], start
+}
+
+// commit makes the offsets of the last evidence the starting point of later deltas.
+func (t *eventLogTracker) commit() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for name, offset := range t.pending {
+		t.committed[name] = offset
+	}
+	t.pending = make(map[string]eventLogOffset)
+}
+
+func (t *eventLogTracker) reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.committed = make(map[string]eventLogOffset)
+	t.pending = make(map[string]eventLogOffset)
+}
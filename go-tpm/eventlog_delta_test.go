@@ -0,0 +1,136 @@
+/*
+ *   Copyright (c) 2022-2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package tpm
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+type deltaEvidence struct {
+	ImaLogs *[]byte `json:"ima_logs"`
+}
+
+func TestDeltaEventLogs(t *testing.T) {
+	imaLog := []byte("10 aa ima-ng sha256:01 /usr/bin/a\n")
+
+	fakeReader := func(path string) ([]byte, error) {
+		return imaLog, nil
+	}
+
+	adapter, err := NewTpmAdapterFactory(&failingTpmFactory{tpm: &failingTpm{}}).New(
+		WithFileReader(fakeReader),
+		WithImaLogs(true),
+		WithDeltaEventLogs(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deltaAdapter := adapter.(DeltaEventLogAdapter)
+
+	collect := func() []byte {
+		evidence, err := adapter.GetEvidence(nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		evidenceJson, err := json.Marshal(evidence)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if bytes.Contains(evidenceJson, []byte("offset")) {
+			t.Fatalf("Did not expect an event log offset in %s", evidenceJson)
+		}
+
+		var de deltaEvidence
+		if err = json.Unmarshal(evidenceJson, &de); err != nil {
+			t.Fatal(err)
+		}
+
+		if de.ImaLogs == nil {
+			t.Fatalf("Expected the ima log to be included in %s", evidenceJson)
+		}
+		return *de.ImaLogs
+	}
+
+	// the first collection includes the full log
+	if first := collect(); !bytes.Equal(first, imaLog) {
+		t.Fatalf("Expected the full log, got %q", first)
+	}
+
+	// the attestation failed (the offsets were not committed), the full log is included again
+	if retried := collect(); !bytes.Equal(retried, imaLog) {
+		t.Fatalf("Expected the full log until the offsets are committed, got %q", retried)
+	}
+	deltaAdapter.CommitEventLogOffsets()
+
+	// the log grows, only the new events are included
+	appended := []byte("10 bb ima-ng sha256:02 /usr/bin/b\n")
+	imaLog = append(imaLog, appended...)
+
+	if second := collect(); !bytes.Equal(second, appended) {
+		t.Fatalf("Expected %q, got %q", appended, second)
+	}
+	deltaAdapter.CommitEventLogOffsets()
+
+	// nothing new was appended, an empty log is included (rather than omitted)
+	if third := collect(); len(third) != 0 {
+		t.Fatalf("Expected no events, got %q", third)
+	}
+	deltaAdapter.CommitEventLogOffsets()
+
+	// resetting the offsets falls back to the full log
+	deltaAdapter.ResetEventLogOffsets()
+	if fourth := collect(); !bytes.Equal(fourth, imaLog) {
+		t.Fatalf("Expected the full log after reset, got %q", fourth)
+	}
+	deltaAdapter.CommitEventLogOffsets()
+
+	// the log was replaced (ex. after a reboot), the full log is included
+	imaLog = []byte("10 cc ima-ng sha256:03 /usr/bin/c\n10 dd ima-ng sha256:04 /usr/bin/d\n10 ee ima-ng sha256:05 /usr/bin/e\n")
+	if fifth := collect(); !bytes.Equal(fifth, imaLog) {
+		t.Fatalf("Expected the full log after it changed, got %q", fifth)
+	}
+}
+
+func TestDeltaEventLogsDisabled(t *testing.T) {
+	imaLog := []byte("10 aa ima-ng sha256:01 /usr/bin/a\n")
+
+	adapter, err := NewTpmAdapterFactory(&failingTpmFactory{tpm: &failingTpm{}}).New(
+		WithFileReader(func(path string) ([]byte, error) { return imaLog, nil }),
+		WithImaLogs(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		evidence, err := adapter.GetEvidence(nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		evidenceJson, err := json.Marshal(evidence)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var de deltaEvidence
+		if err = json.Unmarshal(evidenceJson, &de); err != nil {
+			t.Fatal(err)
+		}
+
+		if de.ImaLogs == nil || !bytes.Equal(*de.ImaLogs, imaLog) {
+			t.Fatalf("Expected the full log in %s", evidenceJson)
+		}
+
+		// has no effect unless delta event logs are enabled
+		adapter.(DeltaEventLogAdapter).CommitEventLogOffsets()
+	}
+}
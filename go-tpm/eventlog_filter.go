@@ -10,9 +10,11 @@ import (
 	"bytes"
 	"crypto"
 	"encoding/binary"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
 // tuple used for looking up PCR and hash algorithm selections.
@@ -53,20 +55,41 @@ type eventLogFilter interface {
 	FilterEventLogs() ([]byte, error)
 }
 
-// newEventLogFilter parses the initial bytes of the event log to determine which
-// type of event log filter to create.
-func newEventLogFilter(evlBuffer []byte, pcrSelections ...PcrSelection) (eventLogFilter, error) {
-	// Create a map of selected pcr indices to the list of hash selected algorithms.
-	// Used to determine which event data should be included in the results.
-	pcrFilterLookup := make(map[int][]crypto.Hash)
-	for _, sel := range pcrSelections {
-		for _, pcr := range sel.Pcrs {
-			if _, ok := pcrFilterLookup[pcr]; !ok {
-				pcrFilterLookup[pcr] = []crypto.Hash{}
-			}
+// EventLogFormat identifies the format of a TCG event log (see ValidateEventLogHeader).
+type EventLogFormat int
 
-			pcrFilterLookup[pcr] = append(pcrFilterLookup[pcr], sel.Hash)
-		}
+const (
+	EventLogFormatUnknown EventLogFormat = iota
+	EventLogFormatTcg12                  // TCG 1.2 ("sha1 log format")
+	EventLogFormatTcg20                  // TCG 2.0 ("crypto agile log format")
+)
+
+func (f EventLogFormat) String() string {
+	switch f {
+	case EventLogFormatTcg12:
+		return "TCG 1.2"
+	case EventLogFormatTcg20:
+		return "TCG 2.0"
+	default:
+		return "unknown"
+	}
+}
+
+// ValidateEventLogHeader parses the header event at the start of 'evlBuffer' and
+// returns the format of the event log (TCG 1.2 or 2.0).  It can be used to check an
+// event log (ex. the contents of /sys/kernel/security/tpm0/binary_bios_measurements)
+// before it is included in evidence.
+func ValidateEventLogHeader(evlBuffer []byte) (EventLogFormat, error) {
+	format, _, err := parseEventLogHeader(evlBuffer)
+	return format, err
+}
+
+// parseEventLogHeader validates the event log's header event and returns the
+// log's format along with the offset of the first event after the header.
+func parseEventLogHeader(evlBuffer []byte) (EventLogFormat, int, error) {
+	// pcr index, event type, sha1 digest and event size
+	if len(evlBuffer) < 4+4+20+4 {
+		return EventLogFormatUnknown, 0, errors.Errorf("The event log header was too short (%d bytes)", len(evlBuffer))
 	}
 
 	pos := 0
@@ -74,14 +97,14 @@ func newEventLogFilter(evlBuffer []byte, pcrSelections ...PcrSelection) (eventLo
 	// pcr index should be 0
 	pcr := int32(binary.LittleEndian.Uint32(evlBuffer[pos : pos+4]))
 	if pcr != 0 {
-		return nil, errors.New("The event log header did not start with PCR 0")
+		return EventLogFormatUnknown, 0, errors.New("The event log header did not start with PCR 0")
 	}
 	pos += 4
 
 	// event type should be 3 (EV_NO_ACTION)
 	eventType := int32(binary.LittleEndian.Uint32(evlBuffer[pos : pos+4]))
 	if eventType != 3 {
-		return nil, errors.New("The event log header did not have event type 3")
+		return EventLogFormatUnknown, 0, errors.New("The event log header did not have event type 3")
 	}
 	pos += 4
 
@@ -89,27 +112,116 @@ func newEventLogFilter(evlBuffer []byte, pcrSelections ...PcrSelection) (eventLo
 
 	eventSize := int(binary.LittleEndian.Uint32(evlBuffer[pos : pos+4]))
 	if eventSize < minHeaderEventSize || eventSize > maxHeaderEventSize {
-		return nil, errors.Errorf("The event log header had an correct event size %d", eventSize)
+		return EventLogFormatUnknown, 0, errors.Errorf("The event log header had an correct event size %d", eventSize)
 	}
 	pos += 4
 
+	if pos+eventSize > len(evlBuffer) {
+		return EventLogFormatUnknown, 0, errors.Errorf("The event log header's event size %d exceeded the log's length %d", eventSize, len(evlBuffer))
+	}
+
 	eventString := string(evlBuffer[pos : pos+minHeaderEventSize])
 	pos += eventSize
 	if strings.HasPrefix(eventString, specIdEvent03) {
-		return &tcg20EventLogFilterImpl{
-			start:           pos,
-			evlBuffer:       evlBuffer,
-			pcrFilterLookup: pcrFilterLookup,
-		}, nil
+		return EventLogFormatTcg20, pos, nil
 	} else if strings.HasPrefix(eventString, startupLocality) {
-		return &tcg12EventLogFilterImpl{
+		return EventLogFormatTcg12, pos, nil
+	}
+
+	return EventLogFormatUnknown, 0, errors.Errorf("The event log header did not contain %q or %q", specIdEvent03, startupLocality)
+}
+
+// pcrEventCounts are the number of events for a PCR that were retained or dropped
+// by an eventLogFilter.
+type pcrEventCounts struct {
+	retained     int
+	notSelected  int // dropped because the PCR was not selected
+	bankMismatch int // dropped because the event did not have a digest in the selected banks
+}
+
+// eventLogFilterStats counts the events that were retained and dropped for each PCR so
+// that filtering (ex. an empty log) can be diagnosed.
+type eventLogFilterStats map[int]*pcrEventCounts
+
+func (s eventLogFilterStats) get(pcr int) *pcrEventCounts {
+	if _, ok := s[pcr]; !ok {
+		s[pcr] = &pcrEventCounts{}
+	}
+	return s[pcr]
+}
+
+// report logs the counts of each PCR to 'logger' (in PCR order).  PCRs whose events were
+// all dropped because of a bank mismatch are logged as warnings.
+func (s eventLogFilterStats) report(logger logrus.FieldLogger) {
+	if logger == nil {
+		return
+	}
+
+	pcrs := make([]int, 0, len(s))
+	for pcr := range s {
+		pcrs = append(pcrs, pcr)
+	}
+	sort.Ints(pcrs)
+
+	for _, pcr := range pcrs {
+		counts := s[pcr]
+		entry := logger.WithFields(logrus.Fields{
+			"pcr":                   pcr,
+			"retained":              counts.retained,
+			"dropped_not_selected":  counts.notSelected,
+			"dropped_bank_mismatch": counts.bankMismatch,
+		})
+
+		switch {
+		case counts.bankMismatch > 0 && counts.retained == 0:
+			entry.Warnf("All %d events of PCR %d were dropped because they do not have digests in the selected banks", counts.bankMismatch, pcr)
+		case counts.notSelected > 0:
+			entry.Infof("%d events of PCR %d were dropped because the PCR was not selected", counts.notSelected, pcr)
+		case counts.bankMismatch == 0:
+			entry.Infof("%d events of PCR %d were retained", counts.retained, pcr)
+		default:
+			entry.Infof("%d events of PCR %d were retained and %d were dropped because they do not have digests in the selected banks", counts.retained, pcr, counts.bankMismatch)
+		}
+	}
+}
+
+// newEventLogFilter parses the initial bytes of the event log to determine which
+// type of event log filter to create.  When 'logger' is not nil, the number of events
+// that were retained and dropped for each PCR (and why) are logged after filtering.
+func newEventLogFilter(evlBuffer []byte, logger logrus.FieldLogger, pcrSelections ...PcrSelection) (eventLogFilter, error) {
+	// Create a map of selected pcr indices to the list of hash selected algorithms.
+	// Used to determine which event data should be included in the results.
+	pcrFilterLookup := make(map[int][]crypto.Hash)
+	for _, sel := range pcrSelections {
+		for _, pcr := range sel.Pcrs {
+			if _, ok := pcrFilterLookup[pcr]; !ok {
+				pcrFilterLookup[pcr] = []crypto.Hash{}
+			}
+
+			pcrFilterLookup[pcr] = append(pcrFilterLookup[pcr], sel.Hash)
+		}
+	}
+
+	format, pos, err := parseEventLogHeader(evlBuffer)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == EventLogFormatTcg20 {
+		return &tcg20EventLogFilterImpl{
 			start:           pos,
 			evlBuffer:       evlBuffer,
 			pcrFilterLookup: pcrFilterLookup,
+			logger:          logger,
 		}, nil
-	} else {
-		return nil, errors.Errorf("The event log header did not contain %q or %q", specIdEvent03, startupLocality)
 	}
+
+	return &tcg12EventLogFilterImpl{
+		start:           pos,
+		evlBuffer:       evlBuffer,
+		pcrFilterLookup: pcrFilterLookup,
+		logger:          logger,
+	}, nil
 }
 
 // This filter implementation linearly parses the TCG 2.0 ("crypto agile log format") event
@@ -119,6 +231,7 @@ type tcg20EventLogFilterImpl struct {
 	start           int
 	evlBuffer       []byte
 	pcrFilterLookup map[int][]crypto.Hash
+	logger          logrus.FieldLogger
 }
 
 func (t *tcg20EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
@@ -127,6 +240,8 @@ func (t *tcg20EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
 	// preallocate 40k for  event logs
 	results.Grow(40960)
 
+	stats := eventLogFilterStats{}
+
 	// write the header as it is needed by the verifier
 	_, err := results.Write(t.evlBuffer[0:t.start])
 	if err != nil {
@@ -155,6 +270,11 @@ func (t *tcg20EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
 			goto done
 		}
 
+		// pcr index, event type and digest count
+		if err := checkEventLogBounds(t.evlBuffer, pos, 12); err != nil {
+			return nil, err
+		}
+
 		// pcr index
 		pcr := int32(binary.LittleEndian.Uint32(t.evlBuffer[pos : pos+4]))
 		if pcr < 0 || pcr > 23 {
@@ -169,7 +289,7 @@ func (t *tcg20EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
 		// digest count
 		digestCount := int32(binary.LittleEndian.Uint32(t.evlBuffer[pos : pos+4]))
 		if digestCount < 0 || digestCount > 4 { // Assume less than 4 (sha1, sha256, sha384, sha512)
-			return nil, errors.Errorf("Event log contained invalid digest count %d at offset %d", pcr, pos)
+			return nil, errors.Errorf("Event log contained invalid digest count %d at offset %d", digestCount, pos)
 		}
 		pos += 4
 
@@ -178,6 +298,9 @@ func (t *tcg20EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
 		digestOffsets := make(map[crypto.Hash]int)
 		for i := 0; i < int(digestCount); i++ {
 			// algorithm id
+			if err := checkEventLogBounds(t.evlBuffer, pos, 2); err != nil {
+				return nil, err
+			}
 			algId := int16(binary.LittleEndian.Uint16(t.evlBuffer[pos : pos+2]))
 			pos += 2
 
@@ -186,11 +309,18 @@ func (t *tcg20EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
 				return nil, err
 			}
 
+			if err := checkEventLogBounds(t.evlBuffer, pos, h.Size()); err != nil {
+				return nil, err
+			}
+
 			digestOffsets[h] = pos
 			pos += h.Size()
 		}
 
 		// event size
+		if err := checkEventLogBounds(t.evlBuffer, pos, 4); err != nil {
+			return nil, err
+		}
 		eventSize := int32(binary.LittleEndian.Uint32(t.evlBuffer[pos : pos+4]))
 		if eventSize < 0 || eventSize > 1024*32 { // this can include secure boot certs and other large data (assume 32k max)
 			return nil, errors.Errorf("Event log contained invalid event size  %d at offset %d", eventSize, pos)
@@ -198,6 +328,9 @@ func (t *tcg20EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
 		pos += 4
 
 		// skip pass event data
+		if err := checkEventLogBounds(t.evlBuffer, pos, int(eventSize)); err != nil {
+			return nil, err
+		}
 		eventStart := pos
 		pos += int(eventSize)
 
@@ -212,7 +345,10 @@ func (t *tcg20EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
 		// pcr index of event is not in selection:  exclude
 		// pcr index of event is in selection BUT does not match the selected digest algorithms:  exclude
 		// pcr index of event is in selection AND has one or more matching, selected digest algorithms:  include
-		if selectedHashAlgs, ok := t.pcrFilterLookup[int(pcr)]; ok {
+		selectedHashAlgs, ok := t.pcrFilterLookup[int(pcr)]
+		if !ok {
+			stats.get(int(pcr)).notSelected++
+		} else {
 
 			// exclude events where the PCR index is the selection, but its hash algorithm is not
 			dCount := 0
@@ -222,8 +358,10 @@ func (t *tcg20EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
 				}
 			}
 			if dCount == 0 {
+				stats.get(int(pcr)).bankMismatch++
 				continue
 			}
+			stats.get(int(pcr)).retained++
 
 			// add pcr index
 			err = binary.Write(&results, binary.LittleEndian, uint32(pcr))
@@ -279,6 +417,7 @@ func (t *tcg20EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
 	}
 
 done:
+	stats.report(t.logger)
 	return results.Bytes(), nil
 }
 
@@ -289,6 +428,7 @@ type tcg12EventLogFilterImpl struct {
 	start           int
 	evlBuffer       []byte
 	pcrFilterLookup map[int][]crypto.Hash
+	logger          logrus.FieldLogger
 }
 
 func (t *tcg12EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
@@ -297,6 +437,8 @@ func (t *tcg12EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
 	// preallocate 40k for  event logs
 	results.Grow(40960)
 
+	stats := eventLogFilterStats{}
+
 	// write the header as it is needed by the verifier
 	_, err := results.Write(t.evlBuffer[0:t.start])
 	if err != nil {
@@ -323,6 +465,11 @@ func (t *tcg12EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
 			goto done
 		}
 
+		// pcr index, event type, digest and event size
+		if err := checkEventLogBounds(t.evlBuffer, pos, 32); err != nil {
+			return nil, err
+		}
+
 		// pcr index
 		pcr := int32(binary.LittleEndian.Uint32(t.evlBuffer[pos : pos+4]))
 		if pcr < 0 || pcr > 23 {
@@ -344,12 +491,18 @@ func (t *tcg12EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
 		}
 		pos += 4
 
+		if err := checkEventLogBounds(t.evlBuffer, pos, eventSize); err != nil {
+			return nil, err
+		}
 		event := t.evlBuffer[pos : pos+eventSize]
 		pos += eventSize
 
 		// Write the filtered event logs to the output buffer when sha1 pcr index is
 		// in the selection.
-		if selectedHashAlgs, ok := t.pcrFilterLookup[int(pcr)]; ok {
+		selectedHashAlgs, ok := t.pcrFilterLookup[int(pcr)]
+		if !ok {
+			stats.get(int(pcr)).notSelected++
+		} else {
 
 			// Check if sha1 is in the selection
 			sha1InSelection := false
@@ -360,8 +513,10 @@ func (t *tcg12EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
 				}
 			}
 			if !sha1InSelection {
+				stats.get(int(pcr)).bankMismatch++
 				continue
 			}
+			stats.get(int(pcr)).retained++
 
 			// add pcr index
 			err = binary.Write(&results, binary.LittleEndian, uint32(pcr))
@@ -396,7 +551,7 @@ func (t *tcg12EventLogFilterImpl) FilterEventLogs() ([]byte, error) {
 	}
 
 done:
-
+	stats.report(t.logger)
 	return results.Bytes(), nil
 }
 
@@ -429,3 +584,12 @@ func cryptoHash2AldId(h crypto.Hash) (int16, error) {
 		return 0, errors.Errorf("Invalid hash algorithm %v", h)
 	}
 }
+
+// checkEventLogBounds returns ErrCorruptEventLog when reading 'size' bytes at offset
+// 'pos' would run past the end of the event log (ex. the log was truncated).
+func checkEventLogBounds(evlBuffer []byte, pos int, size int) error {
+	if pos < 0 || size < 0 || pos+size > len(evlBuffer) {
+		return errors.Wrapf(ErrCorruptEventLog, "reading %d bytes at offset %d exceeds the log's length %d", size, pos, len(evlBuffer))
+	}
+	return nil
+}
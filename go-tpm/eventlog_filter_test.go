@@ -7,9 +7,15 @@
 package tpm
 
 import (
+	"bytes"
 	"crypto"
 	_ "embed"
+	"encoding/binary"
+	"errors"
 	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 )
 
 // Raw /sys/kernel/security/tpm0/binary_bios_measurements file from Azure TDX CVM.
@@ -18,7 +24,7 @@ import (
 var binary_bios_measurements20 []byte
 
 func TestAdapterEventFilter20(t *testing.T) {
-	eventLogFilter, err := newEventLogFilter(binary_bios_measurements20, defaultPcrSelections...)
+	eventLogFilter, err := newEventLogFilter(binary_bios_measurements20, nil, defaultPcrSelections...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -33,7 +39,7 @@ func TestAdapterEventFilter20(t *testing.T) {
 var binary_bios_measurements12 []byte
 
 func TestAdapterEventFilter12(t *testing.T) {
-	eventLogFilter, err := newEventLogFilter(binary_bios_measurements12, []PcrSelection{
+	eventLogFilter, err := newEventLogFilter(binary_bios_measurements12, nil, []PcrSelection{
 		{
 			Hash: crypto.SHA1,
 			Pcrs: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23},
@@ -48,3 +54,252 @@ func TestAdapterEventFilter12(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestEventFilterLogger(t *testing.T) {
+	tests := []struct {
+		name          string
+		evl           []byte
+		pcrSelections []PcrSelection
+	}{
+		{
+			name: "TCG 1.2",
+			evl:  binary_bios_measurements12,
+			pcrSelections: []PcrSelection{
+				{Hash: crypto.SHA256, Pcrs: []int{0}}, // the log only contains sha1 digests
+				{Hash: crypto.SHA1, Pcrs: []int{7}},
+			},
+		},
+		{
+			name: "TCG 2.0",
+			evl:  binary_bios_measurements20,
+			pcrSelections: []PcrSelection{
+				{Hash: crypto.SHA384, Pcrs: []int{0}}, // the log does not contain sha384 digests
+				{Hash: crypto.SHA256, Pcrs: []int{7}},
+			},
+		},
+	}
+
+	expectedCounts := map[string]map[int]pcrEventCounts{
+		"TCG 1.2": {
+			0: {bankMismatch: 10},
+			1: {notSelected: 14},
+			7: {retained: 10},
+			8: {notSelected: 70},
+		},
+		"TCG 2.0": {
+			0: {bankMismatch: 11},
+			1: {notSelected: 23},
+			7: {retained: 8},
+			8: {notSelected: 64},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := test.NewNullLogger()
+
+			eventLogFilter, err := newEventLogFilter(tt.evl, logger, tt.pcrSelections...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = eventLogFilter.FilterEventLogs()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries := map[int]*logrus.Entry{}
+			for _, entry := range hook.AllEntries() {
+				entries[entry.Data["pcr"].(int)] = entry
+			}
+
+			for pcr, expected := range expectedCounts[tt.name] {
+				entry, ok := entries[pcr]
+				if !ok {
+					t.Fatalf("PCR %d was not logged", pcr)
+				}
+
+				actual := pcrEventCounts{
+					retained:     entry.Data["retained"].(int),
+					notSelected:  entry.Data["dropped_not_selected"].(int),
+					bankMismatch: entry.Data["dropped_bank_mismatch"].(int),
+				}
+				if actual != expected {
+					t.Errorf("Expected PCR %d counts %+v, got %+v", pcr, expected, actual)
+				}
+			}
+
+			// the selected PCR without digests in the selected bank is a warning
+			if entries[0].Level != logrus.WarnLevel {
+				t.Errorf("Expected PCR 0 to be logged as a warning, got %v", entries[0].Level)
+			}
+
+			if entries[7].Level != logrus.InfoLevel {
+				t.Errorf("Expected PCR 7 to be logged as info, got %v", entries[7].Level)
+			}
+		})
+	}
+}
+
+func TestValidateEventLogHeader(t *testing.T) {
+	badPcr := bytes.Clone(binary_bios_measurements20)
+	binary.LittleEndian.PutUint32(badPcr[0:4], 1)
+
+	badEventType := bytes.Clone(binary_bios_measurements20)
+	binary.LittleEndian.PutUint32(badEventType[4:8], 1)
+
+	badEventSize := bytes.Clone(binary_bios_measurements20)
+	binary.LittleEndian.PutUint32(badEventSize[28:32], 0xFFFF)
+
+	badSignature := bytes.Clone(binary_bios_measurements20)
+	copy(badSignature[32:], []byte("Not a Spec ID Event"))
+
+	tests := []struct {
+		name           string
+		evl            []byte
+		expectedFormat EventLogFormat
+		errorExpected  bool
+	}{
+		{
+			name:           "TCG 2.0",
+			evl:            binary_bios_measurements20,
+			expectedFormat: EventLogFormatTcg20,
+		},
+		{
+			name:           "TCG 1.2",
+			evl:            binary_bios_measurements12,
+			expectedFormat: EventLogFormatTcg12,
+		},
+		{
+			name:          "Empty",
+			evl:           []byte{},
+			errorExpected: true,
+		},
+		{
+			name:          "Truncated Header",
+			evl:           binary_bios_measurements20[:40],
+			errorExpected: true,
+		},
+		{
+			name:          "Invalid PCR",
+			evl:           badPcr,
+			errorExpected: true,
+		},
+		{
+			name:          "Invalid Event Type",
+			evl:           badEventType,
+			errorExpected: true,
+		},
+		{
+			name:          "Invalid Event Size",
+			evl:           badEventSize,
+			errorExpected: true,
+		},
+		{
+			name:          "Invalid Header Event",
+			evl:           badSignature,
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := ValidateEventLogHeader(tt.evl)
+			if tt.errorExpected {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if format != EventLogFormatUnknown {
+					t.Errorf("expected unknown format, got %s", format)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if format != tt.expectedFormat {
+				t.Errorf("expected format %s, got %s", tt.expectedFormat, format)
+			}
+		})
+	}
+}
+
+func TestEventFilterTruncatedLogs(t *testing.T) {
+	_, start20, err := parseEventLogHeader(binary_bios_measurements20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// find the end of the first TCG_PCR_EVENT2 event following the header (see
+	// tcg20EventLogFilterImpl.FilterEventLogs for the structure's format)
+	pos := start20 + 8
+	digestCount := int(binary.LittleEndian.Uint32(binary_bios_measurements20[pos : pos+4]))
+	pos += 4
+	for i := 0; i < digestCount; i++ {
+		h, err := algIdToCryptoHash(int16(binary.LittleEndian.Uint16(binary_bios_measurements20[pos : pos+2])))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pos += 2 + h.Size()
+	}
+	eventSize := int(binary.LittleEndian.Uint32(binary_bios_measurements20[pos : pos+4]))
+	endOfEvent20 := pos + 4 + eventSize
+
+	_, start12, err := parseEventLogHeader(binary_bios_measurements12)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sha1Selection := []PcrSelection{
+		{
+			Hash: crypto.SHA1,
+			Pcrs: []int{0, 1, 2, 3, 4, 5, 6, 7},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		evl           []byte
+		pcrSelections []PcrSelection
+	}{
+		{
+			name:          "TCG 2.0 Truncated Mid Event Header",
+			evl:           binary_bios_measurements20[:start20+6],
+			pcrSelections: defaultPcrSelections,
+		},
+		{
+			name:          "TCG 2.0 Truncated Mid Digest",
+			evl:           binary_bios_measurements20[:start20+12+2+5],
+			pcrSelections: defaultPcrSelections,
+		},
+		{
+			name:          "TCG 2.0 Truncated Mid Event",
+			evl:           binary_bios_measurements20[:endOfEvent20-1],
+			pcrSelections: defaultPcrSelections,
+		},
+		{
+			name:          "TCG 1.2 Truncated Mid Digest",
+			evl:           binary_bios_measurements12[:start12+8+10],
+			pcrSelections: sha1Selection,
+		},
+		{
+			name:          "TCG 1.2 Truncated Mid Event",
+			evl:           binary_bios_measurements12[:len(binary_bios_measurements12)-1],
+			pcrSelections: sha1Selection,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eventLogFilter, err := newEventLogFilter(tt.evl, nil, tt.pcrSelections...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = eventLogFilter.FilterEventLogs()
+			if !errors.Is(err, ErrCorruptEventLog) {
+				t.Fatalf("expected ErrCorruptEventLog, got %v", err)
+			}
+		})
+	}
+}
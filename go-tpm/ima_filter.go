@@ -0,0 +1,94 @@
+/*
+ *   Copyright (c) 2022-2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package tpm
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The template fields of the IMA templates defined by the kernel (see
+// security/integrity/ima/ima_template.c).  Lines of the IMA ascii runtime measurements
+// are formatted as "{pcr} {template-hash} {template-name} {field1} {field2}..." where
+// each field is separated by a single space (empty fields are printed as "").
+var imaTemplateFields = map[string][]string{
+	"ima":        {"d", "n"},
+	"ima-ng":     {"d-ng", "n-ng"},
+	"ima-ngv2":   {"d-ngv2", "n-ng"},
+	"ima-sig":    {"d-ng", "n-ng", "sig"},
+	"ima-sigv2":  {"d-ngv2", "n-ng", "sig"},
+	"ima-buf":    {"d-ng", "n-ng", "buf"},
+	"ima-modsig": {"d-ng", "n-ng", "sig", "d-modsig", "modsig"},
+	"evm-sig":    {"d-ng", "n-ng", "evmsig", "xattrnames", "xattrlengths", "xattrvalues", "iuid", "igid", "imode"},
+}
+
+// The number of leading columns (pcr, template-hash and template-name) that are always
+// included in filtered IMA logs.
+const imaHeaderColumns = 3
+
+// isImaTemplateField returns true if 'field' is used by any of the known IMA templates.
+func isImaTemplateField(field string) bool {
+	for _, fields := range imaTemplateFields {
+		for _, f := range fields {
+			if f == field {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filterImaLog reduces the size of IMA ascii runtime measurements by clearing the template
+// fields that are not in 'selectedFields' (ex. "sig" or "n-ng").  The pcr, template-hash and
+// template-name columns are always kept so that the log can still be replayed against the
+// IMA PCR and each line keeps its column positions (i.e. an excluded field becomes empty).
+//
+// Lines with unknown templates, or that cannot be split into the template's fields (ex. file
+// names containing spaces), are included unchanged.
+func filterImaLog(imaLog []byte, selectedFields []string) ([]byte, error) {
+	selected := make(map[string]bool, len(selectedFields))
+	for _, field := range selectedFields {
+		selected[field] = true
+	}
+
+	var filtered bytes.Buffer
+	filtered.Grow(len(imaLog))
+
+	lines := strings.SplitAfter(string(imaLog), "\n")
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+
+		content := strings.TrimSuffix(line, "\n")
+		columns := strings.Split(content, " ")
+		if len(columns) < imaHeaderColumns {
+			return nil, errors.Errorf("Invalid IMA measurement %q", content)
+		}
+
+		fields, ok := imaTemplateFields[columns[2]]
+		if !ok || len(columns) != imaHeaderColumns+len(fields) {
+			filtered.WriteString(line)
+			continue
+		}
+
+		for i, field := range fields {
+			if !selected[field] {
+				columns[imaHeaderColumns+i] = ""
+			}
+		}
+
+		filtered.WriteString(strings.Join(columns, " "))
+		if strings.HasSuffix(line, "\n") {
+			filtered.WriteByte('\n')
+		}
+	}
+
+	return filtered.Bytes(), nil
+}
@@ -0,0 +1,79 @@
+/*
+ *   Copyright (c) 2022-2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package tpm
+
+import (
+	"strings"
+	"testing"
+)
+
+// IMA ascii runtime measurements (the second ima-sig measurement does not have a signature).
+const testImaLog = "" +
+	"10 91f34b5c671d73504b274a919661cf80dab1e127 ima-ng sha1:1801e1be3e65ef1eaa5c16617bec8f1274eaf6b3 boot_aggregate\n" +
+	"10 8b3ee1c4bc0a4f0b5f0b8b8d1b1d13b9d6e9ff01 ima-sig sha256:4f3c5e1d9a0b7c2e8f6a1d3b5c7e9f0a2b4c6d8e0f1a3b5c7d9e1f3a5b7c9d1e /usr/bin/bash 030204b1c2d3e40100abcdef0123456789abcdef0123456789abcdef\n" +
+	"10 2a7c9b0e4d6f8a1c3e5b7d9f0a2c4e6b8d0f1a3c ima-sig sha256:0a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9 /usr/lib/libc.so.6 \n" +
+	"10 6d8f0a2c4e6b8d0f1a3c5e7b9d1f3a5c7e9b1d3f unknown-template sha256:00 /some/file extra\n"
+
+func TestFilterImaLog(t *testing.T) {
+	filtered, err := filterImaLog([]byte(testImaLog), []string{"d-ng"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(filtered) >= len(testImaLog) {
+		t.Fatalf("Expected the filtered log (%d bytes) to be smaller than the original (%d bytes)", len(filtered), len(testImaLog))
+	}
+
+	expected := []string{
+		"10 91f34b5c671d73504b274a919661cf80dab1e127 ima-ng sha1:1801e1be3e65ef1eaa5c16617bec8f1274eaf6b3 ",
+		"10 8b3ee1c4bc0a4f0b5f0b8b8d1b1d13b9d6e9ff01 ima-sig sha256:4f3c5e1d9a0b7c2e8f6a1d3b5c7e9f0a2b4c6d8e0f1a3b5c7d9e1f3a5b7c9d1e  ",
+		"10 2a7c9b0e4d6f8a1c3e5b7d9f0a2c4e6b8d0f1a3c ima-sig sha256:0a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9  ",
+		"10 6d8f0a2c4e6b8d0f1a3c5e7b9d1f3a5c7e9b1d3f unknown-template sha256:00 /some/file extra",
+		"",
+	}
+
+	lines := strings.Split(string(filtered), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d", len(expected), len(lines))
+	}
+
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("Line %d: expected %q, got %q", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestFilterImaLogAllFields(t *testing.T) {
+	filtered, err := filterImaLog([]byte(testImaLog), []string{"d", "n", "d-ng", "n-ng", "sig"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(filtered) != testImaLog {
+		t.Fatalf("Expected the log to be unchanged, got %q", filtered)
+	}
+}
+
+func TestFilterImaLogInvalid(t *testing.T) {
+	_, err := filterImaLog([]byte("10 91f34b5c671d73504b274a919661cf80dab1e127\n"), []string{"d-ng"})
+	if err == nil {
+		t.Fatal("Expected an error for an invalid measurement")
+	}
+}
+
+func TestWithImaLogFields(t *testing.T) {
+	_, err := NewTpmAdapterFactory(nil).New(WithImaLogFields([]string{"d-ng", "n-ng"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewTpmAdapterFactory(nil).New(WithImaLogFields([]string{"d-ng", "bogus"}))
+	if err == nil {
+		t.Fatal("Expected an error for an unknown template field")
+	}
+}
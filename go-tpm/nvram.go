@@ -40,7 +40,7 @@ func (tpm *trustedPlatformModule) NVRead(nvHandle int) ([]byte, error) {
 		return nil, errors.Wrapf(err, "Failed to create resource context for handle 0x%x", handle)
 	}
 
-	data, err := tpm.ctx.NVRead(tpm.ctx.OwnerHandleContext(), nvContext, nvPublic.Size, 0, nil, nil)
+	data, err := tpm.ctx.NVRead(tpm.ctx.OwnerHandleContext(), nvContext, nvPublic.Size, 0, tpm.encryptedSession(tpm2.AttrResponseEncrypt))
 	if err != nil {
 		return nil, errors.Wrapf(err, "Error nvram at handle 0x%x", handle)
 	}
@@ -75,9 +75,14 @@ func (tpm *trustedPlatformModule) NVWrite(nvHandle int, data []byte) error {
 	}
 	nvContext.SetAuthValue(tpm.ownerAuth)
 
-	session, err := tpm.ctx.StartAuthSession(nil, nil, tpm2.SessionTypeHMAC, nil, tpm2.HashAlgorithmSHA256)
-	if err != nil {
-		return err
+	// use the encrypted session when it was started (see StartEncryptedSession)
+	session := tpm.encryptedSession(tpm2.AttrCommandEncrypt)
+	if session == nil {
+		session, err = tpm.ctx.StartAuthSession(nil, nil, tpm2.SessionTypeHMAC, nil, tpm2.HashAlgorithmSHA256)
+		if err != nil {
+			return err
+		}
+		defer tpm.ctx.FlushContext(session)
 	}
 
 	err = tpm.ctx.NVWrite(tpm.ctx.OwnerHandleContext(), nvContext, data, 0, session)
@@ -85,8 +90,6 @@ func (tpm *trustedPlatformModule) NVWrite(nvHandle int, data []byte) error {
 		return errors.Wrapf(ErrNvWriteFailed, "Index 0x%x: %s", nvHandle, err.Error())
 	}
 
-	tpm.ctx.FlushContext(session)
-
 	logrus.Debugf("Successfully wrote %d bytes at NV index 0x%x", len(data), nvHandle)
 	return nil
 }
@@ -114,7 +117,7 @@ func (tpm *trustedPlatformModule) NVDelete(nvHandle int) error {
 	}
 	nvContext.SetAuthValue(tpm.ownerAuth)
 
-	err = tpm.ctx.NVUndefineSpace(tpm.ctx.OwnerHandleContext(), nvContext, nil)
+	err = tpm.ctx.NVUndefineSpace(tpm.ctx.OwnerHandleContext(), nvContext, tpm.encryptedSession(0))
 	if err != nil {
 		return errors.Wrapf(ErrNvReleaseFailed, "Index 0x%x: %s", nvHandle, err.Error())
 	}
@@ -153,7 +156,7 @@ func (tpm *trustedPlatformModule) NVDefine(nvHandle int, len int) error {
 		Size:       uint16(len),
 	}
 
-	_, err := tpm.ctx.NVDefineSpace(tpm.ctx.OwnerHandleContext(), auth, &nvPublic, nil)
+	_, err := tpm.ctx.NVDefineSpace(tpm.ctx.OwnerHandleContext(), auth, &nvPublic, tpm.encryptedSession(tpm2.AttrCommandEncrypt))
 	if err != nil {
 		return errors.Wrapf(ErrNvDefineSpaceFailed, "Index 0x%x: %s", nvHandle, err.Error())
 	}
@@ -7,6 +7,9 @@
 package tpm
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"reflect"
 	"testing"
 
@@ -92,6 +95,92 @@ func TestNvWrite(t *testing.T) {
 	}
 }
 
+func TestNvWriteEncryptedSession(t *testing.T) {
+	len := 256
+	testNvHandle := 0x01001899
+
+	tpm, err := newTestTpm()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpm.Close()
+
+	ekCert, err := tpm.GetEKCertificate(DefaultEkNvIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the EK is created at the default handle when the simulator is reset
+	err = tpm.StartEncryptedSession(DefaultEkHandle, ekCert.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tpm.NVDefine(testNvHandle, len)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := make([]byte, len)
+	for i := range d {
+		d[i] = byte(i)
+	}
+
+	err = tpm.NVWrite(testNvHandle, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nv, err := tpm.NVRead(testNvHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(d, nv) {
+		t.Fail()
+	}
+
+	err = tpm.NVDelete(testNvHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStartEncryptedSessionNegative(t *testing.T) {
+	ekKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpm := &trustedPlatformModule{}
+	if err := tpm.StartEncryptedSession(0x1, ekKey.Public()); err != ErrHandleOutOfRange {
+		t.Fatalf("Expected ErrHandleOutOfRange, got %v", err)
+	}
+
+	if err := tpm.StartEncryptedSession(DefaultEkHandle, nil); err == nil {
+		t.Fatal("Expected an error without the EK public key")
+	}
+}
+
+func TestStartEncryptedSessionEkMismatch(t *testing.T) {
+	tpm, err := newTestTpm()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpm.Close()
+
+	// a key that is not the EK's (ex. substituted by an interposer)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tpm.StartEncryptedSession(DefaultEkHandle, otherKey.Public())
+	if !errors.Is(err, ErrEkPublicMismatch) {
+		t.Fatalf("Expected ErrEkPublicMismatch, got %v", err)
+	}
+}
+
 func TestNvSizeCheck(t *testing.T) {
 	tpm, err := newTestTpm()
 	if err != nil {
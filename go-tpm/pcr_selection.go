@@ -0,0 +1,183 @@
+/*
+ *   Copyright (c) 2022-2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package tpm
+
+import (
+	"crypto"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The number of PCRs in each bank (PCRs 0-23).
+const pcrCount = 24
+
+// PcrSelectionBuilder creates a list of PcrSelection in code, as an alternative to
+// tpm2-tools style selection strings (see WithPcrSelections).  For example...
+//
+//	selections, err := tpm.NewPcrSelectionBuilder().
+//		Add(crypto.SHA256, 0, 1, 7).
+//		AddAll(crypto.SHA384).
+//		Build()
+type PcrSelectionBuilder struct {
+	selections []PcrSelection
+	err        error
+}
+
+// NewPcrSelectionBuilder returns a builder without any PCRs selected.
+func NewPcrSelectionBuilder() *PcrSelectionBuilder {
+	return &PcrSelectionBuilder{}
+}
+
+// Add selects 'pcrs' (0-23) in the 'hash' bank (SHA1, SHA256, SHA384 or SHA512).
+// PCRs that are added to the same bank more than once are combined into a single
+// selection.  Invalid banks or PCRs are reported by Build.
+func (b *PcrSelectionBuilder) Add(hash crypto.Hash, pcrs ...int) *PcrSelectionBuilder {
+	if b.err != nil {
+		return b
+	}
+
+	switch hash {
+	case crypto.SHA1, crypto.SHA256, crypto.SHA384, crypto.SHA512:
+	default:
+		b.err = errors.Errorf("Invalid PCR hash %v", hash)
+		return b
+	}
+
+	if len(pcrs) == 0 {
+		b.err = errors.Errorf("No PCRs were provided for the %v bank", hash)
+		return b
+	}
+
+	for _, pcr := range pcrs {
+		if pcr < 0 || pcr >= pcrCount {
+			b.err = errors.Errorf("Bank %d out of range", pcr)
+			return b
+		}
+	}
+
+	for i := range b.selections {
+		if b.selections[i].Hash == hash {
+			b.selections[i].Pcrs = appendPcrs(b.selections[i].Pcrs, pcrs...)
+			return b
+		}
+	}
+
+	b.selections = append(b.selections, PcrSelection{
+		Hash: hash,
+		Pcrs: appendPcrs(nil, pcrs...),
+	})
+
+	return b
+}
+
+// AddAll selects all of the PCRs (0-23) in the 'hash' bank (similar to "sha256:all").
+func (b *PcrSelectionBuilder) AddAll(hash crypto.Hash) *PcrSelectionBuilder {
+	pcrs := make([]int, pcrCount)
+	for i := range pcrs {
+		pcrs[i] = i
+	}
+
+	return b.Add(hash, pcrs...)
+}
+
+// Build returns the selected PCRs or the first error encountered while adding them.
+// An error is returned if no PCRs were selected.
+func (b *PcrSelectionBuilder) Build() ([]PcrSelection, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	if len(b.selections) == 0 {
+		return nil, errors.New("No PCRs were selected")
+	}
+
+	selections := make([]PcrSelection, len(b.selections))
+	for i, s := range b.selections {
+		selections[i] = PcrSelection{
+			Hash: s.Hash,
+			Pcrs: append([]int{}, s.Pcrs...),
+		}
+	}
+
+	return selections, nil
+}
+
+// appendPcrs appends the 'pcrs' that are not already in 'selected'.
+func appendPcrs(selected []int, pcrs ...int) []int {
+	for _, pcr := range pcrs {
+		exists := false
+		for _, s := range selected {
+			if s == pcr {
+				exists = true
+				break
+			}
+		}
+
+		if !exists {
+			selected = append(selected, pcr)
+		}
+	}
+
+	return selected
+}
+
+// pcrBankNames are the tpm2-tools names of the PCR banks supported by ParsePcrSelections.
+var pcrBankNames = map[crypto.Hash]string{
+	crypto.SHA1:   "sha1",
+	crypto.SHA256: "sha256",
+	crypto.SHA384: "sha384",
+	crypto.SHA512: "sha512",
+}
+
+// MergePcrSelections combines tpm2-tools style selection strings (see ParsePcrSelections)
+// into a single selection string containing the union of each bank's PCRs, so that a base
+// selection (ex. from configuration) can be augmented.  For example, "sha256:0-7" and
+// "sha256:10" are merged into "sha256:0,1,2,3,4,5,6,7,10".  Empty selection strings are
+// ignored and an empty string is returned when all of 'selections' are empty.
+func MergePcrSelections(selections ...string) (string, error) {
+	builder := NewPcrSelectionBuilder()
+	for _, selection := range selections {
+		if selection == "" {
+			continue
+		}
+
+		pcrSelections, err := ParsePcrSelections(selection)
+		if err != nil {
+			return "", errors.Wrapf(err, "Invalid PCR selection %q", selection)
+		}
+
+		for _, pcrSelection := range pcrSelections {
+			builder.Add(pcrSelection.Hash, pcrSelection.Pcrs...)
+		}
+	}
+
+	if len(builder.selections) == 0 {
+		return "", nil
+	}
+
+	merged, err := builder.Build()
+	if err != nil {
+		return "", err
+	}
+
+	banks := make([]string, len(merged))
+	for i, pcrSelection := range merged {
+		sort.Ints(pcrSelection.Pcrs)
+
+		pcrs := make([]string, len(pcrSelection.Pcrs))
+		for j, pcr := range pcrSelection.Pcrs {
+			pcrs[j] = strconv.Itoa(pcr)
+		}
+
+		banks[i] = pcrBankNames[pcrSelection.Hash] + ":" + strings.Join(pcrs, ",")
+	}
+
+	return strings.Join(banks, "+"), nil
+}
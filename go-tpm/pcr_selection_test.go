@@ -0,0 +1,140 @@
+/*
+ *   Copyright (c) 2022-2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package tpm
+
+import (
+	"crypto"
+	"reflect"
+	"testing"
+)
+
+func TestPcrSelectionBuilderMatchesParser(t *testing.T) {
+	tests := []struct {
+		selectionString string
+		builder         *PcrSelectionBuilder
+	}{
+		{
+			"sha1:1,2,3",
+			NewPcrSelectionBuilder().Add(crypto.SHA1, 1, 2, 3),
+		},
+		{
+			"sha1:all",
+			NewPcrSelectionBuilder().AddAll(crypto.SHA1),
+		},
+		{
+			"sha1:1,2,3+sha256:1,2,3",
+			NewPcrSelectionBuilder().Add(crypto.SHA1, 1, 2, 3).Add(crypto.SHA256, 1, 2, 3),
+		},
+		{
+			"sha1:all+sha256:1,2,3",
+			NewPcrSelectionBuilder().AddAll(crypto.SHA1).Add(crypto.SHA256, 1, 2, 3),
+		},
+		{
+			"sha256:0,1,7+sha384:10",
+			NewPcrSelectionBuilder().Add(crypto.SHA256, 0, 1).Add(crypto.SHA384, 10).Add(crypto.SHA256, 7, 1),
+		},
+		{
+			"sha512:1,2,3",
+			NewPcrSelectionBuilder().Add(crypto.SHA512, 1, 2, 3),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selectionString, func(t *testing.T) {
+			expected, err := ParsePcrSelections(tt.selectionString)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			selections, err := tt.builder.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(selections, expected) {
+				t.Errorf("Expected %+v, got %+v", expected, selections)
+			}
+		})
+	}
+}
+
+func TestPcrSelectionBuilderInvalid(t *testing.T) {
+	tests := map[string]*PcrSelectionBuilder{
+		"invalid hash":   NewPcrSelectionBuilder().Add(crypto.MD5, 1),
+		"pcr too large":  NewPcrSelectionBuilder().Add(crypto.SHA256, 24),
+		"negative pcr":   NewPcrSelectionBuilder().Add(crypto.SHA256, 0, -1),
+		"no pcrs":        NewPcrSelectionBuilder().Add(crypto.SHA256),
+		"empty":          NewPcrSelectionBuilder(),
+		"error retained": NewPcrSelectionBuilder().Add(crypto.SHA256, 99).Add(crypto.SHA1, 1),
+	}
+
+	for name, builder := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := builder.Build(); err == nil {
+				t.Fatal("Expected an error")
+			}
+		})
+	}
+}
+
+func TestWithPcrSelectionList(t *testing.T) {
+	selections, err := NewPcrSelectionBuilder().Add(crypto.SHA256, 0, 1, 7).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adapter, err := NewTpmAdapterFactory(nil).New(WithPcrSelectionList(selections))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(adapter.(*tpmAdapter).pcrSelections, selections) {
+		t.Errorf("Expected %+v, got %+v", selections, adapter.(*tpmAdapter).pcrSelections)
+	}
+
+	if _, err = NewTpmAdapterFactory(nil).New(WithPcrSelectionList(nil)); err == nil {
+		t.Fatal("Expected an error for empty selections")
+	}
+}
+
+func TestMergePcrSelections(t *testing.T) {
+	tests := []struct {
+		selections []string
+		expected   string
+	}{
+		{[]string{"sha256:0-7", "sha256:10"}, "sha256:0,1,2,3,4,5,6,7,10"},
+		{[]string{"sha256:7,1", "sha256:1,0+sha1:4"}, "sha256:0,1,7+sha1:4"},
+		{[]string{"sha256:0-7", ""}, "sha256:0,1,2,3,4,5,6,7"},
+		{[]string{"", "sha384:23"}, "sha384:23"},
+		{[]string{"", ""}, ""},
+		{[]string{}, ""},
+	}
+
+	for _, tt := range tests {
+		merged, err := MergePcrSelections(tt.selections...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if merged != tt.expected {
+			t.Errorf("Expected %q when merging %q, got %q", tt.expected, tt.selections, merged)
+		}
+
+		// the merged selection is a valid selection string
+		if merged != "" {
+			if _, err = ParsePcrSelections(merged); err != nil {
+				t.Errorf("Merged selection %q is invalid: %v", merged, err)
+			}
+		}
+	}
+
+	for _, invalid := range [][]string{{"sha256:0-7", "sha256:24"}, {"md5:1"}, {"sha256:0", "sha256"}} {
+		if _, err := MergePcrSelections(invalid...); err == nil {
+			t.Errorf("Expected an error when merging %q", invalid)
+		}
+	}
+}
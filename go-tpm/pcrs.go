@@ -7,13 +7,36 @@
 package tpm
 
 import (
+	"crypto"
 	"fmt"
 	"math"
 
 	"github.com/pkg/errors"
 )
 
+// PcrValue is the digest of a PCR in one of the TPM's banks (see ReadPcrValues).
+type PcrValue struct {
+	Index  int
+	Hash   crypto.Hash
+	Digest []byte
+}
+
 func (tpm *trustedPlatformModule) GetPcrs(selection ...PcrSelection) ([]byte, error) {
+	pcrValues, err := tpm.ReadPcrValues(selection...)
+	if err != nil {
+		return nil, err
+	}
+
+	// flatten the results into contigous, index ordered binary (no alg headers, etc.)
+	results := []byte{}
+	for _, pcrValue := range pcrValues {
+		results = append(results, pcrValue.Digest...)
+	}
+
+	return results, nil
+}
+
+func (tpm *trustedPlatformModule) ReadPcrValues(selection ...PcrSelection) ([]PcrValue, error) {
 	pcrSelection, err := toTpm2PcrSelectionList(selection...)
 	if err != nil {
 		return nil, err
@@ -29,8 +52,7 @@ func (tpm *trustedPlatformModule) GetPcrs(selection ...PcrSelection) ([]byte, er
 		return nil, err
 	}
 
-	// flatten the results into contigous, index ordered binary (no alg headers, etc.)
-	results := []byte{}
+	results := []PcrValue{}
 	for _, s := range selectionList {
 		if _, ok := pcrValues[s.Hash]; !ok {
 			return nil, errors.Errorf("PCR values did not contain don't contain digests for PCR bank %v", s.Hash)
@@ -48,7 +70,11 @@ func (tpm *trustedPlatformModule) GetPcrs(selection ...PcrSelection) ([]byte, er
 				return nil, fmt.Errorf("PCR values did not contain a digest for PCR%d in bank %v", i, s.Hash)
 			}
 
-			results = append(results, d...)
+			results = append(results, PcrValue{
+				Index:  i,
+				Hash:   s.Hash.GetHash(),
+				Digest: d,
+			})
 		}
 	}
 
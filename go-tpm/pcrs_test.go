@@ -7,6 +7,7 @@
 package tpm
 
 import (
+	"crypto"
 	"testing"
 )
 
@@ -26,3 +27,41 @@ func TestPcrsRead(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestReadPcrValues(t *testing.T) {
+	tpm, err := newTestTpm()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpm.Close()
+
+	selections, err := ParsePcrSelections("sha256:0-7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pcrValues, err := tpm.ReadPcrValues(selections...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pcrValues) != 8 {
+		t.Fatalf("Expected 8 PCR values, got %d", len(pcrValues))
+	}
+
+	for i, pcrValue := range pcrValues {
+		if pcrValue.Index != i || pcrValue.Hash != crypto.SHA256 || len(pcrValue.Digest) != crypto.SHA256.Size() {
+			t.Errorf("Unexpected PCR value %+v", pcrValue)
+		}
+	}
+
+	// the flattened PCRs are the concatenation of the PCR values
+	pcrs, err := tpm.GetPcrs(selections...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pcrs) != 8*crypto.SHA256.Size() {
+		t.Errorf("Expected %d bytes of PCR values, got %d", 8*crypto.SHA256.Size(), len(pcrs))
+	}
+}
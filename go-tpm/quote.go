@@ -36,7 +36,7 @@ func (tpm *trustedPlatformModule) GetQuote(akHandle int, nonce []byte, selection
 		return nil, nil, err
 	}
 
-	quoted, signature, err := tpm.ctx.Quote(akContext, tpm2.Data(nonce), nil, pcrSelection, nil)
+	quoted, signature, err := tpm.ctx.Quote(akContext, tpm2.Data(nonce), nil, pcrSelection, nil, tpm.encryptedSession(tpm2.AttrCommandEncrypt|tpm2.AttrResponseEncrypt))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -53,3 +53,36 @@ func (tpm *trustedPlatformModule) GetQuote(akHandle int, nonce []byte, selection
 
 	return quoteBytes, signatureBytes, nil
 }
+
+// QuoteClockInfo holds the clock and firmware information from the TPMS_ATTEST structure
+// of a quote (see WithClockInfo).  The reset and restart counts allow verifiers to detect
+// that the TPM was reset (ex. a reboot) or resumed between quotes.
+type QuoteClockInfo struct {
+	Clock           uint64 `json:"clock"`
+	ResetCount      uint32 `json:"reset_count"`
+	RestartCount    uint32 `json:"restart_count"`
+	Safe            bool   `json:"clock_safe"`
+	FirmwareVersion uint64 `json:"firmware_version"`
+}
+
+// parseQuoteClockInfo unmarshals the TPMS_ATTEST structure returned by GetQuote and
+// returns its clock and firmware information.
+func parseQuoteClockInfo(quote []byte) (*QuoteClockInfo, error) {
+	var attest tpm2.Attest
+	_, err := mu.UnmarshalFromBytes(quote, &attest)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to unmarshal quote")
+	}
+
+	if attest.Magic != tpm2.TPMGeneratedValue || attest.Type != tpm2.TagAttestQuote {
+		return nil, errors.New("The quote is not a TPM generated TPMS_ATTEST structure")
+	}
+
+	return &QuoteClockInfo{
+		Clock:           attest.ClockInfo.Clock,
+		ResetCount:      attest.ClockInfo.ResetCount,
+		RestartCount:    attest.ClockInfo.RestartCount,
+		Safe:            attest.ClockInfo.Safe,
+		FirmwareVersion: attest.FirmwareVersion,
+	}, nil
+}
@@ -0,0 +1,94 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package tpm
+
+import (
+	"crypto"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// encryptedSessionSymmetric is the parameter encryption algorithm of sessions started
+// by StartEncryptedSession (AES-128 in CFB mode is required by the TPM 2.0 PC client
+// profile).
+var encryptedSessionSymmetric = tpm2.SymDef{
+	Algorithm: tpm2.SymAlgorithmAES,
+	KeyBits:   &tpm2.SymKeyBitsU{Sym: 128},
+	Mode:      &tpm2.SymModeU{Sym: tpm2.SymModeCFB},
+}
+
+func (tpm *trustedPlatformModule) StartEncryptedSession(ekHandle int, ekPublic crypto.PublicKey) error {
+	if ekHandle < minPersistentHandle || ekHandle > maxPersistentHandle {
+		return ErrHandleOutOfRange
+	}
+
+	if ekPublic == nil {
+		return errors.New("The expected EK public key must be provided")
+	}
+
+	if tpm.session != nil {
+		return errors.New("An encrypted session has already been started")
+	}
+
+	handle := tpm2.Handle(ekHandle)
+	if !tpm.ctx.DoesHandleExist(handle) {
+		return ErrHandleDoesNotExist
+	}
+
+	ekContext, err := tpm.ctx.NewResourceContext(handle)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to create resource context for handle 0x%x", ekHandle)
+	}
+
+	// the public area read from the TPM is not authenticated, make sure the salt is not
+	// encrypted with a key substituted on the TPM interface
+	if err = verifyEkPublic(ekContext, ekPublic); err != nil {
+		return err
+	}
+
+	// the session's salt is encrypted with the EK, so its key cannot be derived by
+	// observing the TPM interface
+	session, err := tpm.ctx.StartAuthSession(ekContext, nil, tpm2.SessionTypeHMAC, &encryptedSessionSymmetric, tpm2.HashAlgorithmSHA256)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to start a session salted with the EK at handle 0x%x", ekHandle)
+	}
+
+	tpm.session = session.WithAttrs(tpm2.AttrContinueSession)
+
+	logrus.Debugf("Started an encrypted session salted with the EK at handle 0x%x", ekHandle)
+	return nil
+}
+
+// verifyEkPublic returns ErrEkPublicMismatch if the public key of 'ekContext' (which is
+// used to encrypt the session's salt) is not 'expected'.
+func verifyEkPublic(ekContext tpm2.ResourceContext, expected crypto.PublicKey) error {
+	object, ok := ekContext.(tpm2.ObjectContext)
+	if !ok || object.Public() == nil {
+		return errors.Wrap(ErrEkPublicMismatch, "Failed to read the EK public area")
+	}
+
+	ekPublic, ok := object.Public().Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !ekPublic.Equal(expected) {
+		return ErrEkPublicMismatch
+	}
+
+	return nil
+}
+
+// encryptedSession returns the session started by StartEncryptedSession with 'attrs'
+// (ex. AttrCommandEncrypt), or nil if it was not started.  The TPM rejects encryption
+// attributes for commands whose first command/response parameter is not a sized buffer,
+// so each command includes only the attributes that apply to it.
+func (tpm *trustedPlatformModule) encryptedSession(attrs tpm2.SessionAttributes) tpm2.SessionContext {
+	if tpm.session == nil {
+		return nil
+	}
+
+	return tpm.session.IncludeAttrs(attrs)
+}
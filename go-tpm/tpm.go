@@ -40,6 +40,11 @@ type TrustedPlatformModule interface {
 	// EK to the endorsement hierachy (root of trust).
 	CreateAK(akHandle int, ekHandle int) error
 
+	// EvictHandle removes the persistent object (ex. an AK) at 'handle' from the TPM.
+	// It fails if the handle is not within the range of persistent handles or if
+	// the handle does not exist.
+	EvictHandle(handle int) error
+
 	// ActivateCredential decrypts a credential blob using the secret and the AK at 'akHandle'.
 	ActivateCredential(ekHandle int, akHandle int, credentialBlob []byte, secret []byte) ([]byte, error)
 
@@ -85,9 +90,24 @@ type TrustedPlatformModule interface {
 	// is not provided, then all sha256 banks are included in the results.
 	GetPcrs(selection ...PcrSelection) ([]byte, error)
 
+	// ReadPcrValues returns the index, bank and digest of each selected PCR (in the order
+	// of the selection's banks and PCR index), similar to tpm2_pcrread.  If 'selection' is
+	// not provided, then all sha256 banks are included in the results.
+	ReadPcrValues(selection ...PcrSelection) ([]PcrValue, error)
+
 	// HandleExists is a utility function that returns true if the handle exists in the TPM.
 	HandleExists(handle int) bool
 
+	// StartEncryptedSession starts an HMAC session salted with the EK at 'ekHandle'
+	// that encrypts the parameters of subsequent quotes and NV commands (ex. the quote's
+	// nonce, NV data and the owner-auth of new NV indexes), protecting them from an
+	// attacker with physical access to the TPM interface.  The session is flushed by
+	// Close.  'ekPublic' is the expected public key of the EK (ex. from the EK
+	// certificate), ErrEkPublicMismatch is returned if the TPM reports a different key
+	// (ex. substituted by an interposer to recover the salt).  It returns an error if the
+	// EK does not exist.
+	StartEncryptedSession(ekHandle int, ekPublic crypto.PublicKey) error
+
 	// Close closes the TPM.
 	Close()
 }
@@ -98,10 +118,18 @@ const (
 	TpmDeviceUnknown TpmDeviceType = iota
 	TpmDeviceLinux
 	TpmDeviceMSSIM
-
-	unknownString = "unknown"
-	mssimString   = "mssim"
-	linuxString   = "linux"
+	// TpmDeviceAuto uses the first available Linux device node (/dev/tpmrm0, then
+	// /dev/tpm0).  The simulator is only used when TpmDeviceMSSIM is requested.
+	TpmDeviceAuto
+	// TpmDeviceLinuxRaw uses the raw Linux device (/dev/tpm0) on systems without the
+	// kernel's resource manager.  Access to the device is serialized within the process.
+	TpmDeviceLinuxRaw
+
+	unknownString  = "unknown"
+	mssimString    = "mssim"
+	linuxString    = "linux"
+	autoString     = "auto"
+	linuxRawString = "linux-raw"
 )
 
 func ParseTpmDeviceType(s string) (TpmDeviceType, error) {
@@ -110,6 +138,10 @@ func ParseTpmDeviceType(s string) (TpmDeviceType, error) {
 		return TpmDeviceLinux, nil
 	case mssimString:
 		return TpmDeviceMSSIM, nil
+	case autoString:
+		return TpmDeviceAuto, nil
+	case linuxRawString:
+		return TpmDeviceLinuxRaw, nil
 	default:
 		return TpmDeviceUnknown, errors.Errorf("Unknown TPM device type: %s", s)
 	}
@@ -123,6 +155,10 @@ func (t TpmDeviceType) String() string {
 		return linuxString
 	case TpmDeviceMSSIM:
 		return mssimString
+	case TpmDeviceAuto:
+		return autoString
+	case TpmDeviceLinuxRaw:
+		return linuxRawString
 	default:
 		panic("unknown TpmDeviceType")
 	}
@@ -137,6 +173,11 @@ type PcrSelection struct {
 
 // Close closes the TPM.
 func (tpm *trustedPlatformModule) Close() {
+	if tpm.ctx != nil && tpm.session != nil {
+		tpm.ctx.FlushContext(tpm.session)
+		tpm.session = nil
+	}
+
 	if tpm.ctx != nil {
 		tpm.ctx.Close()
 	}
@@ -147,4 +188,5 @@ type trustedPlatformModule struct {
 	ctx        *tpm2.TPMContext
 	deviceType TpmDeviceType
 	ownerAuth  []byte
+	session    tpm2.SessionContext
 }
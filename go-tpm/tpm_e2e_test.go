@@ -68,6 +68,89 @@ func TestEndToEnd(t *testing.T) {
 	}
 }
 
+// TestRotateAk simulates tdx-cli's rotate-ak command:  a new AK is created and activated
+// before the old AK is evicted.  When the new AK cannot be activated (ex. the connector
+// fails), the new AK is evicted and the old AK remains.
+func TestRotateAk(t *testing.T) {
+	tpm, err := newTestTpm()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpm.Close()
+
+	err = provisionTestAk(tpm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newAkHandle := testAkHandle + 1
+
+	// rollback: the new AK is evicted and the old AK is untouched
+	err = tpm.CreateAK(newAkHandle, testEkHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tpm.EvictHandle(newAkHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tpm.HandleExists(newAkHandle) || !tpm.HandleExists(testAkHandle) {
+		t.Fatal("Expected only the old AK to exist after rollback")
+	}
+
+	// success: the new AK is activated and the old AK is evicted
+	err = tpm.CreateAK(newAkHandle, testEkHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, akTpmtPublic, _, err := tpm.ReadPublic(newAkHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ekCert, err := tpm.GetEKCertificate(DefaultEkNvIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fakeAesKey := []byte("decafbad")
+	credentialBlob, secret, err := makeCredential(ekCert.PublicKey.(*rsa.PublicKey), akTpmtPublic, fakeAesKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aesKey, err := tpm.ActivateCredential(testEkHandle, newAkHandle, credentialBlob, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(fakeAesKey, aesKey) {
+		t.Fatal("Incorrect aes key")
+	}
+
+	err = tpm.EvictHandle(testAkHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tpm.HandleExists(testAkHandle) || !tpm.HandleExists(newAkHandle) {
+		t.Fatal("Expected only the new AK to exist after rotation")
+	}
+
+	_, _, err = tpm.GetQuote(newAkHandle, nil, defaultPcrSelections...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tpm.EvictHandle(newAkHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func provisionTestAk(tpm TrustedPlatformModule) error {
 	err := tpm.CreateEK(testEkHandle)
 	if err != nil {
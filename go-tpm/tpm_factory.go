@@ -7,9 +7,13 @@
 package tpm
 
 import (
+	"os"
+	"sync"
+
 	"github.com/canonical/go-tpm2"
 	"github.com/canonical/go-tpm2/linux"
 	"github.com/canonical/go-tpm2/mssim"
+	"github.com/pkg/errors"
 )
 
 // TpmFactory is an interface for creating TrustedPlatformModule instances.
@@ -17,6 +21,26 @@ type TpmFactory interface {
 	New(deviceType TpmDeviceType, ownerAuth string) (TrustedPlatformModule, error)
 }
 
+const (
+	tpmResourceManagedDeviceNode = "/dev/tpmrm0"
+	tpmRawDeviceNode             = "/dev/tpm0"
+)
+
+var (
+	// tpmDeviceNodes are probed in order when TpmDeviceAuto is requested.
+	tpmDeviceNodes = []string{tpmResourceManagedDeviceNode, tpmRawDeviceNode}
+
+	// statTpmDeviceNode can be replaced by unit tests to fake the device nodes
+	statTpmDeviceNode = os.Stat
+
+	// openRawTpmDevice can be replaced by unit tests to fake the raw device
+	openRawTpmDevice = linuxRawTpmDevice
+
+	// rawTpmDeviceLock serializes access to the raw device which, unlike the resource
+	// managed device, does not support concurrent connections.
+	rawTpmDeviceLock sync.Mutex
+)
+
 // Default TPM factory that creates a TrustedPlatformModule implementation
 // suitable for use with a physical/linux device or TPM simulator.
 func NewTpmFactory() TpmFactory {
@@ -53,6 +77,16 @@ func (f *tpmFactory) New(deviceType TpmDeviceType, ownerAuth string) (TrustedPla
 		}
 	} else if tpm.deviceType == TpmDeviceMSSIM {
 		device = mssim.NewLocalDevice(mssim.DefaultPort)
+	} else if tpm.deviceType == TpmDeviceLinuxRaw {
+		device, err = newSerializedTpmDevice(tpmRawDeviceNode)
+		if err != nil {
+			return nil, err
+		}
+	} else if tpm.deviceType == TpmDeviceAuto {
+		device, err = autoDetectTpmDevice()
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	tpm.ctx, err = tpm2.OpenTPMDevice(device)
@@ -64,3 +98,93 @@ func (f *tpmFactory) New(deviceType TpmDeviceType, ownerAuth string) (TrustedPla
 
 	return tpm, nil
 }
+
+// selectTpmDeviceNode returns the first of tpmDeviceNodes that exists or an error
+// wrapping ErrTpmDeviceNotFound.
+func selectTpmDeviceNode() (string, error) {
+	for _, node := range tpmDeviceNodes {
+		if _, err := statTpmDeviceNode(node); err == nil {
+			return node, nil
+		}
+	}
+
+	return "", errors.Wrapf(ErrTpmDeviceNotFound, "probed %v", tpmDeviceNodes)
+}
+
+// autoDetectTpmDevice returns the resource managed device (/dev/tpmrm0) when it is
+// available, otherwise the raw device (/dev/tpm0).
+func autoDetectTpmDevice() (tpm2.TPMDevice, error) {
+	node, err := selectTpmDeviceNode()
+	if err != nil {
+		return nil, err
+	}
+
+	if node == tpmRawDeviceNode {
+		return newSerializedTpmDevice(node)
+	}
+
+	rawDevice, err := linux.DefaultTPM2Device()
+	if err != nil {
+		return nil, err
+	}
+
+	return rawDevice.ResourceManagedDevice()
+}
+
+// linuxRawTpmDevice returns the TPM2 raw device at 'path' (ex. /dev/tpm0).
+func linuxRawTpmDevice(path string) (tpm2.TPMDevice, error) {
+	devices, err := linux.ListTPM2Devices()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, device := range devices {
+		if device.Path() == path {
+			return device, nil
+		}
+	}
+
+	return nil, errors.Wrapf(ErrTpmDeviceNotFound, "%q is not a TPM2 device", path)
+}
+
+// newSerializedTpmDevice returns the raw device at 'path' wrapped so that only one
+// connection to it is open at a time (see rawTpmDeviceLock).
+func newSerializedTpmDevice(path string) (tpm2.TPMDevice, error) {
+	device, err := openRawTpmDevice(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &serializedTpmDevice{TPMDevice: device, lock: &rawTpmDeviceLock}, nil
+}
+
+// serializedTpmDevice holds 'lock' from Open until the returned transport is closed,
+// so that concurrent users of the device wait for each other instead of failing.
+type serializedTpmDevice struct {
+	tpm2.TPMDevice
+	lock *sync.Mutex
+}
+
+func (d *serializedTpmDevice) Open() (tpm2.Transport, error) {
+	d.lock.Lock()
+
+	transport, err := d.TPMDevice.Open()
+	if err != nil {
+		d.lock.Unlock()
+		return nil, err
+	}
+
+	return &serializedTransport{Transport: transport, lock: d.lock}, nil
+}
+
+type serializedTransport struct {
+	tpm2.Transport
+	lock   *sync.Mutex
+	closed sync.Once
+}
+
+func (t *serializedTransport) Close() error {
+	err := t.Transport.Close()
+	t.closed.Do(t.lock.Unlock)
+	return err
+}
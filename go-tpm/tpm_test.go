@@ -12,7 +12,9 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"flag"
+	"io"
 	"math/big"
+	"os"
 	"testing"
 	"time"
 
@@ -246,6 +248,18 @@ func TestTpmParseDevice(t *testing.T) {
 			TpmDeviceLinux,
 			false,
 		},
+		{
+			"Test auto device",
+			autoString,
+			TpmDeviceAuto,
+			false,
+		},
+		{
+			"Test linux raw device",
+			linuxRawString,
+			TpmDeviceLinuxRaw,
+			false,
+		},
 		{
 			"Test unknown device",
 			"xyz",
@@ -278,3 +292,140 @@ func TestTpmParseDevice(t *testing.T) {
 		})
 	}
 }
+
+func TestSelectTpmDeviceNode(t *testing.T) {
+	testData := []struct {
+		testName     string
+		existing     []string
+		expectedNode string
+		expectError  bool
+	}{
+		{
+			"Test resource managed device preferred",
+			[]string{tpmResourceManagedDeviceNode, tpmRawDeviceNode},
+			tpmResourceManagedDeviceNode,
+			false,
+		},
+		{
+			"Test fallback to raw device",
+			[]string{tpmRawDeviceNode},
+			tpmRawDeviceNode,
+			false,
+		},
+		{
+			"Test no device",
+			nil,
+			"",
+			true,
+		},
+	}
+
+	defer func() { statTpmDeviceNode = os.Stat }()
+
+	for _, td := range testData {
+		t.Run(td.testName, func(t *testing.T) {
+			statTpmDeviceNode = func(name string) (os.FileInfo, error) {
+				for _, node := range td.existing {
+					if node == name {
+						return nil, nil
+					}
+				}
+				return nil, os.ErrNotExist
+			}
+
+			node, err := selectTpmDeviceNode()
+			if td.expectError {
+				if !errors.Is(err, ErrTpmDeviceNotFound) {
+					t.Fatalf("Expected ErrTpmDeviceNotFound, got %v", err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if node != td.expectedNode {
+				t.Fatalf("Expected node %s, got %s", td.expectedNode, node)
+			}
+		})
+	}
+}
+
+func TestTpmFactoryAutoNoDevice(t *testing.T) {
+	defer func() { statTpmDeviceNode = os.Stat }()
+	statTpmDeviceNode = func(string) (os.FileInfo, error) {
+		return nil, os.ErrNotExist
+	}
+
+	_, err := NewTpmFactory().New(TpmDeviceAuto, "")
+	if !errors.Is(err, ErrTpmDeviceNotFound) {
+		t.Fatalf("Expected ErrTpmDeviceNotFound, got %v", err)
+	}
+}
+
+type fakeTpmDevice struct {
+	opened chan struct{}
+}
+
+func (d *fakeTpmDevice) Open() (tpm2.Transport, error) {
+	d.opened <- struct{}{}
+	return &fakeTpmTransport{}, nil
+}
+
+func (d *fakeTpmDevice) String() string {
+	return "fake"
+}
+
+type fakeTpmTransport struct{}
+
+func (t *fakeTpmTransport) Read(p []byte) (int, error)  { return 0, io.EOF }
+func (t *fakeTpmTransport) Write(p []byte) (int, error) { return len(p), nil }
+func (t *fakeTpmTransport) Close() error                { return nil }
+
+func TestTpmFactoryLinuxRaw(t *testing.T) {
+	device := &fakeTpmDevice{opened: make(chan struct{}, 2)}
+	openedPath := ""
+
+	defer func() { openRawTpmDevice = linuxRawTpmDevice }()
+	openRawTpmDevice = func(path string) (tpm2.TPMDevice, error) {
+		openedPath = path
+		return device, nil
+	}
+
+	first, err := NewTpmFactory().New(TpmDeviceLinuxRaw, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-device.opened
+
+	if openedPath != tpmRawDeviceNode {
+		t.Fatalf("Expected the factory to open %s, got %s", tpmRawDeviceNode, openedPath)
+	}
+
+	// the second connection must wait until the first one is closed
+	secondErr := make(chan error, 1)
+	go func() {
+		second, err := NewTpmFactory().New(TpmDeviceLinuxRaw, "")
+		if err == nil {
+			second.Close()
+		}
+		secondErr <- err
+	}()
+
+	select {
+	case <-device.opened:
+		t.Fatal("The raw device was opened while another connection was open")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	first.Close()
+
+	select {
+	case <-device.opened:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The raw device was not opened after the first connection was closed")
+	}
+
+	if err := <-secondErr; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -84,7 +84,10 @@ func AesDecrypt(cipherText, key []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
-func parsePcrSelections(args string) ([]PcrSelection, error) {
+// ParsePcrSelections parses tpm2-tools style PCR selection strings (ex. "sha1:1,2,3+sha256:all").
+// PCRs can also be provided as ranges (ex. "sha256:0-7").  When 'args' is empty, all
+// sha256 PCRs are selected.
+func ParsePcrSelections(args string) ([]PcrSelection, error) {
 	pcrSelections := []PcrSelection{}
 
 	if args == "" {
@@ -129,6 +132,19 @@ func parsePcrSelections(args string) ([]PcrSelection, error) {
 				continue
 			}
 
+			// ex. "sha256:0-7" (add banks 0 through 7)
+			if first, last, isRange := strings.Cut(str, "-"); isRange {
+				start, startErr := strconv.Atoi(first)
+				end, endErr := strconv.Atoi(last)
+				if startErr != nil || endErr != nil || start < 0 || end > 23 || start > end {
+					return nil, errors.Errorf("Invalid PCR bank range %q", str)
+				}
+				for i := start; i <= end; i++ {
+					pcrSelection.Pcrs = append(pcrSelection.Pcrs, i)
+				}
+				continue
+			}
+
 			bank, err := strconv.Atoi(str)
 			if err != nil {
 				return nil, errors.Errorf("Failed to parse PCR bank %q", str)
@@ -144,3 +160,24 @@ func parsePcrSelections(args string) ([]PcrSelection, error) {
 
 	return pcrSelections, nil
 }
+
+// hashAlgorithmName returns the tpm2-tools style name of 'hashAlgorithm' (ex. "sha256").
+func hashAlgorithmName(hashAlgorithm crypto.Hash) (string, error) {
+	switch hashAlgorithm {
+	case crypto.SHA256:
+		return "sha256", nil
+	case crypto.SHA384:
+		return "sha384", nil
+	case crypto.SHA512:
+		return "sha512", nil
+	default:
+		return "", errors.Errorf("Unsupported hash algorithm %v", hashAlgorithm)
+	}
+}
+
+// digest returns the hash of 'data' using 'hashAlgorithm'.
+func digest(hashAlgorithm crypto.Hash, data []byte) []byte {
+	h := hashAlgorithm.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -60,6 +60,21 @@ var testPcrSelections = map[string][]PcrSelection{
 			Pcrs: []int{1, 2, 3},
 		},
 	},
+	"sha256:0-7": []PcrSelection{
+		{
+			Hash: crypto.SHA256,
+			Pcrs: []int{0, 1, 2, 3, 4, 5, 6, 7},
+		},
+	},
+	"sha256:0,4-6,23": []PcrSelection{
+		{
+			Hash: crypto.SHA256,
+			Pcrs: []int{0, 4, 5, 6, 23},
+		},
+	},
+	"sha256:7-0":  nil, // invalid PCR range
+	"sha256:0-24": nil, // PCR range out of bounds
+	"sha256:x-7":  nil, // not a number range
 	"sha1:400":    nil, // invalid PCR number
 	"sha43:1,2,3": nil, // invalid hash algorithm
 	"sha1:x,2,3":  nil, // not a number string
@@ -68,7 +83,7 @@ var testPcrSelections = map[string][]PcrSelection{
 func TestUtilParsePcrSelections(t *testing.T) {
 
 	for arg, expected := range testPcrSelections {
-		selections, err := parsePcrSelections(arg)
+		selections, err := ParsePcrSelections(arg)
 
 		// if nil was specified in testPcrSelections, then an error
 		// is expected (continue)
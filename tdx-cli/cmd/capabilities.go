@@ -0,0 +1,73 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/intel/trustauthority-client/go-connector"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// detectCapabilities probes the host for the available evidence types (replaced
+// by unit tests to fake the host's devices).
+var detectCapabilities = connector.DetectCapabilities
+
+func newCapabilitiesCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   constants.CapabilitiesCmd,
+		Short: "Displays the types of evidence the host can provide for attestation",
+		Long: `Use this command to determine which evidence types (ex. TDX, TPM, SEV-SNP or
+ NVIDIA GPU) are available on the host.  The results are displayed in json format.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			j, err := json.MarshalIndent(detectCapabilities(), "", " ")
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), string(j))
+			return nil
+		},
+	}
+}
+
+// autoSelectEvidence returns whether TDX and/or TPM evidence should be included based
+// on the host's capabilities (i.e., for the --auto option).  The selection is written
+// to 'w'.  An error is returned if the host does not support any of the evidence types
+// provided by the CLI.
+func autoSelectEvidence(w io.Writer) (withTdx bool, withTpm bool, err error) {
+	capabilities := detectCapabilities()
+
+	var selected []string
+	if capabilities.Tdx {
+		selected = append(selected, "tdx")
+	}
+	if capabilities.Tpm {
+		selected = append(selected, "tpm")
+	}
+
+	// the CLI does not provide SEV-SNP or NVIDIA GPU evidence adapters
+	if capabilities.SevSnp {
+		fmt.Fprintln(w, "SEV-SNP was detected but is not supported by this CLI")
+	}
+	if capabilities.NvGpu {
+		fmt.Fprintln(w, "NVIDIA GPU was detected but is not supported by this CLI")
+	}
+
+	if len(selected) == 0 {
+		return false, false, errors.New("No TDX or TPM evidence is available on the host")
+	}
+
+	fmt.Fprintf(w, "Auto selected evidence: %s\n", strings.Join(selected, ", "))
+	return capabilities.Tdx, capabilities.Tpm, nil
+}
@@ -0,0 +1,157 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/intel/trustauthority-client/go-connector"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeCapabilities replaces capability detection with 'capabilities' until the
+// returned function is called.
+func fakeCapabilities(capabilities connector.Capabilities) func() {
+	detectCapabilities = func() connector.Capabilities {
+		return capabilities
+	}
+
+	return func() {
+		detectCapabilities = connector.DetectCapabilities
+	}
+}
+
+func TestCapabilitiesCmd(t *testing.T) {
+	expected := connector.Capabilities{Tdx: true, Tpm: true}
+	defer fakeCapabilities(expected)()
+
+	var stdout bytes.Buffer
+	cmd := newCapabilitiesCommand()
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{constants.CapabilitiesCmd})
+
+	err := cmd.Execute()
+	assert.NoError(t, err)
+
+	var capabilities connector.Capabilities
+	err = json.Unmarshal(stdout.Bytes(), &capabilities)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, capabilities)
+}
+
+func TestTokenCmdAuto(t *testing.T) {
+	tests := []struct {
+		name          string
+		capabilities  connector.Capabilities
+		expectTdx     bool
+		expectTpm     bool
+		errorExpected bool
+	}{
+		{
+			name:         "TDX and TPM host",
+			capabilities: connector.Capabilities{Tdx: true, Tpm: true},
+			expectTdx:    true,
+			expectTpm:    true,
+		},
+		{
+			name:         "TPM only host",
+			capabilities: connector.Capabilities{Tpm: true},
+			expectTpm:    true,
+		},
+		{
+			name:          "GPU only host",
+			capabilities:  connector.Capabilities{NvGpu: true},
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer fakeCapabilities(tt.capabilities)()
+
+			tdxAdapterFactory := happyMockTdxAdapterFactory().(*MockTdxAdapterFactory)
+			tpmAdapterFactory := happyMockTpmAdapterFactory().(*MockTpmAdapterFactory)
+
+			var stderr bytes.Buffer
+			cmd := newTokenCommand(tdxAdapterFactory, tpmAdapterFactory, mockConfigFactory(nil), happyMockConnectorFactory())
+			cmd.SetErr(&stderr)
+			cmd.SetArgs([]string{
+				constants.TokenCmd,
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+				"--" + constants.AutoOptions.Name,
+			})
+
+			err := cmd.Execute()
+			if tt.errorExpected {
+				assert.Error(t, err)
+				assert.Contains(t, stderr.String(), "NVIDIA GPU was detected")
+				tdxAdapterFactory.AssertNotCalled(t, "New", mock.Anything, mock.Anything)
+				tpmAdapterFactory.AssertNotCalled(t, "New", mock.Anything)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Contains(t, stderr.String(), "Auto selected evidence")
+
+			if tt.expectTdx {
+				tdxAdapterFactory.AssertCalled(t, "New", mock.Anything, mock.Anything)
+			} else {
+				tdxAdapterFactory.AssertNotCalled(t, "New", mock.Anything, mock.Anything)
+			}
+
+			if tt.expectTpm {
+				tpmAdapterFactory.AssertCalled(t, "New", mock.Anything)
+			} else {
+				tpmAdapterFactory.AssertNotCalled(t, "New", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestEvidenceCmdAuto(t *testing.T) {
+	defer fakeCapabilities(connector.Capabilities{Tdx: true, Tpm: true})()
+
+	var stdout, stderr bytes.Buffer
+	cmd := newEvidenceCommand(createDefaultMocks())
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{
+		constants.EvidenceCmd,
+		"--" + constants.ConfigOptions.Name,
+		testNonExistentFileName,
+		"--" + constants.AutoOptions.Name,
+	})
+
+	err := cmd.Execute()
+	assert.NoError(t, err)
+	assert.Contains(t, stderr.String(), "Auto selected evidence: tdx, tpm")
+
+	var evidence map[string]interface{}
+	err = json.Unmarshal(stdout.Bytes(), &evidence)
+	assert.NoError(t, err)
+	assert.Contains(t, evidence, "tdx")
+	assert.Contains(t, evidence, "tpm")
+}
+
+func TestAutoExclusiveOptions(t *testing.T) {
+	cmd := newTokenCommand(createDefaultMocks())
+	cmd.SetArgs([]string{
+		constants.TokenCmd,
+		"--" + constants.ConfigOptions.Name,
+		confFilePath,
+		"--" + constants.AutoOptions.Name,
+		"--" + constants.WithTdxOptions.Name,
+	})
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+}
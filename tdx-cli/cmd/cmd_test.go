@@ -13,8 +13,10 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/binary"
 	"io"
 	"math/big"
+	"testing"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -98,6 +100,15 @@ var (
 	testAesKey          []byte
 )
 
+// newTestQuote returns a minimally sized TD quote with a valid (v4, TDX) header.
+func newTestQuote() []byte {
+	quote := make([]byte, 700)
+	binary.LittleEndian.PutUint16(quote[0:2], 4)    // version
+	binary.LittleEndian.PutUint16(quote[2:4], 2)    // attestation key type
+	binary.LittleEndian.PutUint32(quote[4:8], 0x81) // tee type
+	return quote
+}
+
 func aesEncrypt(plainText, key []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -167,6 +178,31 @@ func (m *MockConnector) AttestEvidence(evidence interface{}, cloudProvider strin
 	return args.Get(0).(connector.AttestResponse), args.Error(1)
 }
 
+func (m *MockConnector) AttestEvidenceStream(evidence interface{}, cloudProvider string, reqId string, onStatus func(connector.AttestStatus)) (connector.AttestResponse, error) {
+	args := m.Called(evidence, cloudProvider, reqId, onStatus)
+	return args.Get(0).(connector.AttestResponse), args.Error(1)
+}
+
+func (m *MockConnector) EvaluateEvidence(evidence interface{}, policyIds []uuid.UUID, cloudProvider string, reqId string) (connector.EvaluateResponse, error) {
+	args := m.Called(evidence, policyIds, cloudProvider, reqId)
+	return args.Get(0).(connector.EvaluateResponse), args.Error(1)
+}
+
+func (m *MockConnector) Ping() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockConnector) GetTokenSigningCaBundle() ([]*x509.Certificate, error) {
+	args := m.Called()
+	return args.Get(0).([]*x509.Certificate), args.Error(1)
+}
+
+func (m *MockConnector) GetCRL(crlUrl string) (*x509.RevocationList, error) {
+	args := m.Called(crlUrl)
+	return args.Get(0).(*x509.RevocationList), args.Error(1)
+}
+
 // MockTpmFactory
 type MockTpmFactory struct {
 	mock.Mock
@@ -177,6 +213,26 @@ func (m *MockTpmFactory) New(deviceType tpm.TpmDeviceType, ownerAuth string) (tp
 	return args.Get(0).(tpm.TrustedPlatformModule), args.Error(1)
 }
 
+// simulatorTpmFactory opens the TPM simulator (mssim) regardless of the device type
+// requested by the command, so that commands can be tested against a real TPM stack.
+type simulatorTpmFactory struct{}
+
+func (f *simulatorTpmFactory) New(deviceType tpm.TpmDeviceType, ownerAuth string) (tpm.TrustedPlatformModule, error) {
+	return tpm.NewTpmFactory().New(tpm.TpmDeviceMSSIM, ownerAuth)
+}
+
+// newSimulatorTpmFactory returns a simulatorTpmFactory or skips the test when the
+// simulator is not running (ex. 'make test' does not start mssim).
+func newSimulatorTpmFactory(t *testing.T) *simulatorTpmFactory {
+	f := &simulatorTpmFactory{}
+	simulator, err := f.New(tpm.TpmDeviceMSSIM, "")
+	if err != nil {
+		t.Skipf("TPM simulator is not available: %v", err)
+	}
+	simulator.Close()
+	return f
+}
+
 // MockTpm
 type MockTpm struct {
 	mock.Mock
@@ -187,7 +243,12 @@ func (m *MockTpm) CreateEK(ekHandle int) error {
 	return args.Error(0)
 }
 func (m *MockTpm) CreateAK(akHandle int, ekHandle int) error {
-	args := m.Called(ekHandle, ekHandle)
+	args := m.Called(akHandle, ekHandle)
+	return args.Error(0)
+}
+
+func (m *MockTpm) EvictHandle(handle int) error {
+	args := m.Called(handle)
 	return args.Error(0)
 }
 
@@ -241,11 +302,21 @@ func (m *MockTpm) GetPcrs(selection ...tpm.PcrSelection) ([]byte, error) {
 	return args.Get(0).([]byte), args.Error(1)
 }
 
+func (m *MockTpm) ReadPcrValues(selection ...tpm.PcrSelection) ([]tpm.PcrValue, error) {
+	args := m.Called(selection)
+	return args.Get(0).([]tpm.PcrValue), args.Error(1)
+}
+
 func (m *MockTpm) HandleExists(handle int) bool {
 	args := m.Called(handle)
 	return args.Get(0).(bool)
 }
 
+func (m *MockTpm) StartEncryptedSession(ekHandle int, ekPublic crypto.PublicKey) error {
+	args := m.Called(ekHandle, ekPublic)
+	return args.Error(0)
+}
+
 func (m *MockTpm) Close() {
 	return
 }
@@ -270,6 +341,11 @@ func (m *MockConfigFactory) LoadConfig(path string) (*Config, error) {
 	return args.Get(0).(*Config), args.Error(1)
 }
 
+func (m *MockConfigFactory) UpdateAkConfig(path string, akHandle int, akCertificateUri string) error {
+	args := m.Called(path, akHandle, akCertificateUri)
+	return args.Error(0)
+}
+
 // MockTdxAdapterFactory
 type MockTdxAdapterFactory struct {
 	mock.Mock
@@ -314,6 +390,7 @@ func happyMockConnectorFactory() connector.ConnectorFactory {
 	mockConnector.On("GetNonce", mock.Anything).Return(connector.GetNonceResponse{}, nil)
 	mockConnector.On("AttestEvidence", mock.Anything, mock.Anything, mock.Anything).Return(connector.AttestResponse{}, nil)
 	mockConnector.On("VerifyToken", mock.Anything).Return(&jwt.Token{}, nil)
+	mockConnector.On("Ping").Return(nil)
 
 	mockConnectorFactory := MockConnectorFactory{}
 	mockConnectorFactory.On("NewConnector", mock.Anything).Return(&mockConnector, nil)
@@ -10,16 +10,44 @@ import (
 	"bytes"
 	"encoding/json"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/intel/trustauthority-client/go-connector"
 	"github.com/pkg/errors"
 )
 
+const (
+	// ConfigEnvVar is the environment variable with the path of the config file that is
+	// used when --config is not provided.
+	ConfigEnvVar = "TRUSTAUTHORITY_CONFIG"
+
+	localConfigFile = "config.json"
+	homeConfigFile  = "~/.tdx-cli.json"
+)
+
+var ErrConfigNotFound = errors.New("A config file was not provided with --config and was not found in the default search path")
+
 type Config struct {
 	CloudProvider        string     `json:"cloud_provider"`
 	TrustAuthorityUrl    string     `json:"trustauthority_url"`
 	TrustAuthorityApiUrl string     `json:"trustauthority_api_url"`
 	TrustAuthorityApiKey string     `json:"trustauthority_api_key"`
 	Tpm                  *TpmConfig `json:"tpm,omitempty"`
+	Tls                  *TlsConfig `json:"tls,omitempty"`
+}
+
+// TlsConfig is the TLS policy applied to all of the CLI's connections to the Trust
+// Authority.  Fields that are not provided use the secure defaults of
+// connector.DefaultTlsConfig.
+type TlsConfig struct {
+	// MinVersion is the minimum TLS version ("1.2" or "1.3"), it can be overridden
+	// with the --tls-min-version option.
+	MinVersion string `json:"min_version"`
+	// CipherSuites is the list of TLS 1.2 cipher suite names (ex.
+	// "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384").  Insecure cipher suites are rejected and
+	// TLS 1.3 cipher suites are not configurable.
+	CipherSuites []string `json:"cipher_suites"`
 }
 
 type TpmConfig struct {
@@ -38,6 +66,11 @@ type TpmConfig struct {
 
 type ConfigFactory interface {
 	LoadConfig(configFile string) (*Config, error)
+
+	// UpdateAkConfig sets the 'ak_handle' and 'ak_certificate' of the tpm configuration
+	// in 'configFile' (see rotate-ak).  The other fields of the config file are not
+	// changed.
+	UpdateAkConfig(configFile string, akHandle int, akCertificateUri string) error
 }
 
 func NewConfigFactory() ConfigFactory {
@@ -46,10 +79,13 @@ func NewConfigFactory() ConfigFactory {
 
 type configFactory struct{}
 
+// LoadConfig reads the config from 'configFile'.  When 'configFile' is empty, the first
+// config file found in the default search path is used (see findConfigFile).  The api key
+// is read from the --api-key-file option when provided.
 func (c *configFactory) LoadConfig(configFile string) (*Config, error) {
-	configFilePath, err := ValidateFilePath(configFile)
+	configFilePath, err := resolveConfigFile(configFile)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Invalid config file path %q provided", configFile)
+		return nil, err
 	}
 	configJson, err := os.ReadFile(configFilePath)
 	if err != nil {
@@ -62,9 +98,129 @@ func (c *configFactory) LoadConfig(configFile string) (*Config, error) {
 
 	}
 
+	if apiKeyFile != "" {
+		cfg.TrustAuthorityApiKey, err = readApiKeyFile(apiKeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return cfg, nil
 }
 
+// UpdateAkConfig rewrites the config file by replacing it with a temporary file so that
+// the config is never partially written.
+func (c *configFactory) UpdateAkConfig(configFile string, akHandle int, akCertificateUri string) error {
+	configFilePath, err := resolveConfigFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	configJson, err := os.ReadFile(configFilePath)
+	if err != nil {
+		return errors.Wrapf(err, "Error reading config file %q", configFile)
+	}
+
+	// the config is updated as raw json so that fields from other sources (ex. the
+	// --api-key-file option) are not written to the file
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(configJson, &fields); err != nil {
+		return errors.Wrap(ErrMalformedJson, err.Error())
+	}
+
+	tpmFields := map[string]json.RawMessage{}
+	if tpmJson, ok := fields["tpm"]; ok {
+		if err = json.Unmarshal(tpmJson, &tpmFields); err != nil {
+			return errors.Wrap(ErrMalformedJson, err.Error())
+		}
+	}
+
+	if tpmFields["ak_handle"], err = json.Marshal(HexInt(akHandle)); err != nil {
+		return err
+	}
+
+	if tpmFields["ak_certificate"], err = json.Marshal(akCertificateUri); err != nil {
+		return err
+	}
+
+	if fields["tpm"], err = json.Marshal(tpmFields); err != nil {
+		return err
+	}
+
+	configJson, err = json.MarshalIndent(fields, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(configFilePath)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(configFilePath), filepath.Base(configFilePath)+".*")
+	if err != nil {
+		return errors.Wrapf(err, "Failed to update config file %q", configFile)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(append(configJson, '\n'))
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Failed to update config file %q", configFile)
+	}
+
+	if err = os.Chmod(tmpFile.Name(), info.Mode().Perm()); err != nil {
+		return errors.Wrapf(err, "Failed to update config file %q", configFile)
+	}
+
+	if err = os.Rename(tmpFile.Name(), configFilePath); err != nil {
+		return errors.Wrapf(err, "Failed to update config file %q", configFile)
+	}
+
+	return nil
+}
+
+// resolveConfigFile returns the validated path of 'configFile' or, when it is empty,
+// the first config file found in the default search path (see findConfigFile).
+func resolveConfigFile(configFile string) (string, error) {
+	var err error
+	if configFile == "" {
+		configFile, err = findConfigFile()
+		if err != nil {
+			return "", err
+		}
+	} else {
+		configFile, err = expandPath(configFile)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	configFilePath, err := ValidateFilePath(configFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "Invalid config file path %q provided", configFile)
+	}
+
+	return configFilePath, nil
+}
+
+// readApiKeyFile returns the api key from the --api-key-file option.
+func readApiKeyFile(keyFile string) (string, error) {
+	keyFilePath, err := ValidateFilePath(keyFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "Invalid api key file path %q provided", keyFile)
+	}
+
+	var connectorCfg connector.Config
+	if err = connector.WithApiKeyFromFile(keyFilePath)(&connectorCfg); err != nil {
+		return "", errors.Wrapf(err, "Error reading api key file %q", keyFile)
+	}
+
+	return connectorCfg.ApiKey, nil
+}
+
 func newConfig(configJson []byte) (*Config, error) {
 	var config Config
 	dec := json.NewDecoder(bytes.NewReader(configJson))
@@ -76,3 +232,47 @@ func newConfig(configJson []byte) (*Config, error) {
 
 	return &config, nil
 }
+
+// findConfigFile returns the first config file that exists in the default search path:
+// $TRUSTAUTHORITY_CONFIG, ./config.json and ~/.tdx-cli.json.  An error is returned if
+// $TRUSTAUTHORITY_CONFIG refers to a file that does not exist.
+func findConfigFile() (string, error) {
+	if envPath := os.Getenv(ConfigEnvVar); envPath != "" {
+		configFile, err := expandPath(envPath)
+		if err != nil {
+			return "", err
+		}
+
+		if _, err = os.Stat(configFile); err != nil {
+			return "", errors.Wrapf(err, "Invalid config file %q provided by %s", envPath, ConfigEnvVar)
+		}
+		return configFile, nil
+	}
+
+	for _, path := range []string{localConfigFile, homeConfigFile} {
+		configFile, err := expandPath(path)
+		if err != nil {
+			return "", err
+		}
+
+		if _, err = os.Stat(configFile); err == nil {
+			return configFile, nil
+		}
+	}
+
+	return "", ErrConfigNotFound
+}
+
+// expandPath replaces a leading "~" with the user's home directory and expands
+// environment variables (ex. $HOME) in 'path'.
+func expandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrapf(err, "Failed to expand %q", path)
+		}
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+
+	return os.ExpandEnv(path), nil
+}
@@ -9,6 +9,7 @@ package cmd
 import (
 	"errors"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -241,3 +242,215 @@ func TestConfigPath(t *testing.T) {
 		})
 	}
 }
+
+func TestFindConfigFile(t *testing.T) {
+	workDir := t.TempDir()
+	homeDir := t.TempDir()
+
+	envConfig := filepath.Join(t.TempDir(), "env.json")
+	localConfig := filepath.Join(workDir, localConfigFile)
+	homeConfig := filepath.Join(homeDir, ".tdx-cli.json")
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	if err = os.Chdir(workDir); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", homeDir)
+
+	testData := []struct {
+		testName     string
+		envPath      string
+		files        []string
+		expectedPath string
+		expectError  bool
+	}{
+		{"Environment variable first", envConfig, []string{envConfig, localConfig, homeConfig}, envConfig, false},
+		{"Environment variable with $HOME", "$HOME/.tdx-cli.json", []string{localConfig, homeConfig}, homeConfig, false},
+		{"Missing environment variable file", envConfig, []string{localConfig, homeConfig}, "", true},
+		{"Local config before home config", "", []string{localConfig, homeConfig}, localConfigFile, false},
+		{"Home config", "", []string{homeConfig}, homeConfig, false},
+		{"No config", "", nil, "", true},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.testName, func(t *testing.T) {
+			t.Setenv(ConfigEnvVar, tc.envPath)
+
+			for _, f := range tc.files {
+				if err := os.WriteFile(f, []byte("{}"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				defer os.Remove(f)
+			}
+
+			configFile, err := findConfigFile()
+			if tc.expectError {
+				if err == nil {
+					t.Fatalf("Expected an error, got config file %q", configFile)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if configFile != tc.expectedPath {
+				t.Errorf("Expected config file %q, got %q", tc.expectedPath, configFile)
+			}
+		})
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+
+	testData := map[string]string{
+		"~":                   homeDir,
+		"~/.tdx-cli.json":     filepath.Join(homeDir, ".tdx-cli.json"),
+		"$HOME/.tdx-cli.json": filepath.Join(homeDir, ".tdx-cli.json"),
+		"/etc/config.json":    "/etc/config.json",
+		"config.json":         "config.json",
+		"~user/.tdx-cli.json": "~user/.tdx-cli.json",
+	}
+
+	for path, expected := range testData {
+		expanded, err := expandPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expanded != expected {
+			t.Errorf("Expected %q to expand to %q, got %q", path, expected, expanded)
+		}
+	}
+}
+
+func TestLoadConfigSearchPath(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv(ConfigEnvVar, "")
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(filepath.Join(homeDir, ".tdx-cli.json"), []byte(`{"trustauthority_url": "https://notused.com:8080"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewConfigFactory().LoadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.TrustAuthorityUrl != "https://notused.com:8080" {
+		t.Errorf("Expected the config from the home directory, got %+v", cfg)
+	}
+
+	// an explicit path is also tilde expanded
+	cfg, err = NewConfigFactory().LoadConfig("~/.tdx-cli.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.TrustAuthorityUrl != "https://notused.com:8080" {
+		t.Errorf("Expected the config from the home directory, got %+v", cfg)
+	}
+}
+
+func TestLoadConfigApiKeyFile(t *testing.T) {
+	defer func() { apiKeyFile = "" }()
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.json")
+	err := os.WriteFile(configFile, []byte(`{"trustauthority_api_key": "Y29uZmlna2V5"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyFile := filepath.Join(dir, "apikey")
+	if err = os.WriteFile(keyFile, []byte(testApiKey+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	apiKeyFile = keyFile
+	cfg, err := NewConfigFactory().LoadConfig(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.TrustAuthorityApiKey != testApiKey {
+		t.Errorf("Expected the api key from %q, got %q", keyFile, cfg.TrustAuthorityApiKey)
+	}
+
+	for _, invalidPath := range []string{filepath.Join(dir, "missing"), dir, "/tmp/key\x00file"} {
+		apiKeyFile = invalidPath
+		if _, err = NewConfigFactory().LoadConfig(configFile); err == nil {
+			t.Errorf("Expected an error for api key file %q", invalidPath)
+		}
+	}
+}
+
+func TestUpdateAkConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(configFile, []byte(`{
+		"trustauthority_api_url": "https://notused.com:8080",
+		"trustauthority_api_key": "YXBpa2V5",
+		"tpm": {
+			"ak_handle": "0x81000801",
+			"owner_auth": "password",
+			"ak_certificate": "nvram://0x01C101D0"
+		}
+	}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = NewConfigFactory().UpdateAkConfig(configFile, 0x81000802, "nvram://0x1c101d1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewConfigFactory().LoadConfig(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Config{
+		TrustAuthorityApiUrl: "https://notused.com:8080",
+		TrustAuthorityApiKey: "YXBpa2V5",
+		Tpm: &TpmConfig{
+			AkHandle:         HexInt(0x81000802),
+			OwnerAuth:        "password",
+			AkCertificateUri: "nvram://0x1c101d1",
+		},
+	}
+	if !reflect.DeepEqual(*cfg, expected) {
+		t.Errorf("Expected config %+v, got %+v", expected, *cfg)
+	}
+
+	info, err := os.Stat(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the config file's permissions to be preserved, got %v", info.Mode().Perm())
+	}
+
+	if err = NewConfigFactory().UpdateAkConfig(filepath.Join(t.TempDir(), "missing.json"), 0x81000802, "nvram://0x1c101d1"); err == nil {
+		t.Error("Expected an error for a missing config file")
+	}
+}
@@ -0,0 +1,30 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"github.com/intel/trustauthority-client/go-connector"
+	"github.com/sirupsen/logrus"
+)
+
+// retryLoggingConnectorFactory creates connectors that log the retries of requests to
+// Trust Authority at debug level (i.e., when --verbose or --log-level debug is set).
+type retryLoggingConnectorFactory struct {
+	ctrFactory connector.ConnectorFactory
+}
+
+func newRetryLoggingConnectorFactory(ctrFactory connector.ConnectorFactory) connector.ConnectorFactory {
+	return &retryLoggingConnectorFactory{ctrFactory: ctrFactory}
+}
+
+func (f *retryLoggingConnectorFactory) NewConnector(config *connector.Config) (connector.Connector, error) {
+	cfg := *config
+	if cfg.HttpRetryLogger == nil {
+		cfg.HttpRetryLogger = logrus.StandardLogger()
+	}
+	return f.ctrFactory.NewConnector(&cfg)
+}
@@ -0,0 +1,31 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/intel/trustauthority-client/go-connector"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRetryLoggingConnectorFactory(t *testing.T) {
+	mockConnectorFactory := MockConnectorFactory{}
+	mockConnectorFactory.On("NewConnector", mock.MatchedBy(func(cfg *connector.Config) bool {
+		return cfg.HttpRetryLogger == logrus.StandardLogger() && cfg.ApiUrl == testValidUrl
+	})).Return(&MockConnector{}, nil)
+
+	cfg := connector.Config{ApiUrl: testValidUrl}
+	_, err := newRetryLoggingConnectorFactory(&mockConnectorFactory).NewConnector(&cfg)
+	assert.NoError(t, err)
+	mockConnectorFactory.AssertExpectations(t)
+
+	// the caller's config is not modified
+	assert.Nil(t, cfg.HttpRetryLogger)
+}
@@ -24,7 +24,7 @@ var createKeyPairCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		err := createKeyPair(cmd)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
+			printError(os.Stderr, err)
 			return err
 		}
 		return nil
@@ -25,7 +25,7 @@ var decryptCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		err := decrypt(cmd)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
+			printError(os.Stderr, err)
 			return err
 		}
 		return nil
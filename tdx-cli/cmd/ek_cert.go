@@ -0,0 +1,80 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intel/trustauthority-client/go-tpm"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func newEkCertCommand(tpmFactory tpm.TpmFactory) *cobra.Command {
+	var nvIndexString string
+
+	cmd := cobra.Command{
+		Use:   constants.EkCertCmd,
+		Short: "Displays the host's TPM EK certificate",
+		Long: `Use this command to display the TPM's EK certificate (read from --nv-index) in PEM
+ format along with its subject, issuer and validity, to verify the platform's identity.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nvIndex := tpm.DefaultEkNvIndex
+			if nvIndexString != "" {
+				i, err := strconv.ParseUint(strings.TrimPrefix(nvIndexString, "0x"), 16, 32)
+				if err != nil {
+					return errors.Wrapf(err, "Invalid NV index %q", nvIndexString)
+				}
+				nvIndex = int(i)
+			}
+
+			t, err := tpmFactory.New(tpm.TpmDeviceLinux, "")
+			if err != nil {
+				return errors.Wrap(err, "Failed to create TPM")
+			}
+			defer t.Close()
+
+			ekCert, err := t.GetEKCertificate(nvIndex)
+			if err != nil {
+				return errors.Wrapf(tpm.WrapTpmError(err), "Failed to read EK certificate at nv index 0x%x", nvIndex)
+			}
+
+			return printEkCertificate(cmd.OutOrStdout(), ekCert)
+		},
+	}
+
+	cmd.Flags().StringVar(&nvIndexString, constants.NvIndexOptions.Name, "", constants.NvIndexOptions.Description)
+
+	return &cmd
+}
+
+// printEkCertificate writes the subject, issuer and validity of 'ekCert' to 'w' followed
+// by the certificate in PEM format.
+func printEkCertificate(w io.Writer, ekCert *x509.Certificate) error {
+	pemBytes := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: ekCert.Raw,
+	})
+	if pemBytes == nil {
+		return errors.New("Failed to encode EK certificate to PEM")
+	}
+
+	fmt.Fprintln(w, "Subject:", ekCert.Subject)
+	fmt.Fprintln(w, "Issuer:", ekCert.Issuer)
+	fmt.Fprintln(w, "Not Before:", ekCert.NotBefore.UTC().Format(time.RFC3339))
+	fmt.Fprintln(w, "Not After:", ekCert.NotAfter.UTC().Format(time.RFC3339))
+	fmt.Fprint(w, string(pemBytes))
+	return nil
+}
@@ -0,0 +1,141 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/intel/trustauthority-client/go-tpm"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestEkCertCmd(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []string
+		expectedNvIndex int
+	}{
+		{
+			name:            "Default NV index",
+			args:            []string{},
+			expectedNvIndex: tpm.DefaultEkNvIndex,
+		},
+		{
+			name:            "Provided NV index",
+			args:            []string{"--" + constants.NvIndexOptions.Name, "0x01c0000a"},
+			expectedNvIndex: 0x01c0000a,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTpm := MockTpm{}
+			mockTpm.On("GetEKCertificate", tt.expectedNvIndex).Return(testCertificate, nil)
+
+			mockTpmFactory := MockTpmFactory{}
+			mockTpmFactory.On("New", tpm.TpmDeviceLinux, "").Return(&mockTpm, nil)
+
+			var stdout bytes.Buffer
+			cmd := newEkCertCommand(&mockTpmFactory)
+			cmd.SetOut(&stdout)
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			assert.NoError(t, err)
+
+			output := stdout.String()
+			assert.Contains(t, output, "Subject: CN=Amber\n")
+			assert.Contains(t, output, "Issuer: CN=Amber\n")
+			assert.Contains(t, output, "Not Before: "+testCertificate.NotBefore.UTC().Format(time.RFC3339)+"\n")
+			assert.Contains(t, output, "Not After: "+testCertificate.NotAfter.UTC().Format(time.RFC3339)+"\n")
+
+			// the PEM follows the parsed fields
+			block, _ := pem.Decode(stdout.Bytes())
+			if assert.NotNil(t, block) {
+				cert, err := x509.ParseCertificate(block.Bytes)
+				assert.NoError(t, err)
+				assert.Equal(t, testCertificate.Raw, cert.Raw)
+			}
+		})
+	}
+}
+
+func TestEkCertCmdSimulator(t *testing.T) {
+	var stdout bytes.Buffer
+	cmd := newEkCertCommand(newSimulatorTpmFactory(t))
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{})
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := stdout.String()
+	assert.Contains(t, output, "Subject: ")
+	assert.Contains(t, output, "Issuer: ")
+
+	block, _ := pem.Decode(stdout.Bytes())
+	if assert.NotNil(t, block) {
+		_, err := x509.ParseCertificate(block.Bytes)
+		assert.NoError(t, err)
+	}
+}
+
+func TestEkCertCmdErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		mockTpmFactory func() *MockTpmFactory
+	}{
+		{
+			name: "Invalid NV index",
+			args: []string{"--" + constants.NvIndexOptions.Name, "0xnothex"},
+			mockTpmFactory: func() *MockTpmFactory {
+				return &MockTpmFactory{}
+			},
+		},
+		{
+			name: "TPM open failure",
+			args: []string{},
+			mockTpmFactory: func() *MockTpmFactory {
+				mockTpmFactory := MockTpmFactory{}
+				mockTpmFactory.On("New", mock.Anything, mock.Anything).Return(&MockTpm{}, errors.New("Unit test failure"))
+				return &mockTpmFactory
+			},
+		},
+		{
+			name: "EK certificate read failure",
+			args: []string{},
+			mockTpmFactory: func() *MockTpmFactory {
+				mockTpm := MockTpm{}
+				mockTpm.On("GetEKCertificate", mock.Anything).Return(&x509.Certificate{}, errors.New("Unit test failure"))
+
+				mockTpmFactory := MockTpmFactory{}
+				mockTpmFactory.On("New", mock.Anything, mock.Anything).Return(&mockTpm, nil)
+				return &mockTpmFactory
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newEkCertCommand(tt.mockTpmFactory())
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			assert.Error(t, err)
+		})
+	}
+}
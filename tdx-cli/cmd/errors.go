@@ -6,9 +6,109 @@
 
 package cmd
 
-import "github.com/pkg/errors"
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/intel/trustauthority-client/go-connector"
+	"github.com/pkg/errors"
+)
 
 var (
 	ErrInvalidFilePath = errors.New("Invalid invalid file path provided")
 	ErrMalformedJson   = errors.New("Malformed JSON provided")
 )
+
+// Error codes included in the json error output (see --json-errors)
+const (
+	ErrorCodeUnknown     = "unknown_error"
+	ErrorCodeConfig      = "config_error"
+	ErrorCodeAttestation = "attestation_error"
+)
+
+// codedError associates an error code and, when available, the Trust Authority
+// trace-id with an error so that failures can be classified by automation.
+type codedError struct {
+	code    string
+	traceId string
+	err     error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// withErrorCode returns an error that includes the code and trace-id in json
+// error output.
+func withErrorCode(err error, code string, traceId string) error {
+	return &codedError{
+		code:    code,
+		traceId: traceId,
+		err:     err,
+	}
+}
+
+// jsonError is the format of errors written to stderr when --json-errors is set.
+type jsonError struct {
+	Error   string `json:"error"`
+	Code    string `json:"code"`
+	TraceId string `json:"trace_id"`
+}
+
+// printError writes 'err' to 'w' as free text unless --json-errors is set (in
+// which case the error is written by executeCommand).
+func printError(w io.Writer, err error) {
+	if !jsonErrors {
+		fmt.Fprintln(w, err.Error())
+	}
+}
+
+// writeJsonError writes 'err' to 'w' as a jsonError object.
+func writeJsonError(w io.Writer, err error) {
+	jsonErr := jsonError{
+		Error: err.Error(),
+		Code:  ErrorCodeUnknown,
+	}
+
+	var ce *codedError
+	if errors.As(err, &ce) {
+		jsonErr.Code = ce.code
+		jsonErr.TraceId = ce.traceId
+	} else if isConnectorConfigError(err) {
+		jsonErr.Code = ErrorCodeConfig
+	}
+
+	b, marshalErr := json.Marshal(jsonErr)
+	if marshalErr != nil {
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+
+	fmt.Fprintln(w, string(b))
+}
+
+// isConnectorConfigError returns true when the connector could not be created
+// due to invalid Trust Authority urls or api key in the configuration.
+func isConnectorConfigError(err error) bool {
+	return errors.Is(err, connector.ErrInvalidBaseUrl) ||
+		errors.Is(err, connector.ErrInvalidApiUrl) ||
+		errors.Is(err, connector.ErrMissingApiKey) ||
+		errors.Is(err, connector.ErrInvalidApiKey) ||
+		errors.Is(err, connector.ErrInvalidApiKeyFile)
+}
+
+// withAuthGuidance adds guidance to errors caused by Trust Authority rejecting the
+// api key (401) or denying it access (403).
+func withAuthGuidance(err error) error {
+	if errors.Is(err, connector.ErrUnauthorized) {
+		return errors.Wrap(err, "Check that 'trustauthority_api_key' is valid and has not expired")
+	} else if errors.Is(err, connector.ErrForbidden) {
+		return errors.Wrap(err, "The api key does not have access to the requested policies or attestation")
+	}
+	return err
+}
@@ -0,0 +1,191 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/intel/trustauthority-client/go-connector"
+	"github.com/intel/trustauthority-client/go-tpm"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestJsonErrors(t *testing.T) {
+	defer func() { jsonErrors = false }()
+
+	testTraceId := "test-trace-id"
+
+	tt := []struct {
+		description     string
+		expectedCode    string
+		expectedTraceId string
+		dependencyMocks func() (TdxAdapterFactory, tpm.TpmAdapterFactory, ConfigFactory, connector.ConnectorFactory)
+	}{
+		{
+			description:     "Test config load failure",
+			expectedCode:    ErrorCodeConfig,
+			expectedTraceId: "",
+			dependencyMocks: func() (TdxAdapterFactory, tpm.TpmAdapterFactory, ConfigFactory, connector.ConnectorFactory) {
+				angryConfigFactory := MockConfigFactory{}
+				angryConfigFactory.On("LoadConfig", mock.Anything).Return(&Config{}, errors.New("Unit test failure"))
+
+				return happyMockTdxAdapterFactory(), happyMockTpmAdapterFactory(), &angryConfigFactory, happyMockConnectorFactory()
+			},
+		},
+		{
+			description:     "Test attestation failure",
+			expectedCode:    ErrorCodeAttestation,
+			expectedTraceId: testTraceId,
+			dependencyMocks: func() (TdxAdapterFactory, tpm.TpmAdapterFactory, ConfigFactory, connector.ConnectorFactory) {
+				headers := http.Header{}
+				headers.Set(connector.HeaderTraceId, testTraceId)
+
+				angryConnector := MockConnector{}
+				angryConnector.On("GetNonce", mock.Anything).Return(connector.GetNonceResponse{}, nil)
+				angryConnector.On("AttestEvidence", mock.Anything, mock.Anything, mock.Anything).Return(connector.AttestResponse{Headers: headers}, errors.New("Unit test failure"))
+
+				angryConnectorFactory := MockConnectorFactory{}
+				angryConnectorFactory.On("NewConnector", mock.Anything).Return(&angryConnector, nil)
+
+				return happyMockTdxAdapterFactory(), happyMockTpmAdapterFactory(), mockConfigFactory(nil), &angryConnectorFactory
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			var stderr bytes.Buffer
+
+			root := &cobra.Command{Use: constants.RootCmd}
+			initRootCommand(root)
+			root.AddCommand(newTokenCommand(tc.dependencyMocks()))
+			root.SetErr(&stderr)
+
+			var jsonErrStderr bytes.Buffer
+			err := executeCommand(root, []string{
+				constants.TokenCmd,
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+				"--" + constants.JsonErrorsOptions.Name,
+			}, &jsonErrStderr)
+			assert.Error(t, err)
+
+			var jsonErr map[string]interface{}
+			err = json.Unmarshal(jsonErrStderr.Bytes(), &jsonErr)
+			assert.NoError(t, err)
+
+			assert.Len(t, jsonErr, 3)
+			assert.Contains(t, jsonErr["error"], "Unit test failure")
+			assert.Equal(t, tc.expectedCode, jsonErr["code"])
+			assert.Equal(t, tc.expectedTraceId, jsonErr["trace_id"])
+		})
+	}
+}
+
+func TestJsonErrorsInvalidFlags(t *testing.T) {
+	tt := []struct {
+		description string
+		args        []string
+	}{
+		{"Unknown flag before --json-errors", []string{"test", "--unknown", "--" + constants.JsonErrorsOptions.Name}},
+		{"Unknown flag after --json-errors", []string{"test", "--" + constants.JsonErrorsOptions.Name, "--unknown"}},
+		{"Invalid flag value", []string{"test", "--count", "many", "--" + constants.JsonErrorsOptions.Name}},
+		{"Subcommand hook", []string{"test", "--fail-hook", "--" + constants.JsonErrorsOptions.Name}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			defer func() { jsonErrors = false }()
+
+			var failHook bool
+			root := &cobra.Command{Use: constants.RootCmd}
+			initRootCommand(root)
+			test := &cobra.Command{
+				Use: "test",
+				PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+					if failHook {
+						return errors.New("Unit test failure")
+					}
+					return nil
+				},
+				RunE: func(cmd *cobra.Command, args []string) error {
+					return nil
+				},
+			}
+			test.Flags().Int("count", 0, "")
+			test.Flags().BoolVar(&failHook, "fail-hook", false, "")
+			root.AddCommand(test)
+
+			// cobra does not write the error or usage
+			var stdout, stderr bytes.Buffer
+			root.SetOut(&stdout)
+			root.SetErr(&stderr)
+
+			var jsonErrStderr bytes.Buffer
+			err := executeCommand(root, tc.args, &jsonErrStderr)
+			assert.Error(t, err)
+			assert.Empty(t, stdout.String())
+			assert.Empty(t, stderr.String())
+
+			var jsonErr jsonError
+			err = json.Unmarshal(jsonErrStderr.Bytes(), &jsonErr)
+			assert.NoError(t, err)
+			assert.Equal(t, ErrorCodeUnknown, jsonErr.Code)
+			assert.NotEmpty(t, jsonErr.Error)
+		})
+	}
+}
+
+func TestParseJsonErrors(t *testing.T) {
+	assert.True(t, parseJsonErrors([]string{"token", "--config", "config.json", "--" + constants.JsonErrorsOptions.Name}))
+	assert.True(t, parseJsonErrors([]string{"--" + constants.JsonErrorsOptions.Name, "token", "--tpm"}))
+	assert.False(t, parseJsonErrors([]string{"token", "--" + constants.JsonErrorsOptions.Name + "=false"}))
+	assert.False(t, parseJsonErrors([]string{"token", "--config", "config.json"}))
+}
+
+func TestWriteJsonErrorUnknown(t *testing.T) {
+	var buf bytes.Buffer
+	writeJsonError(&buf, errors.New("Unit test failure"))
+
+	var jsonErr jsonError
+	err := json.Unmarshal(buf.Bytes(), &jsonErr)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrorCodeUnknown, jsonErr.Code)
+	assert.Equal(t, "Unit test failure", jsonErr.Error)
+}
+
+func TestWriteJsonErrorConnectorConfig(t *testing.T) {
+	for _, connectorErr := range []error{connector.ErrInvalidBaseUrl, connector.ErrInvalidApiUrl, connector.ErrMissingApiKey, connector.ErrInvalidApiKey, connector.ErrInvalidApiKeyFile} {
+		var buf bytes.Buffer
+		writeJsonError(&buf, errors.Wrap(connectorErr, "Unit test failure"))
+
+		var jsonErr jsonError
+		err := json.Unmarshal(buf.Bytes(), &jsonErr)
+		assert.NoError(t, err)
+		assert.Equal(t, ErrorCodeConfig, jsonErr.Code)
+	}
+}
+
+func TestWithAuthGuidance(t *testing.T) {
+	err := withAuthGuidance(errors.Wrap(connector.ErrUnauthorized, "Unit test failure"))
+	assert.ErrorIs(t, err, connector.ErrUnauthorized)
+	assert.Contains(t, err.Error(), "trustauthority_api_key")
+
+	err = withAuthGuidance(errors.Wrap(connector.ErrForbidden, "Unit test failure"))
+	assert.ErrorIs(t, err, connector.ErrForbidden)
+	assert.Contains(t, err.Error(), "does not have access")
+
+	err = errors.New("Unit test failure")
+	assert.Equal(t, err, withAuthGuidance(err))
+}
@@ -7,11 +7,12 @@
 package cmd
 
 import (
-	"crypto/tls"
+	"bytes"
 	"encoding/json"
 	"fmt"
 
 	"github.com/intel/trustauthority-client/go-connector"
+	"github.com/intel/trustauthority-client/go-tdx"
 	"github.com/intel/trustauthority-client/go-tpm"
 	"github.com/intel/trustauthority-client/tdx-cli/constants"
 
@@ -19,6 +20,9 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// stdinFileName is provided as a file name to read from stdin (ex. "--quote-file -")
+const stdinFileName = "-"
+
 func newEvidenceCommand(tdxAdapterFactory TdxAdapterFactory,
 	tpmAdapterFactory tpm.TpmAdapterFactory,
 	cfgFactory ConfigFactory,
@@ -26,7 +30,12 @@ func newEvidenceCommand(tdxAdapterFactory TdxAdapterFactory,
 
 	var withTpm bool
 	var withTdx bool
+	var auto bool
 	var tokenSigningAlg string
+	var audience string
+	var tokenType string
+	var evidenceContext string
+	var claims []string
 	var noVerifierNonce bool
 	var configPath string
 	var policiesMustMatch bool
@@ -35,6 +44,8 @@ func newEvidenceCommand(tdxAdapterFactory TdxAdapterFactory,
 	var withImaLogs bool
 	var withEventLogs bool
 	var withCcel bool
+	var requireCcel bool
+	var quoteFile string
 	var builderOptions []connector.EvidenceBuilderOption
 	var ctr connector.Connector
 
@@ -44,13 +55,14 @@ func newEvidenceCommand(tdxAdapterFactory TdxAdapterFactory,
 		Long: `Use this command to output evidence in json format.  The json can be used 
  as the body of a request to the Trust Authority's /appraisal/v2/attest endpoint.
  Multiple attestation types can be combined in the output using the --tpm and --tdx
- options.`,
+ options.  A previously captured TD quote can be used as TDX evidence with the
+ --quote-file option (use "-" to read a binary or base64 encoded quote from stdin).`,
 		SilenceUsage: true,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 
 			cfg, err := cfgFactory.LoadConfig(configPath)
 			if err != nil {
-				return errors.Wrapf(err, "Could not read config file %q", configPath)
+				return withErrorCode(errors.Wrapf(err, "Could not read config file %q", configPath), ErrorCodeConfig, "")
 			}
 
 			userData, err := string2bytes(userData)
@@ -74,6 +86,13 @@ func newEvidenceCommand(tdxAdapterFactory TdxAdapterFactory,
 				builderOptions = append(builderOptions, connector.WithPoliciesMustMatch(policiesMustMatch))
 			}
 
+			if auto {
+				withTdx, withTpm, err = autoSelectEvidence(cmd.ErrOrStderr())
+				if err != nil {
+					return err
+				}
+			}
+
 			if withTpm {
 				if cfg.Tpm == nil {
 					return errors.Errorf("TPM configuration not found in config file %q", configPath)
@@ -96,7 +115,32 @@ func newEvidenceCommand(tdxAdapterFactory TdxAdapterFactory,
 				builderOptions = append(builderOptions, connector.WithEvidenceAdapter(tpmAdapter))
 			}
 
-			if withTdx {
+			if quoteFile == stdinFileName {
+				// read a binary or base64 encoded quote that was piped to stdin
+				quoteAdapter, err := tdx.NewQuoteReaderAdapter(cmd.InOrStdin(), nil)
+				if err != nil {
+					return errors.Wrap(err, "Failed to read quote from stdin")
+				}
+
+				builderOptions = append(builderOptions, connector.WithEvidenceAdapter(quoteAdapter))
+			} else if quoteFile != "" {
+				quoteFilePath, err := ValidateFilePath(quoteFile)
+				if err != nil {
+					return errors.Wrap(err, "Invalid quote file path provided")
+				}
+
+				quoteFileAdapter, err := tdx.NewQuoteFileAdapter(quoteFilePath, nil)
+				if err != nil {
+					return err
+				}
+
+				builderOptions = append(builderOptions, connector.WithEvidenceAdapter(quoteFileAdapter))
+			} else if withTdx {
+				withCcel, err = resolveCcel(cmd.ErrOrStderr(), cfg.CloudProvider, withCcel, requireCcel)
+				if err != nil {
+					return err
+				}
+
 				tdxAdapter, err := tdxAdapterFactory.New(cfg.CloudProvider, withCcel)
 				if err != nil {
 					return errors.Wrap(err, "Error while creating tdx adapter")
@@ -109,20 +153,18 @@ func newEvidenceCommand(tdxAdapterFactory TdxAdapterFactory,
 				// only create the connector if the user has opted to include a verifier
 				// nonce
 				if cfg.TrustAuthorityApiUrl == "" {
-					return errors.New("The Trust Authority API URL must be present in config")
+					return withErrorCode(errors.New("The Trust Authority API URL must be present in config"), ErrorCodeConfig, "")
+				}
+
+				tlsConfig, err := newTlsConfig(cfg.Tls)
+				if err != nil {
+					return withErrorCode(err, ErrorCodeConfig, "")
 				}
 
 				ctr, err = ctrFactory.NewConnector(&connector.Config{
 					ApiUrl: cfg.TrustAuthorityApiUrl,
 					ApiKey: cfg.TrustAuthorityApiKey,
-					TlsCfg: &tls.Config{
-						CipherSuites: []uint16{
-							tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-							tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-						},
-						InsecureSkipVerify: false,
-						MinVersion:         tls.VersionTLS12,
-					},
+					TlsCfg: tlsConfig,
 				})
 				if err != nil {
 					return errors.Wrap(err, "Failed to create connector")
@@ -140,6 +182,35 @@ func newEvidenceCommand(tdxAdapterFactory TdxAdapterFactory,
 				builderOptions = append(builderOptions, connector.WithTokenSigningAlgorithm(signingAlg))
 			}
 
+			if audience != "" {
+				if !connector.ValidateTokenAudience(audience) {
+					return errors.Errorf("%q is not a valid token audience", audience)
+				}
+
+				builderOptions = append(builderOptions, connector.WithTokenAudience(audience))
+			}
+
+			if tokenType != "" {
+				if !connector.ValidateTokenType(tokenType) {
+					return errors.Errorf("%q is not a valid token type", tokenType)
+				}
+
+				builderOptions = append(builderOptions, connector.WithTokenType(tokenType))
+			}
+
+			if evidenceContext != "" {
+				builderOptions = append(builderOptions, connector.WithEvidenceContext(evidenceContext))
+			}
+
+			claimsRequest, err := parseClaimsRequest(claims)
+			if err != nil {
+				return err
+			}
+
+			if claimsRequest != nil {
+				builderOptions = append(builderOptions, connector.WithClaimsRequest(claimsRequest))
+			}
+
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -153,11 +224,16 @@ func newEvidenceCommand(tdxAdapterFactory TdxAdapterFactory,
 				return err
 			}
 
-			j, err := json.MarshalIndent(evidence, "", " ")
+			b, err := connector.MarshalEvidence(evidence)
 			if err != nil {
 				return err
 			}
-			fmt.Println(string(j))
+
+			var j bytes.Buffer
+			if err = json.Indent(&j, b, "", " "); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), j.String())
 			return nil
 		},
 	}
@@ -165,14 +241,24 @@ func newEvidenceCommand(tdxAdapterFactory TdxAdapterFactory,
 	cmd.Flags().StringVarP(&configPath, constants.ConfigOptions.Name, constants.ConfigOptions.ShortHand, "", constants.ConfigOptions.Description)
 	cmd.Flags().BoolVar(&withTpm, constants.WithTpmOptions.Name, false, constants.WithTpmOptions.Description)
 	cmd.Flags().BoolVar(&withTdx, constants.WithTdxOptions.Name, false, constants.WithTdxOptions.Description)
+	cmd.Flags().BoolVar(&auto, constants.AutoOptions.Name, false, constants.AutoOptions.Description)
 	cmd.Flags().BoolVar(&noVerifierNonce, constants.NoVerifierNonceOptions.Name, false, constants.NoVerifierNonceOptions.Description)
 	cmd.Flags().StringVarP(&userData, constants.UserDataOptions.Name, constants.UserDataOptions.ShortHand, "", constants.UserDataOptions.Description)
 	cmd.Flags().StringVarP(&policyIds, constants.PolicyIdsOptions.Name, constants.PolicyIdsOptions.ShortHand, "", constants.PolicyIdsOptions.Description)
 	cmd.Flags().StringVarP(&tokenSigningAlg, constants.TokenAlgOptions.Name, constants.TokenAlgOptions.ShortHand, "", constants.TokenAlgOptions.Description)
 	cmd.Flags().BoolVar(&policiesMustMatch, constants.PolicyMustMatchOptions.Name, false, constants.PolicyMustMatchOptions.Description)
+	cmd.Flags().StringVar(&audience, constants.AudienceOptions.Name, "", constants.AudienceOptions.Description)
+	cmd.Flags().StringVar(&tokenType, constants.TokenTypeOptions.Name, "", constants.TokenTypeOptions.Description)
+	cmd.Flags().StringVar(&evidenceContext, constants.ContextOptions.Name, "", constants.ContextOptions.Description)
+	cmd.Flags().StringArrayVar(&claims, constants.ClaimOptions.Name, nil, constants.ClaimOptions.Description)
 	cmd.Flags().BoolVar(&withImaLogs, constants.WithImaLogsOptions.Name, false, constants.WithImaLogsOptions.Description)
 	cmd.Flags().BoolVar(&withEventLogs, constants.WithEventLogsOptions.Name, false, constants.WithEventLogsOptions.Description)
 	cmd.Flags().BoolVar(&withCcel, constants.WithCcelOptions.Name, false, constants.WithCcelOptions.Description)
+	cmd.Flags().BoolVar(&requireCcel, constants.RequireCcelOptions.Name, false, constants.RequireCcelOptions.Description)
+	cmd.Flags().StringVar(&quoteFile, constants.QuoteFileOptions.Name, "", constants.QuoteFileOptions.Description)
+
+	cmd.MarkFlagsMutuallyExclusive(constants.AutoOptions.Name, constants.WithTdxOptions.Name)
+	cmd.MarkFlagsMutuallyExclusive(constants.AutoOptions.Name, constants.WithTpmOptions.Name)
 
 	return &cmd
 }
@@ -7,6 +7,13 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/intel/trustauthority-client/go-connector"
@@ -42,6 +49,8 @@ func TestEvidence(t *testing.T) {
 				"--" + constants.PolicyIdsOptions.Name,
 				"fe3268d7-1541-4b17-8c85-7bae3d39650f",
 				"--" + constants.PolicyMustMatchOptions.Name,
+				"--" + constants.AudienceOptions.Name,
+				"relying-party",
 			},
 			errorExpected: false,
 		},
@@ -156,6 +165,21 @@ func TestEvidence(t *testing.T) {
 			},
 			errorExpected: true,
 		},
+		{
+			name: "Test Evidence Invalid Audience",
+			dependencyMocks: func() (TdxAdapterFactory, tpm.TpmAdapterFactory, ConfigFactory, connector.ConnectorFactory) {
+				return createDefaultMocks()
+			},
+			cmdArgs: []string{
+				constants.EvidenceCmd,
+				"--" + constants.ConfigOptions.Name,
+				testNonExistentFileName,
+				"--" + constants.WithTdxOptions.Name,
+				"--" + constants.AudienceOptions.Name,
+				"relying party",
+			},
+			errorExpected: true,
+		},
 		{
 			name: "Test Evidence Connector Factory Failure",
 			dependencyMocks: func() (TdxAdapterFactory, tpm.TpmAdapterFactory, ConfigFactory, connector.ConnectorFactory) {
@@ -196,3 +220,258 @@ func TestEvidence(t *testing.T) {
 		})
 	}
 }
+
+func TestEvidenceAudienceAndContext(t *testing.T) {
+	var stdout bytes.Buffer
+
+	cmd := newEvidenceCommand(createDefaultMocks())
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{
+		constants.EvidenceCmd,
+		"--" + constants.ConfigOptions.Name,
+		testNonExistentFileName,
+		"--" + constants.WithTdxOptions.Name,
+		"--" + constants.AudienceOptions.Name,
+		"https://relying-party.example.com",
+		"--" + constants.ContextOptions.Name,
+		"namespace/workload",
+	})
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var evidence map[string]interface{}
+	err = json.Unmarshal(stdout.Bytes(), &evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if evidence["token_audience"] != "https://relying-party.example.com" {
+		t.Errorf("Expected token_audience in evidence, got %v", evidence["token_audience"])
+	}
+
+	if evidence["context"] != "namespace/workload" {
+		t.Errorf("Expected context in evidence, got %v", evidence["context"])
+	}
+}
+
+func TestEvidenceTokenType(t *testing.T) {
+	var stdout bytes.Buffer
+
+	cmd := newEvidenceCommand(createDefaultMocks())
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{
+		constants.EvidenceCmd,
+		"--" + constants.ConfigOptions.Name,
+		testNonExistentFileName,
+		"--" + constants.WithTdxOptions.Name,
+		"--" + constants.TokenTypeOptions.Name,
+		"ear",
+	})
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var evidence map[string]interface{}
+	err = json.Unmarshal(stdout.Bytes(), &evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if evidence["token_type"] != "ear" {
+		t.Errorf("Expected token_type in evidence, got %v", evidence["token_type"])
+	}
+
+	// unknown token types are rejected
+	cmd = newEvidenceCommand(createDefaultMocks())
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{
+		constants.EvidenceCmd,
+		"--" + constants.ConfigOptions.Name,
+		testNonExistentFileName,
+		"--" + constants.WithTdxOptions.Name,
+		"--" + constants.TokenTypeOptions.Name,
+		"jwe",
+	})
+
+	if err = cmd.Execute(); err == nil {
+		t.Error("Expected an error for an unknown token type")
+	}
+}
+
+func TestEvidenceClaimsRequest(t *testing.T) {
+	var stdout bytes.Buffer
+
+	cmd := newEvidenceCommand(createDefaultMocks())
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{
+		constants.EvidenceCmd,
+		"--" + constants.ConfigOptions.Name,
+		testNonExistentFileName,
+		"--" + constants.WithTdxOptions.Name,
+		"--" + constants.ClaimOptions.Name,
+		"environment=prod",
+		"--" + constants.ClaimOptions.Name,
+		"region=us-west-2",
+	})
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var evidence map[string]interface{}
+	err = json.Unmarshal(stdout.Bytes(), &evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{"environment": "prod", "region": "us-west-2"}
+	if !reflect.DeepEqual(evidence["claims_request"], expected) {
+		t.Errorf("Expected claims_request %v in evidence, got %v", expected, evidence["claims_request"])
+	}
+
+	// oversized values are rejected
+	cmd = newEvidenceCommand(createDefaultMocks())
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{
+		constants.EvidenceCmd,
+		"--" + constants.ConfigOptions.Name,
+		testNonExistentFileName,
+		"--" + constants.WithTdxOptions.Name,
+		"--" + constants.ClaimOptions.Name,
+		"tenant=" + strings.Repeat("a", connector.MaxClaimsRequestValueLength+1),
+	})
+
+	if err = cmd.Execute(); err == nil {
+		t.Error("Expected an error for an oversized claim")
+	}
+}
+
+func TestEvidenceQuoteFile(t *testing.T) {
+	quoteFile := filepath.Join(t.TempDir(), "quote.bin")
+	err := os.WriteFile(quoteFile, newTestQuote(), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalidQuoteFile := filepath.Join(t.TempDir(), "invalid.bin")
+	err = os.WriteFile(invalidQuoteFile, []byte("notaquote"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		quoteFile     string
+		errorExpected bool
+	}{
+		{
+			name:          "Test Evidence Quote File Positive",
+			quoteFile:     quoteFile,
+			errorExpected: false,
+		},
+		{
+			name:          "Test Evidence Quote File Does Not Exist",
+			quoteFile:     testNonExistentFileName,
+			errorExpected: true,
+		},
+		{
+			name:          "Test Evidence Quote File Invalid Quote",
+			quoteFile:     invalidQuoteFile,
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newEvidenceCommand(createDefaultMocks())
+			cmd.SetArgs([]string{
+				constants.EvidenceCmd,
+				"--" + constants.ConfigOptions.Name,
+				testNonExistentFileName,
+				"--" + constants.QuoteFileOptions.Name,
+				tt.quoteFile,
+				"--" + constants.NoVerifierNonceOptions.Name,
+			})
+
+			err := cmd.Execute()
+			if err != nil && !tt.errorExpected {
+				t.Errorf("An error occurred but was not expected: %v", err)
+			} else if err == nil && tt.errorExpected {
+				t.Errorf("Expected an error but none occurred")
+			}
+		})
+	}
+}
+
+func TestEvidenceQuoteStdin(t *testing.T) {
+	quote := newTestQuote()
+
+	tests := []struct {
+		name          string
+		stdin         []byte
+		errorExpected bool
+	}{
+		{
+			name:          "Test Evidence Binary Quote From Stdin",
+			stdin:         quote,
+			errorExpected: false,
+		},
+		{
+			name:          "Test Evidence Base64 Quote From Stdin",
+			stdin:         []byte(base64.StdEncoding.EncodeToString(quote)),
+			errorExpected: false,
+		},
+		{
+			name:          "Test Evidence Invalid Quote From Stdin",
+			stdin:         []byte("notaquote"),
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			cmd := newEvidenceCommand(createDefaultMocks())
+			cmd.SetIn(bytes.NewReader(tt.stdin))
+			cmd.SetOut(&stdout)
+			cmd.SetArgs([]string{
+				constants.EvidenceCmd,
+				"--" + constants.ConfigOptions.Name,
+				testNonExistentFileName,
+				"--" + constants.QuoteFileOptions.Name,
+				"-",
+				"--" + constants.NoVerifierNonceOptions.Name,
+			})
+
+			err := cmd.Execute()
+			if tt.errorExpected {
+				if err == nil {
+					t.Errorf("Expected an error but none occurred")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("An error occurred but was not expected: %v", err)
+			}
+
+			var evidence struct {
+				Tdx struct {
+					Quote []byte `json:"quote"`
+				} `json:"tdx"`
+			}
+			err = json.Unmarshal(stdout.Bytes(), &evidence)
+			if err != nil {
+				t.Fatalf("Evidence output was not valid json: %v", err)
+			}
+
+			if !bytes.Equal(evidence.Tdx.Quote, quote) {
+				t.Errorf("Expected the evidence to contain the quote from stdin")
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/intel/trustauthority-client/go-connector"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// The files of a verification bundle (the CRLs are named after their distribution point,
+// see crlFileName).
+const (
+	bundleJwksFile     = "jwks.json"
+	bundleCaBundleFile = "ca-bundle.pem"
+)
+
+func newExportVerificationBundleCommand(cfgFactory ConfigFactory, ctrFactory connector.ConnectorFactory) *cobra.Command {
+	exportCmd := &cobra.Command{
+		Use:   constants.ExportVerificationBundleCmd,
+		Short: "Exports the certificates and CRLs needed to verify tokens without network access",
+		Long: `Use this command to download the Trust Authority's token signing certificates (JWKS),
+ their CA chain and the CRLs of the chain's distribution points to --out-dir.  The bundle
+ can be copied to disconnected hosts to seed the verification of attestation tokens.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := exportVerificationBundle(cmd, cfgFactory, ctrFactory)
+			if err != nil {
+				printError(os.Stderr, err)
+				return err
+			}
+
+			return nil
+		},
+	}
+	exportCmd.Flags().StringP(constants.ConfigOptions.Name, constants.ConfigOptions.ShortHand, "", constants.ConfigOptions.Description)
+	exportCmd.Flags().StringP(constants.OutDirOptions.Name, constants.OutDirOptions.ShortHand, "", constants.OutDirOptions.Description)
+	exportCmd.MarkFlagRequired(constants.OutDirOptions.Name)
+
+	return exportCmd
+}
+
+func exportVerificationBundle(cmd *cobra.Command, cfgFactory ConfigFactory, ctrFactory connector.ConnectorFactory) error {
+	configFile, err := cmd.Flags().GetString(constants.ConfigOptions.Name)
+	if err != nil {
+		return err
+	}
+
+	outDir, err := cmd.Flags().GetString(constants.OutDirOptions.Name)
+	if err != nil {
+		return err
+	}
+
+	config, err := cfgFactory.LoadConfig(configFile)
+	if err != nil {
+		return withErrorCode(errors.Wrapf(err, "Could not read config file %q", configFile), ErrorCodeConfig, "")
+	}
+
+	if config.TrustAuthorityUrl == "" {
+		return withErrorCode(errors.New("Trust Authority URL is missing in config"), ErrorCodeConfig, "")
+	}
+
+	tlsConfig, err := newTlsConfig(config.Tls)
+	if err != nil {
+		return withErrorCode(err, ErrorCodeConfig, "")
+	}
+
+	cfg := connector.Config{
+		TlsCfg:  tlsConfig,
+		BaseUrl: config.TrustAuthorityUrl,
+	}
+
+	trustAuthorityConnector, err := ctrFactory.NewConnector(&cfg)
+	if err != nil {
+		return err
+	}
+
+	jwks, err := trustAuthorityConnector.GetTokenSigningCertificates()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get the token signing certificates")
+	}
+
+	caBundle, err := trustAuthorityConnector.GetTokenSigningCaBundle()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get the token signing CA certificates")
+	}
+
+	crlUrls, err := jwksCrlDistributionPoints(jwks)
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{
+		bundleJwksFile:     jwks,
+		bundleCaBundleFile: encodeCertificatesPem(caBundle),
+	}
+
+	for i, crlUrl := range crlUrls {
+		crl, err := trustAuthorityConnector.GetCRL(crlUrl)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to download CRL %q", crlUrl)
+		}
+
+		fileName := crlFileName(crlUrl)
+		if _, exists := files[fileName]; exists || fileName == "" {
+			fileName = fmt.Sprintf("crl-%d.der", i)
+		}
+		files[fileName] = crl.Raw
+	}
+
+	if err = os.MkdirAll(outDir, 0755); err != nil {
+		return errors.Wrapf(err, "Failed to create directory %q", outDir)
+	}
+
+	for _, fileName := range sortedFileNames(files) {
+		filePath := filepath.Join(outDir, fileName)
+		if err = os.WriteFile(filePath, files[fileName], 0644); err != nil {
+			return errors.Wrapf(err, "Failed to write %q", filePath)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), filePath)
+	}
+
+	return nil
+}
+
+// loadVerificationBundle reads the verification bundle written to 'bundleDir' by
+// export-verification-bundle (i.e., all files other than the JWKS and CA bundle are CRLs).
+func loadVerificationBundle(bundleDir string) (*connector.VerificationBundle, error) {
+	var bundle connector.VerificationBundle
+
+	entries, err := os.ReadDir(bundleDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read the verification bundle %q", bundleDir)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		filePath := filepath.Join(bundleDir, entry.Name())
+		contents, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read %q", filePath)
+		}
+
+		switch entry.Name() {
+		case bundleJwksFile:
+			bundle.Jwks = contents
+		case bundleCaBundleFile:
+			for block, rest := pem.Decode(contents); block != nil; block, rest = pem.Decode(rest) {
+				caCert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					return nil, errors.Wrapf(err, "Failed to parse a CA certificate in %q", filePath)
+				}
+				bundle.CaCertificates = append(bundle.CaCertificates, caCert)
+			}
+		default:
+			crl, err := x509.ParseRevocationList(contents)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to parse CRL %q", filePath)
+			}
+			bundle.Crls = append(bundle.Crls, crl)
+		}
+	}
+
+	if bundle.Jwks == nil || len(bundle.CaCertificates) == 0 {
+		return nil, errors.Errorf("The verification bundle %q must contain %s and %s", bundleDir, bundleJwksFile, bundleCaBundleFile)
+	}
+
+	return &bundle, nil
+}
+
+// jwksCrlDistributionPoints returns the (unique) CRL distribution points of the
+// certificates in the x5c chains of 'jwks'.
+func jwksCrlDistributionPoints(jwks []byte) ([]string, error) {
+	var keySet struct {
+		Keys []struct {
+			X5c []string `json:"x5c"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(jwks, &keySet); err != nil {
+		return nil, errors.Wrap(err, "Failed to parse the token signing certificates")
+	}
+
+	var crlUrls []string
+	seen := map[string]bool{}
+	for _, key := range keySet.Keys {
+		for i, encodedCert := range key.X5c {
+			der, err := base64.StdEncoding.DecodeString(encodedCert)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to decode x5c certificate[%d]", i)
+			}
+
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to parse x5c certificate[%d]", i)
+			}
+
+			for _, crlUrl := range cert.CRLDistributionPoints {
+				if !seen[crlUrl] {
+					seen[crlUrl] = true
+					crlUrls = append(crlUrls, crlUrl)
+				}
+			}
+		}
+	}
+
+	return crlUrls, nil
+}
+
+// crlFileName returns the last element of the path of 'crlUrl' (ex. "root-ca-crl.der") or
+// "" if it cannot be used as the name of a file in the bundle directory.
+func crlFileName(crlUrl string) string {
+	u, err := url.Parse(crlUrl)
+	if err != nil {
+		return ""
+	}
+
+	fileName := path.Base(u.Path)
+	switch fileName {
+	case ".", "..", "/":
+		return ""
+	}
+
+	return fileName
+}
+
+func encodeCertificatesPem(certs []*x509.Certificate) []byte {
+	var pemBytes []byte
+	for _, cert := range certs {
+		pemBytes = append(pemBytes, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return pemBytes
+}
+
+func sortedFileNames(files map[string][]byte) []string {
+	fileNames := make([]string, 0, len(files))
+	for fileName := range files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	return fileNames
+}
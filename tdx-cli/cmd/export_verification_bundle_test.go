@@ -0,0 +1,297 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// testSigningChain is a root CA and token signing certificate whose CRL distribution
+// points are served by 'server'.
+type testSigningChain struct {
+	server  *httptest.Server
+	rootKey *rsa.PrivateKey
+	root    *x509.Certificate
+	leafKey *rsa.PrivateKey
+	leaf    *x509.Certificate
+	jwks    []byte
+}
+
+func newTestSigningChain(t *testing.T) *testSigningChain {
+	rootKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	rootTemplate := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		CRLDistributionPoints: []string{server.URL + "/crl/root-ca-crl.der"},
+	}
+	rootDer, err := x509.CreateCertificate(rand.Reader, &rootTemplate, &rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := x509.ParseCertificate(rootDer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leafKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTemplate := x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Test Token Signing"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		CRLDistributionPoints: []string{server.URL + "/crl/ats-ca-crl.der"},
+	}
+	leafDer, err := x509.CreateCertificate(rand.Reader, &leafTemplate, root, &leafKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(leafDer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain := &testSigningChain{server: server, rootKey: rootKey, root: root, leafKey: leafKey, leaf: leaf}
+
+	crl := chain.crl(t, time.Now().Add(time.Hour))
+	mux.HandleFunc("/crl/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(crl)
+	})
+
+	chain.jwks, err = json.Marshal(map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{
+				"kty": "RSA",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(leafKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(leafKey.E)).Bytes()),
+				"x5c": []string{
+					base64.StdEncoding.EncodeToString(leafDer),
+					base64.StdEncoding.EncodeToString(rootDer),
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return chain
+}
+
+// crl returns a DER encoded CRL signed by the root CA that expires at 'nextUpdate'.
+func (c *testSigningChain) crl(t *testing.T, nextUpdate time.Time) []byte {
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: nextUpdate.Add(-2 * time.Hour),
+		NextUpdate: nextUpdate,
+	}, c.root, c.rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crl
+}
+
+// parsedCrl returns a CRL signed by the root CA (see crl).
+func (c *testSigningChain) parsedCrl(t *testing.T) *x509.RevocationList {
+	crl, err := x509.ParseRevocationList(c.crl(t, time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crl
+}
+
+// token returns a token signed by the token signing certificate.
+func (c *testSigningChain) token(t *testing.T) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodPS384, jwt.MapClaims{
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "test"
+
+	signed, err := token.SignedString(c.leafKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// exportTestBundle runs export-verification-bundle against 'chain' and returns the
+// bundle's directory.
+func exportTestBundle(t *testing.T, chain *testSigningChain) string {
+	outDir := filepath.Join(t.TempDir(), "bundle")
+
+	mockConnector := MockConnector{}
+	mockConnector.On("GetTokenSigningCertificates").Return(chain.jwks, nil)
+	mockConnector.On("GetTokenSigningCaBundle").Return([]*x509.Certificate{chain.root}, nil)
+	mockConnector.On("GetCRL", mock.Anything).Return(chain.parsedCrl(t), nil)
+	mockConnectorFactory := MockConnectorFactory{}
+	mockConnectorFactory.On("NewConnector", mock.Anything).Return(&mockConnector, nil)
+
+	cmd := newExportVerificationBundleCommand(mockConfigFactory(nil), &mockConnectorFactory)
+	cmd.SetArgs([]string{
+		"--" + constants.ConfigOptions.Name, confFilePath,
+		"--" + constants.OutDirOptions.Name, outDir,
+	})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	return outDir
+}
+
+func TestExportVerificationBundleCmd(t *testing.T) {
+	chain := newTestSigningChain(t)
+	outDir := exportTestBundle(t, chain)
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fileNames []string
+	for _, entry := range entries {
+		fileNames = append(fileNames, entry.Name())
+	}
+	assert.Equal(t, []string{"ats-ca-crl.der", "ca-bundle.pem", "jwks.json", "root-ca-crl.der"}, fileNames)
+
+	jwks, err := os.ReadFile(filepath.Join(outDir, bundleJwksFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, chain.jwks, jwks)
+
+	caBundle, err := os.ReadFile(filepath.Join(outDir, bundleCaBundleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, rest := pem.Decode(caBundle)
+	if assert.NotNil(t, block) {
+		assert.Equal(t, chain.root.Raw, block.Bytes)
+	}
+	assert.Empty(t, rest)
+
+	for _, crlFile := range []string{"ats-ca-crl.der", "root-ca-crl.der"} {
+		der, err := os.ReadFile(filepath.Join(outDir, crlFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", crlFile, err)
+		}
+		assert.NoError(t, crl.CheckSignatureFrom(chain.root))
+	}
+}
+
+func TestExportVerificationBundleCmdFailures(t *testing.T) {
+	chain := newTestSigningChain(t)
+
+	tt := []struct {
+		description string
+		config      *Config
+		jwks        []byte
+		jwksErr     error
+		crlErr      error
+	}{
+		{
+			description: "missing trust authority url",
+			config:      &Config{TrustAuthorityApiKey: testApiKey},
+			jwks:        chain.jwks,
+		},
+		{
+			description: "jwks download failure",
+			jwksErr:     errors.New("Unit test failure"),
+		},
+		{
+			description: "invalid jwks",
+			jwks:        []byte(`{"keys":[{"x5c":["not base64"]}]}`),
+		},
+		{
+			description: "crl download failure",
+			jwks:        []byte(`{"keys":[{"x5c":["` + base64.StdEncoding.EncodeToString(chain.leaf.Raw) + `"]}]}`),
+			crlErr:      errors.New("Unit test failure"),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			outDir := t.TempDir()
+
+			mockConnector := MockConnector{}
+			mockConnector.On("GetTokenSigningCertificates").Return(tc.jwks, tc.jwksErr)
+			mockConnector.On("GetTokenSigningCaBundle").Return([]*x509.Certificate{chain.root}, nil)
+			mockConnector.On("GetCRL", mock.Anything).Return(chain.parsedCrl(t), tc.crlErr)
+			mockConnectorFactory := MockConnectorFactory{}
+			mockConnectorFactory.On("NewConnector", mock.Anything).Return(&mockConnector, nil)
+
+			cmd := newExportVerificationBundleCommand(mockConfigFactory(tc.config), &mockConnectorFactory)
+			cmd.SetArgs([]string{
+				"--" + constants.ConfigOptions.Name, confFilePath,
+				"--" + constants.OutDirOptions.Name, outDir,
+			})
+
+			assert.Error(t, cmd.Execute())
+
+			entries, err := os.ReadDir(outDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Empty(t, entries, "no files should be written when the export fails")
+		})
+	}
+}
+
+func TestCrlFileName(t *testing.T) {
+	tt := map[string]string{
+		"https://example.com/crl/root-ca-crl.der":    "root-ca-crl.der",
+		"https://example.com/crl/ats-ca-crl.der?v=1": "ats-ca-crl.der",
+		"https://example.com/crl/..":                 "",
+		"https://example.com/crl/%2e%2e":             "",
+		"https://example.com/":                       "",
+		"https://example.com":                        "",
+	}
+
+	for crlUrl, expected := range tt {
+		assert.Equal(t, expected, crlFileName(crlUrl), crlUrl)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/intel/trustauthority-client/go-connector"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func newHealthcheckCommand(cfgFactory ConfigFactory, ctrFactory connector.ConnectorFactory) *cobra.Command {
+	healthcheckCmd := &cobra.Command{
+		Use:   constants.HealthcheckCmd,
+		Short: "Checks that Trust Authority is reachable using the provided configuration",
+		Long: `Use this command to verify network connectivity and the API key before attempting
+ attestation.  When the config contains the Trust Authority API URL, an authenticated nonce
+ request is made.  Otherwise, the token signing certificates are requested from the Trust
+ Authority URL.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := healthcheck(cmd, cfgFactory, ctrFactory)
+			if err != nil {
+				printError(os.Stderr, err)
+				return err
+			}
+
+			return nil
+		},
+	}
+	healthcheckCmd.Flags().StringP(constants.ConfigOptions.Name, constants.ConfigOptions.ShortHand, "", constants.ConfigOptions.Description)
+
+	return healthcheckCmd
+}
+
+func healthcheck(cmd *cobra.Command, cfgFactory ConfigFactory, ctrFactory connector.ConnectorFactory) error {
+	configFile, err := cmd.Flags().GetString(constants.ConfigOptions.Name)
+	if err != nil {
+		return err
+	}
+
+	config, err := cfgFactory.LoadConfig(configFile)
+	if err != nil {
+		return withErrorCode(errors.Wrapf(err, "Could not read config file %q", configFile), ErrorCodeConfig, "")
+	}
+
+	if config.TrustAuthorityApiUrl == "" && config.TrustAuthorityUrl == "" {
+		return withErrorCode(errors.New("Either Trust Authority URL or Trust Authority API URL must be present in config"), ErrorCodeConfig, "")
+	}
+
+	tlsConfig, err := newTlsConfig(config.Tls)
+	if err != nil {
+		return withErrorCode(err, ErrorCodeConfig, "")
+	}
+
+	cfg := connector.Config{
+		TlsCfg:  tlsConfig,
+		BaseUrl: config.TrustAuthorityUrl,
+		ApiUrl:  config.TrustAuthorityApiUrl,
+		ApiKey:  config.TrustAuthorityApiKey,
+	}
+
+	trustAuthorityConnector, err := ctrFactory.NewConnector(&cfg)
+	if err != nil {
+		return err
+	}
+
+	err = trustAuthorityConnector.Ping()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), "Trust Authority is reachable")
+	return nil
+}
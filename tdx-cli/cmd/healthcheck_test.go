@@ -0,0 +1,79 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/intel/trustauthority-client/go-connector"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHealthcheckCmd(t *testing.T) {
+	tt := []struct {
+		description     string
+		wantErr         bool
+		dependencyMocks func() (ConfigFactory, connector.ConnectorFactory)
+	}{
+		{
+			description: "Test healthcheck positive",
+			wantErr:     false,
+			dependencyMocks: func() (ConfigFactory, connector.ConnectorFactory) {
+				return mockConfigFactory(nil), happyMockConnectorFactory()
+			},
+		},
+		{
+			description: "Test healthcheck config failure",
+			wantErr:     true,
+			dependencyMocks: func() (ConfigFactory, connector.ConnectorFactory) {
+				angryConfigFactory := MockConfigFactory{}
+				angryConfigFactory.On("LoadConfig", mock.Anything).Return(&Config{}, errors.New("Unit test failure"))
+				return &angryConfigFactory, happyMockConnectorFactory()
+			},
+		},
+		{
+			description: "Test healthcheck missing urls",
+			wantErr:     true,
+			dependencyMocks: func() (ConfigFactory, connector.ConnectorFactory) {
+				return mockConfigFactory(&Config{TrustAuthorityApiKey: testApiKey}), happyMockConnectorFactory()
+			},
+		},
+		{
+			description: "Test healthcheck ping failure",
+			wantErr:     true,
+			dependencyMocks: func() (ConfigFactory, connector.ConnectorFactory) {
+				angryConnector := MockConnector{}
+				angryConnector.On("Ping").Return(errors.New("Unit test failure"))
+
+				angryConnectorFactory := MockConnectorFactory{}
+				angryConnectorFactory.On("NewConnector", mock.Anything).Return(&angryConnector, nil)
+				return mockConfigFactory(nil), &angryConnectorFactory
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			cmd := newHealthcheckCommand(tc.dependencyMocks())
+			cmd.SetArgs([]string{
+				constants.HealthcheckCmd,
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+			})
+
+			err := cmd.Execute()
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
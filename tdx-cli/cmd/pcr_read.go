@@ -0,0 +1,76 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/intel/trustauthority-client/go-tpm"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func newPcrReadCommand(tpmFactory tpm.TpmFactory) *cobra.Command {
+	var pcrSelection string
+
+	cmd := cobra.Command{
+		Use:   constants.PcrReadCmd,
+		Short: "Displays the host's TPM PCR values",
+		Long: `Use this command to display the current values of the TPM's PCRs (formatted like
+ tpm2_pcrread) when debugging PCR mismatches.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			selections, err := tpm.ParsePcrSelections(pcrSelection)
+			if err != nil {
+				return errors.Wrapf(err, "Invalid PCR selection %q", pcrSelection)
+			}
+
+			t, err := tpmFactory.New(tpm.TpmDeviceLinux, "")
+			if err != nil {
+				return errors.Wrap(err, "Failed to create TPM")
+			}
+			defer t.Close()
+
+			pcrValues, err := t.ReadPcrValues(selections...)
+			if err != nil {
+				return errors.Wrap(tpm.WrapTpmError(err), "Failed to read PCR values")
+			}
+
+			printPcrValues(cmd.OutOrStdout(), pcrValues)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&pcrSelection, constants.PcrSelectionOptions.Name, "", constants.PcrSelectionOptions.Description)
+
+	return &cmd
+}
+
+// printPcrValues writes 'pcrValues' to 'w' grouped by bank, in the same format as
+// tpm2_pcrread.  For example...
+//
+//	  sha256:
+//	    0 : 0x3D458CFE55CC03EA1F443F1562BEEC8DF51C75E14A9FCF9A7234A13F198E7969
+func printPcrValues(w io.Writer, pcrValues []tpm.PcrValue) {
+	var bank crypto.Hash
+	for _, pcrValue := range pcrValues {
+		if pcrValue.Hash != bank {
+			bank = pcrValue.Hash
+			fmt.Fprintf(w, "  %s:\n", pcrBankName(bank))
+		}
+		fmt.Fprintf(w, "    %-2d: 0x%X\n", pcrValue.Index, pcrValue.Digest)
+	}
+}
+
+// pcrBankName returns the tpm2-tools name of a PCR bank (ex. "sha256").
+func pcrBankName(hash crypto.Hash) string {
+	return strings.ToLower(strings.ReplaceAll(hash.String(), "-", ""))
+}
@@ -0,0 +1,120 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/intel/trustauthority-client/go-tpm"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPcrReadCmd(t *testing.T) {
+	sha1Digest := bytes.Repeat([]byte{0xab}, crypto.SHA1.Size())
+	sha256Digest := bytes.Repeat([]byte{0x01}, crypto.SHA256.Size())
+
+	mockTpm := MockTpm{}
+	mockTpm.On("ReadPcrValues", []tpm.PcrSelection{
+		{Hash: crypto.SHA1, Pcrs: []int{10}},
+		{Hash: crypto.SHA256, Pcrs: []int{0, 1}},
+	}).Return([]tpm.PcrValue{
+		{Index: 10, Hash: crypto.SHA1, Digest: sha1Digest},
+		{Index: 0, Hash: crypto.SHA256, Digest: sha256Digest},
+		{Index: 1, Hash: crypto.SHA256, Digest: make([]byte, crypto.SHA256.Size())},
+	}, nil)
+
+	mockTpmFactory := MockTpmFactory{}
+	mockTpmFactory.On("New", tpm.TpmDeviceLinux, "").Return(&mockTpm, nil)
+
+	var stdout bytes.Buffer
+	cmd := newPcrReadCommand(&mockTpmFactory)
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"--" + constants.PcrSelectionOptions.Name, "sha1:10+sha256:0-1"})
+
+	err := cmd.Execute()
+	assert.NoError(t, err)
+
+	expected := "  sha1:\n" +
+		"    10: 0xABABABABABABABABABABABABABABABABABABABAB\n" +
+		"  sha256:\n" +
+		"    0 : 0x0101010101010101010101010101010101010101010101010101010101010101\n" +
+		"    1 : 0x0000000000000000000000000000000000000000000000000000000000000000\n"
+	assert.Equal(t, expected, stdout.String())
+}
+
+func TestPcrReadCmdSimulator(t *testing.T) {
+	var stdout bytes.Buffer
+	cmd := newPcrReadCommand(newSimulatorTpmFactory(t))
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"--" + constants.PcrSelectionOptions.Name, "sha256:0-7"})
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	assert.Len(t, lines, 9)
+	assert.Equal(t, "  sha256:", lines[0])
+	for i, line := range lines[1:] {
+		assert.Regexp(t, fmt.Sprintf("^    %-2d: 0x[0-9A-F]{64}$", i), line)
+	}
+}
+
+func TestPcrReadCmdErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		mockTpmFactory func() *MockTpmFactory
+	}{
+		{
+			name: "Invalid PCR selection",
+			args: []string{"--" + constants.PcrSelectionOptions.Name, "sha256:0-24"},
+			mockTpmFactory: func() *MockTpmFactory {
+				return &MockTpmFactory{}
+			},
+		},
+		{
+			name: "TPM open failure",
+			args: []string{},
+			mockTpmFactory: func() *MockTpmFactory {
+				mockTpmFactory := MockTpmFactory{}
+				mockTpmFactory.On("New", mock.Anything, mock.Anything).Return(&MockTpm{}, errors.New("Unit test failure"))
+				return &mockTpmFactory
+			},
+		},
+		{
+			name: "PCR read failure",
+			args: []string{},
+			mockTpmFactory: func() *MockTpmFactory {
+				mockTpm := MockTpm{}
+				mockTpm.On("ReadPcrValues", mock.Anything).Return([]tpm.PcrValue{}, errors.New("Unit test failure"))
+
+				mockTpmFactory := MockTpmFactory{}
+				mockTpmFactory.On("New", mock.Anything, mock.Anything).Return(&mockTpm, nil)
+				return &mockTpmFactory
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newPcrReadCommand(tt.mockTpmFactory())
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			assert.Error(t, err)
+		})
+	}
+}
@@ -7,7 +7,6 @@
 package cmd
 
 import (
-	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -32,17 +31,19 @@ func newProvisionAkCommand(tpmFactory tpm.TpmFactory, cfgFactory ConfigFactory,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := cfgFactory.LoadConfig(configPath)
 			if err != nil {
-				return errors.Wrapf(err, "Could not read config file %q", configPath)
+				return withErrorCode(errors.Wrapf(err, "Could not read config file %q", configPath), ErrorCodeConfig, "")
 			}
 
 			// create a connector that will make the AK provisioning request to ITA
+			tlsConfig, err := newTlsConfig(cfg.Tls)
+			if err != nil {
+				return withErrorCode(err, ErrorCodeConfig, "")
+			}
+
 			ctr, err := ctrFactory.NewConnector(&connector.Config{
 				ApiUrl: cfg.TrustAuthorityApiUrl,
 				ApiKey: cfg.TrustAuthorityApiKey,
-				TlsCfg: &tls.Config{
-					InsecureSkipVerify: false,
-					MinVersion:         tls.VersionTLS12,
-				},
+				TlsCfg: tlsConfig,
 			})
 			if err != nil {
 				return errors.Wrap(err, "Failed to create connector")
@@ -113,17 +114,24 @@ func provisionAk(ekHandle int, akHandle int, ctr connector.Connector, t tpm.Trus
 	// Create the EK and get its public key
 	err := t.CreateEK(ekHandle)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Failed to create EK at handle 0x%x", ekHandle)
+		return nil, errors.Wrapf(tpm.WrapTpmError(err), "Failed to create EK at handle 0x%x", ekHandle)
 	}
 	logrus.Infof("Successfully created EK at handle 0x%x", ekHandle)
 
 	// Create the Ak and get its name
 	err = t.CreateAK(akHandle, ekHandle)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Failed to create AK at handle 0x%x", akHandle)
+		return nil, errors.Wrapf(tpm.WrapTpmError(err), "Failed to create AK at handle 0x%x", akHandle)
 	}
 	logrus.Infof("Successfully created AK at handle 0x%x", akHandle)
 
+	return requestAkCertificate(ekHandle, akHandle, ctr, t)
+}
+
+// requestAkCertificate requests an ITA signed certificate for the AK at 'akHandle' and
+// decrypts it using the EK at 'ekHandle' (i.e., via ActivateCredential).
+func requestAkCertificate(ekHandle int, akHandle int, ctr connector.Connector, t tpm.TrustedPlatformModule) (*x509.Certificate, error) {
+
 	_, akTpmtPublic, _, err := t.ReadPublic(akHandle)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Failed to read AK at handle 0x%x", akHandle)
@@ -143,7 +151,7 @@ func provisionAk(ekHandle int, akHandle int, ctr connector.Connector, t tpm.Trus
 	// Decrypt aes key that encrypts payload
 	aesKey, err := t.ActivateCredential(ekHandle, akHandle, credentialBlob, secret)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Activate credential failed")
+		return nil, errors.Wrapf(tpm.WrapTpmError(err), "Activate credential failed")
 	}
 
 	// decrypt the ak certificate in the payload
@@ -9,6 +9,8 @@ package cmd
 import (
 	"testing"
 
+	"github.com/canonical/go-tpm2"
+	"github.com/intel/trustauthority-client/go-tpm"
 	"github.com/intel/trustauthority-client/tdx-cli/constants"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/mock"
@@ -148,3 +150,16 @@ func testProvisionAkFactories() (MockTpmFactory, MockConfigFactory, MockConnecto
 
 	return mockTpmFactory, mockConfigFactory, mockConnectorFactory
 }
+
+func TestProvisionAkLockout(t *testing.T) {
+	lockoutErr := &tpm2.TPMWarning{Command: tpm2.CommandCreatePrimary, Code: tpm2.WarningLockout}
+
+	mockTpm := &MockTpm{}
+	mockTpm.On("HandleExists", mock.Anything).Return(false)
+	mockTpm.On("CreateEK", mock.Anything).Return(lockoutErr)
+
+	_, err := provisionAk(tpm.DefaultEkHandle, tpm.DefaultAkHandle, &MockConnector{}, mockTpm)
+	if !errors.Is(err, tpm.ErrTpmLockout) {
+		t.Fatalf("Expected error %v, but got %v", tpm.ErrTpmLockout, err)
+	}
+}
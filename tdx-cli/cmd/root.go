@@ -8,13 +8,16 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/intel/trustauthority-client/go-connector"
 	"github.com/intel/trustauthority-client/go-tpm"
 	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -31,8 +34,116 @@ func (f *simpleFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	return []byte(fmt.Sprintf("%s\n", entry.Message)), nil
 }
 
+// jsonErrors is set by the global --json-errors option
+var jsonErrors bool
+
+// apiKeyFile is set by the global --api-key-file option
+var apiKeyFile string
+
+// tlsMinVersion is set by the global --tls-min-version option
+var tlsMinVersion string
+
+// verbose is set by the global --verbose option
+var verbose bool
+
+// logLevel is set by the global --log-level option
+var logLevel string
+
 func init() {
 	logrus.SetFormatter(&simpleFormatter{})
+	initRootCommand(rootCmd)
+}
+
+// initRootCommand adds the global options to the root command.
+func initRootCommand(root *cobra.Command) {
+	root.PersistentFlags().BoolVar(&jsonErrors, constants.JsonErrorsOptions.Name, false, constants.JsonErrorsOptions.Description)
+	root.PersistentFlags().StringVar(&apiKeyFile, constants.ApiKeyFileOptions.Name, "", constants.ApiKeyFileOptions.Description)
+	root.PersistentFlags().StringVar(&tlsMinVersion, constants.TlsMinVersionOptions.Name, "", constants.TlsMinVersionOptions.Description)
+	root.PersistentFlags().BoolVarP(&verbose, constants.VerboseOptions.Name, constants.VerboseOptions.ShortHand, false, constants.VerboseOptions.Description)
+	root.PersistentFlags().StringVar(&logLevel, constants.LogLevelOptions.Name, "", constants.LogLevelOptions.Description)
+	root.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if verbose {
+			// the connector and adapters report progress (and retries) at debug level
+			logrus.SetLevel(logrus.DebugLevel)
+		}
+
+		if logLevel != "" {
+			level, err := logrus.ParseLevel(logLevel)
+			if err != nil {
+				return errors.Wrapf(err, "Invalid --%s", constants.LogLevelOptions.Name)
+			}
+			logrus.SetLevel(level)
+		}
+
+		return nil
+	}
+}
+
+// inheritRootPersistentPreRun makes the subcommands of 'root' that have their own
+// PersistentPreRun(E) run the root's PersistentPreRunE first, since cobra only runs the
+// nearest hook and the global options (ex. --log-level) must apply to every command.
+func inheritRootPersistentPreRun(root *cobra.Command) {
+	rootPreRunE := root.PersistentPreRunE
+	if rootPreRunE == nil {
+		return
+	}
+
+	var inherit func(cmd *cobra.Command)
+	inherit = func(cmd *cobra.Command) {
+		for _, sub := range cmd.Commands() {
+			preRunE, preRun := sub.PersistentPreRunE, sub.PersistentPreRun
+			if preRunE != nil || preRun != nil {
+				sub.PersistentPreRun = nil
+				sub.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+					if err := rootPreRunE(cmd, args); err != nil {
+						return err
+					}
+
+					if preRunE != nil {
+						return preRunE(cmd, args)
+					}
+
+					preRun(cmd, args)
+					return nil
+				}
+			}
+			inherit(sub)
+		}
+	}
+	inherit(root)
+}
+
+// parseJsonErrors returns the value of --json-errors in 'args'.  The other flags are
+// ignored so that it is known before the command's flags are parsed (i.e., when parsing
+// fails due to an invalid flag, which may precede --json-errors).
+func parseJsonErrors(args []string) bool {
+	flags := pflag.NewFlagSet(constants.RootCmd, pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	flags.SetOutput(io.Discard)
+	enabled := flags.Bool(constants.JsonErrorsOptions.Name, false, "")
+
+	// errors are reported when the command parses its flags
+	_ = flags.Parse(args)
+	return *enabled
+}
+
+// executeCommand runs the root command with 'args' and, when --json-errors is set,
+// writes failures (including invalid flags) to 'stderr' in json format.
+func executeCommand(root *cobra.Command, args []string, stderr io.Writer) error {
+	inheritRootPersistentPreRun(root)
+
+	if parseJsonErrors(args) {
+		jsonErrors = true
+		root.SilenceErrors = true
+		root.SilenceUsage = true
+	}
+
+	root.SetArgs(args)
+	err := root.Execute()
+	if err != nil && jsonErrors {
+		writeJsonError(stderr, err)
+	}
+	return err
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -43,7 +154,7 @@ func Execute() {
 	tpmAdapterFactory := tpm.NewTpmAdapterFactory(tpmFactory)
 	tdxAdapterFactory := NewTdxAdapterFactory(tpmFactory) // Azure uses the vTPM to get TDX evidence
 	cfgFactory := NewConfigFactory()
-	ctrFactory := connector.NewConnectorFactory()
+	ctrFactory := newRetryLoggingConnectorFactory(connector.NewConnectorFactory())
 
 	rootCmd.AddCommand(newEvidenceCommand(
 		tdxAdapterFactory,
@@ -58,6 +169,12 @@ func Execute() {
 		ctrFactory,
 	))
 
+	rootCmd.AddCommand(newRotateAkCommand(
+		tpmFactory,
+		cfgFactory,
+		ctrFactory,
+	))
+
 	rootCmd.AddCommand(newTokenCommand(
 		tdxAdapterFactory,
 		tpmAdapterFactory,
@@ -70,7 +187,31 @@ func Execute() {
 		ctrFactory,
 	))
 
-	err := rootCmd.Execute()
+	rootCmd.AddCommand(newHealthcheckCommand(
+		cfgFactory,
+		ctrFactory,
+	))
+
+	rootCmd.AddCommand(newExportVerificationBundleCommand(
+		cfgFactory,
+		ctrFactory,
+	))
+
+	rootCmd.AddCommand(newCapabilitiesCommand())
+
+	rootCmd.AddCommand(newPcrReadCommand(
+		tpmFactory,
+	))
+
+	rootCmd.AddCommand(newEkCertCommand(
+		tpmFactory,
+	))
+
+	rootCmd.AddCommand(newRtmrCheckCommand(
+		tdxAdapterFactory,
+	))
+
+	err := executeCommand(rootCmd, os.Args[1:], os.Stderr)
 	if err != nil {
 		os.Exit(1)
 	}
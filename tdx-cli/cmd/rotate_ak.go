@@ -0,0 +1,243 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/intel/trustauthority-client/go-connector"
+	"github.com/intel/trustauthority-client/go-tpm"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newRotateAkCommand(tpmFactory tpm.TpmFactory, cfgFactory ConfigFactory, ctrFactory connector.ConnectorFactory) *cobra.Command {
+	var configPath string
+	var newAkHandleString string
+
+	cmd := cobra.Command{
+		Use:   constants.RotateAkCmd,
+		Short: "Replaces the host's AK with a new AK and ITA signed AK certificate.",
+		Long: `Creates a new AK at --new-ak-handle (defaults to the configured AK handle + 1), requests
+an ITA signed certificate for it and writes the certificate to the nvram index following the
+configured index.  'ak_handle' and 'ak_certificate' in the config file are then updated to the
+new AK and certificate, and only then are the old AK and certificate removed.  If any step fails
+before the config file is updated, the new AK and certificate are removed and the old AK and
+certificate are left unchanged.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := cfgFactory.LoadConfig(configPath)
+			if err != nil {
+				return withErrorCode(errors.Wrapf(err, "Could not read config file %q", configPath), ErrorCodeConfig, "")
+			}
+
+			if cfg.Tpm == nil {
+				return errors.Errorf("TPM configuration not found in config file %q", configPath)
+			}
+
+			nvIndex, err := parseAkCertificateNvIndex(cfg.Tpm.AkCertificateUri)
+			if err != nil {
+				return err
+			}
+
+			ekHandle := cfg.Tpm.EkHandle
+			if ekHandle == 0 {
+				logrus.Infof("Using default EK handle: 0x%x", tpm.DefaultEkHandle)
+				ekHandle = tpm.DefaultEkHandle
+			}
+
+			akHandle := cfg.Tpm.AkHandle
+			if akHandle == 0 {
+				logrus.Infof("Using default AK handle: 0x%x", tpm.DefaultAkHandle)
+				akHandle = tpm.DefaultAkHandle
+			}
+
+			newAkHandle := int(akHandle) + 1
+			if newAkHandleString != "" {
+				h, err := strconv.ParseUint(strings.TrimPrefix(newAkHandleString, "0x"), 16, 32)
+				if err != nil {
+					return errors.Wrapf(err, "Invalid new AK handle %q", newAkHandleString)
+				}
+				newAkHandle = int(h)
+			}
+
+			if newAkHandle == int(akHandle) {
+				return errors.Errorf("The new AK handle must be different than the current AK handle 0x%x", akHandle)
+			}
+
+			// create a connector that will make the AK provisioning request to ITA
+			tlsConfig, err := newTlsConfig(cfg.Tls)
+			if err != nil {
+				return withErrorCode(err, ErrorCodeConfig, "")
+			}
+
+			ctr, err := ctrFactory.NewConnector(&connector.Config{
+				ApiUrl: cfg.TrustAuthorityApiUrl,
+				ApiKey: cfg.TrustAuthorityApiKey,
+				TlsCfg: tlsConfig,
+			})
+			if err != nil {
+				return errors.Wrap(err, "Failed to create connector")
+			}
+
+			tpm, err := tpmFactory.New(tpm.TpmDeviceLinux, cfg.Tpm.OwnerAuth)
+			if err != nil {
+				return errors.Wrap(err, "Failed to create TPM")
+			}
+			defer tpm.Close()
+
+			updateConfig := func(akHandle int, nvIndex int) error {
+				return cfgFactory.UpdateAkConfig(configPath, akHandle, fmt.Sprintf("nvram://0x%x", nvIndex))
+			}
+
+			akCert, err := rotateAk(int(ekHandle), int(akHandle), newAkHandle, nvIndex, ctr, tpm, updateConfig)
+			if err != nil {
+				return err
+			}
+
+			pemBytes := pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE",
+				Bytes: akCert.Raw,
+			})
+			if pemBytes == nil {
+				return errors.New("Failed to encode AK certificate to PEM")
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), string(pemBytes))
+			logrus.Infof("The AK was rotated to handle 0x%x", newAkHandle)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, constants.ConfigOptions.Name, constants.ConfigOptions.ShortHand, "", constants.ConfigOptions.Description)
+	cmd.Flags().StringVarP(&newAkHandleString, constants.NewAkHandleOptions.Name, constants.NewAkHandleOptions.ShortHand, "", constants.NewAkHandleOptions.Description)
+
+	return &cmd
+}
+
+// rotateAk creates a new AK at 'newAkHandle', obtains an ITA signed certificate for it and
+// writes the certificate to the nv index following 'nvIndex'.  'updateConfig' is then called
+// so that the config refers to the new AK and certificate before the old AK and certificate
+// are removed (i.e., the host always has a valid AK certificate).  The new AK and
+// certificate are removed (and the old AK/certificate are left unchanged) if any step fails
+// prior to updating the config.
+func rotateAk(ekHandle int, oldAkHandle int, newAkHandle int, nvIndex int, ctr connector.Connector, t tpm.TrustedPlatformModule, updateConfig func(akHandle int, nvIndex int) error) (*x509.Certificate, error) {
+
+	if !t.HandleExists(ekHandle) {
+		return nil, errors.Errorf("The EK handle 0x%x does not exist.  Please run 'provision-ak' before rotating the AK", ekHandle)
+	}
+
+	if !t.HandleExists(oldAkHandle) {
+		return nil, errors.Errorf("The AK handle 0x%x does not exist.  Please run 'provision-ak' before rotating the AK", oldAkHandle)
+	}
+
+	if t.HandleExists(newAkHandle) {
+		return nil, errors.Errorf("The new AK handle 0x%x already exists.  Please delete it before running 'rotate-ak'", newAkHandle)
+	}
+
+	newNvIndex := nvIndex + 1
+	if t.NVExists(newNvIndex) {
+		return nil, errors.Errorf("The nv index 0x%x for the new AK certificate already exists.  Please delete it before running 'rotate-ak'", newNvIndex)
+	}
+
+	err := t.CreateAK(newAkHandle, ekHandle)
+	if err != nil {
+		return nil, errors.Wrapf(tpm.WrapTpmError(err), "Failed to create AK at handle 0x%x", newAkHandle)
+	}
+	logrus.Infof("Successfully created AK at handle 0x%x", newAkHandle)
+
+	akCert, err := requestAkCertificate(ekHandle, newAkHandle, ctr, t)
+	if err != nil {
+		return nil, rollbackAk(newAkHandle, -1, err, t)
+	}
+
+	err = writeNvIndex(newNvIndex, akCert.Raw, t)
+	if err != nil {
+		err = errors.Wrapf(err, "Failed to write the AK certificate to nv index 0x%x", newNvIndex)
+		return nil, rollbackAk(newAkHandle, newNvIndex, err, t)
+	}
+	logrus.Infof("Successfully wrote AK certificate to nv index 0x%x", newNvIndex)
+
+	err = updateConfig(newAkHandle, newNvIndex)
+	if err != nil {
+		err = errors.Wrap(err, "Failed to update the config file with the new AK")
+		return nil, rollbackAk(newAkHandle, newNvIndex, err, t)
+	}
+	logrus.Infof("Updated the config file to use the AK at handle 0x%x and the certificate at nv index 0x%x", newAkHandle, newNvIndex)
+
+	// the config refers to the new AK and certificate, the old ones are no longer needed
+	err = t.EvictHandle(oldAkHandle)
+	if err != nil {
+		return nil, errors.Wrapf(err, "The AK was rotated but the old AK at handle 0x%x could not be evicted", oldAkHandle)
+	}
+	logrus.Infof("Successfully evicted the old AK at handle 0x%x", oldAkHandle)
+
+	if t.NVExists(nvIndex) {
+		err = t.NVDelete(nvIndex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "The AK was rotated but the old AK certificate at nv index 0x%x could not be deleted", nvIndex)
+		}
+		logrus.Infof("Successfully deleted the old AK certificate at nv index 0x%x", nvIndex)
+	}
+
+	return akCert, nil
+}
+
+// rollbackAk evicts the new AK at 'akHandle' and deletes its certificate at 'nvIndex' (if
+// not -1) after 'cause' prevented the rotation.
+func rollbackAk(akHandle int, nvIndex int, cause error, t tpm.TrustedPlatformModule) error {
+	if nvIndex != -1 && t.NVExists(nvIndex) {
+		if err := t.NVDelete(nvIndex); err != nil {
+			return errors.Wrapf(cause, "AK rotation failed and the new AK certificate at nv index 0x%x could not be removed (%v)", nvIndex, err)
+		}
+	}
+
+	err := t.EvictHandle(akHandle)
+	if err != nil {
+		return errors.Wrapf(cause, "AK rotation failed and the new AK at handle 0x%x could not be removed (%v)", akHandle, err)
+	}
+
+	logrus.Infof("Removed the new AK at handle 0x%x, the existing AK was not changed", akHandle)
+	return errors.Wrap(cause, "AK rotation failed")
+}
+
+func writeNvIndex(nvIndex int, data []byte, t tpm.TrustedPlatformModule) error {
+	err := t.NVDefine(nvIndex, len(data))
+	if err != nil {
+		return err
+	}
+
+	return t.NVWrite(nvIndex, data)
+}
+
+// parseAkCertificateNvIndex returns the nv index from an "nvram://{index in hex}" AK
+// certificate URI.
+func parseAkCertificateNvIndex(uriString string) (int, error) {
+	uri, err := url.Parse(uriString)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Invalid AK certificate URI %q", uriString)
+	}
+
+	if uri.Scheme != "nvram" {
+		return 0, errors.Errorf("The AK certificate URI %q must be 'nvram://{index in hex}' to rotate the AK", uriString)
+	}
+
+	nvIndex, err := strconv.ParseUint(strings.TrimPrefix(uri.Host, "0x"), 16, 32)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Invalid nv index in AK certificate URI %q", uriString)
+	}
+
+	return int(nvIndex), nil
+}
@@ -0,0 +1,198 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/intel/trustauthority-client/go-tpm"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+const testAkNvIndex = 0x01C101D0
+
+func TestRotateAk(t *testing.T) {
+	mockTpm, mockTpmFactory, mockConfigFactory, mockConnectorFactory := testRotateAkFactories(nil)
+
+	cmd := newRotateAkCommand(&mockTpmFactory, &mockConfigFactory, &mockConnectorFactory)
+	cmd.SetArgs([]string{"--" + constants.ConfigOptions.Name, testNonExistentFileName})
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(out.Bytes(), []byte("BEGIN CERTIFICATE")) {
+		t.Errorf("Expected the AK certificate in the output, but got %q", out.String())
+	}
+
+	// the new AK is created at the default handle + 1, the certificate is written to
+	// the next nv index and the config is updated before the old AK and certificate are
+	// removed
+	mockTpm.AssertCalled(t, "CreateAK", tpm.DefaultAkHandle+1, tpm.DefaultEkHandle)
+	mockTpm.AssertCalled(t, "ActivateCredential", tpm.DefaultEkHandle, tpm.DefaultAkHandle+1, mock.Anything, mock.Anything)
+	mockTpm.AssertCalled(t, "NVWrite", testAkNvIndex+1, testCertificate.Raw)
+	mockConfigFactory.AssertCalled(t, "UpdateAkConfig", testNonExistentFileName, tpm.DefaultAkHandle+1, "nvram://0x1c101d1")
+	mockTpm.AssertCalled(t, "EvictHandle", tpm.DefaultAkHandle)
+	mockTpm.AssertCalled(t, "NVDelete", testAkNvIndex)
+	mockTpm.AssertNotCalled(t, "EvictHandle", tpm.DefaultAkHandle+1)
+	mockTpm.AssertNotCalled(t, "NVDelete", testAkNvIndex+1)
+}
+
+func TestRotateAkNewHandle(t *testing.T) {
+	mockTpm, mockTpmFactory, mockConfigFactory, mockConnectorFactory := testRotateAkFactories(nil)
+
+	cmd := newRotateAkCommand(&mockTpmFactory, &mockConfigFactory, &mockConnectorFactory)
+	cmd.SetArgs([]string{"--" + constants.ConfigOptions.Name, testNonExistentFileName, "--" + constants.NewAkHandleOptions.Name, "0x81000810"})
+	cmd.SetOut(&bytes.Buffer{})
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockTpm.AssertCalled(t, "CreateAK", 0x81000810, tpm.DefaultEkHandle)
+	mockTpm.AssertCalled(t, "EvictHandle", tpm.DefaultAkHandle)
+}
+
+func TestRotateAkConnectorRollback(t *testing.T) {
+	mockTpm, mockTpmFactory, mockConfigFactory, mockConnectorFactory := testRotateAkFactories(errors.New("Unit test failure"))
+
+	cmd := newRotateAkCommand(&mockTpmFactory, &mockConfigFactory, &mockConnectorFactory)
+	cmd.SetArgs([]string{"--" + constants.ConfigOptions.Name, testNonExistentFileName})
+	cmd.SetOut(&bytes.Buffer{})
+
+	err := cmd.Execute()
+	if err == nil {
+		t.Fatal("Expected an error when the connector fails")
+	}
+
+	// the new AK is removed and the old AK/certificate are untouched
+	mockTpm.AssertCalled(t, "EvictHandle", tpm.DefaultAkHandle+1)
+	mockTpm.AssertNotCalled(t, "EvictHandle", tpm.DefaultAkHandle)
+	mockTpm.AssertNotCalled(t, "NVDelete", mock.Anything)
+	mockTpm.AssertNotCalled(t, "NVWrite", mock.Anything, mock.Anything)
+	mockConfigFactory.AssertNotCalled(t, "UpdateAkConfig", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRotateAkConfigRollback(t *testing.T) {
+	mockTpm, mockTpmFactory, mockConfigFactory, mockConnectorFactory := testRotateAkFactories(nil)
+	mockConfigFactory.ExpectedCalls = nil
+	mockConfigFactory.On("LoadConfig", mock.Anything).Return(testRotateAkConfig(), nil)
+	mockConfigFactory.On("UpdateAkConfig", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("Unit test failure"))
+
+	cmd := newRotateAkCommand(&mockTpmFactory, &mockConfigFactory, &mockConnectorFactory)
+	cmd.SetArgs([]string{"--" + constants.ConfigOptions.Name, testNonExistentFileName})
+	cmd.SetOut(&bytes.Buffer{})
+
+	err := cmd.Execute()
+	if err == nil {
+		t.Fatal("Expected an error when the config cannot be updated")
+	}
+
+	// the new AK and certificate are removed and the old AK/certificate are untouched
+	mockTpm.AssertCalled(t, "NVDelete", testAkNvIndex+1)
+	mockTpm.AssertCalled(t, "EvictHandle", tpm.DefaultAkHandle+1)
+	mockTpm.AssertNotCalled(t, "EvictHandle", tpm.DefaultAkHandle)
+	mockTpm.AssertNotCalled(t, "NVDelete", testAkNvIndex)
+}
+
+func TestRotateAkNegative(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		cmdArgs []string
+	}{
+		{
+			name:    "Missing TPM Config",
+			cfg:     &Config{TrustAuthorityApiUrl: testValidUrl},
+			cmdArgs: []string{},
+		},
+		{
+			name: "File AK Certificate",
+			cfg: &Config{
+				TrustAuthorityApiUrl: testValidUrl,
+				Tpm:                  &TpmConfig{AkCertificateUri: "file:///tmp/ak.der"},
+			},
+			cmdArgs: []string{},
+		},
+		{
+			name: "Invalid New AK Handle",
+			cfg: &Config{
+				TrustAuthorityApiUrl: testValidUrl,
+				Tpm:                  &TpmConfig{AkCertificateUri: "nvram://0x01C101D0"},
+			},
+			cmdArgs: []string{"--" + constants.NewAkHandleOptions.Name, "invalid"},
+		},
+		{
+			name: "Same AK Handle",
+			cfg: &Config{
+				TrustAuthorityApiUrl: testValidUrl,
+				Tpm:                  &TpmConfig{AkCertificateUri: "nvram://0x01C101D0"},
+			},
+			cmdArgs: []string{"--" + constants.NewAkHandleOptions.Name, "0x81000801"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newRotateAkCommand(&MockTpmFactory{}, mockConfigFactory(tt.cfg), &MockConnectorFactory{})
+			cmd.SetArgs(append([]string{"--" + constants.ConfigOptions.Name, testNonExistentFileName}, tt.cmdArgs...))
+
+			err := cmd.Execute()
+			if err == nil {
+				t.Error("Expected an error but none occurred")
+			}
+		})
+	}
+}
+
+func testRotateAkFactories(connectorErr error) (*MockTpm, MockTpmFactory, MockConfigFactory, MockConnectorFactory) {
+	mockTpm := MockTpm{}
+	mockTpm.On("HandleExists", tpm.DefaultEkHandle).Return(true)
+	mockTpm.On("HandleExists", tpm.DefaultAkHandle).Return(true)
+	mockTpm.On("HandleExists", mock.Anything).Return(false)
+	mockTpm.On("CreateAK", mock.Anything, mock.Anything).Return(nil)
+	mockTpm.On("ReadPublic", mock.Anything).Return(testAkPub, []byte{}, []byte{}, nil)
+	mockTpm.On("GetEKCertificate", mock.Anything).Return(testCertificate, nil)
+	mockTpm.On("ActivateCredential", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(testAesKey, nil)
+	mockTpm.On("NVExists", testAkNvIndex+1).Return(false).Once()
+	mockTpm.On("NVExists", mock.Anything).Return(true)
+	mockTpm.On("NVDelete", mock.Anything).Return(nil)
+	mockTpm.On("NVDefine", mock.Anything, mock.Anything).Return(nil)
+	mockTpm.On("NVWrite", mock.Anything, mock.Anything).Return(nil)
+	mockTpm.On("EvictHandle", mock.Anything).Return(nil)
+
+	mockTpmFactory := MockTpmFactory{}
+	mockTpmFactory.On("New", mock.Anything, mock.Anything).Return(&mockTpm, nil)
+
+	mockConnector := MockConnector{}
+	mockConnector.On("GetAKCertificate", mock.Anything, mock.Anything).Return([]byte{}, []byte{}, testEncryptedAkCert, connectorErr)
+
+	mockConnectorFactory := MockConnectorFactory{}
+	mockConnectorFactory.On("NewConnector", mock.Anything).Return(&mockConnector, nil)
+
+	mockConfigFactory := MockConfigFactory{}
+	mockConfigFactory.On("LoadConfig", mock.Anything).Return(testRotateAkConfig(), nil)
+	mockConfigFactory.On("UpdateAkConfig", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	return &mockTpm, mockTpmFactory, mockConfigFactory, mockConnectorFactory
+}
+
+func testRotateAkConfig() *Config {
+	return &Config{
+		TrustAuthorityApiUrl: testValidUrl,
+		Tpm: &TpmConfig{
+			AkCertificateUri: "nvram://0x01C101D0",
+		},
+	}
+}
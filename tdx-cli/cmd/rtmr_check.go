@@ -0,0 +1,186 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/intel/trustauthority-client/go-tdx"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// rtmrNames are the keys of the expected RTMRs json file (the index corresponds to the
+// RTMR).
+var rtmrNames = [...]string{"rtmr0", "rtmr1", "rtmr2", "rtmr3"}
+
+func newRtmrCheckCommand(tdxAdapterFactory TdxAdapterFactory) *cobra.Command {
+	var expectedPath string
+
+	cmd := cobra.Command{
+		Use:   constants.RtmrCheckCmd,
+		Short: "Compares the TD's RTMRs with expected values",
+		Long: `Use this command (ex. in a golden image pipeline) to verify that the TD's runtime
+ measurements match the values expected from a reproducible build.  The RTMRs of the TD
+ quote are replayed from the CCEL and compared with the --expected json file, the command
+ exits with an error when an RTMR differs.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			expected, err := readExpectedRtmrs(expectedPath)
+			if err != nil {
+				return err
+			}
+
+			_, err = resolveCcel(cmd.ErrOrStderr(), "", true, true)
+			if err != nil {
+				return err
+			}
+
+			quote, ccel, err := collectQuoteAndCcel(tdxAdapterFactory)
+			if err != nil {
+				return err
+			}
+
+			return checkRtmrs(cmd.OutOrStdout(), expected, quote, ccel)
+		},
+	}
+
+	cmd.Flags().StringVarP(&expectedPath, constants.ExpectedRtmrsOptions.Name, constants.ExpectedRtmrsOptions.ShortHand, "", constants.ExpectedRtmrsOptions.Description)
+	cmd.MarkFlagRequired(constants.ExpectedRtmrsOptions.Name)
+
+	return &cmd
+}
+
+// readExpectedRtmrs reads the expected RTMRs from the json file at 'path' (ex. {"rtmr1":
+// "<sha384 hex>"}).  RTMRs that are not in the file are not checked.
+func readExpectedRtmrs(path string) (map[string]tdx.HexBytes, error) {
+	path, err := ValidateFilePath(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid expected RTMRs file path provided")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read the expected RTMRs file %q", path)
+	}
+
+	var expected map[string]tdx.HexBytes
+	err = json.Unmarshal(data, &expected)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse the expected RTMRs file %q", path)
+	}
+
+	if len(expected) == 0 {
+		return nil, errors.Errorf("The expected RTMRs file %q does not contain any RTMRs", path)
+	}
+
+	for name, value := range expected {
+		if !isRtmrName(name) {
+			return nil, errors.Errorf("Invalid RTMR %q in %q, expected one of rtmr0-rtmr3", name, path)
+		}
+
+		if len(value) != crypto.SHA384.Size() {
+			return nil, errors.Errorf("Invalid %s value in %q, expected a %d byte sha384 digest", name, path, crypto.SHA384.Size())
+		}
+	}
+
+	return expected, nil
+}
+
+func isRtmrName(name string) bool {
+	for _, rtmrName := range rtmrNames {
+		if name == rtmrName {
+			return true
+		}
+	}
+	return false
+}
+
+// collectQuoteAndCcel returns the quote and CCEL of TDX evidence collected from the host.
+func collectQuoteAndCcel(tdxAdapterFactory TdxAdapterFactory) ([]byte, []byte, error) {
+	adapter, err := tdxAdapterFactory.New("", true)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Error while creating tdx adapter")
+	}
+
+	evidence, err := adapter.GetEvidence(nil, nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to collect TDX evidence")
+	}
+
+	evidenceJson, err := json.Marshal(evidence)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to marshal TDX evidence")
+	}
+
+	var tdxEvidence struct {
+		Quote    []byte `json:"quote"`
+		EventLog []byte `json:"event_log"`
+	}
+	err = json.Unmarshal(evidenceJson, &tdxEvidence)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to unmarshal TDX evidence")
+	}
+
+	if len(tdxEvidence.Quote) == 0 {
+		return nil, nil, errors.New("The TDX evidence does not contain a quote")
+	}
+
+	if len(tdxEvidence.EventLog) == 0 {
+		return nil, nil, errors.New("The TDX evidence does not contain the CCEL")
+	}
+
+	return tdxEvidence.Quote, tdxEvidence.EventLog, nil
+}
+
+// checkRtmrs writes the result of comparing the RTMRs replayed from 'ccel' with the
+// 'expected' RTMRs to 'w'.  For example...
+//
+//	rtmr1 (sha384): match 0x5C0F...
+//	rtmr2 (sha384): mismatch, expected 0x0000..., actual 0x8A27...
+//
+// An error is returned if any RTMR differs or the RTMRs of 'quote' do not match the CCEL.
+func checkRtmrs(w io.Writer, expected map[string]tdx.HexBytes, quote []byte, ccel []byte) error {
+	replayed, err := tdx.ReplayCcel(ccel)
+	if err != nil {
+		return errors.Wrap(err, "Failed to replay the CCEL")
+	}
+
+	mismatches := 0
+	err = tdx.VerifyQuoteAgainstCcel(quote, ccel)
+	if errors.Is(err, tdx.ErrorRtmrMismatch) {
+		fmt.Fprintf(w, "quote: %s\n", err.Error())
+		mismatches++
+	} else if err != nil {
+		return errors.Wrap(err, "Failed to compare the TD quote with the CCEL")
+	}
+
+	for i, name := range rtmrNames {
+		actual := replayed[i]
+		expectedRtmr, ok := expected[name]
+		if !ok {
+			fmt.Fprintf(w, "%s (sha384): not checked 0x%X\n", name, []byte(actual))
+		} else if !bytes.Equal(expectedRtmr, actual) {
+			fmt.Fprintf(w, "%s (sha384): mismatch, expected 0x%X, actual 0x%X\n", name, []byte(expectedRtmr), []byte(actual))
+			mismatches++
+		} else {
+			fmt.Fprintf(w, "%s (sha384): match 0x%X\n", name, []byte(actual))
+		}
+	}
+
+	if mismatches > 0 {
+		return errors.Errorf("%d RTMR check(s) failed", mismatches)
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testTdxQuotePath = "../../go-tdx/test/resources/quote.bin"
+	testCcelDataPath = "../../go-tdx/test/resources/CCEL.data.bin"
+
+	// offset of RTMR0 in a v4 quote (header + td report body fields before the RTMRs)
+	testQuoteRtmrOffset = 48 + 328
+)
+
+// the RTMRs replayed from the go-tdx CCEL test resources
+var testCcelRtmrs = []string{
+	"8083cd6898cc52a90231cdf9c0532bf9513c40465c6f71e56cbe32ee2c11a9dfc030297ca3ca0f62477d6d1f610d3fdb",
+	"6484f0d72c03521c0434553be34e8db8228b729e799666d2b7754085c77aa9981f5a440df3047194b24f212ff1160c1e",
+	"c3e7ed9d7e909b29732f676d01dc63de869b049362b522a315cb042689670be07344c347cf85d985c7b928d4934e41e1",
+	"000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000",
+}
+
+// rtmrCheckMockTdxAdapterFactory returns a tdx adapter factory whose evidence contains the
+// test quote (with its RTMRs replaced by 'rtmrs') and the test CCEL.
+func rtmrCheckMockTdxAdapterFactory(t *testing.T, rtmrs []string) (TdxAdapterFactory, []byte) {
+	quote, err := os.ReadFile(testTdxQuotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, rtmr := range rtmrs {
+		b, err := hex.DecodeString(rtmr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(quote[testQuoteRtmrOffset+i*len(b):], b)
+	}
+
+	ccel, err := os.ReadFile(testCcelDataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockCompositeAdapter := MockCompositeEvidenceAdapter{}
+	mockCompositeAdapter.On("GetEvidence", mock.Anything, mock.Anything).Return(map[string]interface{}{
+		"quote":     quote,
+		"event_log": ccel,
+	}, nil)
+
+	mockTdxAdapterFactory := MockTdxAdapterFactory{}
+	mockTdxAdapterFactory.On("New", "", true).Return(&mockCompositeAdapter, nil)
+
+	return &mockTdxAdapterFactory, ccel
+}
+
+func writeExpectedRtmrs(t *testing.T, json string) string {
+	path := filepath.Join(t.TempDir(), "expected-rtmrs.json")
+	err := os.WriteFile(path, []byte(json), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runRtmrCheckCmd(tdxAdapterFactory TdxAdapterFactory, expectedPath string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := newRtmrCheckCommand(tdxAdapterFactory)
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--" + constants.ExpectedRtmrsOptions.Name, expectedPath})
+
+	err := cmd.Execute()
+	return stdout.String(), err
+}
+
+func TestRtmrCheckCmdMatch(t *testing.T) {
+	tdxAdapterFactory, ccel := rtmrCheckMockTdxAdapterFactory(t, testCcelRtmrs)
+	defer fakeCcel(ccel, nil)()
+
+	expectedPath := writeExpectedRtmrs(t, `{
+		"rtmr0": "`+testCcelRtmrs[0]+`",
+		"rtmr1": "`+testCcelRtmrs[1]+`",
+		"rtmr2": "`+testCcelRtmrs[2]+`"
+	}`)
+
+	stdout, err := runRtmrCheckCmd(tdxAdapterFactory, expectedPath)
+	assert.NoError(t, err)
+
+	expected := "rtmr0 (sha384): match 0x" + strings.ToUpper(testCcelRtmrs[0]) + "\n" +
+		"rtmr1 (sha384): match 0x" + strings.ToUpper(testCcelRtmrs[1]) + "\n" +
+		"rtmr2 (sha384): match 0x" + strings.ToUpper(testCcelRtmrs[2]) + "\n" +
+		"rtmr3 (sha384): not checked 0x" + strings.ToUpper(testCcelRtmrs[3]) + "\n"
+	assert.Equal(t, expected, stdout)
+}
+
+func TestRtmrCheckCmdMismatch(t *testing.T) {
+	tdxAdapterFactory, ccel := rtmrCheckMockTdxAdapterFactory(t, testCcelRtmrs)
+	defer fakeCcel(ccel, nil)()
+
+	otherRtmr := strings.Repeat("ab", 48)
+	expectedPath := writeExpectedRtmrs(t, `{"rtmr1": "`+testCcelRtmrs[1]+`", "rtmr2": "`+otherRtmr+`"}`)
+
+	stdout, err := runRtmrCheckCmd(tdxAdapterFactory, expectedPath)
+	assert.ErrorContains(t, err, "1 RTMR check(s) failed")
+	assert.Contains(t, stdout, "rtmr1 (sha384): match 0x"+strings.ToUpper(testCcelRtmrs[1])+"\n")
+	assert.Contains(t, stdout, "rtmr2 (sha384): mismatch, expected 0x"+strings.ToUpper(otherRtmr)+", actual 0x"+strings.ToUpper(testCcelRtmrs[2])+"\n")
+}
+
+func TestRtmrCheckCmdQuoteMismatch(t *testing.T) {
+	// the quote's RTMRs are not those replayed from the CCEL
+	tdxAdapterFactory, ccel := rtmrCheckMockTdxAdapterFactory(t, nil)
+	defer fakeCcel(ccel, nil)()
+
+	expectedPath := writeExpectedRtmrs(t, `{"rtmr1": "`+testCcelRtmrs[1]+`"}`)
+
+	stdout, err := runRtmrCheckCmd(tdxAdapterFactory, expectedPath)
+	assert.ErrorContains(t, err, "1 RTMR check(s) failed")
+	assert.Contains(t, stdout, "quote: the quote's RTMR does not match the value replayed from the CCEL: rtmr0")
+	assert.Contains(t, stdout, "rtmr1 (sha384): match 0x"+strings.ToUpper(testCcelRtmrs[1])+"\n")
+}
+
+func TestRtmrCheckCmdInvalidExpected(t *testing.T) {
+	tdxAdapterFactory, ccel := rtmrCheckMockTdxAdapterFactory(t, testCcelRtmrs)
+	defer fakeCcel(ccel, nil)()
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"empty", `{}`},
+		{"invalid json", `{"rtmr1":`},
+		{"invalid rtmr", `{"rtmr4": "` + testCcelRtmrs[0] + `"}`},
+		{"invalid hex", `{"rtmr1": "xyz"}`},
+		{"invalid size", `{"rtmr1": "abcd"}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := runRtmrCheckCmd(tdxAdapterFactory, writeExpectedRtmrs(t, tc.expected))
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := runRtmrCheckCmd(tdxAdapterFactory, testNonExistentFileName)
+	assert.Error(t, err)
+}
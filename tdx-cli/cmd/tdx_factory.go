@@ -7,14 +7,21 @@
 package cmd
 
 import (
+	"fmt"
+	"io"
 	"strings"
 
 	"github.com/intel/trustauthority-client/go-aztdx"
 	"github.com/intel/trustauthority-client/go-connector"
 	"github.com/intel/trustauthority-client/go-tdx"
 	"github.com/intel/trustauthority-client/go-tpm"
+	"github.com/pkg/errors"
 )
 
+// getCcel reads the host's CCEL (replaced by unit tests to fake the host's ACPI
+// tables).
+var getCcel = tdx.GetCcel
+
 // TdxAdapterFactory is an interface for creating TDX adapters.
 type TdxAdapterFactory interface {
 	New(cloudProvider string, eventLogDisabled bool) (connector.CompositeEvidenceAdapter, error)
@@ -46,3 +53,40 @@ func (f *tdxAdapterFactory) New(cloudProvider string, withCcel bool) (connector.
 
 	return tdxAdapter, nil
 }
+
+// resolveCcel returns whether the CCEL should be included in TDX evidence (i.e., the
+// 'withCcel' parameter of TdxAdapterFactory.New).  When 'requireCcel' is set, an error is
+// returned if the host does not provide the CCEL.  Otherwise, when 'withCcel' is set and
+// the CCEL is not available, a warning is written to 'w' and the TDX evidence is collected
+// without it.
+func resolveCcel(w io.Writer, cloudProvider string, withCcel bool, requireCcel bool) (bool, error) {
+	if !withCcel && !requireCcel {
+		return false, nil
+	}
+
+	if strings.ToLower(cloudProvider) == CloudProviderAzure {
+		if requireCcel {
+			return false, errors.New("The CCEL is not supported by Azure TDX evidence")
+		}
+		return false, nil
+	}
+
+	_, err := getCcel()
+	if err == nil {
+		return true, nil
+	}
+
+	if errors.Is(err, tdx.ErrorCcelTableNotFound) || errors.Is(err, tdx.ErrorCcelDataNotFound) {
+		err = errors.New("The Confidential Computing Event Log (CCEL) is not available on this host, " +
+			"make sure the command is run with sufficient privileges in a TD that exposes the CCEL ACPI table")
+	} else {
+		err = errors.Wrap(err, "Failed to read the Confidential Computing Event Log (CCEL)")
+	}
+
+	if requireCcel {
+		return false, err
+	}
+
+	fmt.Fprintf(w, "Warning: %s, TDX evidence will not include the CCEL\n", err.Error())
+	return false, nil
+}
@@ -0,0 +1,138 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/intel/trustauthority-client/go-tdx"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeCcel replaces the host's CCEL with 'ccel' (or 'err') and returns a function
+// that restores it.
+func fakeCcel(ccel []byte, err error) func() {
+	getCcel = func() ([]byte, error) {
+		return ccel, err
+	}
+
+	return func() {
+		getCcel = tdx.GetCcel
+	}
+}
+
+// tdxConfigFactory returns a config factory for a (non-Azure) TD.
+func tdxConfigFactory() ConfigFactory {
+	return mockConfigFactory(&Config{
+		TrustAuthorityApiUrl: testValidUrl,
+		TrustAuthorityUrl:    testValidUrl,
+		TrustAuthorityApiKey: testApiKey,
+	})
+}
+
+func TestCcelModes(t *testing.T) {
+	missingCcel := fmt.Errorf("%w: open /sys/firmware/acpi/tables/CCEL: no such file or directory", tdx.ErrorCcelTableNotFound)
+
+	tests := []struct {
+		name           string
+		ccelErr        error
+		args           []string
+		errorExpected  bool
+		expectWithCcel bool
+		expectWarning  bool
+	}{
+		{
+			name:           "CCEL available",
+			args:           []string{"--" + constants.WithCcelOptions.Name},
+			expectWithCcel: true,
+		},
+		{
+			name:          "CCEL missing, warning",
+			ccelErr:       missingCcel,
+			args:          []string{"--" + constants.WithCcelOptions.Name},
+			expectWarning: true,
+		},
+		{
+			name:           "CCEL required and available",
+			args:           []string{"--" + constants.RequireCcelOptions.Name},
+			expectWithCcel: true,
+		},
+		{
+			name:          "CCEL required and missing",
+			ccelErr:       missingCcel,
+			args:          []string{"--" + constants.RequireCcelOptions.Name},
+			errorExpected: true,
+		},
+		{
+			name:    "CCEL not requested",
+			ccelErr: missingCcel,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer fakeCcel([]byte{0x01}, tt.ccelErr)()
+
+			tdxAdapterFactory := happyMockTdxAdapterFactory().(*MockTdxAdapterFactory)
+
+			var stderr bytes.Buffer
+			cmd := newEvidenceCommand(tdxAdapterFactory, happyMockTpmAdapterFactory(), tdxConfigFactory(), happyMockConnectorFactory())
+			cmd.SetErr(&stderr)
+			cmd.SetArgs(append([]string{
+				"--" + constants.ConfigOptions.Name,
+				testNonExistentFileName,
+				"--" + constants.WithTdxOptions.Name,
+			}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.errorExpected {
+				assert.ErrorContains(t, err, "CCEL")
+				assert.ErrorContains(t, err, "is not available on this host")
+				tdxAdapterFactory.AssertNotCalled(t, "New", mock.Anything, mock.Anything)
+				return
+			}
+
+			assert.NoError(t, err)
+			tdxAdapterFactory.AssertCalled(t, "New", mock.Anything, tt.expectWithCcel)
+
+			if tt.expectWarning {
+				assert.Contains(t, stderr.String(), "Warning: The Confidential Computing Event Log (CCEL) is not available on this host")
+			} else {
+				assert.NotContains(t, stderr.String(), "Warning")
+			}
+		})
+	}
+}
+
+func TestTokenRequireCcelMissing(t *testing.T) {
+	defer fakeCcel(nil, tdx.ErrorCcelDataNotFound)()
+
+	tdxAdapterFactory := happyMockTdxAdapterFactory().(*MockTdxAdapterFactory)
+	cmd := newTokenCommand(tdxAdapterFactory, happyMockTpmAdapterFactory(), tdxConfigFactory(), happyMockConnectorFactory())
+	cmd.SetArgs([]string{
+		"--" + constants.ConfigOptions.Name,
+		confFilePath,
+		"--" + constants.RequireCcelOptions.Name,
+	})
+
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "The Confidential Computing Event Log (CCEL) is not available on this host")
+	tdxAdapterFactory.AssertNotCalled(t, "New", mock.Anything, mock.Anything)
+}
+
+func TestRequireCcelAzure(t *testing.T) {
+	withCcel, err := resolveCcel(&bytes.Buffer{}, CloudProviderAzure, true, false)
+	assert.NoError(t, err)
+	assert.False(t, withCcel)
+
+	_, err = resolveCcel(&bytes.Buffer{}, CloudProviderAzure, false, true)
+	assert.Error(t, err)
+}
@@ -7,14 +7,12 @@
 package cmd
 
 import (
-	"crypto/tls"
 	"encoding/base64"
-	"encoding/pem"
+	"encoding/json"
 	"fmt"
 	"os"
 	"regexp"
 
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/intel/trustauthority-client/go-connector"
 	"github.com/intel/trustauthority-client/go-tpm"
@@ -46,7 +44,7 @@ func newTokenCommand(tdxAdapterFactory TdxAdapterFactory,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			err := getToken(cmd, tdxAdapterFactory, tpmAdapterFactory, cfgFactory, ctrFactory)
 			if err != nil {
-				fmt.Fprintln(os.Stderr, err.Error())
+				printError(os.Stderr, err)
 				return err
 			}
 			return nil
@@ -56,18 +54,27 @@ func newTokenCommand(tdxAdapterFactory TdxAdapterFactory,
 	tokenCmd.Flags().StringP(constants.ConfigOptions.Name, constants.ConfigOptions.ShortHand, "", constants.ConfigOptions.Description)
 	tokenCmd.Flags().StringP(constants.UserDataOptions.Name, constants.UserDataOptions.ShortHand, "", constants.UserDataOptions.Description)
 	tokenCmd.Flags().StringP(constants.PolicyIdsOptions.Name, constants.PolicyIdsOptions.ShortHand, "", constants.PolicyIdsOptions.Description)
-	tokenCmd.Flags().StringP(constants.PublicKeyPathOption, "f", "", "Public key to be used as userdata")
+	tokenCmd.Flags().StringP(constants.PublicKeyPathOption, "f", "", "Public key (PEM or DER) to be used as userdata")
 	tokenCmd.Flags().StringP(constants.RequestIdOptions.Name, constants.RequestIdOptions.ShortHand, "", constants.RequestIdOptions.Description)
+	tokenCmd.Flags().Bool(constants.PrintRequestIdOptions.Name, false, constants.PrintRequestIdOptions.Description)
+	tokenCmd.Flags().Bool(constants.VerifyTokenOptions.Name, false, constants.VerifyTokenOptions.Description)
 	tokenCmd.Flags().StringP(constants.TokenAlgOptions.Name, constants.TokenAlgOptions.ShortHand, "", constants.TokenAlgOptions.Description)
 	tokenCmd.Flags().Bool(constants.PolicyMustMatchOptions.Name, false, constants.PolicyMustMatchOptions.Description)
+	tokenCmd.Flags().String(constants.AudienceOptions.Name, "", constants.AudienceOptions.Description)
+	tokenCmd.Flags().String(constants.TokenTypeOptions.Name, "", constants.TokenTypeOptions.Description)
+	tokenCmd.Flags().String(constants.ContextOptions.Name, "", constants.ContextOptions.Description)
+	tokenCmd.Flags().StringArray(constants.ClaimOptions.Name, nil, constants.ClaimOptions.Description)
 	tokenCmd.Flags().Bool(constants.WithTdxOptions.Name, false, constants.WithTdxOptions.Description)
 	tokenCmd.Flags().Bool(constants.WithTpmOptions.Name, false, constants.WithTpmOptions.Description)
+	tokenCmd.Flags().Bool(constants.AutoOptions.Name, false, constants.AutoOptions.Description)
 	tokenCmd.Flags().Bool(constants.NoVerifierNonceOptions.Name, false, constants.NoVerifierNonceOptions.Description)
 	tokenCmd.Flags().Bool(constants.WithImaLogsOptions.Name, false, constants.WithImaLogsOptions.Description)
 	tokenCmd.Flags().Bool(constants.WithEventLogsOptions.Name, false, constants.WithEventLogsOptions.Description)
 	tokenCmd.Flags().Bool(constants.WithCcelOptions.Name, false, constants.WithCcelOptions.Description)
+	tokenCmd.Flags().Bool(constants.RequireCcelOptions.Name, false, constants.RequireCcelOptions.Description)
 
-	tokenCmd.MarkFlagRequired(constants.ConfigOptions.Name)
+	tokenCmd.MarkFlagsMutuallyExclusive(constants.AutoOptions.Name, constants.WithTdxOptions.Name)
+	tokenCmd.MarkFlagsMutuallyExclusive(constants.AutoOptions.Name, constants.WithTpmOptions.Name)
 	return &tokenCmd
 }
 
@@ -85,21 +92,27 @@ func getToken(cmd *cobra.Command,
 	}
 	config, err := cfgFactory.LoadConfig(configFile)
 	if err != nil {
-		return errors.Wrapf(err, "Could not read config file %q", configFile)
+		return withErrorCode(errors.Wrapf(err, "Could not read config file %q", configFile), ErrorCodeConfig, "")
 	}
 
 	// token requires Trust Authority API URL and API key
 	if config.TrustAuthorityApiUrl == "" || config.TrustAuthorityApiKey == "" {
-		return errors.New("Either Trust Authority API URL or Trust Authority API Key is missing in config")
+		return withErrorCode(errors.New("Either Trust Authority API URL or Trust Authority API Key is missing in config"), ErrorCodeConfig, "")
 	}
 
-	tlsConfig := &tls.Config{
-		CipherSuites: []uint16{
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-		},
-		InsecureSkipVerify: false,
-		MinVersion:         tls.VersionTLS12,
+	verifyToken, err := cmd.Flags().GetBool(constants.VerifyTokenOptions.Name)
+	if err != nil {
+		return err
+	}
+
+	// verifying the token requires the Trust Authority URL to download the signing certificates
+	if verifyToken && config.TrustAuthorityUrl == "" {
+		return withErrorCode(errors.New("Trust Authority URL must be present in config to verify the token"), ErrorCodeConfig, "")
+	}
+
+	tlsConfig, err := newTlsConfig(config.Tls)
+	if err != nil {
+		return withErrorCode(err, ErrorCodeConfig, "")
 	}
 
 	cfg := connector.Config{
@@ -108,18 +121,13 @@ func getToken(cmd *cobra.Command,
 		ApiKey: config.TrustAuthorityApiKey,
 	}
 
-	trustAuthorityConnector, err := ctrFactory.NewConnector(&cfg)
-	if err != nil {
-		return err
+	if verifyToken {
+		cfg.BaseUrl = config.TrustAuthorityUrl
 	}
 
-	_, err = base64.URLEncoding.DecodeString(config.TrustAuthorityApiKey)
+	trustAuthorityConnector, err := ctrFactory.NewConnector(&cfg)
 	if err != nil {
-		// check if jwt token is passed instead of api-key (packaged software use-case)
-		_, _, err = new(jwt.Parser).ParseUnverified(config.TrustAuthorityApiKey, jwt.MapClaims{})
-		if err != nil {
-			return errors.Wrap(err, "Invalid Trust Authority Api key")
-		}
+		return err
 	}
 
 	userData, err := cmd.Flags().GetString(constants.UserDataOptions.Name)
@@ -142,11 +150,41 @@ func getToken(cmd *cobra.Command,
 		return err
 	}
 
+	printRequestId, err := cmd.Flags().GetBool(constants.PrintRequestIdOptions.Name)
+	if err != nil {
+		return err
+	}
+
 	tokenSigningAlg, err := cmd.Flags().GetString(constants.TokenAlgOptions.Name)
 	if err != nil {
 		return err
 	}
 
+	audience, err := cmd.Flags().GetString(constants.AudienceOptions.Name)
+	if err != nil {
+		return err
+	}
+
+	tokenType, err := cmd.Flags().GetString(constants.TokenTypeOptions.Name)
+	if err != nil {
+		return err
+	}
+
+	evidenceContext, err := cmd.Flags().GetString(constants.ContextOptions.Name)
+	if err != nil {
+		return err
+	}
+
+	claims, err := cmd.Flags().GetStringArray(constants.ClaimOptions.Name)
+	if err != nil {
+		return err
+	}
+
+	claimsRequest, err := parseClaimsRequest(claims)
+	if err != nil {
+		return err
+	}
+
 	noVerifierNonce, err := cmd.Flags().GetBool(constants.NoVerifierNonceOptions.Name)
 	if err != nil {
 		return err
@@ -182,14 +220,29 @@ func getToken(cmd *cobra.Command,
 		return err
 	}
 
+	requireCcel, err := cmd.Flags().GetBool(constants.RequireCcelOptions.Name)
+	if err != nil {
+		return err
+	}
+
 	withUefiEventLogs, err := cmd.Flags().GetBool(constants.WithEventLogsOptions.Name)
 	if err != nil {
 		return err
 	}
 
-	// backward compatibility cli options: if the user did not specify "--tdx" or "--tpm" options,
-	// include TDX evidence by default
-	if !withTdx && !withTpm {
+	auto, err := cmd.Flags().GetBool(constants.AutoOptions.Name)
+	if err != nil {
+		return err
+	}
+
+	if auto {
+		withTdx, withTpm, err = autoSelectEvidence(cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+	} else if !withTdx && !withTpm {
+		// backward compatibility cli options: if the user did not specify "--tdx" or "--tpm" options,
+		// include TDX evidence by default
 		withTdx = true
 	}
 
@@ -209,11 +262,10 @@ func getToken(cmd *cobra.Command,
 			return errors.Wrap(err, "Error reading public key from file")
 		}
 
-		publicKeyBlock, _ := pem.Decode(publicKey)
-		if publicKeyBlock == nil {
-			return errors.New("No PEM data found in public key file")
+		userDataBytes, err = parsePublicKeyBytes(publicKey)
+		if err != nil {
+			return err
 		}
-		userDataBytes = publicKeyBlock.Bytes
 	}
 	if len(userDataBytes) != 0 {
 		builderOptions = append(builderOptions, connector.WithUserData(userDataBytes))
@@ -245,7 +297,36 @@ func getToken(cmd *cobra.Command,
 		builderOptions = append(builderOptions, connector.WithTokenSigningAlgorithm(signingAlg))
 	}
 
+	if audience != "" {
+		if !connector.ValidateTokenAudience(audience) {
+			return errors.Errorf("%q is not a valid token audience", audience)
+		}
+
+		builderOptions = append(builderOptions, connector.WithTokenAudience(audience))
+	}
+
+	if tokenType != "" {
+		if !connector.ValidateTokenType(tokenType) {
+			return errors.Errorf("%q is not a valid token type", tokenType)
+		}
+
+		builderOptions = append(builderOptions, connector.WithTokenType(tokenType))
+	}
+
+	if evidenceContext != "" {
+		builderOptions = append(builderOptions, connector.WithEvidenceContext(evidenceContext))
+	}
+
+	if claimsRequest != nil {
+		builderOptions = append(builderOptions, connector.WithClaimsRequest(claimsRequest))
+	}
+
 	if withTdx {
+		withCcel, err = resolveCcel(cmd.ErrOrStderr(), config.CloudProvider, withCcel, requireCcel)
+		if err != nil {
+			return err
+		}
+
 		tdxAdapter, err := tdxAdapterFactory.New(config.CloudProvider, withCcel)
 		if err != nil {
 			return errors.Wrap(err, "Error creating tdx adapter")
@@ -287,16 +368,38 @@ func getToken(cmd *cobra.Command,
 	}
 
 	response, err := trustAuthorityConnector.AttestEvidence(evidence, config.CloudProvider, reqId)
+	var traceId string
 	if response.Headers != nil {
-		fmt.Fprintln(os.Stderr, "Trace Id:", response.Headers.Get(connector.HeaderTraceId))
-		if reqId != "" {
-			fmt.Fprintln(os.Stderr, "Request Id:", response.Headers.Get(connector.HeaderRequestId))
-		}
+		traceId = response.Headers.Get(connector.HeaderTraceId)
+		fmt.Fprintln(cmd.ErrOrStderr(), "Trace Id:", traceId)
 	}
+
+	// always display the effective request id (provided or generated) so that it
+	// can be used for troubleshooting
+	fmt.Fprintln(cmd.ErrOrStderr(), "Request Id:", reqId)
 	if err != nil {
-		return err
+		return withErrorCode(withAuthGuidance(err), ErrorCodeAttestation, traceId)
+	}
+
+	if verifyToken {
+		_, err = trustAuthorityConnector.VerifyToken(response.Token)
+		if err != nil {
+			return withErrorCode(errors.Wrap(err, "The token could not be verified"), ErrorCodeAttestation, traceId)
+		}
+	}
+
+	if printRequestId {
+		requestIdJson, err := json.Marshal(struct {
+			RequestId string `json:"request_id"`
+		}{
+			RequestId: reqId,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(requestIdJson))
 	}
 
-	fmt.Fprint(os.Stdout, response.Token)
+	fmt.Fprint(cmd.OutOrStdout(), response.Token)
 	return nil
 }
@@ -7,9 +7,20 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"io"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 	"github.com/intel/trustauthority-client/go-connector"
 	"github.com/intel/trustauthority-client/go-tpm"
 	"github.com/intel/trustauthority-client/tdx-cli/constants"
@@ -212,6 +223,120 @@ func TestTokenCmd(t *testing.T) {
 				return createDefaultMocks()
 			},
 		},
+		{
+			args: []string{
+				constants.TokenCmd,
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+				"--" + constants.AudienceOptions.Name,
+				"https://relying-party.example.com",
+			},
+			wantErr:     false,
+			description: "Test with valid audience",
+			dependencyMocks: func() (TdxAdapterFactory, tpm.TpmAdapterFactory, ConfigFactory, connector.ConnectorFactory) {
+				return createDefaultMocks()
+			},
+		},
+		{
+			args: []string{
+				constants.TokenCmd,
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+				"--" + constants.AudienceOptions.Name,
+				"relying party",
+			},
+			wantErr:     true,
+			description: "Test with invalid audience",
+			dependencyMocks: func() (TdxAdapterFactory, tpm.TpmAdapterFactory, ConfigFactory, connector.ConnectorFactory) {
+				return createDefaultMocks()
+			},
+		},
+		{
+			args: []string{
+				constants.TokenCmd,
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+				"--" + constants.TokenTypeOptions.Name,
+				"ear",
+			},
+			wantErr:     false,
+			description: "Test with valid token type",
+			dependencyMocks: func() (TdxAdapterFactory, tpm.TpmAdapterFactory, ConfigFactory, connector.ConnectorFactory) {
+				return createDefaultMocks()
+			},
+		},
+		{
+			args: []string{
+				constants.TokenCmd,
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+				"--" + constants.ClaimOptions.Name,
+				"environment=prod",
+				"--" + constants.ClaimOptions.Name,
+				"tenant=acme",
+			},
+			wantErr:     false,
+			description: "Test with claims",
+			dependencyMocks: func() (TdxAdapterFactory, tpm.TpmAdapterFactory, ConfigFactory, connector.ConnectorFactory) {
+				return createDefaultMocks()
+			},
+		},
+		{
+			args: []string{
+				constants.TokenCmd,
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+				"--" + constants.ClaimOptions.Name,
+				"environment",
+			},
+			wantErr:     true,
+			description: "Test with malformed claim",
+			dependencyMocks: func() (TdxAdapterFactory, tpm.TpmAdapterFactory, ConfigFactory, connector.ConnectorFactory) {
+				return createDefaultMocks()
+			},
+		},
+		{
+			args: []string{
+				constants.TokenCmd,
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+				"--" + constants.TokenTypeOptions.Name,
+				"cwt",
+			},
+			wantErr:     true,
+			description: "Test with unknown token type",
+			dependencyMocks: func() (TdxAdapterFactory, tpm.TpmAdapterFactory, ConfigFactory, connector.ConnectorFactory) {
+				return createDefaultMocks()
+			},
+		},
+		{
+			args: []string{
+				constants.TokenCmd,
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+				"--" + constants.ContextOptions.Name,
+				"namespace/workload",
+			},
+			wantErr:     false,
+			description: "Test with valid context",
+			dependencyMocks: func() (TdxAdapterFactory, tpm.TpmAdapterFactory, ConfigFactory, connector.ConnectorFactory) {
+				return createDefaultMocks()
+			},
+		},
+		{
+			args: []string{
+				constants.TokenCmd,
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+				"--" + constants.ContextOptions.Name,
+				"workload\x00",
+			},
+			wantErr:     true,
+			description: "Test with invalid context",
+			dependencyMocks: func() (TdxAdapterFactory, tpm.TpmAdapterFactory, ConfigFactory, connector.ConnectorFactory) {
+				return createDefaultMocks()
+			},
+		},
 		{
 			args: []string{
 				constants.TokenCmd,
@@ -266,7 +391,11 @@ func TestTokenCmd(t *testing.T) {
 					TrustAuthorityApiKey: "@p!key",
 				}, nil)
 
-				return happyMockTdxAdapterFactory(), happyMockTpmAdapterFactory(), &angryConfigFactory, happyMockConnectorFactory()
+				// connector.New validates the api key
+				angryConnectorFactory := MockConnectorFactory{}
+				angryConnectorFactory.On("NewConnector", mock.Anything).Return(&MockConnector{}, connector.ErrInvalidApiKey)
+
+				return happyMockTdxAdapterFactory(), happyMockTpmAdapterFactory(), &angryConfigFactory, &angryConnectorFactory
 			},
 		},
 		{
@@ -298,3 +427,212 @@ func TestTokenCmd(t *testing.T) {
 		})
 	}
 }
+
+func TestTokenCmdApiKey(t *testing.T) {
+	jwtApiKey, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{}).SignedString([]byte("testkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		description string
+		apiKey      string
+		expectedErr error
+	}{
+		{
+			description: "Test malformed api key",
+			apiKey:      "@p!key",
+			expectedErr: connector.ErrInvalidApiKey,
+		},
+		{
+			description: "Test base64 api key",
+			apiKey:      testApiKey,
+		},
+		{
+			description: "Test jwt api key",
+			apiKey:      jwtApiKey,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			// connector.New validates the api key, the command returns its error as-is
+			ctrFactory := happyMockConnectorFactory()
+			if tc.expectedErr != nil {
+				angryConnectorFactory := MockConnectorFactory{}
+				angryConnectorFactory.On("NewConnector", mock.Anything).Return(&MockConnector{}, tc.expectedErr)
+				ctrFactory = &angryConnectorFactory
+			}
+
+			cmd := newTokenCommand(happyMockTdxAdapterFactory(), happyMockTpmAdapterFactory(), mockConfigFactory(&Config{
+				TrustAuthorityUrl:    testValidUrl,
+				TrustAuthorityApiUrl: testValidUrl,
+				TrustAuthorityApiKey: tc.apiKey,
+			}), ctrFactory)
+			cmd.SetArgs([]string{
+				constants.TokenCmd,
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+			})
+
+			err := cmd.Execute()
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTokenCmdRequestId(t *testing.T) {
+	tt := []struct {
+		description string
+		requestId   string
+	}{
+		{
+			description: "Test generated request id is displayed",
+			requestId:   "",
+		},
+		{
+			description: "Test provided request id is displayed",
+			requestId:   "req1",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			args := []string{
+				constants.TokenCmd,
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+				"--" + constants.PrintRequestIdOptions.Name,
+			}
+			if tc.requestId != "" {
+				args = append(args, "--"+constants.RequestIdOptions.Name, tc.requestId)
+			}
+
+			cmd := newTokenCommand(createDefaultMocks())
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&stderr)
+			cmd.SetArgs(args)
+
+			err := cmd.Execute()
+			assert.NoError(t, err)
+
+			// the first line of stdout contains the request id in json format
+			line, err := bufio.NewReader(&stdout).ReadString('\n')
+			assert.NoError(t, err)
+
+			var output struct {
+				RequestId string `json:"request_id"`
+			}
+			err = json.Unmarshal([]byte(line), &output)
+			assert.NoError(t, err)
+
+			if tc.requestId == "" {
+				_, err = uuid.Parse(output.RequestId)
+				assert.NoError(t, err, "Expected a generated uuid request id")
+			} else {
+				assert.Equal(t, tc.requestId, output.RequestId)
+			}
+
+			assert.True(t, strings.Contains(stderr.String(), "Request Id: "+output.RequestId))
+		})
+	}
+}
+
+func TestTokenCmdVerify(t *testing.T) {
+	testToken := "header.payload.signature"
+
+	tt := []struct {
+		description string
+		config      *Config
+		verifyErr   error
+		wantErr     bool
+	}{
+		{
+			description: "Test valid token is printed",
+			verifyErr:   nil,
+			wantErr:     false,
+		},
+		{
+			description: "Test invalid token fails",
+			verifyErr:   errors.New("Failed to verify jwt token"),
+			wantErr:     true,
+		},
+		{
+			description: "Test missing Trust Authority URL",
+			config: &Config{
+				TrustAuthorityApiUrl: testValidUrl,
+				TrustAuthorityApiKey: testApiKey,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			var stdout bytes.Buffer
+
+			mockConnector := MockConnector{}
+			mockConnector.On("GetNonce", mock.Anything).Return(connector.GetNonceResponse{}, nil)
+			mockConnector.On("AttestEvidence", mock.Anything, mock.Anything, mock.Anything).Return(connector.AttestResponse{Token: testToken}, nil)
+			mockConnector.On("VerifyToken", testToken).Return(&jwt.Token{}, tc.verifyErr)
+
+			mockConnectorFactory := MockConnectorFactory{}
+			mockConnectorFactory.On("NewConnector", mock.Anything).Return(&mockConnector, nil)
+
+			cmd := newTokenCommand(happyMockTdxAdapterFactory(), happyMockTpmAdapterFactory(), mockConfigFactory(tc.config), &mockConnectorFactory)
+			cmd.SetOut(&stdout)
+			cmd.SetErr(io.Discard)
+			cmd.SetArgs([]string{
+				constants.TokenCmd,
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+				"--" + constants.VerifyTokenOptions.Name,
+			})
+
+			err := cmd.Execute()
+			if tc.wantErr {
+				assert.Error(t, err)
+				assert.NotContains(t, stdout.String(), testToken, "The token should not be printed")
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testToken, stdout.String())
+			mockConnector.AssertCalled(t, "VerifyToken", testToken)
+		})
+	}
+}
+
+func TestTokenCmdDerPublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	derKeyPath := "publickey.der"
+	_ = os.WriteFile(derKeyPath, der, 0600)
+	defer os.Remove(derKeyPath)
+
+	cmd := newTokenCommand(createDefaultMocks())
+	cmd.SetArgs([]string{
+		constants.TokenCmd,
+		"--" + constants.ConfigOptions.Name,
+		confFilePath,
+		"--" + constants.PublicKeyPathOption,
+		derKeyPath,
+	})
+
+	err = cmd.Execute()
+	assert.NoError(t, err)
+}
@@ -7,17 +7,96 @@
 package cmd
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/pem"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/intel/trustauthority-client/go-connector"
 	"github.com/intel/trustauthority-client/tdx-cli/constants"
 	"github.com/pkg/errors"
 )
 
+// tlsVersions are the values supported by 'tls.min_version' and --tls-min-version.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTlsConfig returns the TLS configuration used by all of the CLI's connections to
+// the Trust Authority.  It starts with the secure defaults of connector.DefaultTlsConfig
+// and applies the policy from the config file ('tlsCfg' can be nil) and the
+// --tls-min-version option.
+func newTlsConfig(tlsCfg *TlsConfig) (*tls.Config, error) {
+	tlsConfig := connector.DefaultTlsConfig()
+
+	minVersion := tlsMinVersion
+	if minVersion == "" && tlsCfg != nil {
+		minVersion = tlsCfg.MinVersion
+	}
+
+	if minVersion != "" {
+		version, ok := tlsVersions[minVersion]
+		if !ok {
+			return nil, errors.Errorf("Invalid minimum TLS version %q, has to be one of 1.2 or 1.3", minVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if tlsCfg != nil && len(tlsCfg.CipherSuites) != 0 {
+		cipherSuites, err := parseCipherSuites(tlsCfg.CipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.CipherSuites = cipherSuites
+	}
+
+	return tlsConfig, nil
+}
+
+// parseCipherSuites converts cipher suite names to their ids, rejecting insecure
+// cipher suites and TLS 1.3 cipher suites (which are not configurable).
+func parseCipherSuites(names []string) ([]uint16, error) {
+	var cipherSuites []uint16
+	for _, name := range names {
+		var found *tls.CipherSuite
+		for _, cs := range tls.CipherSuites() {
+			if cs.Name == name {
+				found = cs
+				break
+			}
+		}
+
+		if found == nil {
+			for _, cs := range tls.InsecureCipherSuites() {
+				if cs.Name == name {
+					return nil, errors.Errorf("Cipher suite %q is insecure and cannot be used", name)
+				}
+			}
+			return nil, errors.Errorf("Unknown cipher suite %q", name)
+		}
+
+		tls12 := false
+		for _, v := range found.SupportedVersions {
+			if v == tls.VersionTLS12 {
+				tls12 = true
+			}
+		}
+		if !tls12 {
+			return nil, errors.Errorf("Cipher suite %q is not configurable, only TLS 1.2 cipher suites can be provided", name)
+		}
+
+		cipherSuites = append(cipherSuites, found.ID)
+	}
+
+	return cipherSuites, nil
+}
+
 func parsePolicyIds(policyIds string) ([]uuid.UUID, error) {
 	var pIds []uuid.UUID
 	if len(policyIds) != 0 {
@@ -34,6 +113,33 @@ func parsePolicyIds(policyIds string) ([]uuid.UUID, error) {
 	return pIds, nil
 }
 
+// parseClaimsRequest converts the repeated "key=value" --claim options into the
+// claims-request metadata of the attestation request (see connector.WithClaimsRequest).
+func parseClaimsRequest(claims []string) (map[string]string, error) {
+	if len(claims) == 0 {
+		return nil, nil
+	}
+
+	claimsRequest := make(map[string]string, len(claims))
+	for _, claim := range claims {
+		key, value, found := strings.Cut(claim, "=")
+		if !found {
+			return nil, errors.Errorf("The claim %q must be in the form key=value", claim)
+		}
+
+		if _, exists := claimsRequest[key]; exists {
+			return nil, errors.Errorf("The claim %q was provided more than once", key)
+		}
+		claimsRequest[key] = value
+	}
+
+	if err := connector.ValidateClaimsRequest(claimsRequest); err != nil {
+		return nil, err
+	}
+
+	return claimsRequest, nil
+}
+
 // string2bytes converts a string to a byte slice. The string can be either a base64 or hex encoded string.
 // The function returns nil bytes if the input string is empty.
 func string2bytes(s string) ([]byte, error) {
@@ -89,3 +195,17 @@ func checkFilePathForInvalidChars(path string) error {
 	}
 	return nil
 }
+
+// parsePublicKeyBytes returns the DER bytes of the public key in 'publicKey', which can
+// be PEM encoded (ex. from create-key-pair) or a raw DER (PKIX) public key.
+func parsePublicKeyBytes(publicKey []byte) ([]byte, error) {
+	publicKeyBlock, _ := pem.Decode(publicKey)
+	if publicKeyBlock != nil {
+		return publicKeyBlock.Bytes, nil
+	}
+
+	if _, err := x509.ParsePKIXPublicKey(publicKey); err != nil {
+		return nil, errors.Wrap(err, "No PEM data or DER public key found in public key file")
+	}
+	return publicKey, nil
+}
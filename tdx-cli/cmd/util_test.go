@@ -8,7 +8,25 @@ package cmd
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/intel/trustauthority-client/go-connector"
+	"github.com/intel/trustauthority-client/tdx-cli/constants"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestString2Bytes(t *testing.T) {
@@ -37,3 +55,313 @@ func TestString2Bytes(t *testing.T) {
 		}
 	}
 }
+
+func TestNewTlsConfig(t *testing.T) {
+	defaultCipherSuites := []uint16{
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	}
+
+	tests := []struct {
+		name                 string
+		tlsCfg               *TlsConfig
+		minVersionOption     string
+		expectedMinVersion   uint16
+		expectedCipherSuites []uint16
+		expectError          bool
+	}{
+		{
+			name:                 "Default policy",
+			tlsCfg:               nil,
+			expectedMinVersion:   tls.VersionTLS12,
+			expectedCipherSuites: defaultCipherSuites,
+		},
+		{
+			name: "Configured policy",
+			tlsCfg: &TlsConfig{
+				MinVersion:   "1.3",
+				CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"},
+			},
+			expectedMinVersion:   tls.VersionTLS13,
+			expectedCipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256},
+		},
+		{
+			name:                 "Min version option overrides config",
+			tlsCfg:               &TlsConfig{MinVersion: "1.2"},
+			minVersionOption:     "1.3",
+			expectedMinVersion:   tls.VersionTLS13,
+			expectedCipherSuites: defaultCipherSuites,
+		},
+		{
+			name:             "Invalid min version option",
+			minVersionOption: "1.1",
+			expectError:      true,
+		},
+		{
+			name:        "Invalid config min version",
+			tlsCfg:      &TlsConfig{MinVersion: "tls13"},
+			expectError: true,
+		},
+		{
+			name:        "Unknown cipher suite",
+			tlsCfg:      &TlsConfig{CipherSuites: []string{"TLS_UNKNOWN"}},
+			expectError: true,
+		},
+		{
+			name:        "Insecure cipher suite",
+			tlsCfg:      &TlsConfig{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+			expectError: true,
+		},
+		{
+			name:        "TLS 1.3 cipher suite",
+			tlsCfg:      &TlsConfig{CipherSuites: []string{"TLS_AES_256_GCM_SHA384"}},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tlsMinVersion = tc.minVersionOption
+			defer func() { tlsMinVersion = "" }()
+
+			tlsConfig, err := newTlsConfig(tc.tlsCfg)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedMinVersion, tlsConfig.MinVersion)
+			assert.Equal(t, tc.expectedCipherSuites, tlsConfig.CipherSuites)
+			assert.False(t, tlsConfig.InsecureSkipVerify)
+		})
+	}
+}
+
+func TestNewTlsConfigCrl(t *testing.T) {
+	crl := newTestSigningChain(t).crl(t, time.Now().Add(time.Hour))
+
+	// the CRL distribution point only supports TLS 1.2 with AES-GCM cipher suites
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(crl)
+	}))
+	server.TLS = &tls.Config{
+		MaxVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+
+	tests := []struct {
+		name        string
+		tlsCfg      *TlsConfig
+		expectError bool
+	}{
+		{
+			name:   "Default policy",
+			tlsCfg: nil,
+		},
+		{
+			name:        "Min version 1.3",
+			tlsCfg:      &TlsConfig{MinVersion: "1.3"},
+			expectError: true,
+		},
+		{
+			name: "Unsupported cipher suites",
+			tlsCfg: &TlsConfig{CipherSuites: []string{
+				"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+				"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+			}},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tlsConfig, err := newTlsConfig(tc.tlsCfg)
+			assert.NoError(t, err)
+			tlsConfig.RootCAs = rootCAs
+
+			ctr, err := connector.New(&connector.Config{
+				ApiUrl: server.URL,
+				ApiKey: testApiKey,
+				TlsCfg: tlsConfig,
+			})
+			assert.NoError(t, err)
+
+			_, err = ctr.GetCRL(server.URL + "/ats.crl")
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTlsMinVersionOption(t *testing.T) {
+	defer func() { tlsMinVersion = "" }()
+
+	var resolved *tls.Config
+	root := &cobra.Command{Use: constants.RootCmd}
+	initRootCommand(root)
+	root.AddCommand(&cobra.Command{
+		Use: "test",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			resolved, err = newTlsConfig(nil)
+			return err
+		},
+	})
+	root.SetArgs([]string{"test", "--" + constants.TlsMinVersionOptions.Name, "1.3"})
+
+	err := root.Execute()
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), resolved.MinVersion)
+}
+
+func TestVerboseOption(t *testing.T) {
+	defer func(previous logrus.Level) {
+		verbose = false
+		logrus.SetLevel(previous)
+	}(logrus.GetLevel())
+	logrus.SetLevel(logrus.InfoLevel)
+
+	var level logrus.Level
+	root := &cobra.Command{Use: constants.RootCmd}
+	initRootCommand(root)
+	root.AddCommand(&cobra.Command{
+		Use: "test",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			level = logrus.GetLevel()
+			return nil
+		},
+	})
+
+	root.SetArgs([]string{"test"})
+	assert.NoError(t, root.Execute())
+	assert.Equal(t, logrus.InfoLevel, level)
+
+	root.SetArgs([]string{"test", "--" + constants.VerboseOptions.Name})
+	assert.NoError(t, root.Execute())
+	assert.Equal(t, logrus.DebugLevel, level)
+}
+
+func TestLogLevelOption(t *testing.T) {
+	defer func(previous logrus.Level) {
+		logLevel = ""
+		logrus.SetLevel(previous)
+	}(logrus.GetLevel())
+	logrus.SetLevel(logrus.InfoLevel)
+
+	var level logrus.Level
+	root := &cobra.Command{Use: constants.RootCmd}
+	initRootCommand(root)
+	root.AddCommand(&cobra.Command{
+		Use: "test",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			level = logrus.GetLevel()
+			return nil
+		},
+	})
+
+	root.SetArgs([]string{"test", "--" + constants.LogLevelOptions.Name, "debug"})
+	assert.NoError(t, root.Execute())
+	assert.Equal(t, logrus.DebugLevel, level)
+
+	root.SetArgs([]string{"test", "--" + constants.LogLevelOptions.Name, "warn"})
+	assert.NoError(t, root.Execute())
+	assert.Equal(t, logrus.WarnLevel, level)
+
+	root.SetArgs([]string{"test", "--" + constants.LogLevelOptions.Name, "loud"})
+	assert.Error(t, root.Execute())
+}
+
+func TestLogLevelOptionSubcommandHook(t *testing.T) {
+	defer func(previous logrus.Level) {
+		logLevel = ""
+		logrus.SetLevel(previous)
+	}(logrus.GetLevel())
+	logrus.SetLevel(logrus.InfoLevel)
+
+	var level logrus.Level
+	var subcommandHook bool
+	root := &cobra.Command{Use: constants.RootCmd}
+	initRootCommand(root)
+	root.AddCommand(&cobra.Command{
+		Use: "test",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			subcommandHook = true
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			level = logrus.GetLevel()
+			return nil
+		},
+	})
+
+	// the root's hook applies --log-level even though the subcommand has its own hook
+	assert.NoError(t, executeCommand(root, []string{"test", "--" + constants.LogLevelOptions.Name, "debug"}, io.Discard))
+	assert.Equal(t, logrus.DebugLevel, level)
+	assert.True(t, subcommandHook)
+}
+
+func TestParsePublicKeyBytes(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	fromPem, err := parsePublicKeyBytes(pemBytes)
+	assert.NoError(t, err)
+
+	fromDer, err := parsePublicKeyBytes(der)
+	assert.NoError(t, err)
+
+	assert.Equal(t, der, fromPem)
+	assert.Equal(t, fromPem, fromDer)
+
+	_, err = parsePublicKeyBytes([]byte("not a public key"))
+	assert.Error(t, err)
+}
+
+func TestParseClaimsRequest(t *testing.T) {
+	claims, err := parseClaimsRequest([]string{"environment=prod", "selector=app=web", "empty="})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"environment": "prod", "selector": "app=web", "empty": ""}
+	if !reflect.DeepEqual(claims, expected) {
+		t.Errorf("Expected %v, got %v", expected, claims)
+	}
+
+	claims, err = parseClaimsRequest(nil)
+	if err != nil || claims != nil {
+		t.Errorf("Expected no claims, got %v, %v", claims, err)
+	}
+
+	invalid := [][]string{
+		{"environment"},
+		{"environment=prod", "environment=dev"},
+		{"tenant id=acme"},
+		{strings.Repeat("k", connector.MaxClaimsRequestKeyLength+1) + "=value"},
+	}
+
+	for _, claims := range invalid {
+		if _, err := parseClaimsRequest(claims); err == nil {
+			t.Errorf("Expected an error for claims %v", claims)
+		}
+	}
+}
@@ -7,10 +7,12 @@
 package cmd
 
 import (
-	"crypto/tls"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 	"github.com/intel/trustauthority-client/go-connector"
 	"github.com/intel/trustauthority-client/tdx-cli/constants"
 	"github.com/pkg/errors"
@@ -25,7 +27,7 @@ func newVerifyCommand(cfgFactory ConfigFactory, ctrFactory connector.ConnectorFa
 		RunE: func(cmd *cobra.Command, args []string) error {
 			err := verifyToken(cmd, cfgFactory, ctrFactory)
 			if err != nil {
-				fmt.Fprintln(os.Stderr, err.Error())
+				printError(os.Stderr, err)
 				return err
 			}
 
@@ -34,35 +36,103 @@ func newVerifyCommand(cfgFactory ConfigFactory, ctrFactory connector.ConnectorFa
 	}
 	verifyCmd.Flags().StringP(constants.ConfigOptions.Name, constants.ConfigOptions.ShortHand, "", constants.ConfigOptions.Description)
 	verifyCmd.Flags().StringP(constants.TokenOption, "t", "", "Token in JWT format")
+	verifyCmd.Flags().StringArray(constants.RequirePolicyOptions.Name, nil, constants.RequirePolicyOptions.Description)
+	verifyCmd.Flags().String(constants.MinTcbStatusOptions.Name, "", constants.MinTcbStatusOptions.Description)
+	verifyCmd.Flags().String(constants.BundleOptions.Name, "", constants.BundleOptions.Description)
 	verifyCmd.MarkFlagRequired(constants.TokenOption)
-	verifyCmd.MarkFlagRequired(constants.ConfigOptions.Name)
 
 	return verifyCmd
 }
 
 func verifyToken(cmd *cobra.Command, cfgFactory ConfigFactory, ctrFactory connector.ConnectorFactory) error {
 
-	configFile, err := cmd.Flags().GetString(constants.ConfigOptions.Name)
+	token, err := cmd.Flags().GetString(constants.TokenOption)
+	if err != nil {
+		return err
+	}
+
+	requiredPolicies, err := cmd.Flags().GetStringArray(constants.RequirePolicyOptions.Name)
+	if err != nil {
+		return err
+	}
+
+	requiredPolicyIds, err := parsePolicyIds(strings.Join(requiredPolicies, ","))
+	if err != nil {
+		return err
+	}
+
+	minTcbStatusName, err := cmd.Flags().GetString(constants.MinTcbStatusOptions.Name)
 	if err != nil {
 		return err
 	}
 
+	var minTcbStatus connector.TcbStatus
+	if minTcbStatusName != "" {
+		minTcbStatus, err = connector.ParseTcbStatus(minTcbStatusName)
+		if err != nil {
+			return err
+		}
+	}
+
+	bundleDir, err := cmd.Flags().GetString(constants.BundleOptions.Name)
+	if err != nil {
+		return err
+	}
+
+	var parsedToken *jwt.Token
+	if bundleDir != "" {
+		// the bundle replaces the Trust Authority, the config is not needed
+		bundle, err := loadVerificationBundle(bundleDir)
+		if err != nil {
+			return err
+		}
+
+		parsedToken, err = connector.VerifyTokenOffline(token, bundle)
+		if err != nil {
+			return errors.Wrap(err, "Could not verify the token")
+		}
+	} else {
+		parsedToken, err = verifyTokenOnline(cmd, cfgFactory, ctrFactory, token)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = checkRequiredPolicies(parsedToken, requiredPolicyIds); err != nil {
+		return err
+	}
+
+	if minTcbStatus != "" {
+		if err = connector.CheckMinTcbStatus(parsedToken, minTcbStatus); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(os.Stdout, parsedToken.Claims)
+	return nil
+
+}
+
+// verifyTokenOnline verifies 'token' using the token signing certificates of the Trust
+// Authority in config.
+func verifyTokenOnline(cmd *cobra.Command, cfgFactory ConfigFactory, ctrFactory connector.ConnectorFactory, token string) (*jwt.Token, error) {
+	configFile, err := cmd.Flags().GetString(constants.ConfigOptions.Name)
+	if err != nil {
+		return nil, err
+	}
+
 	config, err := cfgFactory.LoadConfig(configFile)
 	if err != nil {
-		return errors.Wrapf(err, "Could not read config file %q", configFile)
+		return nil, withErrorCode(errors.Wrapf(err, "Could not read config file %q", configFile), ErrorCodeConfig, "")
 	}
 
 	if config.TrustAuthorityUrl == "" {
-		return errors.New("Trust Authority URL is missing in config")
+		return nil, withErrorCode(errors.New("Trust Authority URL is missing in config"), ErrorCodeConfig, "")
 	}
 
-	tlsConfig := &tls.Config{
-		CipherSuites: []uint16{
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-		},
-		InsecureSkipVerify: false,
-		MinVersion:         tls.VersionTLS12,
+	tlsConfig, err := newTlsConfig(config.Tls)
+	if err != nil {
+		return nil, withErrorCode(err, ErrorCodeConfig, "")
 	}
 
 	cfg := connector.Config{
@@ -72,20 +142,47 @@ func verifyToken(cmd *cobra.Command, cfgFactory ConfigFactory, ctrFactory connec
 
 	trustAuthorityConnector, err := ctrFactory.NewConnector(&cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	token, err := cmd.Flags().GetString(constants.TokenOption)
+	parsedToken, err := trustAuthorityConnector.VerifyToken(token)
 	if err != nil {
-		return err
+		return nil, errors.Wrap(err, "Could not verify the token")
 	}
 
-	parsedToken, err := trustAuthorityConnector.VerifyToken(string(token))
+	return parsedToken, nil
+}
+
+// checkRequiredPolicies returns an error unless all of the 'required' policies are in the
+// token's matched policies (see --require-policy).
+func checkRequiredPolicies(token *jwt.Token, required []uuid.UUID) error {
+	if len(required) == 0 {
+		return nil
+	}
+
+	policyClaims, err := connector.GetPolicyClaims(token)
 	if err != nil {
-		return errors.Wrap(err, "Could not verify the token")
+		return errors.Wrap(err, "Failed to read the token's policy claims")
 	}
 
-	fmt.Fprintln(os.Stdout, parsedToken.Claims)
-	return nil
+	var missing []string
+	for _, id := range required {
+		matched := false
+		for _, policy := range policyClaims.Matched {
+			if policy.Id == id {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			missing = append(missing, id.String())
+		}
+	}
 
+	if len(missing) > 0 {
+		return errors.Errorf("The token did not match the required policies: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
 }
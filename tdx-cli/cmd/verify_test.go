@@ -8,11 +8,18 @@ package cmd
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 	"github.com/intel/trustauthority-client/go-connector"
 	"github.com/intel/trustauthority-client/tdx-cli/constants"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -50,9 +57,12 @@ func TestVerifyCmd(t *testing.T) {
 				token,
 			},
 			wantErr:     true,
-			description: "Test without config file",
+			description: "Test without config file in the search path",
 			dependencyMocks: func() (ConfigFactory, connector.ConnectorFactory) {
-				return mockConfigFactory(nil), happyMockConnectorFactory()
+				angryConfigFactory := MockConfigFactory{}
+				angryConfigFactory.On("LoadConfig", "").Return(&Config{}, ErrConfigNotFound)
+
+				return &angryConfigFactory, happyMockConnectorFactory()
 			},
 		},
 		{
@@ -128,3 +138,225 @@ func TestVerifyCmd_MalformedTrustAuthorityUrl(t *testing.T) {
 	_, err := execute(t, rootCmd, constants.VerifyCmd, "--"+constants.ConfigOptions.Name, confFilePath, "--"+constants.TokenOption, token)
 	assert.Error(t, err)
 }
+
+func TestVerifyCmd_RequirePolicy(t *testing.T) {
+	matchedPolicy := uuid.New()
+	otherPolicy := uuid.New()
+
+	verifiedToken := &jwt.Token{
+		Claims: jwt.MapClaims{
+			"policy_ids_matched":   []interface{}{map[string]interface{}{"id": matchedPolicy.String(), "version": "v1"}},
+			"policy_ids_unmatched": []interface{}{map[string]interface{}{"id": otherPolicy.String(), "version": "v1"}},
+		},
+	}
+
+	tt := []struct {
+		description      string
+		requiredPolicies []string
+		wantErr          bool
+	}{
+		{
+			description:      "Token matched the required policy",
+			requiredPolicies: []string{matchedPolicy.String()},
+			wantErr:          false,
+		},
+		{
+			description:      "Token did not match a required policy",
+			requiredPolicies: []string{matchedPolicy.String(), otherPolicy.String()},
+			wantErr:          true,
+		},
+		{
+			description:      "Invalid required policy id",
+			requiredPolicies: []string{"not-a-uuid"},
+			wantErr:          true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			mockConnector := MockConnector{}
+			mockConnector.On("VerifyToken", mock.Anything).Return(verifiedToken, nil)
+
+			mockConnectorFactory := MockConnectorFactory{}
+			mockConnectorFactory.On("NewConnector", mock.Anything).Return(&mockConnector, nil)
+
+			args := []string{
+				constants.VerifyCmd,
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+				"--" + constants.TokenOption,
+				token,
+			}
+			for _, policy := range tc.requiredPolicies {
+				args = append(args, "--"+constants.RequirePolicyOptions.Name, policy)
+			}
+
+			cmd := newVerifyCommand(mockConfigFactory(nil), &mockConnectorFactory)
+			cmd.SetArgs(args[1:])
+
+			err := cmd.Execute()
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyCmd_MinTcbStatus(t *testing.T) {
+	tt := []struct {
+		description  string
+		tcbStatus    string
+		minTcbStatus string
+		wantErr      bool
+	}{
+		{
+			description:  "UpToDate token",
+			tcbStatus:    "UpToDate",
+			minTcbStatus: "UpToDate",
+			wantErr:      false,
+		},
+		{
+			description:  "SWHardeningNeeded token above the minimum",
+			tcbStatus:    "SWHardeningNeeded",
+			minTcbStatus: "OutOfDate",
+			wantErr:      false,
+		},
+		{
+			description:  "OutOfDate token below the minimum",
+			tcbStatus:    "OutOfDate",
+			minTcbStatus: "UpToDate",
+			wantErr:      true,
+		},
+		{
+			description:  "Revoked token below the minimum",
+			tcbStatus:    "Revoked",
+			minTcbStatus: "OUT_OF_DATE",
+			wantErr:      true,
+		},
+		{
+			description:  "Invalid minimum TCB status",
+			tcbStatus:    "UpToDate",
+			minTcbStatus: "Latest",
+			wantErr:      true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			verifiedToken := &jwt.Token{
+				Claims: jwt.MapClaims{"attester_tcb_status": tc.tcbStatus},
+			}
+
+			mockConnector := MockConnector{}
+			mockConnector.On("VerifyToken", mock.Anything).Return(verifiedToken, nil)
+
+			mockConnectorFactory := MockConnectorFactory{}
+			mockConnectorFactory.On("NewConnector", mock.Anything).Return(&mockConnector, nil)
+
+			cmd := newVerifyCommand(mockConfigFactory(nil), &mockConnectorFactory)
+			cmd.SetArgs([]string{
+				"--" + constants.ConfigOptions.Name,
+				confFilePath,
+				"--" + constants.TokenOption,
+				token,
+				"--" + constants.MinTcbStatusOptions.Name,
+				tc.minTcbStatus,
+			})
+
+			err := cmd.Execute()
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyCmd_Bundle(t *testing.T) {
+	chain := newTestSigningChain(t)
+
+	tt := []struct {
+		description   string
+		expiredCrl    bool
+		removeFile    string
+		wantErr       bool
+		expectWarning bool
+	}{
+		{
+			description: "valid bundle",
+		},
+		{
+			description:   "expired crl",
+			expiredCrl:    true,
+			expectWarning: true,
+		},
+		{
+			description: "missing crl",
+			removeFile:  "ats-ca-crl.der",
+			wantErr:     true,
+		},
+		{
+			description: "missing jwks",
+			removeFile:  bundleJwksFile,
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			bundleDir := exportTestBundle(t, chain)
+
+			// both CRLs are signed by the root CA, remove one so that only the
+			// modified CRL is used
+			if err := os.Remove(filepath.Join(bundleDir, "root-ca-crl.der")); err != nil {
+				t.Fatal(err)
+			}
+			if tc.expiredCrl {
+				err := os.WriteFile(filepath.Join(bundleDir, "ats-ca-crl.der"), chain.crl(t, time.Now().Add(-time.Hour)), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.removeFile != "" {
+				if err := os.Remove(filepath.Join(bundleDir, tc.removeFile)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			hook := logrustest.NewGlobal()
+			defer hook.Reset()
+
+			// the bundle is used instead of the config and Trust Authority
+			angryConfigFactory := MockConfigFactory{}
+			angryConfigFactory.On("LoadConfig", mock.Anything).Return(&Config{}, errors.New("Unit test failure"))
+			angryConnectorFactory := MockConnectorFactory{}
+			angryConnectorFactory.On("NewConnector", mock.Anything).Return(nil, errors.New("Unit test failure"))
+
+			cmd := newVerifyCommand(&angryConfigFactory, &angryConnectorFactory)
+			cmd.SetArgs([]string{
+				"--" + constants.TokenOption,
+				chain.token(t),
+				"--" + constants.BundleOptions.Name,
+				bundleDir,
+			})
+
+			err := cmd.Execute()
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			warned := false
+			for _, entry := range hook.AllEntries() {
+				if entry.Level == logrus.WarnLevel && strings.Contains(entry.Message, "stale") {
+					warned = true
+				}
+			}
+			assert.Equal(t, tc.expectWarning, warned)
+		})
+	}
+}
@@ -26,7 +26,7 @@ var versionCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		err := getVersion()
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
+			printError(os.Stderr, err)
 			return err
 		}
 
@@ -23,6 +23,14 @@ const (
 	VerifyCmd        = "verify"
 	EvidenceCmd      = "evidence"
 	ProvisionAkCmd   = "provision-ak"
+	RotateAkCmd      = "rotate-ak"
+	HealthcheckCmd   = "healthcheck"
+	CapabilitiesCmd  = "capabilities"
+	PcrReadCmd       = "pcr-read"
+	EkCertCmd        = "ek-cert"
+	RtmrCheckCmd     = "rtmr-check"
+
+	ExportVerificationBundleCmd = "export-verification-bundle"
 )
 
 // Options Names
@@ -43,16 +51,38 @@ type CommandOptions struct {
 }
 
 var (
-	ConfigOptions          = CommandOptions{"config", "c", "Trust Authority config in JSON format"}
+	ConfigOptions          = CommandOptions{"config", "c", "Trust Authority config in JSON format (defaults to $TRUSTAUTHORITY_CONFIG, ./config.json or ~/.tdx-cli.json)"}
 	WithTpmOptions         = CommandOptions{"tpm", "", "Include TPM evidence in evidence output"}
 	WithTdxOptions         = CommandOptions{"tdx", "", "Include TDX evidence in evidence output"}
+	AutoOptions            = CommandOptions{"auto", "", "Include the evidence types (TDX and/or TPM) detected on the host, cannot be combined with --tdx or --tpm"}
 	NoVerifierNonceOptions = CommandOptions{"no-verifier-nonce", "", "Do not include an ITA verifier-nonce in evidence"}
 	UserDataOptions        = CommandOptions{"user-data", "u", "User data in hex or base64 encoded format"}
 	PolicyIdsOptions       = CommandOptions{"policy-ids", "p", "Trust Authority Policy Ids, comma separated"}
-	TokenAlgOptions        = CommandOptions{"token-signing-alg", "a", "Token signing algorithm to be used, support PS384 and RS256"}
+	TokenAlgOptions        = CommandOptions{"token-signing-alg", "a", "Token signing algorithm to be used, support PS256, PS384, RS256 and RS384"}
 	PolicyMustMatchOptions = CommandOptions{"policy-must-match", "", "When true, all policies must match for a token to be created"}
+	TokenTypeOptions       = CommandOptions{"token-type", "", "Profile of the token to be requested, supports legacy (default) and ear (Entity Attestation Result)"}
+	ClaimOptions           = CommandOptions{"claim", "", "Metadata (key=value) included in the request for policies to key off (ex. environment=prod), can be repeated"}
+	ContextOptions         = CommandOptions{"context", "", "Free-form label (ex. workload name or namespace) included in the request that Trust Authority echoes back for correlation"}
+	AudienceOptions        = CommandOptions{"audience", "", "Audience ('aud' claim) the token is requested for, at most 256 printable characters without whitespace"}
 	WithImaLogsOptions     = CommandOptions{"ima", "", "When set, TPM evidence will include IMA runtime measurements"}
 	WithEventLogsOptions   = CommandOptions{"evl", "", "When set, TPM evidence will include UEFI event logs"}
-	WithCcelOptions        = CommandOptions{"ccel", "", "When set, TDX evidence will include Confidential Computing Event Logs"}
+	WithCcelOptions        = CommandOptions{"ccel", "", "When set, TDX evidence will include Confidential Computing Event Logs (a warning is displayed if they are not available)"}
+	RequireCcelOptions     = CommandOptions{"require-ccel", "", "When set, TDX evidence must include Confidential Computing Event Logs (fails if they are not available)"}
 	RequestIdOptions       = CommandOptions{"request-id", "r", "Request ID for the token"}
+	JsonErrorsOptions      = CommandOptions{"json-errors", "", "When set, failures are written to stderr as json objects with 'error', 'code' and 'trace_id' fields"}
+	VerifyTokenOptions     = CommandOptions{"verify", "", "When set, the token is verified (requires 'trustauthority_url' in config) before it is printed"}
+	RequirePolicyOptions   = CommandOptions{"require-policy", "", "Policy Id (UUID) that the token must have matched ('policy_ids_matched' claim), can be repeated"}
+	MinTcbStatusOptions    = CommandOptions{"min-tcb-status", "", "Minimum TCB status of the token ('attester_tcb_status' claim), one of UpToDate, SWHardeningNeeded, ConfigurationNeeded, ConfigurationAndSWHardeningNeeded, OutOfDate, OutOfDateConfigurationNeeded or Revoked"}
+	NvIndexOptions         = CommandOptions{"nv-index", "", "NV index (in hex) of the EK certificate, defaults to 0x01c00002"}
+	PcrSelectionOptions    = CommandOptions{"pcr-selection", "", "tpm2-tools style PCR selection (ex. 'sha256:0-7+sha1:all'), defaults to all sha256 PCRs"}
+	ApiKeyFileOptions      = CommandOptions{"api-key-file", "", "File containing the Trust Authority API key (ex. a mounted secret), overrides 'trustauthority_api_key' in config"}
+	TlsMinVersionOptions   = CommandOptions{"tls-min-version", "", "Minimum TLS version (1.2 or 1.3) used to connect to Trust Authority, overrides 'tls.min_version' in config"}
+	PrintRequestIdOptions  = CommandOptions{"print-request-id", "", "When set, the request ID (provided or generated) is printed to stdout in json format before the token"}
+	NewAkHandleOptions     = CommandOptions{"new-ak-handle", "", "Persistent handle (in hex) of the new AK, defaults to the configured AK handle + 1"}
+	VerboseOptions         = CommandOptions{"verbose", "v", "When set, progress of each stage (ex. collecting the TPM quote, reading event logs) is written to stderr"}
+	LogLevelOptions        = CommandOptions{"log-level", "", "Level of the messages written to stderr (ex. 'info', 'debug'), 'debug' includes the progress of each stage and the retries of requests to Trust Authority"}
+	OutDirOptions          = CommandOptions{"out-dir", "o", "Directory the verification bundle is written to (created if it does not exist)"}
+	BundleOptions          = CommandOptions{"bundle", "", "Directory of a verification bundle (see export-verification-bundle) used to verify the token without network access"}
+	ExpectedRtmrsOptions   = CommandOptions{"expected", "e", "Path of a json file with the expected (hex encoded) RTMR values, ex. {\"rtmr1\": \"<sha384>\", \"rtmr2\": \"<sha384>\"}"}
+	QuoteFileOptions       = CommandOptions{"quote-file", "", "Path to a previously captured TD quote that is used as TDX evidence (instead of collecting a quote from the host), or \"-\" to read it from stdin"}
 )